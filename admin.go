@@ -0,0 +1,90 @@
+package viper
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// AdminHandler returns the HTTP handler of the config debug endpoint of
+// p, meant to be mounted on the admin listener of a service with
+// http.StripPrefix:
+//
+//	GET  /config   the effective config as JSON, the values of sensitive and
+//	               referenced keys redacted
+//	GET  /origins  the origin of every key, as reported by Origin
+//	GET  /history  the load history, oldest first
+//	POST /reload   reloads the files and sources of the last load the way a
+//	               watched change does, answering with the keys it changed
+//
+// Requests go through auth: the GET routes require AdminView and the reload
+// AdminReload. A nil auth rejects every request, so the endpoint is never
+// open by accident. Reloads are recorded in the history as coming from
+// "<admin NAME>", NAME being the principal requesting them.
+func (p *Parser) AdminHandler(auth *AdminAuth) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /config", auth.Wrap(AdminView, http.HandlerFunc(p.serveConfig)))
+	mux.Handle("GET /origins", auth.Wrap(AdminView, http.HandlerFunc(p.serveOrigins)))
+	mux.Handle("GET /history", auth.Wrap(AdminView, http.HandlerFunc(p.serveHistory)))
+	mux.Handle("POST /reload", auth.Wrap(AdminReload, http.HandlerFunc(p.serveReload)))
+	return mux
+}
+
+func (p *Parser) serveConfig(w http.ResponseWriter, r *http.Request) {
+	var settings interface{}
+	p.reading(func() { settings = p.redact(p.effective(), "") })
+	writeJSON(w, http.StatusOK, normalizeJSON(settings))
+}
+
+func (p *Parser) serveOrigins(w http.ResponseWriter, r *http.Request) {
+	origins := make(map[string]string)
+	p.reading(func() {
+		for k := range flatten(p.effective()) {
+			origins[k] = p.origin(k)
+		}
+	})
+	writeJSON(w, http.StatusOK, origins)
+}
+
+func (p *Parser) serveHistory(w http.ResponseWriter, r *http.Request) {
+	p.mu.RLock()
+	history := append([]ReloadRecord{}, p.history...)
+	p.mu.RUnlock()
+	writeJSON(w, http.StatusOK, history)
+}
+
+// reloadResult is the answer of a reload requested through the admin
+// endpoint
+type reloadResult struct {
+	Changed []string `json:"changed"`
+	Error   string   `json:"error,omitempty"`
+}
+
+func (p *Parser) serveReload(w http.ResponseWriter, r *http.Request) {
+	if err := p.openForWatch(); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, reloadResult{Error: err.Error()})
+		return
+	}
+	source := "<admin>"
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		source = "<admin " + principal.Name + ">"
+	}
+
+	var changes ChangeSet
+	var err error
+	files := func() []string { return append([]string(nil), p.files...) }
+	p.reloader(source, files, func(c ChangeSet, rerr error) { changes, err = c, rerr })()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, reloadResult{Changed: []string{}, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, reloadResult{Changed: changes.Keys()})
+}
+
+// writeJSON answers with v encoded as JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
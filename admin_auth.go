@@ -0,0 +1,198 @@
+package viper
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// AdminAction identifies an operation exposed through the admin surface
+type AdminAction string
+
+const (
+	// AdminView covers read-only inspection of the effective config
+	AdminView AdminAction = "view"
+	// AdminReload covers forcing a reload from the configured sources
+	AdminReload AdminAction = "reload"
+	// AdminOverride covers mutating live values
+	AdminOverride AdminAction = "override"
+)
+
+var (
+	// ErrUnauthenticated is returned when the caller could not be identified
+	ErrUnauthenticated = errors.New("admin: unauthenticated")
+	// ErrForbidden is returned when the caller may not perform the action
+	ErrForbidden = errors.New("admin: forbidden")
+)
+
+// Principal describes an authenticated caller of the admin surface
+type Principal struct {
+	// Name identifies the caller (token owner, certificate subject...)
+	Name string
+	// Method records how the caller was authenticated
+	Method string
+}
+
+// Authenticator identifies the caller of an admin request
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+// Authenticate calls f(r)
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// Authorizer decides whether a principal may perform an admin action
+type Authorizer interface {
+	Authorize(p *Principal, action AdminAction) error
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface
+type AuthorizerFunc func(p *Principal, action AdminAction) error
+
+// Authorize calls f(p, action)
+func (f AuthorizerFunc) Authorize(p *Principal, action AdminAction) error {
+	return f(p, action)
+}
+
+// BearerTokenAuthenticator authenticates requests carrying one of the given
+// tokens in the Authorization header. The map goes from token to principal name.
+func BearerTokenAuthenticator(tokens map[string]string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		header := r.Header.Get("Authorization")
+		if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+			return nil, ErrUnauthenticated
+		}
+		given := []byte(strings.TrimSpace(header[7:]))
+
+		var name string
+		found := false
+		for token, owner := range tokens {
+			// keep comparing after a match so timing does not leak the position
+			if subtle.ConstantTimeCompare(given, []byte(token)) == 1 {
+				name = owner
+				found = true
+			}
+		}
+		if !found {
+			return nil, ErrUnauthenticated
+		}
+		return &Principal{Name: name, Method: "bearer"}, nil
+	})
+}
+
+// MTLSAuthenticator authenticates requests presenting a verified client
+// certificate whose common name or DNS SAN is in the allowed list. An empty
+// list accepts any verified certificate.
+func MTLSAuthenticator(allowed ...string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return nil, ErrUnauthenticated
+		}
+		leaf := r.TLS.VerifiedChains[0][0]
+		names := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+		if len(allowed) == 0 {
+			return &Principal{Name: names[0], Method: "mtls"}, nil
+		}
+		for _, name := range names {
+			for _, a := range allowed {
+				if name == a {
+					return &Principal{Name: name, Method: "mtls"}, nil
+				}
+			}
+		}
+		return nil, ErrUnauthenticated
+	})
+}
+
+// AnyAuthenticator tries each authenticator in order and returns the first
+// principal found
+func AnyAuthenticator(authns ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		for _, a := range authns {
+			if p, err := a.Authenticate(r); err == nil && p != nil {
+				return p, nil
+			}
+		}
+		return nil, ErrUnauthenticated
+	})
+}
+
+// PolicyAuthorizer grants actions per principal name. The "*" entry applies
+// to every authenticated principal.
+func PolicyAuthorizer(grants map[string][]AdminAction) Authorizer {
+	return AuthorizerFunc(func(p *Principal, action AdminAction) error {
+		if p == nil {
+			return ErrUnauthenticated
+		}
+		for _, name := range []string{p.Name, "*"} {
+			for _, a := range grants[name] {
+				if a == action {
+					return nil
+				}
+			}
+		}
+		return ErrForbidden
+	})
+}
+
+// AdminAuth guards admin handlers with an authentication and an
+// authorization step. A nil Authorizer lets every authenticated principal
+// view the config but denies the actions changing it, AdminReload and
+// AdminOverride, which need an Authorizer granting them.
+type AdminAuth struct {
+	Authenticator Authenticator
+	Authorizer    Authorizer
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal stored by AdminAuth.Wrap, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// Wrap returns a handler that only calls next when the caller is authenticated
+// and allowed to perform the given action. A nil AdminAuth or Authenticator
+// rejects every request, so the admin surface is never open by accident.
+func (a *AdminAuth) Wrap(action AdminAction, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a == nil || a.Authenticator == nil {
+			http.Error(w, ErrUnauthenticated.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		p, err := a.Authenticator.Authenticate(r)
+		if err != nil || p == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nexen-admin"`)
+			http.Error(w, ErrUnauthenticated.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if err := a.authorize(p, action); err != nil {
+			http.Error(w, ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// authorize asks the Authorizer whether p may perform action, only allowing
+// AdminView without one
+func (a *AdminAuth) authorize(p *Principal, action AdminAction) error {
+	if a.Authorizer == nil {
+		if action != AdminView {
+			return ErrForbidden
+		}
+		return nil
+	}
+	return a.Authorizer.Authorize(p, action)
+}
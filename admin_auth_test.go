@@ -0,0 +1,101 @@
+package viper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuth_Wrap(t *testing.T) {
+	auth := &AdminAuth{
+		Authenticator: AnyAuthenticator(
+			BearerTokenAuthenticator(map[string]string{"s3cr3t": "ops", "r3ad": "viewer"}),
+			MTLSAuthenticator("deployer"),
+		),
+		Authorizer: PolicyAuthorizer(map[string][]AdminAction{
+			"*":        {AdminView},
+			"ops":      {AdminReload, AdminOverride},
+			"deployer": {AdminReload},
+		}),
+	}
+
+	clientCert := func(cn string) *tls.ConnectionState {
+		return &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		action AdminAction
+		token  string
+		tls    *tls.ConnectionState
+		want   int
+	}{
+		{name: "no credentials", action: AdminView, want: http.StatusUnauthorized},
+		{name: "wrong token", action: AdminView, token: "nope", want: http.StatusUnauthorized},
+		{name: "viewer can view", action: AdminView, token: "r3ad", want: http.StatusOK},
+		{name: "viewer cannot reload", action: AdminReload, token: "r3ad", want: http.StatusForbidden},
+		{name: "ops can override", action: AdminOverride, token: "s3cr3t", want: http.StatusOK},
+		{name: "mtls deployer can reload", action: AdminReload, tls: clientCert("deployer"), want: http.StatusOK},
+		{name: "mtls deployer cannot override", action: AdminOverride, tls: clientCert("deployer"), want: http.StatusForbidden},
+		{name: "mtls unknown subject", action: AdminView, tls: clientCert("intruder"), want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen *Principal
+			h := auth.Wrap(tt.action, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen, _ = PrincipalFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			req.TLS = tt.tls
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("Wrap() status = %v, want %v", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && seen == nil {
+				t.Error("Wrap() did not store the principal in the request context")
+			}
+		})
+	}
+}
+
+func TestAdminAuth_NilRejects(t *testing.T) {
+	var auth *AdminAuth
+	h := auth.Wrap(AdminView, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler must not be reached without an authenticator")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Wrap() status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminAuth_NilAuthorizerDeniesChanges(t *testing.T) {
+	auth := &AdminAuth{Authenticator: BearerTokenAuthenticator(map[string]string{"s3cr3t": "ops"})}
+	for action, want := range map[AdminAction]int{
+		AdminView:     http.StatusOK,
+		AdminReload:   http.StatusForbidden,
+		AdminOverride: http.StatusForbidden,
+	} {
+		h := auth.Wrap(action, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer s3cr3t")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Wrap(%s) status = %v, want %v", action, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package viper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_AdminHandler(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  host: a\n  password: s3cret\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &AdminAuth{
+		Authenticator: BearerTokenAuthenticator(map[string]string{"op-token": "alice", "ro-token": "bob"}),
+		Authorizer: PolicyAuthorizer(map[string][]AdminAction{
+			"alice": {AdminView, AdminReload},
+			"bob":   {AdminView},
+		}),
+	}
+	srv := httptest.NewServer(http.StripPrefix("/debug/config", p.AdminHandler(auth)))
+	defer srv.Close()
+
+	do := func(method, path, token string, v interface{}) int {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+"/debug/config"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode < 300 {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	var config map[string]map[string]interface{}
+	if code := do("GET", "/config", "ro-token", &config); code != http.StatusOK {
+		t.Fatalf("GET /config = %d", code)
+	}
+	if got := config["db"]["password"]; got != redacted {
+		t.Errorf("db.password = %v, want it redacted", got)
+	}
+	if got := config["db"]["host"]; got != "a" {
+		t.Errorf("db.host = %v, want a", got)
+	}
+
+	var origins map[string]string
+	if code := do("GET", "/origins", "ro-token", &origins); code != http.StatusOK {
+		t.Fatalf("GET /origins = %d", code)
+	}
+	if got := origins["db.host"]; got != configFile {
+		t.Errorf("origin of db.host = %q, want %q", got, configFile)
+	}
+
+	if code := do("GET", "/config", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /config without a token = %d, want 401", code)
+	}
+	if code := do("POST", "/reload", "ro-token", nil); code != http.StatusForbidden {
+		t.Errorf("POST /reload by a viewer = %d, want 403", code)
+	}
+	if code := do("GET", "/reload", "op-token", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /reload = %d, want 405", code)
+	}
+
+	replaceFile(t, configFile, "db:\n  host: b\n  password: s3cret\n")
+	var result reloadResult
+	if code := do("POST", "/reload", "op-token", &result); code != http.StatusOK {
+		t.Fatalf("POST /reload = %d", code)
+	}
+	if len(result.Changed) != 1 || result.Changed[0] != "db.host" {
+		t.Errorf("changed = %v, want [db.host]", result.Changed)
+	}
+	if got := p.GetString("db.host"); got != "b" {
+		t.Errorf("db.host = %q after the reload, want b", got)
+	}
+
+	replaceFile(t, configFile, "db: [")
+	if code := do("POST", "/reload", "op-token", nil); code != http.StatusInternalServerError {
+		t.Errorf("POST /reload of an invalid file = %d, want 500", code)
+	}
+
+	var history []ReloadRecord
+	if code := do("GET", "/history", "ro-token", &history); code != http.StatusOK {
+		t.Fatalf("GET /history = %d", code)
+	}
+	if len(history) != 3 || history[1].File != "<admin alice>" || history[2].Error == "" {
+		t.Errorf("history = %+v, want the load, the reload by alice and the failed one", history)
+	}
+
+	rec := httptest.NewRecorder()
+	p.AdminHandler(nil).ServeHTTP(rec, httptest.NewRequest("GET", "/config", nil))
+	if rec.Code != http.StatusUnauthorized || strings.Contains(rec.Body.String(), "s3cret") {
+		t.Errorf("GET /config with a nil auth = %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,97 @@
+package viper
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// keyAliases maps deprecated keys to the keys replacing them
+type keyAliases struct {
+	targets map[string]string
+	// warned holds the aliases whose read was already logged
+	warned sync.Map
+}
+
+// RegisterAlias declares old as the former name of key new, so renamed keys
+// keep working while operators migrate: a config file setting old sets new
+// instead, unless it also sets new, and reading old reads new. Aliasing a
+// section aliases the keys it contains. Each load logs the deprecated keys
+// found in the files and the first read of each alias is logged, so the
+// remaining users of the old names can be found. The files are renamed
+// from the next load on.
+func (p *Parser) RegisterAlias(old, new string) {
+	old = strings.ToLower(p.normalizePath(old))
+	new = strings.ToLower(p.normalizePath(new))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.aliases.targets == nil {
+		p.aliases.targets = make(map[string]string)
+	}
+	p.aliases.targets[old] = new
+	p.version++
+}
+
+// aliased returns the key path reads, for the normalized path. Callers hold
+// the read lock.
+func (p *Parser) aliased(path string) string {
+	if len(p.aliases.targets) == 0 {
+		return path
+	}
+	key := strings.ToLower(path)
+	for old, new := range p.aliases.targets {
+		if rest, ok := strings.CutPrefix(key, old); ok && (rest == "" || rest[0] == '.') {
+			if _, warned := p.aliases.warned.LoadOrStore(old, true); !warned {
+				p.logger.Warn("deprecated config key read", "key", old, "use", new)
+			}
+			return new + rest
+		}
+	}
+	return path
+}
+
+// applyAliases moves the values the settings hold at deprecated keys to
+// the keys replacing them
+func (p *Parser) applyAliases(settings map[string]interface{}) map[string]interface{} {
+	if len(p.aliases.targets) == 0 {
+		return settings
+	}
+	olds := make([]string, 0, len(p.aliases.targets))
+	for old := range p.aliases.targets {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+
+	settings = lowerKeys(settings)
+	for _, old := range olds {
+		v, ok := lookupPath(settings, old)
+		if !ok {
+			continue
+		}
+		new := p.aliases.targets[old]
+		source := p.pending.sourcesOf(old)
+		deletePath(settings, old)
+		if _, set := lookupPath(settings, new); set {
+			p.logger.Warn("deprecated config key ignored, its replacement is set", "key", old, "use", new, "source", source)
+			continue
+		}
+		p.logger.Warn("deprecated config key", "key", old, "use", new, "source", source)
+		setPath(settings, new, v)
+		p.pending.renameOrigins(old, new)
+	}
+	return settings
+}
+
+// renameOrigins attributes the keys under old to the same keys under new
+func (l *loadInfo) renameOrigins(old, new string) {
+	renamed := make(map[string]string)
+	for k, source := range l.origins {
+		if rest, ok := strings.CutPrefix(k, old); ok && (rest == "" || rest[0] == '.') {
+			delete(l.origins, k)
+			renamed[new+rest] = source
+		}
+	}
+	for k, source := range renamed {
+		l.origins[k] = source
+	}
+}
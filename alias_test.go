@@ -0,0 +1,75 @@
+package viper
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_RegisterAlias(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": "Redis:\n  Addr: redis:6379\nlog_level: debug\ndatabase:\n  host: db\n  port: 5432\n",
+		"both.yaml":   "log_level: debug\nlog:\n  level: warn\n",
+	})
+	var logs bytes.Buffer
+	p := New(WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	p.RegisterAlias("redis.addr", "cache.addr")
+	p.RegisterAlias("log_level", "log.level")
+	p.RegisterAlias("database", "db")
+	configFile := filepath.Join(dir, "config.yaml")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"cache.addr", "redis:6379"},
+		{"redis.addr", "redis:6379"},
+		{"log.level", "debug"},
+		{"LOG_LEVEL", "debug"},
+		{"db.port", 5432},
+		{"database.host", "db"},
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.path); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if got, want := p.AllKeys(), []string{"cache.addr", "db.host", "db.port", "log.level"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("AllKeys() = %v, want the keys renamed", got)
+	}
+	p.mu.RLock()
+	origin := p.origin("cache.addr")
+	p.mu.RUnlock()
+	if origin != configFile {
+		t.Errorf("origin of cache.addr = %q, want %q", origin, configFile)
+	}
+
+	for _, want := range []string{
+		`msg="deprecated config key" key=redis.addr use=cache.addr source=` + configFile,
+		`msg="deprecated config key" key=database use=db source=` + configFile,
+		`msg="deprecated config key read" key=log_level use=log.level`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs = %s, want them to contain %s", logs.String(), want)
+		}
+	}
+	if n := strings.Count(logs.String(), `"deprecated config key read" key=log_level`); n != 1 {
+		t.Errorf("the read of log_level was logged %d times, want once", n)
+	}
+
+	logs.Reset()
+	if _, err := p.Parse(filepath.Join(dir, "both.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("log.level"); got != "warn" {
+		t.Errorf("log.level = %q, want the replacement to win", got)
+	}
+	if !strings.Contains(logs.String(), `msg="deprecated config key ignored, its replacement is set" key=log_level`) {
+		t.Errorf("logs = %s, want the ignored key reported", logs.String())
+	}
+}
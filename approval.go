@@ -0,0 +1,128 @@
+package viper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChangeRequest describes a change about to be applied by a reload
+type ChangeRequest struct {
+	// Source is the file the change comes from
+	Source string
+	// Changes lists the keys the change would add, remove or modify
+	Changes ChangeSet
+	// Settings holds the settings the change would install, with lower-cased
+	// keys, so approval records embedded in the config can be checked
+	Settings map[string]interface{}
+}
+
+// Approver decides whether a change coming from a reload can be applied.
+// Returning an error holds the change back.
+type Approver interface {
+	Approve(ctx context.Context, req ChangeRequest) error
+}
+
+// ApproverFunc adapts a function to the Approver interface
+type ApproverFunc func(ctx context.Context, req ChangeRequest) error
+
+// Approve calls f(ctx, req)
+func (f ApproverFunc) Approve(ctx context.Context, req ChangeRequest) error {
+	return f(ctx, req)
+}
+
+// ApprovalError is returned when an approver rejected a change
+type ApprovalError struct {
+	// Source is the file the change comes from
+	Source string
+	// Err is the error returned by the approver
+	Err error
+}
+
+func (e *ApprovalError) Error() string {
+	return fmt.Sprintf("change from %s not approved: %v", e.Source, e.Err)
+}
+
+func (e *ApprovalError) Unwrap() error {
+	return e.Err
+}
+
+// WithApprover registers an approver consulted before Watch applies a
+// change to a file, as pushed by a deployment or config management system.
+// The initial Parse is not subject to approval. Unapproved changes are not
+// applied: the previous config stays in place, the rejection is logged,
+// recorded in the load history and reported to the OnChangeHeld callbacks.
+// Approvers run without the parser lock, so they may read the parser, but
+// must not load or reload it. Their context expires after 30 seconds.
+func WithApprover(a Approver) Option {
+	return func(p *Parser) {
+		p.approver = a
+	}
+}
+
+// OnChangeHeld registers a callback invoked when an approver holds back a
+// change, with the change and the error returned by the approver
+func (p *Parser) OnChangeHeld(callback func(ChangeRequest, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.heldListeners = append(p.heldListeners, callback)
+}
+
+// approvalTimeout bounds the time an approver has to decide on a change
+const approvalTimeout = 30 * time.Second
+
+// approvalRequest returns the change to approve for settings to replace the
+// current config, nil when no approval is needed. Callers hold the lock.
+func (p *Parser) approvalRequest(configFile string, settings map[string]interface{}) *ChangeRequest {
+	if p.approver == nil || p.own == nil {
+		return nil
+	}
+	next := lowerKeys(settings)
+	changes := p.diff(lowerKeys(p.own), next)
+	if changes.Empty() {
+		return nil
+	}
+	return &ChangeRequest{Source: configFile, Changes: changes, Settings: next}
+}
+
+// approve asks the approver whether req can be applied, if any. Callers
+// must not hold p.mu.
+func (p *Parser) approve(ctx context.Context, req *ChangeRequest) error {
+	if req == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, approvalTimeout)
+	defer cancel()
+	if err := p.approver.Approve(ctx, *req); err != nil {
+		return &heldChange{req: *req, err: &ApprovalError{Source: req.Source, Err: err}}
+	}
+	return nil
+}
+
+// heldChange carries the change held back by an approver up to the reload
+// callbacks
+type heldChange struct {
+	req ChangeRequest
+	err *ApprovalError
+}
+
+func (h *heldChange) Error() string { return h.err.Error() }
+
+func (h *heldChange) Unwrap() error { return h.err }
+
+// changeHeld reports a change held back by an approver to the OnChangeHeld
+// callbacks. Callers must not hold p.mu.
+func (p *Parser) changeHeld(err error) {
+	var held *heldChange
+	if !errors.As(err, &held) {
+		return
+	}
+	p.mu.RLock()
+	listeners := append([]func(ChangeRequest, error){}, p.heldListeners...)
+	p.mu.RUnlock()
+
+	for _, callback := range listeners {
+		callback(held.req, held.err.Err)
+	}
+}
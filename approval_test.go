@@ -0,0 +1,133 @@
+package viper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithApprover(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "server:\n  port: 8080\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	errNoTicket := errors.New("no approval ticket")
+	approver := ApproverFunc(func(_ context.Context, req ChangeRequest) error {
+		approval, _ := req.Settings["approval"].(map[string]interface{})
+		if approval["ticket"] == nil {
+			return errNoTicket
+		}
+		return nil
+	})
+
+	p := New(WithApprover(approver))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatalf("Parse() error = %v, want the initial load to skip approval", err)
+	}
+
+	type heldEvent struct {
+		req ChangeRequest
+		err error
+	}
+	held := make(chan heldEvent, 1)
+	p.OnChangeHeld(func(req ChangeRequest, err error) {
+		select {
+		case held <- heldEvent{req, err}:
+		default:
+		}
+	})
+	reloaded := make(chan struct{}, 1)
+	if err := p.Watch(configFile, func() {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch(configFile)
+
+	if err := os.WriteFile(configFile, []byte("server:\n  port: 9090\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-held:
+		if !errors.Is(ev.err, errNoTicket) {
+			t.Errorf("held error = %v, want %v", ev.err, errNoTicket)
+		}
+		if got, want := ev.req.Changes.Keys(), []string{"server.port"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("held changes = %v, want %v", got, want)
+		}
+		if ev.req.Source != configFile {
+			t.Errorf("held source = %q, want %q", ev.req.Source, configFile)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("unapproved change not reported")
+	}
+	<-reloaded
+	if got := p.GetInt("server.port"); got != 8080 {
+		t.Errorf("server.port = %d, want the unapproved change held back", got)
+	}
+	p.mu.RLock()
+	last := p.history[len(p.history)-1]
+	p.mu.RUnlock()
+	if !strings.Contains(last.Error, "not approved") {
+		t.Errorf("last load error = %q, want the rejection recorded", last.Error)
+	}
+
+	if err := os.WriteFile(configFile, []byte("approval:\n  ticket: CHG-42\nserver:\n  port: 9090\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(time.Second)
+	for p.GetInt("server.port") != 9090 {
+		select {
+		case <-reloaded:
+		case <-deadline:
+			t.Fatal("approved change not applied")
+		}
+	}
+}
+
+func TestWithApprover_RunsUnlocked(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "server:\n  port: 8080\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	type call struct {
+		port     int
+		deadline bool
+	}
+	calls := make(chan call, 1)
+	var p *Parser
+	p = New(WithApprover(ApproverFunc(func(ctx context.Context, _ ChangeRequest) error {
+		// reading the parser must not deadlock with the reload
+		_, ok := ctx.Deadline()
+		calls <- call{port: p.GetInt("server.port"), deadline: ok}
+		return nil
+	})))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Watch(configFile, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch(configFile)
+
+	if err := os.WriteFile(configFile, []byte("server:\n  port: 9090\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-calls:
+		if c.port != 8080 {
+			t.Errorf("server.port seen by the approver = %d, want the current 8080", c.port)
+		}
+		if !c.deadline {
+			t.Error("approver context has no deadline")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("approver not called")
+	}
+}
@@ -0,0 +1,370 @@
+package viper
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultAWSRefresh is how often a watched AWS source reads its values
+	// again
+	DefaultAWSRefresh = 5 * time.Minute
+	// DefaultAWSTimeout bounds each request of an AWS source using the
+	// default client
+	DefaultAWSTimeout = 10 * time.Second
+)
+
+// AWSCredentials are the credentials signing the requests of the AWS
+// sources
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSSource is a source reading parameters from AWS Systems Manager
+// Parameter Store or a secret from AWS Secrets Manager, merged under a
+// prefix of the local key space. Register it with WithSource and watch it
+// with WatchSource, which reads the values again periodically.
+type AWSSource struct {
+	service  string
+	names    []string
+	prefix   string
+	region   string
+	creds    AWSCredentials
+	endpoint string
+	client   *http.Client
+	refresh  time.Duration
+}
+
+// AWSOption configures an AWSSource
+type AWSOption func(*AWSSource)
+
+// WithAWSRegion sets the region of the service, AWS_REGION by default
+func WithAWSRegion(region string) AWSOption {
+	return func(s *AWSSource) {
+		s.region = region
+	}
+}
+
+// WithAWSCredentials sets the credentials signing the requests. They are
+// read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// by default.
+func WithAWSCredentials(creds AWSCredentials) AWSOption {
+	return func(s *AWSSource) {
+		s.creds = creds
+	}
+}
+
+// WithAWSEndpoint replaces the regional endpoint of the service, as for a
+// VPC endpoint or a local emulator
+func WithAWSEndpoint(endpoint string) AWSOption {
+	return func(s *AWSSource) {
+		s.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithAWSClient sets the HTTP client sending the requests, a client giving
+// up after DefaultAWSTimeout by default
+func WithAWSClient(client *http.Client) AWSOption {
+	return func(s *AWSSource) {
+		s.client = client
+	}
+}
+
+// WithAWSRefresh sets how often a watch reads the values again,
+// DefaultAWSRefresh by default
+func WithAWSRefresh(d time.Duration) AWSOption {
+	return func(s *AWSSource) {
+		s.refresh = d
+	}
+}
+
+// SSMParameters returns a source reading parameters from the Parameter
+// Store, decrypting secure strings. Names ending with a slash select a whole
+// subtree, the parameter /myapp/db/host under /myapp/ becoming db.host;
+// other names select a single parameter, /myapp/db/host becoming
+// myapp.db.host. The keys are set under prefix, at the root when empty.
+func SSMParameters(prefix string, names []string, opts ...AWSOption) *AWSSource {
+	return newAWSSource("ssm", prefix, names, opts)
+}
+
+// SecretsManagerSecret returns a source reading the current value of a
+// secret from Secrets Manager. A JSON object secret sets its fields under
+// prefix; any other secret sets prefix itself, which must then not be
+// empty.
+func SecretsManagerSecret(prefix, secretID string, opts ...AWSOption) *AWSSource {
+	return newAWSSource("secretsmanager", prefix, []string{secretID}, opts)
+}
+
+func newAWSSource(service, prefix string, names []string, opts []AWSOption) *AWSSource {
+	s := &AWSSource{
+		service: service,
+		names:   names,
+		prefix:  prefix,
+		region:  os.Getenv("AWS_REGION"),
+		creds: AWSCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		client:  &http.Client{Timeout: DefaultAWSTimeout},
+		refresh: DefaultAWSRefresh,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Load reads the selected values
+func (s *AWSSource) Load(ctx context.Context) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if s.service == "secretsmanager" {
+		if err := s.loadSecret(ctx, values); err != nil {
+			return nil, err
+		}
+	} else if err := s.loadParameters(ctx, values); err != nil {
+		return nil, err
+	}
+
+	if s.prefix == "" {
+		return values, nil
+	}
+	settings := make(map[string]interface{})
+	if v, ok := values[""]; ok {
+		setPath(settings, s.prefix, v)
+	} else {
+		setPath(settings, s.prefix, values)
+	}
+	return settings, nil
+}
+
+// Watch reads the values again every refresh interval, calling onChange
+// when they changed
+func (s *AWSSource) Watch(ctx context.Context, onChange func(), onError func(error)) {
+	last, err := s.Load(ctx)
+	if err != nil {
+		onError(err)
+	}
+	ticker := time.NewTicker(s.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := s.Load(ctx)
+		if err != nil {
+			onError(err)
+			continue
+		}
+		if !reflect.DeepEqual(current, last) {
+			onChange()
+		}
+		last = current
+	}
+}
+
+type ssmParameter struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+func (s *AWSSource) loadParameters(ctx context.Context, values map[string]interface{}) error {
+	var single []string
+	for _, name := range s.names {
+		if !strings.HasSuffix(name, "/") {
+			single = append(single, name)
+			continue
+		}
+		var token string
+		for {
+			var resp struct {
+				Parameters []ssmParameter `json:"Parameters"`
+				NextToken  string         `json:"NextToken"`
+			}
+			req := map[string]interface{}{"Path": name, "Recursive": true, "WithDecryption": true}
+			if token != "" {
+				req["NextToken"] = token
+			}
+			if err := s.call(ctx, "AmazonSSM.GetParametersByPath", req, &resp); err != nil {
+				return fmt.Errorf("ssm: reading %q: %w", name, err)
+			}
+			for _, param := range resp.Parameters {
+				setPath(values, ssmKey(strings.TrimPrefix(param.Name, name)), param.Value)
+			}
+			if token = resp.NextToken; token == "" {
+				break
+			}
+		}
+	}
+
+	// GetParameters accepts at most 10 names
+	for len(single) > 0 {
+		batch := single[:min(10, len(single))]
+		single = single[len(batch):]
+		var resp struct {
+			Parameters        []ssmParameter `json:"Parameters"`
+			InvalidParameters []string       `json:"InvalidParameters"`
+		}
+		req := map[string]interface{}{"Names": batch, "WithDecryption": true}
+		if err := s.call(ctx, "AmazonSSM.GetParameters", req, &resp); err != nil {
+			return fmt.Errorf("ssm: reading %s: %w", strings.Join(batch, ", "), err)
+		}
+		if len(resp.InvalidParameters) > 0 {
+			return fmt.Errorf("ssm: unknown parameters %s", strings.Join(resp.InvalidParameters, ", "))
+		}
+		for _, param := range resp.Parameters {
+			setPath(values, ssmKey(param.Name), param.Value)
+		}
+	}
+	return nil
+}
+
+// ssmKey turns a parameter name into a dot-notation key
+func ssmKey(name string) string {
+	return strings.ReplaceAll(strings.Trim(name, "/"), "/", ".")
+}
+
+func (s *AWSSource) loadSecret(ctx context.Context, values map[string]interface{}) error {
+	id := s.names[0]
+	var resp struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := s.call(ctx, "secretsmanager.GetSecretValue", map[string]interface{}{"SecretId": id}, &resp); err != nil {
+		return fmt.Errorf("secretsmanager: reading %q: %w", id, err)
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(resp.SecretString), &fields) == nil {
+		for k, v := range fields {
+			values[k] = v
+		}
+		return nil
+	}
+	if s.prefix == "" {
+		return fmt.Errorf("secretsmanager: secret %q is not a JSON object and needs a prefix", id)
+	}
+	values[""] = resp.SecretString
+	return nil
+}
+
+// call invokes an action of the JSON API of the service
+func (s *AWSSource) call(ctx context.Context, target string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	endpoint := s.endpoint
+	if endpoint == "" {
+		if s.region == "" {
+			return fmt.Errorf("no AWS region configured")
+		}
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", s.service, s.region)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := readBody(ctx, resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(b, &failure) == nil && failure.Type != "" {
+			return fmt.Errorf("%s: %s %s", resp.Status, failure.Type, failure.Message)
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.Unmarshal(b, out)
+}
+
+// sign adds the AWS Signature Version 4 headers to req
+func (s *AWSSource) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.creds.SessionToken)
+	}
+
+	// every header set is signed, in the sorted order SigV4 requires
+	headers := []string{"host"}
+	for h := range req.Header {
+		headers = append(headers, strings.ToLower(h))
+	}
+	sort.Strings(headers)
+	var canonical strings.Builder
+	for _, h := range headers {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonical.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signed := strings.Join(headers, ";")
+	payload := sha256.Sum256(body)
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	request := strings.Join([]string{
+		req.Method, path, canonicalQuery(req.URL.Query()), canonical.String(), signed, hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	region := s.region
+	if region == "" {
+		region = "us-east-1"
+	}
+	scope := date + "/" + region + "/" + s.service + "/aws4_request"
+	hashed := sha256.Sum256([]byte(request))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	signature := hex.EncodeToString(hmacSHA256(awsSigningKey(s.creds.SecretAccessKey, date, region, s.service), toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.creds.AccessKeyID, scope, signed, signature))
+}
+
+func canonicalQuery(q url.Values) string {
+	return strings.ReplaceAll(q.Encode(), "+", "%20")
+}
+
+// awsSigningKey derives the Signature Version 4 key of a day, region and
+// service
+func awsSigningKey(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,194 @@
+package viper
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAWS serves the Parameter Store and Secrets Manager actions
+type fakeAWS struct {
+	mu     sync.Mutex
+	params map[string]string
+	secret string
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "Signature=") {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"__type": "UnrecognizedClientException", "message": "invalid signature"}`))
+		return
+	}
+	var in map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&in)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var params []ssmParameter
+	switch r.Header.Get("X-Amz-Target") {
+	case "AmazonSSM.GetParametersByPath":
+		for name, value := range f.params {
+			if strings.HasPrefix(name, in["Path"].(string)) {
+				params = append(params, ssmParameter{Name: name, Value: value})
+			}
+		}
+		sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+		// one parameter per page
+		page := 0
+		if token, ok := in["NextToken"].(string); ok {
+			page = len(token)
+		}
+		out := map[string]interface{}{"Parameters": params[page : page+1]}
+		if page+1 < len(params) {
+			out["NextToken"] = strings.Repeat("x", page+1)
+		}
+		json.NewEncoder(w).Encode(out)
+	case "AmazonSSM.GetParameters":
+		var invalid []string
+		for _, name := range in["Names"].([]interface{}) {
+			if value, ok := f.params[name.(string)]; ok {
+				params = append(params, ssmParameter{Name: name.(string), Value: value})
+			} else {
+				invalid = append(invalid, name.(string))
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Parameters": params, "InvalidParameters": invalid})
+	case "secretsmanager.GetSecretValue":
+		json.NewEncoder(w).Encode(map[string]interface{}{"SecretString": f.secret})
+	}
+}
+
+func TestAWSSource(t *testing.T) {
+	aws := &fakeAWS{
+		params: map[string]string{
+			"/myapp/db/host": "db.internal",
+			"/myapp/db/port": "5432",
+			"/shared/region": "eu-west-1",
+		},
+		secret: `{"password": "s3cret", "user": "app"}`,
+	}
+	srv := httptest.NewServer(aws)
+	defer srv.Close()
+
+	dir := writeFiles(t, map[string]string{"config.yaml": "app:\n  db:\n    host: localhost\n    name: app\n"})
+	opts := []AWSOption{
+		WithAWSEndpoint(srv.URL),
+		WithAWSRegion("eu-west-1"),
+		WithAWSCredentials(AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}),
+		WithAWSRefresh(20 * time.Millisecond),
+	}
+	ssm := SSMParameters("app", []string{"/myapp/", "/shared/region"}, opts...)
+	p := New(
+		WithSource("ssm", ssm),
+		WithSource("secrets", SecretsManagerSecret("app.db", "myapp/db", opts...)),
+	)
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"app.db.host":       "db.internal",
+		"app.db.port":       "5432",
+		"app.db.name":       "app",
+		"app.db.password":   "s3cret",
+		"app.shared.region": "eu-west-1",
+	} {
+		if got := p.GetString(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	reloaded := make(chan struct{}, 1)
+	if err := p.WatchSource("ssm", func() {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch("ssm")
+	time.Sleep(10 * time.Millisecond)
+	aws.mu.Lock()
+	aws.params["/myapp/db/host"] = "db2.internal"
+	aws.mu.Unlock()
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reload after a parameter changed")
+	}
+	if got := p.GetString("app.db.host"); got != "db2.internal" {
+		t.Errorf("app.db.host = %q after the refresh, want db2.internal", got)
+	}
+
+	plain := SecretsManagerSecret("db.password", "plain", opts...)
+	aws.mu.Lock()
+	aws.secret = "hunter2"
+	aws.mu.Unlock()
+	got, err := plain.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := lookupPath(got, "db.password"); v != "hunter2" {
+		t.Errorf("plain secret = %v, want it at the prefix", got)
+	}
+
+	errTests := []struct {
+		name    string
+		src     *AWSSource
+		wantErr string
+	}{
+		{"unknown parameter", SSMParameters("", []string{"/missing"}, opts...), "unknown parameters /missing"},
+		{"plain secret without prefix", SecretsManagerSecret("", "plain", opts...), "needs a prefix"},
+		{"unsigned", SSMParameters("", []string{"/myapp/"}, WithAWSEndpoint(srv.URL)), "UnrecognizedClientException"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.src.Load(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAWSSigningKey(t *testing.T) {
+	// example from the AWS Signature Version 4 documentation
+	key := awsSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	if got := hex.EncodeToString(key); got != "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d" {
+		t.Errorf("awsSigningKey() = %s", got)
+	}
+}
+
+func TestAWSSource_SignSessionToken(t *testing.T) {
+	// post-sts-header-before from the AWS Signature Version 4 test suite
+	s := &AWSSource{
+		service: "service",
+		region:  "us-east-1",
+		creds: AWSCredentials{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			SessionToken:    "AQoDYXdzEPT//////////wEXAMPLEtc764bNrC9SAPBSM22wDOk4x4HIZ8j4FZTwdQWLWsKWHGBuFqwAeMicRXmxfpSPfIeoIYRqTflfKD8YUuwthAx7mSEI/qkPpKPi/kMcGdQrmGdeehM4IC1NtBmUpp2wUE8phUZampKsburEDy0KPkyQDYwT7WZ0wq5VSXDvp75YU9HFvlRd8Tx6q6fE8YQcHNVXAkiY9q6d+xo0rKwT38xVqr7ZD0u0iPPkUL64lIZbqBAz+scqKmlzm8FDrypNC9Yjc8fPOLn9FX9KSYvKTr4rvx3iSIlTJabIQwj2ICCR/oLxBA==",
+		},
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.sign(req, nil, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date;x-amz-security-token, " +
+		"Signature=85d96828115b5dc0cfc3bd16ad9e210dd772bbebba041836c64533a82be05ead"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package viper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ClientConfig holds the connection settings of a remote config backend,
+// read from a bootstrap section of the local config like:
+//
+//	bootstrap:
+//	  consul:
+//	    endpoint: https://consul.internal:8501
+//	    token: $ref{file:/run/secrets/consul-token}
+//	    timeout: 10m
+//	    tls:
+//	      ca_file: /etc/ssl/internal-ca.pem
+type ClientConfig struct {
+	// Endpoint is the address of the backend
+	Endpoint string `mapstructure:"endpoint"`
+	// Token, Username and Password authenticate the client, as the backend
+	// expects them
+	Token    string `mapstructure:"token"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// Timeout bounds every request of the client built by HTTPClient
+	Timeout time.Duration `mapstructure:"timeout"`
+	// TLS configures the connections to Endpoint
+	TLS ClientTLS `mapstructure:"tls"`
+	// Options holds the backend-specific settings of the section
+	Options map[string]interface{} `mapstructure:",remain"`
+}
+
+// ClientTLS holds the TLS settings of a ClientConfig
+type ClientTLS struct {
+	// CAFile holds the PEM certificates trusted to verify the server,
+	// instead of the system pool
+	CAFile string `mapstructure:"ca_file"`
+	// CertFile and KeyFile hold the client certificate, for mutual TLS
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// ServerName overrides the name verified in the server certificate
+	ServerName         string `mapstructure:"server_name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+// TLSConfig returns the TLS configuration of the client, to dial the
+// backend over gRPC or HTTP. It returns nil when no TLS setting is given.
+func (c ClientConfig) TLSConfig() (*tls.Config, error) {
+	t := c.TLS
+	if t == (ClientTLS{}) {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %q", t.CAFile)
+		}
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// HTTPClient returns an HTTP client honoring the timeout and TLS settings
+func (c ClientConfig) HTTPClient() (*http.Client, error) {
+	cfg, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil && c.Timeout == 0 {
+		return http.DefaultClient, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	return &http.Client{Transport: transport, Timeout: c.Timeout}, nil
+}
+
+// bootstrap builds a source from a section of the settings read before it
+type bootstrap struct {
+	section string
+	build   func(ClientConfig) (Source, error)
+	// last is the section the current source was built from
+	last map[string]interface{}
+}
+
+// WithBootstrapSource registers a source whose connection settings come
+// from the config itself, in a two-phase load: the config files and the
+// sources registered before it are read first, the section at the given
+// key is decoded into a ClientConfig, references in it resolved, and build
+// returns the source then merged on top as WithSource does. The source is
+// built again whenever a load finds the section changed; WatchSource
+// watches the source built by the last load. A missing section fails the
+// load.
+func WithBootstrapSource(name, section string, build func(ClientConfig) (Source, error)) Option {
+	return func(p *Parser) {
+		p.sources = append(p.sources, namedSource{
+			name:      name,
+			bootstrap: &bootstrap{section: strings.ToLower(section), build: build},
+		})
+	}
+}
+
+// bootstrapSource returns the source of s for the settings read before it,
+// building it when the bootstrap section changed. Callers hold p.mu.
+func (p *Parser) bootstrapSource(ctx context.Context, s *namedSource, settings map[string]interface{}) (Source, error) {
+	b := s.bootstrap
+	raw, ok := lookupPath(lowerKeys(settings), b.section)
+	section, isMap := toStringMap(raw)
+	if !ok || !isMap {
+		return nil, fmt.Errorf("bootstrap section %q is missing", b.section)
+	}
+	resolved, err := p.resolveRefs(ctx, section, b.section)
+	if err != nil {
+		return nil, fmt.Errorf("resolving bootstrap section %q: %w", b.section, err)
+	}
+	section = resolved.(map[string]interface{})
+	if s.src != nil && reflect.DeepEqual(section, b.last) {
+		return s.src, nil
+	}
+
+	var cfg ClientConfig
+	if err := p.decodeInto(section, &cfg); err != nil {
+		return nil, fmt.Errorf("decoding bootstrap section %q: %w", b.section, err)
+	}
+	src, err := b.build(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("building source from %q: %w", b.section, err)
+	}
+	s.src, b.last = src, section
+	return src, nil
+}
@@ -0,0 +1,82 @@
+package viper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_BootstrapSource(t *testing.T) {
+	t.Setenv("BOOTSTRAPTEST_TOKEN", "s3cret")
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+bootstrap:
+  kv:
+    endpoint: https://kv.internal
+    token: $ref{env:BOOTSTRAPTEST_TOKEN}
+    timeout: 2s
+    prefix: app/
+log: info
+`,
+		"moved.yaml": `
+bootstrap:
+  kv:
+    endpoint: https://kv2.internal
+log: info
+`,
+		"missing.yaml": "log: info\n",
+	})
+
+	var built []ClientConfig
+	p := New(WithBootstrapSource("kv", "bootstrap.kv", func(c ClientConfig) (Source, error) {
+		built = append(built, c)
+		return SourceFunc(func(context.Context) (map[string]interface{}, error) {
+			return map[string]interface{}{"log": "debug", "endpoint": c.Endpoint}, nil
+		}), nil
+	}))
+
+	for _, name := range []string{"config.yaml", "config.yaml", "moved.yaml"} {
+		if _, err := p.Parse(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(built) != 2 {
+		t.Fatalf("source built %d times, want once per distinct section", len(built))
+	}
+	first := built[0]
+	if first.Endpoint != "https://kv.internal" || first.Token != "s3cret" || first.Timeout != 2*time.Second || first.Options["prefix"] != "app/" {
+		t.Errorf("client config = %+v, want the bootstrap section decoded", first)
+	}
+	if got := p.GetString("endpoint"); got != "https://kv2.internal" {
+		t.Errorf("endpoint = %q, want the one of the rebuilt source", got)
+	}
+	if got := p.GetString("log"); got != "debug" {
+		t.Errorf("log = %q, want the source merged on top of the files", got)
+	}
+
+	_, err := p.Parse(filepath.Join(dir, "missing.yaml"))
+	if err == nil || !strings.Contains(err.Error(), `bootstrap section "bootstrap.kv" is missing`) {
+		t.Errorf("Parse() error = %v, want the missing section reported", err)
+	}
+}
+
+func TestClientConfig_HTTPClient(t *testing.T) {
+	client, err := ClientConfig{Timeout: time.Second, TLS: ClientTLS{ServerName: "kv"}}.HTTPClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Timeout != time.Second {
+		t.Errorf("Timeout = %v, want 1s", client.Timeout)
+	}
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(ca, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (ClientConfig{TLS: ClientTLS{CAFile: ca}}).TLSConfig(); err == nil {
+		t.Error("TLSConfig() with an invalid CA file succeeded")
+	}
+}
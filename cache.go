@@ -0,0 +1,88 @@
+package viper
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// memoCache holds values derived from a single version of the config
+type memoCache struct {
+	mu      sync.Mutex
+	version uint64
+	entries map[string]*memoEntry
+}
+
+type memoEntry struct {
+	mu    sync.Mutex
+	done  bool
+	value interface{}
+}
+
+// entry returns the entry for key at the given config version. Entries of
+// older versions are dropped as soon as a newer version is seen.
+func (c *memoCache) entry(version uint64, key string) *memoEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version < c.version {
+		// the config changed while the caller was reading, don't keep it
+		return &memoEntry{}
+	}
+	if version > c.version || c.entries == nil {
+		c.version = version
+		c.entries = make(map[string]*memoEntry)
+	}
+	e, ok := c.entries[key]
+	if !ok {
+		e = &memoEntry{}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// Version returns a number identifying the current state of the config. It
+// changes whenever a load, a reload or an override changes the settings.
+func (p *Parser) Version() uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.version
+}
+
+// Cached returns the value computed by fn for key, calling fn at most once
+// per config version, so expensive conversions like unmarshaling a subtree
+// or parsing TLS material are not redone between reloads. Errors are not
+// cached and fn is called again on the next read.
+func (p *Parser) Cached(key string, fn func() (interface{}, error)) (interface{}, error) {
+	return p.cached("user:"+key, fn)
+}
+
+func (p *Parser) cached(key string, fn func() (interface{}, error)) (interface{}, error) {
+	e := p.memo.entry(p.Version(), key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done {
+		return e.value, nil
+	}
+	v, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	e.value, e.done = v, true
+	return v, nil
+}
+
+// GetRegexp compiles the regular expression stored at path. The compiled
+// expression is cached until the config changes.
+func (p *Parser) GetRegexp(path string) (*regexp.Regexp, error) {
+	v, err := p.cached("regexp:"+path, func() (interface{}, error) {
+		re, err := regexp.Compile(p.GetString(path))
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", path, err)
+		}
+		return re, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*regexp.Regexp), nil
+}
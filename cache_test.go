@@ -0,0 +1,77 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_Cached(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"v1.json": `{"db": {"host": "a"}, "route": "^/api/v1/"}`,
+		"v2.json": `{"db": {"host": "b"}, "route": "^/api/v2/"}`,
+	})
+
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "v1.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	host := func() (interface{}, error) {
+		calls++
+		return p.GetString("db.host"), nil
+	}
+	for i := 0; i < 3; i++ {
+		if v, err := p.Cached("db", host); err != nil || v != "a" {
+			t.Fatalf("Cached() = %v, %v, want a", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times for one version, want 1", calls)
+	}
+
+	re, err := p.GetRegexp("route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := p.GetRegexp("route"); again != re {
+		t.Error("GetRegexp() recompiled an unchanged expression")
+	}
+
+	// a reload invalidates every cached value
+	version := p.Version()
+	if _, err := p.Parse(filepath.Join(dir, "v2.json")); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version() == version {
+		t.Error("Version() unchanged after a reload")
+	}
+	if v, _ := p.Cached("db", host); v != "b" || calls != 2 {
+		t.Errorf("Cached() = %v after %d calls, want b after 2", v, calls)
+	}
+	if re, err = p.GetRegexp("route"); err != nil || !re.MatchString("/api/v2/users") {
+		t.Errorf("GetRegexp() = %v, %v, want the reloaded expression", re, err)
+	}
+
+	p.Override("route", "(")
+	if _, err := p.GetRegexp("route"); err == nil {
+		t.Error("GetRegexp() of an invalid expression should fail")
+	}
+
+	// errors are not cached
+	fail := true
+	flaky := func() (interface{}, error) {
+		if fail {
+			return nil, errors.New("not yet")
+		}
+		return 1, nil
+	}
+	if _, err := p.Cached("flaky", flaky); err == nil {
+		t.Fatal("Cached() should return the error of fn")
+	}
+	fail = false
+	if v, err := p.Cached("flaky", flaky); err != nil || v != 1 {
+		t.Errorf("Cached() = %v, %v after a failure, want 1", v, err)
+	}
+}
@@ -0,0 +1,201 @@
+package viper
+
+import (
+	"slices"
+	"strings"
+)
+
+// Child returns a parser inheriting the settings of p. The child starts with
+// the same options, required keys and aliases as p, then applies opts, and
+// the config it loads must also satisfy the schema of p, checked on the
+// config of p with the child's on top. Its effective config is the
+// config of p with the child's own sources, loaded with Parse, merged on top,
+// and overrides set on the child never reach p. Whenever p reloads, the child
+// picks up the new values and notifies its own change listeners.
+func (p *Parser) Child(opts ...Option) *Parser {
+	return p.child("", opts)
+}
+
+// Sub returns a child parser scoped to the sub-tree at path, so a module
+// receives its own section without knowing the global layout:
+// p.Sub("database").GetString("url") reads database.url of p. Keys are
+// relative to path, environment variables included: with the default
+// prefix, url reads NEXEN_DATABASE_URL. The sub-tree follows the reloads of
+// p like the config of a Child does, and is empty while path is not set.
+// The required keys and aliases of p under path carry over relative to it.
+func (p *Parser) Sub(path string, opts ...Option) *Parser {
+	return p.child(strings.ToLower(p.normalizePath(path)), opts)
+}
+
+// child creates a child parser inheriting the sub-tree of p at prefix, or
+// all of it when prefix is empty
+func (p *Parser) child(prefix string, opts []Option) *Parser {
+	// the mapper is set at construction, so it is read without the lock
+	var inherit []Option
+	if mapper := p.env.mapper; mapper != nil {
+		inherit = append(inherit, WithEnvKeyMapper(func(key string) string {
+			return mapper(joinPath(prefix, key))
+		}))
+	}
+	c := New(inherit...)
+
+	p.mu.RLock()
+	// mapped names are the names of the full keys of p, under its prefixes
+	scope := func(envPrefix string) string {
+		if prefix == "" || c.env.mapper != nil {
+			return envPrefix
+		}
+		return strings.Trim(envPrefix+"_"+strings.ReplaceAll(prefix, ".", "_"), "_")
+	}
+	c.v.SetEnvPrefix(scope(p.v.GetEnvPrefix()))
+	for _, legacy := range p.env.prefixes {
+		c.env.prefixes = append(c.env.prefixes, scope(legacy))
+	}
+	c.options = p.options.clone()
+	// the schema of p describes its own tree, see inheritedViolations
+	c.schema, c.schemaErr = nil, nil
+	c.required = scopedKeys(c.required, prefix)
+	for old, new := range p.aliases.targets {
+		old, okOld := cutPath(old, prefix)
+		new, okNew := cutPath(new, prefix)
+		if !okOld || !okNew {
+			continue
+		}
+		if c.aliases.targets == nil {
+			c.aliases.targets = make(map[string]string)
+		}
+		c.aliases.targets[old] = new
+	}
+	for typ, codec := range p.codecs {
+		switch codec.(type) {
+		case *yamlCodec, *jsonCodec, *jsoncCodec, *tomlCodec, *cueCodec, *xmlCodec,
+			*iniCodec, *hclCodec, *propertiesCodec, *dotenvCodec:
+			// built-in codecs read their settings from the parser owning them
+			continue
+		}
+		c.codecs[typ] = codec
+	}
+	p.mu.RUnlock()
+	if c.configType != "" {
+		c.v.SetConfigType(c.configType)
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.parent, c.prefix = p, prefix
+	if err := c.compose(); err != nil {
+		c.logger.Warn("inheriting parent config", "error", err)
+	}
+
+	p.mu.Lock()
+	p.children = append(p.children, c)
+	p.mu.Unlock()
+	return c
+}
+
+// scopedKeys returns the keys of keys at or under prefix, relative to it
+func scopedKeys(keys []string, prefix string) []string {
+	scoped := keys[:0:0]
+	for _, key := range keys {
+		if rel, ok := cutPath(key, prefix); ok && rel != "" {
+			scoped = append(scoped, rel)
+		}
+	}
+	return scoped
+}
+
+// cutPath returns key relative to prefix, and whether key is prefix or a key
+// under it. Every key is under the empty prefix.
+func cutPath(key, prefix string) (string, bool) {
+	if prefix == "" {
+		return key, true
+	}
+	rest, ok := strings.CutPrefix(key, prefix)
+	if !ok || (rest != "" && rest[0] != '.') {
+		return "", false
+	}
+	return strings.TrimPrefix(rest, "."), true
+}
+
+// OnChange registers a callback invoked after each reload of the config,
+// including reloads inherited from a parent parser
+func (p *Parser) OnChange(callback func()) {
+	p.onChange(callback)
+}
+
+// listener is a callback registered with OnChange
+type listener struct {
+	id       uint64
+	callback func()
+}
+
+// onChange registers callback as a change listener and returns the function
+// removing it
+func (p *Parser) onChange(callback func()) (remove func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listenerID++
+	id := p.listenerID
+	p.listeners = append(p.listeners, listener{id, callback})
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.listeners = slices.DeleteFunc(p.listeners, func(l listener) bool { return l.id == id })
+	}
+}
+
+// compose installs the parser's own settings, merged on top of the parent's
+// effective config for a child, as the config layer. Callers hold p.mu.
+func (p *Parser) compose() error {
+	settings := p.own
+	if p.parent != nil {
+		if err := p.checkParentLocks(p.own); err != nil {
+			return err
+		}
+		p.parent.mu.RLock()
+		inherited := p.parent.settings()
+		p.parent.mu.RUnlock()
+		if p.prefix != "" {
+			sub, _ := lookupPath(inherited, p.prefix)
+			inherited, _ = toStringMap(sub)
+		}
+		settings = deepMerge(inherited, p.own)
+	}
+	p.version++
+	if err := p.setConfig(settings, p.ownType); err != nil {
+		return err
+	}
+	p.layer = settings
+	p.bindEnv()
+	return nil
+}
+
+// parentKey returns the key of the parent of a child parser matching key
+func (p *Parser) parentKey(key string) string {
+	return joinPath(p.prefix, key)
+}
+
+// changed refreshes the children and calls the change listeners after the
+// effective config of p changed. Callers must not hold p.mu.
+func (p *Parser) changed() {
+	p.mu.RLock()
+	children := append([]*Parser(nil), p.children...)
+	listeners := append([]listener(nil), p.listeners...)
+	p.mu.RUnlock()
+
+	for _, c := range children {
+		c.mu.Lock()
+		err := c.compose()
+		c.mu.Unlock()
+		if err != nil {
+			c.logger.Warn("refreshing inherited config", "error", err)
+			continue
+		}
+		c.changed()
+	}
+	for _, l := range listeners {
+		l.callback()
+	}
+}
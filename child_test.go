@@ -0,0 +1,214 @@
+package viper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParser_Child(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app.yaml":    "server:\n  host: app.local\n  port: 8080\nlog:\n  level: info\n",
+		"plugin.yaml": "server:\n  port: 9090\nplugin:\n  enabled: true\n",
+	})
+
+	parent := New(WithEnvPrefix("childtest"))
+	if _, err := parent.Parse(filepath.Join(dir, "app.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	child := parent.Child()
+	if got := child.GetString("server.host"); got != "app.local" {
+		t.Errorf("inherited server.host = %q, want app.local", got)
+	}
+	if got := child.GetEnvPrefix(); got != "childtest" {
+		t.Errorf("child env prefix = %q, want childtest", got)
+	}
+
+	if _, err := child.Parse(filepath.Join(dir, "plugin.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	child.Override("log.level", "debug")
+
+	tests := []struct {
+		key        string
+		wantChild  interface{}
+		wantParent interface{}
+	}{
+		{"server.host", "app.local", "app.local"},
+		{"server.port", 9090, 8080},
+		{"plugin.enabled", true, nil},
+		{"log.level", "debug", "info"},
+	}
+	for _, tt := range tests {
+		if got := child.Get(tt.key); got != tt.wantChild {
+			t.Errorf("child %s = %v, want %v", tt.key, got, tt.wantChild)
+		}
+		if got := parent.Get(tt.key); got != tt.wantParent {
+			t.Errorf("parent %s = %v, want %v", tt.key, got, tt.wantParent)
+		}
+	}
+}
+
+func TestParser_ChildInheritsChanges(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"app.json": `{"feature": {"enabled": false, "limit": 1}}`})
+	configFile := filepath.Join(dir, "app.json")
+
+	parent := New()
+	if _, err := parent.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	child := parent.Child()
+	child.Override("feature.limit", 5)
+
+	changes := make(chan struct{}, 1)
+	child.OnChange(func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+	if err := parent.Watch(configFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer parent.StopWatch(configFile)
+
+	if err := os.WriteFile(configFile, []byte(`{"feature": {"enabled": true, "limit": 2}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("child was not notified of the parent reload")
+	}
+	if !child.GetBool("feature.enabled") {
+		t.Error("child did not pick up feature.enabled from the parent reload")
+	}
+	if got := child.GetInt("feature.limit"); got != 5 {
+		t.Errorf("child feature.limit = %d, want its own override 5", got)
+	}
+	if got := parent.GetInt("feature.limit"); got != 2 {
+		t.Errorf("parent feature.limit = %d, want 2", got)
+	}
+}
+
+func TestParser_Sub(t *testing.T) {
+	t.Setenv("SUBTEST_DATABASE_POOL_SIZE", "20")
+	dir := writeFiles(t, map[string]string{
+		"v1.yaml": "database:\n  url: postgres://a\n  pool:\n    max: 5\nlog: info\n",
+		"v2.yaml": "database:\n  url: postgres://b\n  pool:\n    max: 5\nlog: info\n",
+	})
+	p := New(WithEnvPrefix("subtest"))
+	if _, err := p.Parse(filepath.Join(dir, "v1.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	db := p.Sub("database")
+	if got := db.GetString("url"); got != "postgres://a" {
+		t.Errorf("url = %q, want the key relative to the sub-tree", got)
+	}
+	if got := db.GetInt("pool.size"); got != 20 {
+		t.Errorf("pool.size = %d, want the env value of database.pool.size", got)
+	}
+	if got := db.Get("log"); got != nil {
+		t.Errorf("log = %v, want keys outside the sub-tree hidden", got)
+	}
+	if got := p.Sub("database").Sub("pool").GetInt("size"); got != 20 {
+		t.Errorf("size of a nested sub = %d, want 20", got)
+	}
+
+	var seen interface{}
+	db.Subscribe("url", func(_, new interface{}) { seen = new })
+	if _, err := p.Parse(filepath.Join(dir, "v2.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.GetString("url"); got != "postgres://b" || seen != "postgres://b" {
+		t.Errorf("url = %q, notified %v after the parent reloaded, want postgres://b", got, seen)
+	}
+	db.mu.RLock()
+	origin := db.origin("url")
+	db.mu.RUnlock()
+	if want := "parent: " + filepath.Join(dir, "v2.yaml"); origin != want {
+		t.Errorf("origin of url = %q, want %q", origin, want)
+	}
+
+	if got := p.Sub("missing").Get("url"); got != nil {
+		t.Errorf("url of a missing sub-tree = %v, want nil", got)
+	}
+}
+
+func TestParser_ChildInheritsValidation(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app.yaml":      "server:\n  host: app.local\n  port: 8080\n",
+		"bad_port.yaml": "server:\n  port: x\n",
+		"plugin.yaml":   "plugin:\n  enabled: true\n",
+		"sub_port.yaml": "port: 70000\n",
+		"sub_addr.yaml": "addr: sub.local\n",
+		"sub_db.yaml":   "pool: 4\n",
+	})
+	schema := []byte(`{
+		"type": "object",
+		"required": ["server"],
+		"properties": {
+			"server": {
+				"type": "object",
+				"properties": {"port": {"type": "integer", "maximum": 65535}}
+			}
+		}
+	}`)
+	validator := &stubValidator{}
+	parent := New(WithSchema(schema), WithValidator(validator))
+	if _, err := parent.Parse(filepath.Join(dir, "app.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the schema applies to the config the child serves, parent's included
+	if _, err := parent.Child().Parse(filepath.Join(dir, "plugin.yaml")); err != nil {
+		t.Errorf("child Parse() of a partial config error = %v", err)
+	}
+	var schemaErr *SchemaError
+	_, err := parent.Child().Parse(filepath.Join(dir, "bad_port.yaml"))
+	if !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 1 || schemaErr.Violations[0].Path != "server.port" {
+		t.Errorf("child Parse() error = %v, want a violation at server.port", err)
+	}
+	_, err = parent.Sub("server").Parse(filepath.Join(dir, "sub_port.yaml"))
+	if !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 1 || schemaErr.Violations[0].Path != "port" {
+		t.Errorf("sub Parse() error = %v, want a violation at port", err)
+	}
+
+	// aliases and required keys are scoped to the sub-tree
+	parent.Require("db.url")
+	parent.RegisterAlias("server.addr", "server.host")
+	server := parent.Sub("server")
+	if _, err := server.Parse(filepath.Join(dir, "sub_addr.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.GetString("host"); got != "sub.local" {
+		t.Errorf("sub host = %q, want sub.local through the alias", got)
+	}
+	var missing *MissingKeysError
+	_, err = parent.Sub("db").Parse(filepath.Join(dir, "sub_db.yaml"))
+	if !errors.As(err, &missing) || len(missing.Keys) != 1 || missing.Keys[0] != "url" {
+		t.Errorf("sub Parse() error = %v, want url missing", err)
+	}
+
+	var target struct{ Server struct{ Host string } }
+	if err := parent.Child().Unmarshal(&target); err == nil {
+		t.Error("child Unmarshal() succeeded, want the parent's validator failing")
+	}
+	if validator.calls != 1 {
+		t.Errorf("validator calls = %d, want 1 from the child", validator.calls)
+	}
+
+	// options of the child leave the parent as it was
+	parent.Child(WithSensitiveKeys("plugin.*")).Require("plugin.enabled")
+	if parent.isSensitive("plugin.enabled") {
+		t.Error("sensitive keys of the child leaked into the parent")
+	}
+	if len(parent.required) != 1 {
+		t.Errorf("parent required = %q, want the child's keys kept apart", parent.required)
+	}
+}
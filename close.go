@@ -0,0 +1,108 @@
+package viper
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// ErrClosed is returned by the loads, watches, overrides and rollbacks of a
+// closed parser
+var ErrClosed = errors.New("config parser closed")
+
+// Close stops every watch of p and of its children: the watchers of files
+// and directories and the pollers of sources and resolvers, waiting for
+// their goroutines to exit, along with the timers of expiring overrides. The
+// sources, resolvers and secret cache implementing io.Closer are closed,
+// except the resolvers a child inherits, and the children of p are closed
+// too. The getters keep serving the last config loaded, while the other
+// operations return ErrClosed, as does closing p again.
+func (p *Parser) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	children := p.children
+	p.children = nil
+	for _, top := range p.overrides {
+		for o := top; o != nil; o = o.prev {
+			if o.timer != nil {
+				o.timer.Stop()
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	p.watchMu.Lock()
+	for name, stop := range p.watches {
+		stop()
+		delete(p.watches, name)
+	}
+	p.watchMu.Unlock()
+
+	var errs []error
+	for _, c := range children {
+		if err := c.Close(); err != nil && !errors.Is(err, ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	if parent := p.parent; parent != nil {
+		parent.mu.Lock()
+		parent.children = slices.DeleteFunc(parent.children, func(c *Parser) bool { return c == p })
+		parent.mu.Unlock()
+	}
+
+	// backends shared by several of them are closed once
+	var closers []io.Closer
+	add := func(v interface{}) {
+		c, ok := v.(io.Closer)
+		if !ok || reflect.TypeOf(c).Comparable() && slices.Contains(closers, c) {
+			return
+		}
+		closers = append(closers, c)
+	}
+	var inherited map[string]Resolver
+	if parent := p.parent; parent != nil {
+		parent.mu.RLock()
+		inherited = maps.Clone(parent.resolvers)
+		parent.mu.RUnlock()
+	}
+	p.mu.RLock()
+	for _, s := range p.sources {
+		add(s.src)
+	}
+	for scheme, r := range p.resolvers {
+		// the resolvers of the parent are left to it
+		if pr, ok := inherited[scheme]; ok && reflect.TypeOf(r).Comparable() && pr == r {
+			continue
+		}
+		add(r)
+	}
+	add(p.refs)
+	p.mu.RUnlock()
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkOpen returns ErrClosed once p is closed. Callers hold p.mu.
+func (p *Parser) checkOpen() error {
+	if p.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// openForWatch returns ErrClosed once p is closed. It takes the read lock.
+func (p *Parser) openForWatch() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.checkOpen()
+}
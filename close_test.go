@@ -0,0 +1,136 @@
+package viper
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// closingSource is a watchable source recording when it stops and closes
+type closingSource struct {
+	stopped chan struct{}
+	closed  int
+}
+
+func (s *closingSource) Load(context.Context) (map[string]interface{}, error) {
+	return map[string]interface{}{"remote": true}, nil
+}
+
+func (s *closingSource) Watch(ctx context.Context, onChange func(), onError func(error)) {
+	<-ctx.Done()
+	close(s.stopped)
+}
+
+func (s *closingSource) Close() error {
+	s.closed++
+	return nil
+}
+
+// closingResolver is a resolver recording when it closes
+type closingResolver struct {
+	closed int
+}
+
+func (r *closingResolver) Resolve(context.Context, string) (string, error) { return "", nil }
+
+func (r *closingResolver) Close() error {
+	r.closed++
+	return nil
+}
+
+func TestParser_Close(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 8080\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	src := &closingSource{stopped: make(chan struct{})}
+	p := New(WithSource("remote", src), WithSource("mirror", src))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan struct{}, 10)
+	if err := p.Watch(configFile, func() { reloads <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WatchSource("remote", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.OverrideFor("port", 1, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	child := p.Child()
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-src.stopped:
+	default:
+		t.Error("the source poller is still running after Close")
+	}
+	if src.closed != 1 {
+		t.Errorf("source closed %d times, want once", src.closed)
+	}
+	if len(p.watches) != 0 || len(p.children) != 0 {
+		t.Errorf("watches = %v, children = %v after Close, want none", p.watches, p.children)
+	}
+	if got := child.Close(); !errors.Is(got, ErrClosed) {
+		t.Errorf("child Close() = %v, want the child closed with its parent", got)
+	}
+
+	replaceFile(t, configFile, "port: 9090\n")
+	select {
+	case <-reloads:
+		t.Error("a change was reloaded after Close")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := p.GetInt("port"); got != 1 {
+		t.Errorf("port = %d after Close, want the last config served", got)
+	}
+
+	if _, err := p.Parse(configFile); !errors.Is(err, ErrClosed) {
+		t.Errorf("Parse() = %v, want ErrClosed", err)
+	}
+	if err := p.Watch(configFile, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Watch() = %v, want ErrClosed", err)
+	}
+	if err := p.WatchDir(dir, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("WatchDir() = %v, want ErrClosed", err)
+	}
+	if err := p.WatchSource("remote", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("WatchSource() = %v, want ErrClosed", err)
+	}
+	if err := p.Override("port", 2); !errors.Is(err, ErrClosed) {
+		t.Errorf("Override() = %v, want ErrClosed", err)
+	}
+	if err := p.Rollback(1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Rollback() = %v, want ErrClosed", err)
+	}
+	if err := p.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close() = %v, want ErrClosed", err)
+	}
+}
+
+func TestParser_Close_Child(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 8080\n"})
+	src := &closingSource{stopped: make(chan struct{})}
+	resolver := &closingResolver{}
+	p := New(WithResolver("remote", resolver))
+	child := p.Child(WithSource("remote", src))
+	if err := child.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if src.closed != 1 {
+		t.Errorf("child source closed %d times, want once", src.closed)
+	}
+	if resolver.closed != 0 {
+		t.Error("the resolver inherited from the parent was closed with the child")
+	}
+	if len(p.children) != 0 {
+		t.Error("the closed child is still refreshed by its parent")
+	}
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Errorf("Parse() of the parent = %v after closing a child", err)
+	}
+}
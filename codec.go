@@ -0,0 +1,138 @@
+package viper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// WithCodec registers a codec for the given config type, replacing the
+// built-in one if any. Types without a registered codec are decoded by
+// viper itself.
+func WithCodec(typ string, codec viper.Codec) Option {
+	return func(p *Parser) {
+		p.codecs[strings.ToLower(typ)] = codec
+	}
+}
+
+// defaultCodecs returns the codecs the parser decodes itself, so the key
+// case and the typing of scalar values are under its control
+func (p *Parser) defaultCodecs() map[string]viper.Codec {
+	yml := &yamlCodec{p: p}
+	js := &jsonCodec{p: p}
+	codecs := map[string]viper.Codec{
+		"yaml":  yml,
+		"yml":   yml,
+		"json":  js,
+		"jsonc": &jsoncCodec{json: js},
+		"toml":  &tomlCodec{p: p},
+		"cue":   &cueCodec{p: p},
+		"xml":   &xmlCodec{p: p},
+	}
+	ini := &iniCodec{p: p}
+	hcl := &hclCodec{p: p}
+	props := &propertiesCodec{p: p}
+	env := &dotenvCodec{p: p}
+	for typ, codec := range map[string]viper.Codec{
+		"ini": ini, "hcl": hcl, "tfvars": hcl,
+		"properties": props, "props": props, "prop": props,
+		"env": env, "dotenv": env,
+	} {
+		codecs[typ] = codec
+	}
+	return codecs
+}
+
+// readFile decodes a single config file into a settings map without touching
+// the parser's own viper instance
+func (p *Parser) readFile(configFile, typ string) (map[string]interface{}, error) {
+	b, err := p.readSourceFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	p.pending.addSource(configFile, b)
+	return p.decode(b, typ, configFile)
+}
+
+// decode runs the preprocessors over raw config bytes and decodes them as
+// the given type. The source names where the bytes come from in error
+// reports and selects the preprocessors of its extensions.
+func (p *Parser) decode(b []byte, typ, source string) (map[string]interface{}, error) {
+	b, err := p.preprocess(b, source)
+	if err != nil {
+		return nil, err
+	}
+	if p.limits.MaxSize > 0 && int64(len(b)) > p.limits.MaxSize {
+		return nil, &LimitError{Limit: "size", Max: p.limits.MaxSize}
+	}
+	settings, err := p.decodeRaw(b, typ, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkLimits(settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (p *Parser) decodeRaw(b []byte, typ, source string) (map[string]interface{}, error) {
+	if codec, ok := p.codecs[strings.ToLower(typ)]; ok {
+		settings := make(map[string]interface{})
+		yc, isYAML := codec.(*yamlCodec)
+		if !isYAML {
+			if err := p.checkDuplicates(codec.Decode(b, settings), source); err != nil {
+				return nil, err
+			}
+			return settings, nil
+		}
+		doc, err := yc.decodeDocument(b, settings)
+		if err := p.checkDuplicates(err, source); err != nil {
+			return nil, err
+		}
+		if doc != nil {
+			p.pending.documents[source] = doc
+		}
+		return settings, nil
+	}
+
+	// Fall back to the formats viper supports natively
+	v := viper.New()
+	if typ != "" {
+		v.SetConfigType(typ)
+	}
+	if err := v.ReadConfig(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+type jsonCodec struct {
+	p *Parser
+}
+
+func (c *jsonCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func (c *jsonCodec) Decode(b []byte, v map[string]interface{}) error {
+	if c.p.useNumber {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		if dec.More() {
+			return errors.New("invalid character after top-level value")
+		}
+		exactNumbers(v)
+	} else if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if c.p.duplicateKeyPolicy() == DuplicateKeysAllow {
+		return nil
+	}
+	return jsonDuplicates(b)
+}
@@ -0,0 +1,140 @@
+package viper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConflictPolicy defines how the parser reacts to sources disagreeing on
+// the type of a key, one defining a map where another defines a scalar or
+// a list
+type ConflictPolicy int
+
+const (
+	// ConflictsWarn logs the conflicts and lets the source merged last win
+	ConflictsWarn ConflictPolicy = iota
+	// ConflictsError makes Parse fail with a *ConflictError
+	ConflictsError
+	// ConflictsAllow silently lets the source merged last win
+	ConflictsAllow
+)
+
+// WithConflictPolicy sets the policy applied to merge conflicts. Whatever
+// the policy, the conflicts of the last load are reported by Conflicts.
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(p *Parser) {
+		p.conflictPolicy = policy
+	}
+}
+
+// ConflictSide is one of the values of a merge conflict
+type ConflictSide struct {
+	// Location is the source the value comes from
+	Location Location
+	// Kind is the type of the value: map, list, string, number, bool or
+	// null
+	Kind string
+}
+
+// MergeConflict is a key holding a map in one source and a scalar or a list
+// in another
+type MergeConflict struct {
+	Key string
+	// Old is the value replaced, New the value replacing it
+	Old, New ConflictSide
+}
+
+func (c MergeConflict) String() string {
+	return fmt.Sprintf("%s: %s from %s replaced by %s from %s", c.Key, c.Old.Kind, c.Old.Location, c.New.Kind, c.New.Location)
+}
+
+// ConflictError is returned when merging the sources produced conflicts
+type ConflictError struct {
+	Conflicts []MergeConflict
+}
+
+func (e *ConflictError) Error() string {
+	msgs := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		msgs[i] = c.String()
+	}
+	return "merge conflicts: " + strings.Join(msgs, "; ")
+}
+
+// Conflicts returns the merge conflicts of the last load
+func (p *Parser) Conflicts() []MergeConflict {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]MergeConflict(nil), p.info.conflicts...)
+}
+
+// addConflict records that the value at key, old, is replaced by new of an
+// incompatible type
+func (l *loadInfo) addConflict(key string, old, new interface{}) {
+	l.conflicts = append(l.conflicts, MergeConflict{
+		Key: key,
+		Old: ConflictSide{Location: Location{File: l.sourceOf(key, old)}, Kind: conflictKind(old)},
+		New: ConflictSide{Location: Location{File: l.sourceOf(key, new)}, Kind: conflictKind(new)},
+	})
+}
+
+// sourceOf returns the source the value at key came from, as recorded by
+// setOrigin, taking the first key of maps
+func (l *loadInfo) sourceOf(key string, v interface{}) string {
+	key = strings.ToLower(key)
+	if m, ok := toStringMap(v); ok {
+		leaves := flatten(lowerKeys(m))
+		keys := make([]string, 0, len(leaves))
+		for k := range leaves {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if source, ok := l.origins[key+"."+k]; ok {
+				return source
+			}
+		}
+		return ""
+	}
+	return l.origins[key]
+}
+
+func conflictKind(v interface{}) string {
+	if _, ok := toStringMap(v); ok {
+		return "map"
+	}
+	switch v.(type) {
+	case nil:
+		return "null"
+	case []interface{}:
+		return "list"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	}
+	if isNumber(v) {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// checkConflicts applies the conflict policy to the conflicts of the load
+// in progress
+func (p *Parser) checkConflicts() error {
+	if len(p.pending.conflicts) == 0 {
+		return nil
+	}
+	sort.SliceStable(p.pending.conflicts, func(i, j int) bool {
+		return p.pending.conflicts[i].Key < p.pending.conflicts[j].Key
+	})
+	err := &ConflictError{Conflicts: p.pending.conflicts}
+	switch p.mergeConflictPolicy() {
+	case ConflictsError:
+		return err
+	case ConflictsWarn:
+		p.logger.Warn("config sources conflict", "conflicts", err.Error())
+	}
+	return nil
+}
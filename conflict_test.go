@@ -0,0 +1,82 @@
+package viper
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParser_Conflicts(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml": "server:\n  port: 8080\nlog: debug\nname: app\n",
+		"prod.yaml": "server: localhost:80\nlog:\n  level: info\nname: prod\n",
+	})
+	base, prod := filepath.Join(dir, "base.yaml"), filepath.Join(dir, "prod.yaml")
+	want := []MergeConflict{
+		{
+			Key: "log",
+			Old: ConflictSide{Location: Location{File: base}, Kind: "string"},
+			New: ConflictSide{Location: Location{File: prod}, Kind: "map"},
+		},
+		{
+			Key: "server",
+			Old: ConflictSide{Location: Location{File: base}, Kind: "map"},
+			New: ConflictSide{Location: Location{File: prod}, Kind: "string"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		policy  ConflictPolicy
+		wantErr bool
+		wantLog bool
+	}{
+		{"warn", ConflictsWarn, false, true},
+		{"error", ConflictsError, true, false},
+		{"allow", ConflictsAllow, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			p := New(WithConflictPolicy(tt.policy), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+			_, err := p.ParseAll(base, prod)
+			if tt.wantErr {
+				var cerr *ConflictError
+				if !errors.As(err, &cerr) {
+					t.Fatalf("ParseAll() error = %v, want a *ConflictError", err)
+				}
+				if !reflect.DeepEqual(cerr.Conflicts, want) {
+					t.Errorf("conflicts = %+v, want %+v", cerr.Conflicts, want)
+				}
+				if !strings.Contains(err.Error(), "server: map from "+base+" replaced by string from "+prod) {
+					t.Errorf("error = %q, want both sources named", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Conflicts(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Conflicts() = %+v, want %+v", got, want)
+			}
+			if got := p.GetString("server"); got != "localhost:80" {
+				t.Errorf("server = %q, want the last source to win", got)
+			}
+			if logged := strings.Contains(logs.String(), "config sources conflict"); logged != tt.wantLog {
+				t.Errorf("logged = %v, want %v: %s", logged, tt.wantLog, logs.String())
+			}
+		})
+	}
+
+	p := New(WithConflictPolicy(ConflictsError))
+	if _, err := p.ParseAll(base, base); err != nil {
+		t.Errorf("ParseAll() of compatible files error = %v", err)
+	}
+	if got := p.Conflicts(); len(got) != 0 {
+		t.Errorf("Conflicts() = %v, want none", got)
+	}
+}
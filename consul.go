@@ -0,0 +1,190 @@
+package viper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultConsulWait is how long a blocking query of a Consul watch waits
+// for a change before it is renewed
+const DefaultConsulWait = 5 * time.Minute
+
+// consulRetryDelay is the pause of a Consul watch after a failed query
+var consulRetryDelay = 5 * time.Second
+
+// ConsulSource is a source reading the keys under a prefix of the Consul KV
+// store. The key app/server/port under the prefix app becomes server.port,
+// holding the raw value as a string. Keys ending with a slash, which Consul
+// uses as folders, are skipped.
+type ConsulSource struct {
+	addr       string
+	prefix     string
+	token      string
+	datacenter string
+	client     *http.Client
+	wait       time.Duration
+}
+
+// ConsulOption configures a ConsulSource
+type ConsulOption func(*ConsulSource)
+
+// WithConsulToken sets the ACL token sent with every request
+func WithConsulToken(token string) ConsulOption {
+	return func(c *ConsulSource) {
+		c.token = token
+	}
+}
+
+// WithConsulDatacenter reads the keys of the given datacenter instead of
+// the one of the agent
+func WithConsulDatacenter(dc string) ConsulOption {
+	return func(c *ConsulSource) {
+		c.datacenter = dc
+	}
+}
+
+// WithConsulClient sets the HTTP client sending the requests, as for TLS
+// client certificates. Its timeout must exceed the blocking query wait.
+func WithConsulClient(client *http.Client) ConsulOption {
+	return func(c *ConsulSource) {
+		c.client = client
+	}
+}
+
+// WithConsulWait sets how long the blocking queries of a watch wait for a
+// change, DefaultConsulWait by default
+func WithConsulWait(d time.Duration) ConsulOption {
+	return func(c *ConsulSource) {
+		c.wait = d
+	}
+}
+
+// ConsulKV returns a source reading the keys under prefix from the Consul
+// agent at addr, as in "http://127.0.0.1:8500". Register it with WithSource
+// and watch it with WatchSource, which uses blocking queries.
+func ConsulKV(addr, prefix string, opts ...ConsulOption) *ConsulSource {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	c := &ConsulSource{
+		addr:   strings.TrimSuffix(addr, "/"),
+		prefix: strings.Trim(prefix, "/"),
+		client: http.DefaultClient,
+		wait:   DefaultConsulWait,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type consulPair struct {
+	Key   string `json:"Key"`
+	Value []byte `json:"Value"`
+}
+
+// Load reads the keys under the prefix
+func (c *ConsulSource) Load(ctx context.Context) (map[string]interface{}, error) {
+	pairs, _, err := c.query(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]interface{})
+	for _, pair := range pairs {
+		rest := strings.TrimPrefix(pair.Key, c.folder())
+		if rest == "" || strings.HasSuffix(rest, "/") {
+			continue
+		}
+		setPath(settings, strings.ReplaceAll(rest, "/", "."), string(pair.Value))
+	}
+	return settings, nil
+}
+
+// Watch long polls the prefix with blocking queries, calling onChange
+// whenever its index moves
+func (c *ConsulSource) Watch(ctx context.Context, onChange func(), onError func(error)) {
+	var index uint64
+	for {
+		_, next, err := c.query(ctx, index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			onError(err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(consulRetryDelay):
+			}
+			continue
+		}
+		if index != 0 && next != index {
+			onChange()
+		}
+		// an index going backwards means the store was reset
+		if next < index {
+			next = 0
+		}
+		index = next
+	}
+}
+
+// query lists the pairs under the prefix, blocking until the index moves
+// past index when it is not zero, and returns the index of the result
+func (c *ConsulSource) query(ctx context.Context, index uint64) ([]consulPair, uint64, error) {
+	params := url.Values{"recurse": {"true"}}
+	if c.datacenter != "" {
+		params.Set("dc", c.datacenter)
+	}
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", c.wait.String())
+	}
+	u := c.addr + "/v1/kv/" + c.folder() + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// no key under the prefix
+		return nil, next, nil
+	default:
+		return nil, 0, fmt.Errorf("consul: reading %q: %s", c.prefix, resp.Status)
+	}
+	b, err := readBody(ctx, resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: reading %q: %w", c.prefix, err)
+	}
+	var pairs []consulPair
+	if err := json.Unmarshal(b, &pairs); err != nil {
+		return nil, 0, fmt.Errorf("consul: reading %q: %w", c.prefix, err)
+	}
+	return pairs, next, nil
+}
+
+// folder returns the prefix the keys are listed under, ending with a slash
+// so sibling keys sharing its first letters are left out
+func (c *ConsulSource) folder() string {
+	if c.prefix == "" {
+		return ""
+	}
+	return c.prefix + "/"
+}
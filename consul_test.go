@@ -0,0 +1,123 @@
+package viper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsul serves a KV store answering blocking queries
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   uint64
+	pairs   map[string]string
+	changed chan struct{}
+}
+
+func newFakeConsul(pairs map[string]string) *fakeConsul {
+	return &fakeConsul{index: 1, pairs: pairs, changed: make(chan struct{})}
+}
+
+func (f *fakeConsul) set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pairs[key] = value
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Consul-Token") != "secret" {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	if index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); index >= f.index {
+		changed := f.changed
+		f.mu.Unlock()
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return
+		}
+		f.mu.Lock()
+	}
+	defer f.mu.Unlock()
+
+	prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	var pairs []consulPair
+	for k, v := range f.pairs {
+		if strings.HasPrefix(k, prefix) {
+			pairs = append(pairs, consulPair{Key: k, Value: []byte(v)})
+		}
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	if len(pairs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(pairs)
+}
+
+func TestConsulKV(t *testing.T) {
+	consul := newFakeConsul(map[string]string{
+		"app/":               "",
+		"app/server/port":    "9090",
+		"app/log/level":      "info",
+		"application/server": "other app",
+	})
+	srv := httptest.NewServer(consul)
+	defer srv.Close()
+
+	dir := writeFiles(t, map[string]string{"config.yaml": "server:\n  host: localhost\n  port: 8080\n"})
+	src := ConsulKV(srv.URL, "app/", WithConsulToken("secret"), WithConsulWait(time.Second))
+	p := New(WithSource("consul", src))
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetInt("server.port"); got != 9090 {
+		t.Errorf("server.port = %v, want the Consul value 9090", got)
+	}
+	if got := p.GetString("server.host"); got != "localhost" {
+		t.Errorf("server.host = %q, want the file value", got)
+	}
+	if got := p.GetString("log.level"); got != "info" {
+		t.Errorf("log.level = %q, want info", got)
+	}
+
+	reloaded := make(chan struct{}, 1)
+	if err := p.WatchSource("consul", func() {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch("consul")
+
+	// let the watch learn the current index before changing a key
+	time.Sleep(50 * time.Millisecond)
+	consul.set("app/log/level", "debug")
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reload after a Consul change")
+	}
+	if got := p.GetString("log.level"); got != "debug" {
+		t.Errorf("log.level = %q after the change, want debug", got)
+	}
+
+	if _, err := New(WithSource("consul", ConsulKV(srv.URL, "app"))).Parse(filepath.Join(dir, "config.yaml")); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Parse() without a token error = %v, want a 403", err)
+	}
+}
@@ -0,0 +1,85 @@
+package viper
+
+import (
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	cueerrors "cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	cueparser "cuelang.org/go/cue/parser"
+)
+
+// cueCodec decodes CUE files. The document is evaluated with its
+// constraints and definitions, which stay out of the settings; a value
+// breaking a constraint, or left incomplete like port: int, fails with a
+// *SchemaError.
+type cueCodec struct {
+	p *Parser
+}
+
+func (c *cueCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	val := cuecontext.New().Encode(v)
+	if err := val.Err(); err != nil {
+		return nil, fmt.Errorf("cue: %w", err)
+	}
+	node := val.Syntax()
+	if s, ok := node.(*ast.StructLit); ok {
+		// write the fields at the top level, without the braces
+		node = &ast.File{Decls: s.Elts}
+	}
+	b, err := format.Node(node)
+	if err != nil {
+		return nil, fmt.Errorf("cue: %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+func (c *cueCodec) Decode(b []byte, v map[string]interface{}) error {
+	f, err := cueparser.ParseFile("", b)
+	if err != nil {
+		return fmt.Errorf("cue: %w", err)
+	}
+	val := cuecontext.New().BuildFile(f)
+	if err := val.Err(); err != nil {
+		return cueViolations(err)
+	}
+	if err := val.Validate(cue.Concrete(true)); err != nil {
+		return cueViolations(err)
+	}
+	if val.IncompleteKind() != cue.StructKind {
+		return fmt.Errorf("cue: the document root must be a struct")
+	}
+	var settings map[string]interface{}
+	if err := val.Decode(&settings); err != nil {
+		return fmt.Errorf("cue: %w", err)
+	}
+	for k, val := range settings {
+		v[k] = val
+	}
+	return nil
+}
+
+// cueViolations reports the errors of a CUE evaluation as schema
+// violations, with the lines of the values and constraints involved
+func cueViolations(err error) error {
+	errs := cueerrors.Errors(err)
+	violations := make([]SchemaViolation, 0, len(errs))
+	for _, e := range errs {
+		format, args := e.Msg()
+		msg := fmt.Sprintf(format, args...)
+		var lines []string
+		for _, pos := range cueerrors.Positions(e) {
+			lines = append(lines, fmt.Sprint(pos.Line()))
+		}
+		if len(lines) == 1 {
+			msg += " (line " + lines[0] + ")"
+		} else if len(lines) > 1 {
+			msg += " (lines " + strings.Join(lines, ", ") + ")"
+		}
+		violations = append(violations, SchemaViolation{Path: strings.Join(e.Path(), "."), Message: msg})
+	}
+	return &SchemaError{Violations: violations}
+}
@@ -0,0 +1,74 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_CUE(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.cue": `
+#Server: {
+	host: string | *"localhost"
+	port: int & >0 & <65536
+	_internal: true
+}
+server: #Server & {port: 8080}
+replicas: 3
+ratio: replicas / 2
+`})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.cue")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"server", map[string]interface{}{"host": "localhost", "port": 8080}},
+		{"ratio", 1.5},
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.path); !jsonEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if p.Get("#server") != nil {
+		t.Error("the definitions are part of the settings")
+	}
+
+	violations := []struct {
+		name    string
+		content string
+		path    string
+		want    string
+	}{
+		{"bound", "#S: {port: int & <65536}\nserver: #S & {port: 70000}\n", "server.port", "(lines 1, 2)"},
+		{"conflict", "name: \"a\"\nname: \"b\"\n", "name", "conflicting values"},
+		{"incomplete", "server: {port: int}\n", "server.port", "incomplete value int"},
+		{"closed", "#S: {port: int}\nserver: #S & {prot: 1}\n", "server.prot", "not allowed"},
+	}
+	for _, tt := range violations {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"bad.cue": tt.content})
+			_, err := New().Parse(filepath.Join(dir, "bad.cue"))
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("Parse() error = %v, want a *SchemaError", err)
+			}
+			v := schemaErr.Violations[0]
+			if v.Path != tt.path || !strings.Contains(v.Message, tt.want) {
+				t.Errorf("violation = %v, want %s to mention %q", v, tt.path, tt.want)
+			}
+		})
+	}
+
+	t.Run("syntax", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{"bad.cue": "server: {\n"})
+		_, err := New().Parse(filepath.Join(dir, "bad.cue"))
+		if err == nil || !strings.Contains(err.Error(), "cue: ") {
+			t.Errorf("Parse() error = %v, want a cue syntax error", err)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package viper
+
+import (
+	"sync"
+	"time"
+)
+
+// WithWatchDebounce waits for the watched files and directories to be quiet
+// for d before reloading them. Editors and ConfigMap updates save a file
+// with a burst of writes, creates and renames; the burst then triggers a
+// single reload, once it is over, reading the final content rather than a
+// truncated or half-written file. WithMinReloadInterval still applies to
+// the reloads that follow.
+func WithWatchDebounce(d time.Duration) Option {
+	return func(p *Parser) {
+		p.watchDebounce = d
+	}
+}
+
+// debouncer runs a function once calls have stopped for a while
+type debouncer struct {
+	wait time.Duration
+
+	mu    sync.Mutex
+	fn    func()
+	timer *time.Timer
+}
+
+// run schedules fn for when wait has elapsed without another call, each
+// call postponing the one scheduled before. fn runs right away when wait is
+// not positive.
+func (d *debouncer) run(fn func()) {
+	if d.wait <= 0 {
+		fn()
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fn = fn
+	if d.timer != nil && d.timer.Stop() {
+		d.timer.Reset(d.wait)
+		return
+	}
+	d.timer = time.AfterFunc(d.wait, func() {
+		d.mu.Lock()
+		fn := d.fn
+		d.mu.Unlock()
+		fn()
+	})
+}
+
+// stop cancels the scheduled call, if any
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
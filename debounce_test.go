@@ -0,0 +1,82 @@
+package viper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithWatchDebounce(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.json": `{"rev": 0}`})
+	configFile := filepath.Join(dir, "config.json")
+
+	debounce := 200 * time.Millisecond
+	p := New(WithWatchDebounce(debounce))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	var reloads int32
+	if err := p.Watch(configFile, func() { atomic.AddInt32(&reloads, 1) }); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch(configFile)
+
+	// an editor saving through a temporary file, several times in a row
+	for i := 1; i <= 5; i++ {
+		tmp := configFile + ".tmp"
+		if err := os.WriteFile(tmp, []byte(fmt.Sprintf(`{"rev": %d}`, i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, configFile); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := p.GetInt("rev"); got != 0 {
+		t.Errorf("rev = %d during the burst, want the reload postponed", got)
+	}
+
+	deadline := time.Now().Add(5 * debounce)
+	for p.GetInt("rev") != 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := p.GetInt("rev"); got != 5 {
+		t.Fatalf("rev = %d, want the final content 5", got)
+	}
+	time.Sleep(debounce)
+	if got := atomic.LoadInt32(&reloads); got != 1 {
+		t.Errorf("applied %d reloads, want 1 for the burst", got)
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	var calls int32
+	d := &debouncer{wait: 50 * time.Millisecond}
+	for i := 0; i < 5; i++ {
+		d.run(func() { atomic.AddInt32(&calls, 1) })
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Fatalf("calls = %d during the burst, want 0", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("calls = %d after the burst, want 1", got)
+	}
+
+	d.run(func() { atomic.AddInt32(&calls, 1) })
+	d.stop()
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("calls = %d after stop, want the scheduled call cancelled", got)
+	}
+
+	(&debouncer{}).run(func() { atomic.AddInt32(&calls, 1) })
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("calls = %d without a wait, want the call run right away", got)
+	}
+}
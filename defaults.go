@@ -0,0 +1,148 @@
+package viper
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// WithDefaults seeds default values, available before Parse runs. Nested
+// maps set the defaults of the keys they contain, so a default map never
+// hides the keys of the config file.
+//
+// Values are looked up in this order, the first layer defining a key wins:
+// overrides, flags set on the command line, config files, environment
+// variables, defaults.
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(p *Parser) {
+		for k, v := range flatten(defaults) {
+			p.v.SetDefault(k, v)
+		}
+	}
+}
+
+// SetDefault sets the default value of a key, used when no other layer
+// defines it
+func (p *Parser) SetDefault(path string, value interface{}) {
+	p.mu.Lock()
+	path = p.normalizePath(path)
+	if m, ok := toStringMap(value); ok {
+		for k, v := range flatten(m) {
+			p.v.SetDefault(path+"."+k, v)
+		}
+	} else {
+		p.v.SetDefault(path, value)
+	}
+	p.bindEnv()
+	p.version++
+	p.mu.Unlock()
+	p.changed()
+}
+
+// DefaultTagHookFunc returns a decode hook honoring the `default:"value"`
+// tags of struct fields absent from the config, or set to null. The
+// default is decoded like a value of the config would be, so
+// `default:"30s"` fills a time.Duration and `default:"a,b"` a slice. Struct
+// fields absent from the config get the defaults of their own fields, as do
+// the structs held by slices and maps; nil pointers are left nil.
+//
+// Parser.Unmarshal uses it. It can also be passed to Config.Viper.Unmarshal
+// with viper.DecodeHook.
+func DefaultTagHookFunc() mapstructure.DecodeHookFunc {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		m, ok := data.(map[string]interface{})
+		if !ok || to.Kind() != reflect.Struct || to == timeType {
+			return data, nil
+		}
+		return applyDefaultTags(m, to), nil
+	}
+}
+
+// applyDefaultTags returns m with the defaults of the fields of t it lacks.
+// Keys are matched the same way mapstructure does.
+func applyDefaultTags(m map[string]interface{}, t reflect.Type) map[string]interface{} {
+	out := m
+	copied := false
+	set := func(k string, v interface{}) {
+		if !copied {
+			// copy on first write so the source settings stay untouched
+			out = make(map[string]interface{}, len(m)+1)
+			for k, v := range m {
+				out[k] = v
+			}
+			copied = true
+		}
+		out[k] = v
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+		name := field.Name
+		if tag[0] != "" {
+			name = tag[0]
+		}
+		if name == "-" || slices.Contains(tag[1:], "remain") {
+			continue
+		}
+		if (field.Anonymous && tag[0] == "") || slices.Contains(tag[1:], "squash") {
+			if field.Type.Kind() == reflect.Struct {
+				for k, v := range applyDefaultTags(out, field.Type) {
+					if _, ok := out[k]; !ok {
+						set(k, v)
+					}
+				}
+			}
+			continue
+		}
+
+		k, v := lookupField(out, name)
+		if v != nil {
+			continue
+		}
+		if k == "" {
+			k = name
+		}
+		if def, ok := field.Tag.Lookup("default"); ok {
+			set(k, def)
+		} else if field.Type.Kind() == reflect.Struct && field.Type != timeType && hasDefaultTags(field.Type) {
+			// decoded from an empty section, filled by the hook in turn
+			set(k, map[string]interface{}{})
+		}
+	}
+	return out
+}
+
+// lookupField returns the key of m matching the field name ignoring case
+// and its value
+func lookupField(m map[string]interface{}, name string) (string, interface{}) {
+	if v, ok := m[name]; ok {
+		return name, v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return k, v
+		}
+	}
+	return "", nil
+}
+
+// hasDefaultTags reports whether a field of t, or of the structs it embeds
+// or holds by value, has a default tag
+func hasDefaultTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := field.Tag.Lookup("default"); ok {
+			return true
+		}
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType && hasDefaultTags(field.Type) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package viper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDefaults(t *testing.T) {
+	t.Setenv("DEFAULTSTEST_LOG_LEVEL", "warn")
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  host: db.local\nlog:\n  format: json\n"})
+
+	p := New(WithEnvPrefix("defaultstest"), WithDefaults(map[string]interface{}{
+		"db": map[string]interface{}{
+			"host": "localhost",
+			"port": 5432,
+		},
+		"log": map[string]interface{}{
+			"level":  "info",
+			"format": "text",
+		},
+		"name": "app",
+	}))
+	if got := p.GetString("name"); got != "app" {
+		t.Errorf("name before Parse = %q, want the default", got)
+	}
+
+	cfg, err := p.Parse(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetDefault("cache", map[string]interface{}{"ttl": "1m"})
+	p.SetDefault("name", "service")
+	if err := p.Override("log.format", "logfmt"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"db.host", "db.local"},  // config file over default
+		{"db.port", "5432"},      // default
+		{"log.level", "warn"},    // env over default
+		{"log.format", "logfmt"}, // override over config file
+		{"name", "service"},      // default replaced with SetDefault
+		{"cache.ttl", "1m"},      // nested default set with SetDefault
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := p.GetString(tt.path); got != tt.want {
+				t.Errorf("GetString(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	db, ok := cfg.Raw["db"].(map[string]interface{})
+	if !ok || db["port"] != 5432 {
+		t.Errorf("Raw db = %v, want the default port merged in", cfg.Raw["db"])
+	}
+}
+
+func TestDefaults_ConfigOverEnv(t *testing.T) {
+	t.Setenv("DEFAULTSTEST_DB_HOST", "db.env")
+	t.Setenv("DEFAULTSTEST_DB_PORT", "6543")
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  host: db.local\n"})
+	p := New(WithEnvPrefix("defaultstest"), WithDefaults(map[string]interface{}{"db": map[string]interface{}{"host": "localhost", "port": 5432}}))
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("db.host"); got != "db.local" {
+		t.Errorf("db.host = %q, want the config file over the env value", got)
+	}
+	if got := p.GetInt("db.port"); got != 6543 {
+		t.Errorf("db.port = %d, want the env value over the default", got)
+	}
+	if got := p.Origin("db.host"); got != filepath.Join(dir, "config.yaml") {
+		t.Errorf("Origin(db.host) = %q, want the config file", got)
+	}
+}
+
+func TestParser_Unmarshal_defaultTags(t *testing.T) {
+	type backend struct {
+		Host   string
+		Weight int `default:"1"`
+	}
+	type tls struct {
+		Enabled bool   `default:"true"`
+		MinTLS  string `mapstructure:"min_version" default:"1.2"`
+	}
+	type common struct {
+		Region string `default:"eu-west-1"`
+	}
+	type config struct {
+		common   `mapstructure:",squash"`
+		Name     string        `default:"app"`
+		Port     int           `default:"8080"`
+		Timeout  time.Duration `default:"30s"`
+		Tags     []string      `default:"a,b"`
+		Level    string        `default:"info"`
+		TLS      tls
+		Proxy    *tls
+		Backends []backend
+		Pools    map[string]backend
+	}
+	p := New()
+	if _, err := p.ParseBytes([]byte(`
+port: 9090
+level:
+tls:
+  min_version: "1.3"
+backends:
+  - host: a
+  - host: b
+    weight: 5
+pools:
+  main:
+    host: c
+`), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	var cfg config
+	if err := p.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := config{
+		common:   common{Region: "eu-west-1"},
+		Name:     "app",
+		Port:     9090,
+		Timeout:  30 * time.Second,
+		Tags:     []string{"a", "b"},
+		Level:    "info",
+		TLS:      tls{Enabled: true, MinTLS: "1.3"},
+		Backends: []backend{{Host: "a", Weight: 1}, {Host: "b", Weight: 5}},
+		Pools:    map[string]backend{"main": {Host: "c", Weight: 1}},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", cfg, want)
+	}
+	if got := p.Get("name"); got != nil {
+		t.Errorf("name = %v, want the tag defaults kept out of the config", got)
+	}
+
+	var bad struct {
+		Port int `default:"eighty"`
+	}
+	if err := New().Unmarshal(&bad); err == nil {
+		t.Error("Unmarshal() with an invalid default did not fail")
+	}
+}
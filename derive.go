@@ -0,0 +1,134 @@
+package viper
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cast"
+)
+
+// View gives read access to the config while a derived value is computed
+type View interface {
+	Get(path string) interface{}
+	GetString(path string) string
+	GetInt(path string) int
+	GetBool(path string) bool
+	GetStringMap(path string) map[string]interface{}
+	GetStringSlice(path string) []string
+}
+
+// derivedKeys holds the derived keys and their values for a config version
+type derivedKeys struct {
+	fns   map[string]func(View) (interface{}, error)
+	order []string
+
+	mu      sync.Mutex
+	version uint64
+	values  map[string]interface{}
+}
+
+// Derive registers a key computed by fn from the rest of the config, like a
+// DSN assembled from a host, a port and a password. The value is served by
+// the getters on top of every source but the overrides, recomputed lazily
+// whenever a load, a reload or an override changes the config, and its
+// origin is "derived". fn may read other derived keys; cycles fail. When
+// fn fails, the error is logged and the key reads as nil.
+func (p *Parser) Derive(key string, fn func(c View) (interface{}, error)) {
+	key = strings.ToLower(p.normalizePath(key))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.derived.fns == nil {
+		p.derived.fns = make(map[string]func(View) (interface{}, error))
+	}
+	if _, exists := p.derived.fns[key]; !exists {
+		p.derived.order = append(p.derived.order, key)
+	}
+	p.derived.fns[key] = fn
+	p.version++
+}
+
+// derivedValue returns the value of path when it is a derived key. Callers
+// hold the read lock.
+func (p *Parser) derivedValue(path string) (interface{}, bool) {
+	key := strings.ToLower(path)
+	if _, ok := p.derived.fns[key]; !ok {
+		return nil, false
+	}
+
+	d := &p.derived
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.values == nil || d.version != p.version {
+		dv := &derivation{p: p, values: make(map[string]interface{}), state: make(map[string]bool)}
+		for _, k := range d.order {
+			dv.cycle = nil
+			dv.value(k, nil)
+		}
+		d.values, d.version = dv.values, p.version
+	}
+	return d.values[key], true
+}
+
+// derivation computes the derived keys of a config version, each once
+type derivation struct {
+	p      *Parser
+	values map[string]interface{}
+	// state holds false for the keys being computed, true once done
+	state map[string]bool
+	// cycle is the cycle found while computing the current key
+	cycle error
+}
+
+// value computes key, logging the failures
+func (dv *derivation) value(key string, path []string) interface{} {
+	if done, seen := dv.state[key]; seen {
+		if !done && dv.cycle == nil {
+			dv.cycle = fmt.Errorf("derived key cycle: %s", strings.Join(append(path, key), " -> "))
+		}
+		return dv.values[key]
+	}
+	dv.state[key] = false
+	path = append(path, key)
+	v, err := dv.p.derived.fns[key](derivationView{dv: dv, path: path})
+	if err == nil {
+		err = dv.cycle
+	}
+	dv.state[key] = true
+	if err != nil {
+		dv.p.logger.Error("cannot derive config key", "key", key, "error", err)
+		return nil
+	}
+	dv.values[key] = v
+	return v
+}
+
+// derivationView is the View passed to the function computing a derived
+// key, reading the other derived keys from the derivation in progress
+type derivationView struct {
+	dv   *derivation
+	path []string
+}
+
+func (v derivationView) Get(path string) interface{} {
+	p := v.dv.p
+	return p.lookup(p.normalizePath(path), func(key string) (interface{}, bool) {
+		key = strings.ToLower(key)
+		if _, ok := p.derived.fns[key]; !ok {
+			return nil, false
+		}
+		return v.dv.value(key, v.path), true
+	})
+}
+
+func (v derivationView) GetString(path string) string { return cast.ToString(v.Get(path)) }
+func (v derivationView) GetInt(path string) int       { return cast.ToInt(v.Get(path)) }
+func (v derivationView) GetBool(path string) bool     { return cast.ToBool(v.Get(path)) }
+
+func (v derivationView) GetStringMap(path string) map[string]interface{} {
+	return cast.ToStringMap(v.Get(path))
+}
+
+func (v derivationView) GetStringSlice(path string) []string {
+	return cast.ToStringSlice(v.Get(path))
+}
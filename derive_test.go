@@ -0,0 +1,76 @@
+package viper
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_Derive(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  host: localhost\n  port: 5432\n  user: app\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	p := New()
+	calls := 0
+	p.Derive("db.dsn", func(c View) (interface{}, error) {
+		calls++
+		return fmt.Sprintf("postgres://%s@%s/%s", c.GetString("db.user"), c.GetString("db.addr"), c.GetString("db.name")), nil
+	})
+	p.Derive("db.addr", func(c View) (interface{}, error) {
+		return fmt.Sprintf("%s:%d", c.GetString("db.host"), c.GetInt("db.port")), nil
+	})
+	p.Derive("db.name", func(View) (interface{}, error) {
+		return "main", nil
+	})
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.GetString("db.dsn"); got != "postgres://app@localhost:5432/main" {
+		t.Errorf("db.dsn = %q", got)
+	}
+	p.GetString("DB.DSN")
+	if calls != 1 {
+		t.Errorf("derivation ran %d times, want once per config version", calls)
+	}
+	p.mu.RLock()
+	origin := p.origin("db.dsn")
+	p.mu.RUnlock()
+	if origin != "derived" {
+		t.Errorf("origin of db.dsn = %q, want derived", origin)
+	}
+
+	// overrides change the inputs and replace derived keys
+	if err := p.Override("db.host", "db.internal"); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("db.dsn"); got != "postgres://app@db.internal:5432/main" {
+		t.Errorf("db.dsn = %q after overriding db.host", got)
+	}
+	if err := p.Override("db.name", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("db.dsn"); got != "postgres://app@db.internal:5432/other" {
+		t.Errorf("db.dsn = %q after overriding db.name", got)
+	}
+
+	t.Run("failures", func(t *testing.T) {
+		p := New()
+		p.Derive("a", func(c View) (interface{}, error) { return c.Get("b"), nil })
+		p.Derive("b", func(c View) (interface{}, error) { return c.Get("a"), nil })
+		p.Derive("broken", func(View) (interface{}, error) { return nil, errors.New("boom") })
+		p.Derive("ok", func(View) (interface{}, error) { return 1, nil })
+		if _, err := p.Parse(configFile); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"a", "b", "broken"} {
+			if got := p.Get(key); got != nil {
+				t.Errorf("%s = %v, want nil", key, got)
+			}
+		}
+		if got := p.GetInt("ok"); got != 1 {
+			t.Errorf("ok = %v, want 1", got)
+		}
+	})
+}
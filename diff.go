@@ -0,0 +1,252 @@
+package viper
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DefaultSensitiveKeys lists the key patterns treated as sensitive unless
+// more are added with WithSensitiveKeys
+var DefaultSensitiveKeys = []string{
+	"*password*",
+	"*passwd*",
+	"*secret*",
+	"*token*",
+	"*apikey*",
+	"*api_key*",
+	"*private_key*",
+	"*credential*",
+}
+
+// WithSensitiveKeys adds glob patterns (as in path.Match) matched against the
+// lower-cased dot-notation key. Values of matching keys never leave the parser
+// through diffs or change notifications.
+func WithSensitiveKeys(patterns ...string) Option {
+	return func(p *Parser) {
+		for _, pattern := range patterns {
+			p.sensitive = append(p.sensitive, strings.ToLower(pattern))
+		}
+	}
+}
+
+// isSensitive reports whether the key matches any of the sensitive patterns
+func (p *Parser) isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range p.sensitivePatterns() {
+		if matchGlob(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches key against pattern as path.Match does, the *word*
+// patterns of DefaultSensitiveKeys without parsing them for every key
+func matchGlob(pattern, key string) bool {
+	if word, ok := strings.CutPrefix(pattern, "*"); ok {
+		if word, ok = strings.CutSuffix(word, "*"); ok && isLiteral(word) && strings.IndexByte(key, '/') < 0 {
+			return strings.Contains(key, word)
+		}
+	}
+	ok, _ := path.Match(pattern, key)
+	return ok
+}
+
+// isLiteral reports whether s holds none of the special characters of
+// path.Match, nor the separator it never matches across
+func isLiteral(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', '\\', '/':
+			return false
+		}
+	}
+	return true
+}
+
+// Change describes the transition of a single key between two configurations
+type Change struct {
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+	// Sensitive is set when the key matched a sensitive pattern. Old and New
+	// are left empty in that case.
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// ChangeSet lists the keys that differ between two configurations, indexed
+// by their dot-notation path
+type ChangeSet struct {
+	Added    map[string]Change `json:"added,omitempty"`
+	Removed  map[string]Change `json:"removed,omitempty"`
+	Modified map[string]Change `json:"modified,omitempty"`
+}
+
+// Empty reports whether the change set contains no changes
+func (c ChangeSet) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+// Keys returns every changed key in lexical order
+func (c ChangeSet) Keys() []string {
+	keys := make([]string, 0, len(c.Added)+len(c.Removed)+len(c.Modified))
+	for _, m := range []map[string]Change{c.Added, c.Removed, c.Modified} {
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String renders a one-line summary of the changes. Sensitive keys are
+// reported as changed without their values, so the output is safe to ship
+// to logs and chat channels.
+func (c ChangeSet) String() string {
+	parts := make([]string, 0, len(c.Added)+len(c.Removed)+len(c.Modified))
+	for _, k := range c.Keys() {
+		if ch, ok := c.Added[k]; ok {
+			if ch.Sensitive {
+				parts = append(parts, fmt.Sprintf("+%s (sensitive)", k))
+			} else {
+				parts = append(parts, fmt.Sprintf("+%s=%v", k, ch.New))
+			}
+			continue
+		}
+		if _, ok := c.Removed[k]; ok {
+			parts = append(parts, "-"+k)
+			continue
+		}
+		if ch := c.Modified[k]; ch.Sensitive {
+			parts = append(parts, fmt.Sprintf("~%s changed (sensitive)", k))
+		} else {
+			parts = append(parts, fmt.Sprintf("~%s: %v -> %v", k, ch.Old, ch.New))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Diff compares two settings maps, as found in Config.Raw, and returns the
+// changes needed to go from old to new. Values of sensitive keys are
+// omitted, as are the values of lists holding any.
+func (p *Parser) Diff(old, new map[string]interface{}) ChangeSet {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.diff(old, new)
+}
+
+func (p *Parser) diff(old, new map[string]interface{}) ChangeSet {
+	before := flatten(old)
+	after := flatten(new)
+
+	cs := ChangeSet{
+		Added:    map[string]Change{},
+		Removed:  map[string]Change{},
+		Modified: map[string]Change{},
+	}
+	for k, ov := range before {
+		nv, ok := after[k]
+		switch {
+		case !ok:
+			cs.Removed[k] = p.change(k, ov, nil)
+		case !reflect.DeepEqual(ov, nv):
+			cs.Modified[k] = p.change(k, ov, nv)
+		}
+	}
+	for k, nv := range after {
+		if _, ok := before[k]; !ok {
+			cs.Added[k] = p.change(k, nil, nv)
+		}
+	}
+	return cs
+}
+
+func (p *Parser) change(key string, old, new interface{}) Change {
+	if p.isSensitive(key) || p.holdsSensitive(old, key) || p.holdsSensitive(new, key) {
+		return Change{Sensitive: true}
+	}
+	return Change{Old: old, New: new}
+}
+
+// holdsSensitive reports whether v, the value at path, holds a sensitive
+// key, as lists of maps do, such as dbs[0].password in a list of databases
+func (p *Parser) holdsSensitive(v interface{}, path string) bool {
+	if m, ok := toStringMap(v); ok {
+		for k, child := range m {
+			key := joinPath(path, k)
+			if p.isSensitive(key) || p.holdsSensitive(child, key) {
+				return true
+			}
+		}
+		return false
+	}
+	if list, ok := v.([]interface{}); ok {
+		for i, item := range list {
+			if p.holdsSensitive(item, fmt.Sprintf("%s[%d]", path, i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// flatten returns the leaves of a nested settings map indexed by their
+// dot-notation path. Slices are treated as leaves.
+func flatten(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	flattenInto(out, "", m)
+	return out
+}
+
+func flattenInto(out map[string]interface{}, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := toStringMap(v); ok {
+			flattenInto(out, key, nested)
+			continue
+		}
+		out[key] = v
+	}
+}
+
+// walkLeaves calls f with the dot-notation path and the value of every leaf
+// of m, the entries flatten returns, without building the flattened map.
+// It stops at the first error f returns.
+func walkLeaves(m map[string]interface{}, prefix string, f func(key string, v interface{}) error) error {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := toStringMap(v); ok {
+			if err := walkLeaves(nested, key, f); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := f(key, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toStringMap normalises the map flavours produced by the different decoders
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			out[fmt.Sprint(k)] = v
+		}
+		return out, true
+	}
+	return nil, false
+}
@@ -0,0 +1,89 @@
+package viper
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParser_Diff(t *testing.T) {
+	old := map[string]interface{}{
+		"server": map[string]interface{}{"port": 80, "host": "localhost"},
+		"db":     map[string]interface{}{"password": "hunter2", "pool": 4},
+		"legacy": true,
+	}
+	new := map[string]interface{}{
+		"server":  map[string]interface{}{"port": 8080, "host": "localhost"},
+		"db":      map[string]interface{}{"password": "correct-horse", "pool": 4},
+		"feature": map[string]interface{}{"signing_token": "abc"},
+	}
+
+	p := New()
+	cs := p.Diff(old, new)
+
+	if got := cs.Keys(); !jsonEqual(got, []string{"db.password", "feature.signing_token", "legacy", "server.port"}) {
+		t.Fatalf("Diff() keys = %v", got)
+	}
+
+	if ch := cs.Modified["server.port"]; ch.Old != 80 || ch.New != 8080 || ch.Sensitive {
+		t.Errorf("Diff() server.port = %+v", ch)
+	}
+	if ch := cs.Modified["db.password"]; !ch.Sensitive || ch.Old != nil || ch.New != nil {
+		t.Errorf("Diff() db.password leaked values: %+v", ch)
+	}
+	if ch := cs.Added["feature.signing_token"]; !ch.Sensitive || ch.New != nil {
+		t.Errorf("Diff() feature.signing_token leaked values: %+v", ch)
+	}
+	if _, ok := cs.Removed["legacy"]; !ok {
+		t.Error("Diff() legacy not reported as removed")
+	}
+
+	for _, out := range []string{cs.String(), mustJSON(t, cs)} {
+		for _, secret := range []string{"hunter2", "correct-horse", "abc"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("Diff() output %q leaks %q", out, secret)
+			}
+		}
+	}
+}
+
+func TestParser_DiffSensitiveInList(t *testing.T) {
+	old := map[string]interface{}{
+		"dbs": []interface{}{map[string]interface{}{"host": "a", "password": "hunter2"}},
+	}
+	new := map[string]interface{}{
+		"dbs": []interface{}{map[string]interface{}{"host": "a", "password": "correct-horse"}},
+	}
+	cs := New().Diff(old, new)
+	if ch := cs.Modified["dbs"]; !ch.Sensitive || ch.Old != nil || ch.New != nil {
+		t.Errorf("Diff() dbs = %+v, want the list holding a password redacted", ch)
+	}
+	for _, out := range []string{cs.String(), mustJSON(t, cs)} {
+		if strings.Contains(out, "hunter2") || strings.Contains(out, "correct-horse") {
+			t.Errorf("Diff() output %q leaks a password", out)
+		}
+	}
+}
+
+func TestWithSensitiveKeys(t *testing.T) {
+	p := New(WithSensitiveKeys("tls.*"))
+	cs := p.Diff(
+		map[string]interface{}{"tls": map[string]interface{}{"cert": "old"}},
+		map[string]interface{}{"tls": map[string]interface{}{"cert": "new"}},
+	)
+	if ch := cs.Modified["tls.cert"]; !ch.Sensitive {
+		t.Errorf("Diff() tls.cert = %+v, want sensitive", ch)
+	}
+	if !p.isSensitive("DB.Password") {
+		t.Error("default patterns dropped by WithSensitiveKeys")
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,105 @@
+package viper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ParseDir reads every config file of dir in lexical order and deep-merges
+// them as ParseAll does, so fragments like 10-base.yaml and 20-tls.yaml
+// dropped into a conf.d directory make up a single config. Files are picked
+// by their extension, the ones handled by preprocessors being stripped
+// first; hidden files and sub-directories are skipped. A directory without
+// config files fails.
+func (p *Parser) ParseDir(dir string) (*Config, error) {
+	files, err := p.dirFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return p.ParseAll(files...)
+}
+
+// WatchDir watches dir for fragments being added, removed or changed,
+// reading the config files it then holds again as ParseDir does and
+// calling callback after every reload attempt. The directory replaces the
+// files loaded before as the config. Swaps of the ..data link of mounted
+// Kubernetes ConfigMaps are picked up too. The watch is stopped with
+// StopWatch(dir).
+func (p *Parser) WatchDir(dir string, callback func()) error {
+	p.watchMu.Lock()
+	defer p.watchMu.Unlock()
+	if err := p.openForWatch(); err != nil {
+		return err
+	}
+
+	if stop, exists := p.watches[dir]; exists {
+		stop()
+		delete(p.watches, dir)
+	}
+
+	files := func() []string {
+		files, err := p.dirFiles(dir)
+		if err != nil {
+			p.logger.Warn("cannot list config directory", "dir", dir, "error", err)
+		}
+		return files
+	}
+	apply := p.reloader(dir, files, func(ChangeSet, error) {
+		if callback != nil {
+			callback()
+		}
+	})
+
+	limiter := &throttle{interval: p.reloadInterval}
+	settle := &debouncer{wait: p.watchDebounce}
+	stopWatcher, err := watchDir(dir, p.logger, p.isConfigFile, func() { settle.run(func() { limiter.run(apply) }) })
+	if err != nil {
+		return fmt.Errorf("error watching config directory %q: %w", dir, err)
+	}
+	p.watches[dir] = func() {
+		stopWatcher()
+		settle.stop()
+		limiter.stop()
+	}
+	return nil
+}
+
+// dirFiles returns the config files of dir in lexical order
+func (p *Parser) dirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config directory %q: %w", dir, err)
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !p.isConfigFile(e.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config file in %q", dir)
+	}
+	return files, nil
+}
+
+// isConfigFile reports whether the file named name, in a watched or parsed
+// directory, is a config file the parser can decode
+func (p *Parser) isConfigFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(p.unwrappedName(name)))
+	if ext == "" {
+		return false
+	}
+	if _, ok := p.codecs[ext[1:]]; ok {
+		return true
+	}
+	return slices.Contains(viper.SupportedExts, ext[1:])
+}
@@ -0,0 +1,90 @@
+package viper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_ParseDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"10-base.yaml":   "name: base\nlog: info\ndb:\n  host: localhost\n",
+		"20-db.json":     `{"db": {"pool": 16}}`,
+		"30-log.yaml":    "log: warn\n",
+		".30-log.yaml":   "log: hidden\n",
+		"README.md":      "not a config\n",
+		"sub/99-x.yaml":  "log: nested\n",
+		"40-name.toml.x": "name = 'unknown extension'\n",
+	})
+
+	p := New()
+	if _, err := p.ParseDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"name", "base"},
+		{"log", "warn"},
+		{"db.host", "localhost"},
+		{"db.pool", 16},
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.path); !jsonEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	for name, dir := range map[string]string{"empty": t.TempDir(), "missing": filepath.Join(dir, "missing")} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New().ParseDir(dir); err == nil {
+				t.Error("ParseDir() succeeded")
+			}
+		})
+	}
+}
+
+func TestParser_WatchDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"10-base.yaml": "log: info\npool: 4\n",
+		"20-log.yaml":  "log: warn\n",
+	})
+	p := New()
+	if _, err := p.ParseDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan struct{}, 100)
+	if err := p.WatchDir(dir, func() { reloads <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch(dir)
+
+	waitFor := func(what string, ok func() bool) {
+		t.Helper()
+		deadline := time.After(time.Second)
+		for !ok() {
+			select {
+			case <-reloads:
+			case <-deadline:
+				t.Fatalf("timeout waiting for %s", what)
+			}
+		}
+	}
+
+	replaceFile(t, filepath.Join(dir, "30-pool.yaml"), "pool: 8\n")
+	waitFor("the added fragment", func() bool { return p.GetInt("pool") == 8 })
+
+	if err := os.Remove(filepath.Join(dir, "20-log.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the removed fragment", func() bool { return p.GetString("log") == "info" })
+	p.mu.RLock()
+	files := strings.Join(p.files, ",")
+	p.mu.RUnlock()
+	if strings.Contains(files, "20-log.yaml") {
+		t.Errorf("loaded files = %s, want the removed fragment gone", files)
+	}
+}
@@ -0,0 +1,182 @@
+package viper
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// WithDotenv reads the KEY=VALUE pairs of the given .env files on every
+// load, merged in order beneath the config files: DB_HOST=db makes db_host
+// read as db unless a config file sets it. Missing files are skipped, so a
+// .env only present on development machines can be named unconditionally.
+func WithDotenv(files ...string) Option {
+	return func(p *Parser) {
+		p.dotenv = append(p.dotenv, files...)
+	}
+}
+
+// WithDotenvExport also sets the pairs of the WithDotenv files as variables
+// of the process environment, before the config is read, so the bound and
+// automatic environment variables, the env references and the required keys
+// see them as they would in a container. Variables already set in the
+// environment are kept; the ones exported by a previous load follow the
+// changes of the files.
+func WithDotenvExport() Option {
+	return func(p *Parser) {
+		p.dotenvExport = true
+	}
+}
+
+// readDotenv reads the WithDotenv files, exporting their pairs when asked
+func (p *Parser) readDotenv() (map[string]interface{}, error) {
+	var settings map[string]interface{}
+	for _, file := range p.dotenv {
+		exists, err := p.fileExists(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading dotenv file %q: %w", file, err)
+		}
+		if !exists {
+			continue
+		}
+		pairs, err := p.readFile(file, "env")
+		if err != nil {
+			return nil, fmt.Errorf("error reading dotenv file %q: %w", file, err)
+		}
+		if p.dotenvExport {
+			if err := p.exportDotenv(pairs); err != nil {
+				return nil, fmt.Errorf("error exporting dotenv file %q: %w", file, err)
+			}
+		}
+		// the variable names are upper case, the keys they set are not
+		pairs = lowerKeys(pairs)
+		if err := p.pending.setOrigin(file, pairs); err != nil {
+			return nil, err
+		}
+		settings = p.merge(settings, pairs)
+	}
+	return settings, nil
+}
+
+// exportDotenv sets the pairs as environment variables, unless they were
+// set by something else than a previous export
+func (p *Parser) exportDotenv(pairs map[string]interface{}) error {
+	p.exportMu.Lock()
+	defer p.exportMu.Unlock()
+	if p.exported == nil {
+		p.exported = make(map[string]string)
+	}
+	for name, v := range pairs {
+		value := fmt.Sprint(v)
+		if current, set := os.LookupEnv(name); set {
+			if exported, ok := p.exported[name]; !ok || exported != current {
+				continue
+			}
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+		p.exported[name] = value
+	}
+	return nil
+}
+
+// dotenvName matches the variable names of .env files
+var dotenvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// dotenvCodec decodes .env files: KEY=VALUE lines, optionally starting with
+// export, with # comments. Single-quoted values are literal, double-quoted
+// ones expand \n, \t, \" and \\ escapes; both may span lines. Unquoted
+// values end at a # preceded by a space. Keys are not nested.
+type dotenvCodec struct {
+	p *Parser
+}
+
+func (c *dotenvCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	keys, values, err := leaves("env", v)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, k := range keys {
+		value := values[k]
+		if strings.ContainsAny(value, " \t\n\r#'\"\\$") {
+			value = `"` + dotenvEscaper.Replace(value) + `"`
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k, value)
+	}
+	return []byte(b.String()), nil
+}
+
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+var dotenvUnescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n", `\r`, "\r", `\t`, "\t")
+
+func (c *dotenvCodec) Decode(b []byte, v map[string]interface{}) error {
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+	seen := keySet{}
+	dups := &duplicates{}
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !dotenvName.MatchString(name) {
+			return fmt.Errorf("env: line %d: expected KEY=VALUE", lineNo)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			body := value[1:]
+			for {
+				end := closingQuote(body, quote)
+				if end >= 0 {
+					if rest := strings.TrimSpace(body[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+						return fmt.Errorf("env: line %d: unexpected %q after the quoted value", lineNo, rest)
+					}
+					body = body[:end]
+					break
+				}
+				if i++; i >= len(lines) {
+					return fmt.Errorf("env: line %d: unterminated quoted value", lineNo)
+				}
+				body += "\n" + lines[i]
+			}
+			value = body
+			if quote == '"' {
+				value = dotenvUnescaper.Replace(value)
+			}
+		} else {
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			value = strings.TrimSpace(value)
+		}
+
+		if first, dup := seen.add(name, Location{Line: lineNo}); dup {
+			dups.report(first.key, first.loc, Location{Line: lineNo})
+		}
+		v[name] = value
+	}
+	return dups.err()
+}
+
+// closingQuote returns the index of the quote ending s, skipping the ones
+// escaped in double-quoted values, or -1
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
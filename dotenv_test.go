@@ -0,0 +1,125 @@
+package viper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDotenvCodec_Decode(t *testing.T) {
+	content := `# local settings
+export DB_HOST=localhost
+DB_PORT = 5432 # the default port
+GREETING="hello\n\"world\""
+RAW='no \n escapes # here'
+EMPTY=
+CERT="-----BEGIN-----
+abc
+-----END-----"
+URL=http://example.com/#anchor
+`
+	got := map[string]interface{}{}
+	if err := (&dotenvCodec{}).Decode([]byte(content), got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"DB_HOST":  "localhost",
+		"DB_PORT":  "5432",
+		"GREETING": "hello\n\"world\"",
+		"RAW":      `no \n escapes # here`,
+		"EMPTY":    "",
+		"CERT":     "-----BEGIN-----\nabc\n-----END-----",
+		"URL":      "http://example.com/#anchor",
+	}
+	if !jsonEqual(got, want) {
+		t.Errorf("Decode() = %#v, want %#v", got, want)
+	}
+
+	b, err := (&dotenvCodec{}).Encode(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip := map[string]interface{}{}
+	if err := (&dotenvCodec{}).Decode(b, roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(roundTrip, want) {
+		t.Errorf("Decode(Encode()) = %#v, want %#v", roundTrip, want)
+	}
+
+	errTests := map[string]string{
+		"not a pair":  "just words\n",
+		"unclosed":    "A=\"open\n",
+		"after quote": "A='x' y\n",
+	}
+	for name, content := range errTests {
+		t.Run(name, func(t *testing.T) {
+			if err := (&dotenvCodec{}).Decode([]byte(content), map[string]interface{}{}); err == nil || !strings.HasPrefix(err.Error(), "env: line 1") {
+				t.Errorf("Decode() error = %v, want the line reported", err)
+			}
+		})
+	}
+
+	var dupErr *DuplicateKeyError
+	if err := (&dotenvCodec{}).Decode([]byte("A=1\nA=2\n"), map[string]interface{}{}); !errors.As(err, &dupErr) {
+		t.Errorf("Decode() error = %v, want a *DuplicateKeyError", err)
+	}
+}
+
+func TestParser_Dotenv(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".env":        "LOG=debug\nDB_HOST=localhost\nDOTENVTEST_TOKEN=from-file\nDOTENVTEST_SET=from-file\n",
+		"config.yaml": "log: info\ntoken: $ref{env:DOTENVTEST_TOKEN}\n",
+	})
+	envFile := filepath.Join(dir, ".env")
+	configFile := filepath.Join(dir, "config.yaml")
+	t.Setenv("DOTENVTEST_SET", "from-env")
+	for _, name := range []string{"DOTENVTEST_TOKEN", "LOG", "DB_HOST"} {
+		name := name
+		if _, set := os.LookupEnv(name); set {
+			t.Skipf("%s is set in the environment", name)
+		}
+		t.Cleanup(func() { os.Unsetenv(name) })
+	}
+
+	p := New(WithDotenv(envFile, filepath.Join(dir, "missing.env")), WithDotenvExport())
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"log", "info"},
+		{"db_host", "localhost"},
+		{"token", "from-file"},
+	}
+	for _, tt := range tests {
+		if got := p.GetString(tt.path); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	if got := os.Getenv("DOTENVTEST_SET"); got != "from-env" {
+		t.Errorf("DOTENVTEST_SET = %q, want the environment to win", got)
+	}
+
+	replaceFile(t, envFile, "DOTENVTEST_TOKEN=rotated\n")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("token"); got != "rotated" {
+		t.Errorf("token = %q after the dotenv file changed, want the new value exported", got)
+	}
+
+	t.Run("parse", func(t *testing.T) {
+		p := New()
+		if _, err := p.Parse(envFile); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.GetString("dotenvtest_token"); got != "rotated" {
+			t.Errorf("dotenvtest_token = %q, want the value of the file", got)
+		}
+	})
+}
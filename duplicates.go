@@ -0,0 +1,304 @@
+package viper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DuplicateKeyPolicy defines how the parser reacts to keys defined more
+// than once
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeysError makes Parse fail with a *DuplicateKeyError
+	DuplicateKeysError DuplicateKeyPolicy = iota
+	// DuplicateKeysWarn logs the duplicates and keeps the last occurrence
+	DuplicateKeysWarn
+	// DuplicateKeysAllow silently keeps the last occurrence
+	DuplicateKeysAllow
+)
+
+// WithDuplicateKeys sets the policy applied to duplicate keys. Keys are
+// compared ignoring case, since viper does not tell them apart either.
+func WithDuplicateKeys(policy DuplicateKeyPolicy) Option {
+	return func(p *Parser) {
+		p.duplicateKeys = policy
+	}
+}
+
+// Location points at a position inside a config source
+type Location struct {
+	File string
+	Line int
+}
+
+func (l Location) String() string {
+	switch {
+	case l.File == "" && l.Line == 0:
+		return "unknown location"
+	case l.File == "":
+		return fmt.Sprintf("line %d", l.Line)
+	case l.Line == 0:
+		return l.File
+	}
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// DuplicateKey lists every location a key was defined at
+type DuplicateKey struct {
+	Key       string
+	Locations []Location
+}
+
+// DuplicateKeyError is returned when a source defines the same key more
+// than once
+type DuplicateKeyError struct {
+	Duplicates []DuplicateKey
+}
+
+func (e *DuplicateKeyError) Error() string {
+	parts := make([]string, 0, len(e.Duplicates))
+	for _, d := range e.Duplicates {
+		locs := make([]string, 0, len(d.Locations))
+		for _, l := range d.Locations {
+			locs = append(locs, l.String())
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", d.Key, strings.Join(locs, ", ")))
+	}
+	return "duplicate keys: " + strings.Join(parts, "; ")
+}
+
+// duplicates collects duplicate definitions in document order. Callers
+// track the keys already seen in each map and only report clashes, so the
+// full key paths are only built for actual duplicates.
+type duplicates struct {
+	found map[string]*DuplicateKey
+	order []string
+}
+
+// report records that key, first defined at first, is defined again at loc
+func (d *duplicates) report(key string, first, loc Location) {
+	lk := strings.ToLower(key)
+	dup, ok := d.found[lk]
+	if !ok {
+		if d.found == nil {
+			d.found = make(map[string]*DuplicateKey)
+		}
+		dup = &DuplicateKey{Key: key, Locations: []Location{first}}
+		d.found[lk] = dup
+		d.order = append(d.order, lk)
+	}
+	dup.Locations = append(dup.Locations, loc)
+}
+
+// err returns a *DuplicateKeyError, or nil when no duplicate was found
+func (d *duplicates) err() error {
+	if len(d.order) == 0 {
+		return nil
+	}
+	e := &DuplicateKeyError{Duplicates: make([]DuplicateKey, 0, len(d.order))}
+	for _, k := range d.order {
+		e.Duplicates = append(e.Duplicates, *d.found[k])
+	}
+	return e
+}
+
+// keySet tracks the keys of a single map, ignoring case
+type keySet map[string]keySeen
+
+type keySeen struct {
+	key string
+	loc Location
+}
+
+// add records key and returns the first definition when it is a duplicate
+func (s keySet) add(key string, loc Location) (keySeen, bool) {
+	lk := strings.ToLower(key)
+	if first, ok := s[lk]; ok {
+		return first, true
+	}
+	s[lk] = keySeen{key: key, loc: loc}
+	return keySeen{}, false
+}
+
+// pathStack builds dot-notation key paths on demand while walking a document
+type pathStack []pathSegment
+
+type pathSegment struct {
+	key   string
+	index int // position in the parent slice, when key is empty
+}
+
+func (s *pathStack) pushKey(key string)    { *s = append(*s, pathSegment{key: key}) }
+func (s *pathStack) pushIndex(i int)       { *s = append(*s, pathSegment{index: i}) }
+func (s *pathStack) pop()                  { *s = (*s)[:len(*s)-1] }
+func (s pathStack) with(key string) string { return joinPath(s.String(), key) }
+
+func (s pathStack) String() string {
+	var sb strings.Builder
+	for _, seg := range s {
+		if seg.key == "" {
+			fmt.Fprintf(&sb, "[%d]", seg.index)
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(seg.key)
+	}
+	return sb.String()
+}
+
+// checkDuplicates applies the duplicate key policy to an error returned by
+// a codec, attributing the duplicates to the given source
+func (p *Parser) checkDuplicates(err error, source string) error {
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		return err
+	}
+	for i := range dupErr.Duplicates {
+		for j := range dupErr.Duplicates[i].Locations {
+			if dupErr.Duplicates[i].Locations[j].File == "" {
+				dupErr.Duplicates[i].Locations[j].File = source
+			}
+		}
+	}
+
+	switch p.duplicateKeyPolicy() {
+	case DuplicateKeysWarn:
+		p.logger.Warn("config defines duplicate keys", "source", source, "duplicates", dupErr.Error())
+		return nil
+	case DuplicateKeysAllow:
+		return nil
+	}
+	return dupErr
+}
+
+// jsonDuplicates scans a JSON document for keys defined twice in the same
+// object. The document is expected to be valid.
+func jsonDuplicates(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dups := &duplicates{}
+	lines := lineCounter{b: b}
+	var path pathStack
+
+	var walk func() error
+	walk = func() error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			return nil
+		}
+		switch delim {
+		case '{':
+			seen := keySet{}
+			for dec.More() {
+				tok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key := tok.(string)
+				loc := Location{Line: lines.at(dec.InputOffset())}
+				if first, dup := seen.add(key, loc); dup {
+					dups.report(path.with(first.key), first.loc, loc)
+				}
+				path.pushKey(key)
+				err = walk()
+				path.pop()
+				if err != nil {
+					return err
+				}
+			}
+		case '[':
+			for i := 0; dec.More(); i++ {
+				path.pushIndex(i)
+				err := walk()
+				path.pop()
+				if err != nil {
+					return err
+				}
+			}
+		}
+		_, err = dec.Token() // closing delimiter
+		return err
+	}
+
+	if err := walk(); err != nil {
+		return err
+	}
+	return dups.err()
+}
+
+// caseDuplicates looks for keys differing only by case in a decoded map,
+// for codecs that cannot report positions
+func caseDuplicates(m map[string]interface{}) error {
+	dups := &duplicates{}
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		if list, ok := v.([]interface{}); ok {
+			// arrays of tables keep their own keys, check each element
+			for i, item := range list {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), item)
+			}
+			return
+		}
+		m, ok := toStringMap(v)
+		if !ok {
+			return
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		seen := keySet{}
+		for _, k := range keys {
+			if first, dup := seen.add(k, Location{}); dup {
+				dups.report(joinPath(prefix, first.key), Location{}, Location{})
+				continue
+			}
+			walk(joinPath(prefix, k), m[k])
+		}
+	}
+	walk("", m)
+	return dups.err()
+}
+
+// lineAt converts a byte offset into a 1-based line number
+func lineAt(b []byte, offset int64) int {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	return bytes.Count(b[:offset], []byte("\n")) + 1
+}
+
+// lineCounter converts the increasing byte offsets of a document into
+// 1-based line numbers, counting each line once
+type lineCounter struct {
+	b      []byte
+	offset int64
+	lines  int
+}
+
+func (c *lineCounter) at(offset int64) int {
+	if offset > int64(len(c.b)) {
+		offset = int64(len(c.b))
+	}
+	c.lines += bytes.Count(c.b[c.offset:offset], []byte("\n"))
+	c.offset = offset
+	return c.lines + 1
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
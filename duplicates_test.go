@@ -0,0 +1,101 @@
+package viper
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_DuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		configType string
+		content    string
+		want       []DuplicateKey
+	}{
+		{
+			name:       "json",
+			configType: "json",
+			content:    "{\n  \"server\": {\n    \"port\": 80,\n    \"port\": 8080\n  }\n}",
+			want:       []DuplicateKey{{Key: "server.port", Locations: []Location{{Line: 3}, {Line: 4}}}},
+		},
+		{
+			name:       "json keys differing by case",
+			configType: "json",
+			content:    "{\"Host\": \"a\",\n\"host\": \"b\"}",
+			want:       []DuplicateKey{{Key: "Host", Locations: []Location{{Line: 1}, {Line: 2}}}},
+		},
+		{
+			name:       "yaml",
+			configType: "yaml",
+			content:    "db:\n  host: a\n  pool: 1\n  host: b\n",
+			want:       []DuplicateKey{{Key: "db.host", Locations: []Location{{Line: 2}, {Line: 4}}}},
+		},
+		{
+			name:       "toml keys differing by case",
+			configType: "toml",
+			content:    "Name = \"a\"\nname = \"b\"\n",
+			want:       []DuplicateKey{{Key: "Name", Locations: []Location{{}, {}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configFile := filepath.Join(tmpDir, "config."+tt.configType)
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := New().Parse(configFile)
+			var dupErr *DuplicateKeyError
+			if !errors.As(err, &dupErr) {
+				t.Fatalf("Parse() error = %v, want a *DuplicateKeyError", err)
+			}
+			for i := range tt.want {
+				for j := range tt.want[i].Locations {
+					tt.want[i].Locations[j].File = configFile
+				}
+			}
+			if !jsonEqual(dupErr.Duplicates, tt.want) {
+				t.Errorf("Parse() duplicates = %+v, want %+v", dupErr.Duplicates, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDuplicateKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"key": "first", "key": "last"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("warn", func(t *testing.T) {
+		var logs bytes.Buffer
+		p := New(WithDuplicateKeys(DuplicateKeysWarn), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		if _, err := p.Parse(configFile); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.GetString("key"); got != "last" {
+			t.Errorf("key = %v, want 'last'", got)
+		}
+		if !strings.Contains(logs.String(), "duplicate keys: key") {
+			t.Errorf("expected a warning, got %q", logs.String())
+		}
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		p := New(WithDuplicateKeys(DuplicateKeysAllow))
+		if _, err := p.Parse(configFile); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.GetString("key"); got != "last" {
+			t.Errorf("key = %v, want 'last'", got)
+		}
+	})
+}
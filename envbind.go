@@ -0,0 +1,89 @@
+package viper
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// BindStructEnv binds the key of every leaf field of target, a struct or a
+// pointer to one, to its environment variable, named as WriteEnvExample
+// names it: NEXEN_DB_HOST for the db.host key with the default prefix,
+// then to the variables of the legacy prefixes set with WithEnvPrefixes.
+// Keys follow the mapstructure tags, looking into nested and squashed
+// structs. The automatic environment lookup only sees the keys a config
+// file, a default or an override declares; once bound, keys set by the
+// environment alone are also listed by AllKeys and AllSettings and filled
+// by Unmarshal and UnmarshalExact.
+func (p *Parser) BindStructEnv(target interface{}) error {
+	t := reflect.TypeOf(target)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot bind the environment of %T, want a struct", target)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, key := range leafKeys(t, "", nil) {
+		key = strings.ToLower(p.normalizePath(key))
+		names := p.envNames(key)
+		if err := p.v.BindEnv(append([]string{key}, names...)...); err != nil {
+			return fmt.Errorf("cannot bind the environment of %s: %w", key, err)
+		}
+		if p.env.explicit() {
+			if p.env.bound == nil {
+				p.env.bound = make(map[string]bool)
+			}
+			p.env.bound[key] = true
+			p.warnLegacyEnv(names)
+		}
+	}
+	p.version++
+	return nil
+}
+
+// leafKeys lists the keys of the fields of t holding values rather than
+// nested structs, looking into nested and squashed structs. seen holds the
+// types of the structs being walked, so recursive types end.
+func leafKeys(t reflect.Type, prefix string, seen []reflect.Type) []string {
+	seen = append(seen, t)
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+		name := field.Name
+		if tag[0] != "" {
+			name = tag[0]
+		}
+		if name == "-" || slices.Contains(tag[1:], "remain") {
+			continue
+		}
+		key := joinPath(prefix, strings.ToLower(name))
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct || ft == timeType || ft == urlType {
+			if field.IsExported() {
+				keys = append(keys, key)
+			}
+			continue
+		}
+		if slices.Contains(seen, ft) {
+			continue
+		}
+		if (field.Anonymous && tag[0] == "") || slices.Contains(tag[1:], "squash") {
+			keys = append(keys, leafKeys(ft, prefix, seen)...)
+		} else {
+			keys = append(keys, leafKeys(ft, key, seen)...)
+		}
+	}
+	return keys
+}
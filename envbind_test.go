@@ -0,0 +1,64 @@
+package viper
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParser_BindStructEnv(t *testing.T) {
+	type Base struct {
+		Name string `mapstructure:"name"`
+	}
+	type Config struct {
+		Base `mapstructure:",squash"`
+		DB   struct {
+			Host string        `mapstructure:"host"`
+			Port int           `mapstructure:"port"`
+			TTL  time.Duration `mapstructure:"ttl"`
+		} `mapstructure:"db"`
+		Started time.Time `mapstructure:"started"`
+		Ignored string    `mapstructure:"-"`
+	}
+
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  host: file\n"})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NEXEN_NAME", "svc")
+	t.Setenv("NEXEN_DB_PORT", "6432")
+	t.Setenv("NEXEN_DB_TTL", "5s")
+	t.Setenv("NEXEN_IGNORED", "x")
+
+	if err := p.BindStructEnv(&Config{}); err != nil {
+		t.Fatal(err)
+	}
+	keys := p.AllKeys()
+	for _, key := range []string{"name", "db.port", "db.ttl"} {
+		if !slices.Contains(keys, key) {
+			t.Errorf("AllKeys() = %v, want the env-only key %s", keys, key)
+		}
+	}
+	for _, key := range []string{"started", "ignored"} {
+		if slices.Contains(keys, key) {
+			t.Errorf("AllKeys() = %v, want no unset or ignored key %s", keys, key)
+		}
+	}
+	if got := p.AllSettings()["db"].(map[string]interface{})["port"]; got != "6432" {
+		t.Errorf("AllSettings() db.port = %v, want 6432", got)
+	}
+
+	var cfg Config
+	if err := p.UnmarshalExact(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "svc" || cfg.DB.Host != "file" || cfg.DB.Port != 6432 || cfg.DB.TTL != 5*time.Second {
+		t.Errorf("UnmarshalExact() = %+v, want the environment and the file", cfg)
+	}
+
+	if err := p.BindStructEnv(42); err == nil {
+		t.Error("BindStructEnv() of an int succeeded")
+	}
+}
@@ -0,0 +1,99 @@
+package viper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// envEntry documents the environment variable backing a key
+type envEntry struct {
+	key    string
+	name   string
+	value  string
+	origin string
+	secret bool
+}
+
+// envEntries lists the environment variables of the known keys, sorted by
+// name, with their current value. Sensitive and referenced values are left
+// empty. Callers hold the read lock.
+func (p *Parser) envEntries() []envEntry {
+	flat := flatten(p.settings())
+	entries := make([]envEntry, 0, len(flat))
+	for k, v := range flat {
+		e := envEntry{key: k, name: p.envName(k), origin: p.origin(k)}
+		if p.isSensitive(k) || p.referenced(k) {
+			e.secret = true
+		} else {
+			e.value = exportString(v, " ")
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries
+}
+
+// WriteEnvExample writes a .env.example file listing the environment
+// variables the parser reads for every known key, named after the env prefix
+// and key replacer rules, with the current values as examples. Sensitive
+// values are left empty.
+func (p *Parser) WriteEnvExample(w io.Writer) error {
+	var entries []envEntry
+	p.reading(func() { entries = p.envEntries() })
+
+	bw := bufio.NewWriter(w)
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		comment := e.key + " (" + e.origin + ")"
+		if e.secret {
+			comment += ", sensitive"
+		}
+		fmt.Fprintf(bw, "# %s\n%s=%s\n", comment, e.name, dotenvQuote(e.value))
+	}
+	return bw.Flush()
+}
+
+// WriteSystemdEnvironment writes the Environment= lines of a systemd unit
+// setting the environment variables of every known key, as WriteEnvExample
+// does. Sensitive variables are commented out, to be set with a credential
+// or an EnvironmentFile instead.
+func (p *Parser) WriteSystemdEnvironment(w io.Writer) error {
+	var entries []envEntry
+	p.reading(func() { entries = p.envEntries() })
+
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		line := "Environment=" + systemdQuote(e.name+"="+e.value)
+		if e.secret {
+			line = "#" + line
+		}
+		fmt.Fprintln(bw, line)
+	}
+	return bw.Flush()
+}
+
+// dotenvQuote double-quotes values that would not survive a .env parser
+// unquoted
+func dotenvQuote(s string) string {
+	if s == "" || !strings.ContainsAny(s, " \t\n\"'#$\\`") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`, "`", "\\`")
+	return `"` + r.Replace(s) + `"`
+}
+
+// systemdQuote quotes an assignment for Environment=, escaping the
+// specifiers systemd would expand
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if !strings.ContainsAny(s, " \t\n\"'\\") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
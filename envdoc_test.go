@@ -0,0 +1,62 @@
+package viper
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_WriteEnvExample(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+server:
+  port: 8080
+  banner: "hello $USER"
+db:
+  password: hunter2
+hosts: [a, b]
+`})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New(WithEnvPrefix("app"), WithDefaults(map[string]interface{}{"log.format": "100%"}))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("dotenv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := p.WriteEnvExample(&buf); err != nil {
+			t.Fatal(err)
+		}
+		want := "# db.password (" + configFile + "), sensitive\n" +
+			"APP_DB_PASSWORD=\n" +
+			"\n" +
+			"# hosts (" + configFile + ")\n" +
+			"APP_HOSTS=\"a b\"\n" +
+			"\n" +
+			"# log.format (default)\n" +
+			"APP_LOG_FORMAT=100%\n" +
+			"\n" +
+			"# server.banner (" + configFile + ")\n" +
+			"APP_SERVER_BANNER=\"hello \\$USER\"\n" +
+			"\n" +
+			"# server.port (" + configFile + ")\n" +
+			"APP_SERVER_PORT=8080\n"
+		if got := buf.String(); got != want {
+			t.Errorf("WriteEnvExample() =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("systemd", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := p.WriteSystemdEnvironment(&buf); err != nil {
+			t.Fatal(err)
+		}
+		want := "#Environment=APP_DB_PASSWORD=\n" +
+			"Environment=\"APP_HOSTS=a b\"\n" +
+			"Environment=APP_LOG_FORMAT=100%%\n" +
+			"Environment=\"APP_SERVER_BANNER=hello $USER\"\n" +
+			"Environment=APP_SERVER_PORT=8080\n"
+		if got := buf.String(); got != want {
+			t.Errorf("WriteSystemdEnvironment() =\n%s\nwant\n%s", got, want)
+		}
+	})
+}
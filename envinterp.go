@@ -0,0 +1,112 @@
+package viper
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envPlaceholderPattern matches the placeholders of WithEnvInterpolation,
+// like ${PORT} and ${PORT:-8080}, and their escaped form $${PORT}
+var envPlaceholderPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// WithEnvInterpolation lets string values refer to environment variables,
+// as in
+//
+//	database_url: ${DATABASE_URL}
+//	port: ${PORT:-8080}
+//
+// ${NAME} is replaced by the value of the variable NAME, and ${NAME:-value}
+// by value when NAME is unset or empty. A variable unset without a default
+// is replaced by an empty string and logged. Placeholders are replaced
+// once the files and sources are merged, before the references are
+// resolved, so they may build references too. $${NAME} is kept as the
+// literal ${NAME}, and Save writes the placeholders back instead of their
+// values.
+//
+// With WithInterpolation, the environment placeholders are replaced first:
+// ${NAME} names a variable when it is set, and a key of the config
+// otherwise, while placeholders holding a dot, like ${server.host}, always
+// name keys. The values of variables are written as is, placeholders
+// included.
+func WithEnvInterpolation() Option {
+	return func(p *Parser) {
+		p.envInterpolation = true
+	}
+}
+
+// expandEnv replaces the environment placeholders of settings. Callers
+// hold p.mu.
+func (p *Parser) expandEnv(settings map[string]interface{}) map[string]interface{} {
+	out, _ := p.expandEnvValue(settings, "", true)
+	return out.(map[string]interface{})
+}
+
+// expandEnvValue replaces the environment placeholders of v, found at
+// path, and reports whether it changed. The entries of maps are keys,
+// recorded with their placeholders, unless they sit in a list.
+func (p *Parser) expandEnvValue(v interface{}, path string, keyed bool) (interface{}, bool) {
+	if m, ok := toStringMap(v); ok {
+		out := make(map[string]interface{}, len(m))
+		changed := false
+		for k, child := range m {
+			childPath := joinPath(path, strings.ToLower(k))
+			r, c := p.expandEnvValue(child, childPath, keyed)
+			if _, isMap := r.(map[string]interface{}); c && keyed && !isMap {
+				p.pending.templates[childPath] = child
+			}
+			out[k] = r
+			changed = changed || c
+		}
+		return out, changed
+	}
+
+	switch t := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		changed := false
+		for i, child := range t {
+			var c bool
+			out[i], c = p.expandEnvValue(child, fmt.Sprintf("%s[%d]", path, i), false)
+			changed = changed || c
+		}
+		return out, changed
+	case string:
+		if !strings.Contains(t, "${") {
+			return t, false
+		}
+		r := p.expandEnvString(t, path)
+		return r, r != t
+	}
+	return v, false
+}
+
+// expandEnvString replaces the environment placeholders of s, found at path
+func (p *Parser) expandEnvString(s, path string) string {
+	return envPlaceholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.HasPrefix(m, "$$") {
+			if p.interpolation {
+				// left for the key placeholders to unescape
+				return m
+			}
+			return m[1:]
+		}
+		sub := envPlaceholderPattern.FindStringSubmatch(m)
+		name, hasDefault, def := sub[1], sub[2] != "", sub[3]
+		value, set := os.LookupEnv(name)
+		switch {
+		case hasDefault && value == "":
+			value = def
+		case !set && p.interpolation:
+			// names a key of the config
+			return m
+		case !set:
+			p.logger.Warn("environment variable not set", "name", name, "key", path)
+		}
+		if p.interpolation {
+			value = strings.ReplaceAll(value, "${", "$${")
+		}
+		return value
+	})
+}
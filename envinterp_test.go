@@ -0,0 +1,104 @@
+package viper
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_WithEnvInterpolation(t *testing.T) {
+	t.Setenv("ENVINTERPTEST_DATABASE_URL", "postgres://db/app")
+	t.Setenv("ENVINTERPTEST_EMPTY", "")
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+database_url: ${ENVINTERPTEST_DATABASE_URL}
+port: ${ENVINTERPTEST_PORT:-8080}
+host: ${ENVINTERPTEST_EMPTY:-localhost}
+missing: "[${ENVINTERPTEST_MISSING}]"
+literal: "$${ENVINTERPTEST_DATABASE_URL}"
+hosts:
+  - "${ENVINTERPTEST_PORT:-9090}"
+`})
+	configFile := filepath.Join(dir, "config.yaml")
+	var logs bytes.Buffer
+	p := New(WithEnvInterpolation(), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"database_url", "postgres://db/app"},
+		{"port", "8080"},
+		{"host", "localhost"},
+		{"missing", "[]"},
+		{"literal", "${ENVINTERPTEST_DATABASE_URL}"},
+	}
+	for _, tt := range tests {
+		if got := p.GetString(tt.path); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	if got := p.GetInt("port"); got != 8080 {
+		t.Errorf("port = %d, want 8080", got)
+	}
+	if got := p.GetStringSlice("hosts"); len(got) != 1 || got[0] != "9090" {
+		t.Errorf("hosts = %v, want [9090]", got)
+	}
+	if !strings.Contains(logs.String(), "name=ENVINTERPTEST_MISSING") {
+		t.Errorf("logs = %s, want the unset variable logged", logs.String())
+	}
+
+	if err := p.Save(configFile); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "postgres://db/app") || !strings.Contains(string(b), "${ENVINTERPTEST_PORT:-8080}") {
+		t.Errorf("saved config = %s, want the placeholders written back", b)
+	}
+}
+
+func TestParser_WithEnvInterpolation_Keys(t *testing.T) {
+	t.Setenv("ENVINTERPTEST_HOST", "env-host")
+	t.Setenv("ENVINTERPTEST_TEMPLATE", "${server.port}")
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+server:
+  port: 8080
+env_host: ${ENVINTERPTEST_HOST}
+key_port: ${server.port}
+url: "http://${ENVINTERPTEST_HOST}:${server.port}"
+raw: ${ENVINTERPTEST_TEMPLATE}
+fallback: ${server}
+`})
+	p := New(WithEnvInterpolation(), WithInterpolation())
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"env_host", "env-host"},
+		{"key_port", "8080"},
+		{"url", "http://env-host:8080"},
+		{"raw", "${server.port}"},
+		{"fallback.port", "8080"},
+	}
+	for _, tt := range tests {
+		if got := p.GetString(tt.path); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	dir = writeFiles(t, map[string]string{"config.yaml": "url: ${ENVINTERPTEST_UNSET}\n"})
+	if _, err := New(WithEnvInterpolation(), WithInterpolation()).Parse(filepath.Join(dir, "config.yaml")); err == nil {
+		t.Error("Parse() of an unset variable naming no key succeeded")
+	}
+}
@@ -0,0 +1,140 @@
+package viper
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// envBindings holds the environment variables looked up beyond the ones
+// viper derives from the keys
+type envBindings struct {
+	// prefixes holds the legacy prefixes still honored behind the primary one
+	prefixes []string
+	mapper   EnvKeyMapper
+	// bound holds the keys bound to their variables
+	bound map[string]bool
+	// warned holds the legacy variables whose use was already logged
+	warned sync.Map
+}
+
+// explicit reports whether the keys need binding to their variables
+func (e *envBindings) explicit() bool {
+	return len(e.prefixes) > 0 || e.mapper != nil
+}
+
+// EnvKeyMapper returns the name of the environment variable of key, a
+// lower-cased dotted path, without the prefix. The name is upper-cased and
+// prefixed afterwards.
+type EnvKeyMapper func(key string) string
+
+// NestedEnvKeys maps the keys to variable names separating the levels with
+// a double underscore, as NEXEN_SERVER__TLS__CERT for server.tls.cert, so
+// keys holding underscores keep distinct names: server.max_conns reads
+// NEXEN_SERVER__MAX_CONNS and server.max.conns NEXEN_SERVER__MAX__CONNS.
+func NestedEnvKeys(key string) string {
+	return strings.ReplaceAll(key, ".", "__")
+}
+
+// WithEnvKeyMapper names the environment variables of the keys with mapper
+// rather than by replacing the dots of the keys with underscores. Only the
+// mapped names are looked up, for the keys the config files, the defaults
+// and BindStructEnv declare, and WriteEnvExample and Origin report them.
+func WithEnvKeyMapper(mapper EnvKeyMapper) Option {
+	return func(p *Parser) {
+		p.env.mapper = mapper
+	}
+}
+
+// WithEnvPrefixes sets the prefixes of the environment variables in order
+// of precedence, as in WithEnvPrefixes("nexen", "legacy") while a product
+// is renamed: NEXEN_DB_HOST sets db.host, and LEGACY_DB_HOST does when the
+// former is not set. The first prefix is the one WithEnvPrefix sets, the
+// others are deprecated: their variables are looked up for the keys the
+// config files, the defaults and BindStructEnv declare, and the first use
+// of each is logged, as is a legacy variable ignored because the variable
+// replacing it is set.
+func WithEnvPrefixes(prefixes ...string) Option {
+	return func(p *Parser) {
+		if len(prefixes) == 0 {
+			return
+		}
+		p.v.SetEnvPrefix(prefixes[0])
+		p.env.prefixes = append([]string(nil), prefixes[1:]...)
+	}
+}
+
+// envName returns the environment variable viper looks up for key
+func (p *Parser) envName(key string) string {
+	return envVar(p.v.GetEnvPrefix(), p.env.mapper, key)
+}
+
+// envNames returns the environment variables setting key, in order of
+// precedence
+func (p *Parser) envNames(key string) []string {
+	names := []string{p.envName(key)}
+	for _, prefix := range p.env.prefixes {
+		names = append(names, envVar(prefix, p.env.mapper, key))
+	}
+	return names
+}
+
+// envVar returns the environment variable of key under prefix
+func envVar(prefix string, mapper EnvKeyMapper, key string) string {
+	name := strings.ReplaceAll(key, ".", "_")
+	if mapper != nil {
+		name = mapper(key)
+	}
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+	return strings.ToUpper(name)
+}
+
+// bindEnv binds the keys of the parser to their variables when viper
+// cannot derive them, and logs the legacy variables in use. Callers hold
+// p.mu.
+func (p *Parser) bindEnv() {
+	if !p.env.explicit() {
+		return
+	}
+	for _, key := range p.v.AllKeys() {
+		names := p.envNames(key)
+		if !p.env.bound[key] {
+			p.bindEnvNames(key, names)
+		}
+		p.warnLegacyEnv(names)
+	}
+}
+
+// bindEnvNames binds key to the variables of names. Callers hold p.mu.
+func (p *Parser) bindEnvNames(key string, names []string) {
+	if p.env.mapper == nil {
+		// viper looks up the primary variable itself, before the bound ones
+		names = names[1:]
+	}
+	_ = p.v.BindEnv(append([]string{key}, names...)...)
+	if p.env.bound == nil {
+		p.env.bound = make(map[string]bool)
+	}
+	p.env.bound[key] = true
+}
+
+// warnLegacyEnv logs the first use of each legacy variable of names
+func (p *Parser) warnLegacyEnv(names []string) {
+	primary := os.Getenv(names[0]) != ""
+	for _, name := range names[1:] {
+		if os.Getenv(name) == "" {
+			continue
+		}
+		if _, warned := p.env.warned.LoadOrStore(name, true); warned {
+			continue
+		}
+		if primary {
+			p.logger.Warn("deprecated environment variable ignored, its replacement is set", "name", name, "use", names[0])
+			continue
+		}
+		p.logger.Warn("deprecated environment variable", "name", name, "use", names[0])
+		primary = true
+	}
+}
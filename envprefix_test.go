@@ -0,0 +1,115 @@
+package viper
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_WithEnvPrefixes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  user: file\n"})
+	var logs bytes.Buffer
+	p := New(WithEnvPrefixes("nexen", "legacy"), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	t.Setenv("LEGACY_DB_HOST", "legacy")
+	t.Setenv("NEXEN_DB_PORT", "6432")
+	t.Setenv("LEGACY_DB_PORT", "7432")
+	t.Setenv("LEGACY_TIMEOUT", "5s")
+	p.SetDefault("timeout", "1s")
+	p.SetDefault("db.host", "localhost")
+	p.SetDefault("db.port", 5432)
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.GetString("db.host"); got != "legacy" {
+		t.Errorf("db.host = %q, want the legacy variable", got)
+	}
+	if got := p.GetInt("db.port"); got != 6432 {
+		t.Errorf("db.port = %d, want the primary prefix to win", got)
+	}
+	if got := p.GetString("db.user"); got != "file" {
+		t.Errorf("db.user = %q, want the file", got)
+	}
+	if got := p.GetString("timeout"); got != "5s" {
+		t.Errorf("timeout = %q, want the legacy variable to override the default", got)
+	}
+	if got := p.Origin("db.host"); got != "env LEGACY_DB_HOST" {
+		t.Errorf("Origin(db.host) = %q, want env LEGACY_DB_HOST", got)
+	}
+	if got := p.GetEnvPrefix(); got != "nexen" {
+		t.Errorf("GetEnvPrefix() = %q, want nexen", got)
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, "name=LEGACY_DB_HOST use=NEXEN_DB_HOST") {
+		t.Errorf("logs = %s, want the use of LEGACY_DB_HOST logged", out)
+	}
+	if !strings.Contains(out, `msg="deprecated environment variable ignored, its replacement is set" name=LEGACY_DB_PORT`) {
+		t.Errorf("logs = %s, want LEGACY_DB_PORT logged as ignored", out)
+	}
+	if n := strings.Count(out, "LEGACY_DB_HOST"); n != 1 {
+		t.Errorf("LEGACY_DB_HOST logged %d times, want once", n)
+	}
+
+	if got := p.Sub("db").GetString("host"); got != "legacy" {
+		t.Errorf("Sub(db) host = %q, want the legacy variable", got)
+	}
+}
+
+func TestParser_WithEnvPrefixes_BindStructEnv(t *testing.T) {
+	type Config struct {
+		Token string `mapstructure:"token"`
+	}
+	p := New(WithEnvPrefixes("nexen", "legacy"))
+	t.Setenv("LEGACY_TOKEN", "abc")
+	if err := p.BindStructEnv(Config{}); err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	if err := p.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "abc" {
+		t.Errorf("token = %q, want the legacy variable", cfg.Token)
+	}
+}
+
+func TestParser_WithEnvKeyMapper(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "server:\n  max:\n    conns: 20\n  tls:\n    key: file.key\n"})
+	p := New(WithEnvKeyMapper(NestedEnvKeys))
+	t.Setenv("NEXEN_SERVER__MAX_CONNS", "30")
+	t.Setenv("NEXEN_SERVER__TLS__CERT", "env.pem")
+	t.Setenv("NEXEN_SERVER_TLS_CERT", "ignored.pem")
+	t.Setenv("NEXEN_SERVER_MAX_CONNS", "40")
+	p.SetDefault("server.max_conns", 10)
+	p.SetDefault("server.tls.cert", "default.pem")
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.GetInt("server.max_conns"); got != 30 {
+		t.Errorf("server.max_conns = %d, want 30", got)
+	}
+	if got := p.GetInt("server.max.conns"); got != 20 {
+		t.Errorf("server.max.conns = %d, want the file value", got)
+	}
+	if got := p.GetString("server.tls.cert"); got != "env.pem" {
+		t.Errorf("server.tls.cert = %q, want the mapped variable", got)
+	}
+	if got := p.Origin("server.tls.cert"); got != "env NEXEN_SERVER__TLS__CERT" {
+		t.Errorf("Origin(server.tls.cert) = %q, want env NEXEN_SERVER__TLS__CERT", got)
+	}
+	if got := p.Sub("server").GetString("tls.cert"); got != "env.pem" {
+		t.Errorf("Sub(server) tls.cert = %q, want the mapped variable", got)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteEnvExample(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "NEXEN_SERVER__MAX__CONNS") {
+		t.Errorf("WriteEnvExample() = %s, want the mapped names", buf.String())
+	}
+}
@@ -0,0 +1,148 @@
+package viper
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// FlattenOptions controls how nested settings are flattened into a flat
+// string map
+type FlattenOptions struct {
+	// Separator joins the keys of nested maps, "." when empty
+	Separator string
+	// Prefix is prepended to every key
+	Prefix string
+	// UpperCase upper-cases the keys
+	UpperCase bool
+	// ListSeparator joins the items of lists made of scalars. When empty,
+	// lists are encoded as JSON.
+	ListSeparator string
+}
+
+// ToStringMapString flattens the settings into a map of strings, the only
+// shape practical to hand across process or FFI boundaries. Lists of maps
+// and lists mixing types are always encoded as JSON.
+func (c *Config) ToStringMapString(opts FlattenOptions) map[string]string {
+	if opts.Separator == "" {
+		opts.Separator = "."
+	}
+	out := make(map[string]string)
+	flattenStrings(out, opts, "", c.Raw)
+	return out
+}
+
+// EnvVar is an environment variable exported from the config
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// String returns the variable in the NAME=value form used by os/exec
+func (e EnvVar) String() string {
+	return e.Name + "=" + e.Value
+}
+
+// ToEnvStruct exports the settings as environment variables, sorted by name,
+// named the way the parser looks them up: upper-cased, prefixed with the env
+// prefix and with dots replaced by underscores, or named by the mapper set
+// with WithEnvKeyMapper. A child process using this package with the same
+// prefix and mapper reads the same config back. Lists of scalars are joined
+// with spaces.
+func (c *Config) ToEnvStruct() []EnvVar {
+	prefix := ""
+	if c.Viper != nil {
+		prefix = c.Viper.GetEnvPrefix()
+	}
+	flat := c.ToStringMapString(FlattenOptions{ListSeparator: " "})
+
+	vars := make([]EnvVar, 0, len(flat))
+	for key, value := range flat {
+		vars = append(vars, EnvVar{Name: envVar(prefix, c.envMapper, strings.ToLower(key)), Value: value})
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars
+}
+
+func flattenStrings(out map[string]string, opts FlattenOptions, path string, m map[string]interface{}) {
+	for k, v := range m {
+		key := k
+		if opts.UpperCase {
+			key = strings.ToUpper(k)
+		}
+		if path != "" {
+			key = path + opts.Separator + key
+		}
+
+		if nested, ok := toStringMap(v); ok {
+			flattenStrings(out, opts, key, nested)
+			continue
+		}
+		out[opts.Prefix+key] = exportString(v, opts.ListSeparator)
+	}
+}
+
+// exportString renders a leaf value
+func exportString(v interface{}, listSep string) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case time.Duration:
+		return t.String()
+	case []interface{}:
+		if listSep != "" {
+			if items, ok := scalarStrings(t); ok {
+				return strings.Join(items, listSep)
+			}
+		}
+		b, err := json.Marshal(normalizeJSON(t))
+		if err != nil {
+			return fmt.Sprint(t)
+		}
+		return string(b)
+	}
+	if s, err := cast.ToStringE(v); err == nil {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// scalarStrings renders the items of a list made only of scalars
+func scalarStrings(list []interface{}) ([]string, bool) {
+	items := make([]string, len(list))
+	for i, item := range list {
+		switch item.(type) {
+		case []interface{}, map[string]interface{}, map[interface{}]interface{}:
+			return nil, false
+		}
+		items[i] = exportString(item, "")
+	}
+	return items, true
+}
+
+// normalizeJSON converts the map flavours json.Marshal cannot encode
+func normalizeJSON(v interface{}) interface{} {
+	if m, ok := toStringMap(v); ok {
+		out := make(map[string]interface{}, len(m))
+		for k, item := range m {
+			out[k] = normalizeJSON(item)
+		}
+		return out
+	}
+	if list, ok := v.([]interface{}); ok {
+		out := make([]interface{}, len(list))
+		for i, item := range list {
+			out[i] = normalizeJSON(item)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,143 @@
+package viper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const exportConfig = `
+server:
+  host: api.local
+  port: 8080
+  tls: true
+tags: [a, b]
+servers:
+  - name: a
+started: 2024-05-01T10:00:00Z
+`
+
+func TestConfig_ToStringMapString(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": exportConfig})
+	cfg, err := New().Parse(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts FlattenOptions
+		want map[string]string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{
+				"server.host": "api.local",
+				"server.port": "8080",
+				"server.tls":  "true",
+				"tags":        `["a","b"]`,
+				"servers":     `[{"name":"a"}]`,
+				"started":     "2024-05-01T10:00:00Z",
+			},
+		},
+		{
+			name: "prefix and separators",
+			opts: FlattenOptions{Separator: "__", Prefix: "app/", UpperCase: true, ListSeparator: ","},
+			want: map[string]string{
+				"app/SERVER__HOST": "api.local",
+				"app/SERVER__PORT": "8080",
+				"app/SERVER__TLS":  "true",
+				"app/TAGS":         "a,b",
+				"app/SERVERS":      `[{"name":"a"}]`,
+				"app/STARTED":      "2024-05-01T10:00:00Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.ToStringMapString(tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToStringMapString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_ToEnvStruct(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": exportConfig})
+	cfg, err := New(WithEnvPrefix("exporttest")).Parse(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := cfg.ToEnvStruct()
+	names := make([]string, len(vars))
+	for i, v := range vars {
+		names[i] = v.String()
+	}
+	want := []string{
+		"EXPORTTEST_SERVERS=[{\"name\":\"a\"}]",
+		"EXPORTTEST_SERVER_HOST=api.local",
+		"EXPORTTEST_SERVER_PORT=8080",
+		"EXPORTTEST_SERVER_TLS=true",
+		"EXPORTTEST_STARTED=2024-05-01T10:00:00Z",
+		"EXPORTTEST_TAGS=a b",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("ToEnvStruct() = %v, want %v", names, want)
+	}
+
+	// a parser in a child process reads the same values back
+	for _, v := range vars {
+		t.Setenv(v.Name, v.Value)
+	}
+	empty := filepath.Join(t.TempDir(), "empty.yaml")
+	if err := os.WriteFile(empty, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	child := New(WithEnvPrefix("exporttest"))
+	if _, err := child.Parse(empty); err != nil {
+		t.Fatal(err)
+	}
+	if got := child.GetInt("server.port"); got != 8080 {
+		t.Errorf("server.port read back = %d, want 8080", got)
+	}
+	if got := strings.Join(child.GetStringSlice("tags"), ","); got != "a,b" {
+		t.Errorf("tags read back = %q, want a,b", got)
+	}
+}
+
+func TestConfig_ToEnvStructMapper(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "server:\n  max_conns: 10\n  tls:\n    cert: a.pem\n"})
+	cfg, err := New(WithEnvPrefix("mappertest"), WithEnvKeyMapper(NestedEnvKeys)).Parse(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := cfg.ToEnvStruct()
+	want := []EnvVar{
+		{"MAPPERTEST_SERVER__MAX_CONNS", "10"},
+		{"MAPPERTEST_SERVER__TLS__CERT", "a.pem"},
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Fatalf("ToEnvStruct() = %v, want %v", vars, want)
+	}
+
+	// a parser with the same mapper reads the same values back
+	for _, v := range vars {
+		t.Setenv(v.Name, v.Value)
+	}
+	child := New(WithEnvPrefix("mappertest"), WithEnvKeyMapper(NestedEnvKeys), WithDefaults(map[string]interface{}{
+		"server": map[string]interface{}{"max_conns": 1, "tls": map[string]interface{}{"cert": "b.pem"}},
+	}))
+	if _, err := child.ParseBytes([]byte("name: child\n"), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if got := child.GetInt("server.max_conns"); got != 10 {
+		t.Errorf("server.max_conns read back = %d, want 10", got)
+	}
+	if got := child.GetString("server.tls.cert"); got != "a.pem" {
+		t.Errorf("server.tls.cert read back = %q, want a.pem", got)
+	}
+}
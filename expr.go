@@ -0,0 +1,379 @@
+package viper
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/spf13/cast"
+)
+
+// WatchExpr calls callback whenever a reload changes the result of expr,
+// instead of on every change of the files it reads from. Expressions read
+// keys by their dot-notation path and support numbers, quoted strings,
+// true, false and nil, the arithmetic operators + - * / %, the comparisons
+// == != < <= > >=, the boolean operators && || ! and parentheses, as in
+// "limits.max_conns * workers" or "tls.enabled && server.port != 443".
+// Missing keys evaluate to nil. When the evaluation fails, the error is
+// logged and the previous result is kept. The returned function cancels the
+// watch.
+func (p *Parser) WatchExpr(expr string, callback func(old, new interface{})) (cancel func(), err error) {
+	node, err := parseExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expr, err)
+	}
+
+	var mu sync.Mutex
+	cancelled := false
+	last, err := node.eval(p.Get)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating %q: %w", expr, err)
+	}
+	remove := p.onChange(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if cancelled {
+			return
+		}
+		v, err := node.eval(p.Get)
+		if err != nil {
+			p.logger.Warn("cannot evaluate watched expression", "expr", expr, "error", err)
+			return
+		}
+		if reflect.DeepEqual(v, last) {
+			return
+		}
+		old := last
+		last = v
+		callback(old, v)
+	})
+	return func() {
+		remove()
+		mu.Lock()
+		defer mu.Unlock()
+		cancelled = true
+	}, nil
+}
+
+// exprNode is a node of a parsed expression
+type exprNode interface {
+	eval(get func(string) interface{}) (interface{}, error)
+}
+
+type (
+	literalNode struct{ value interface{} }
+	keyNode     struct{ key string }
+	unaryNode   struct {
+		op      string
+		operand exprNode
+	}
+	binaryNode struct {
+		op          string
+		left, right exprNode
+	}
+)
+
+func (n literalNode) eval(func(string) interface{}) (interface{}, error) { return n.value, nil }
+
+func (n keyNode) eval(get func(string) interface{}) (interface{}, error) {
+	v := get(n.key)
+	if isNumber(v) {
+		return cast.ToFloat64(v), nil
+	}
+	return v, nil
+}
+
+func (n unaryNode) eval(get func(string) interface{}) (interface{}, error) {
+	v, err := n.operand.eval(get)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		b, err := cast.ToBoolE(v)
+		if err != nil {
+			return nil, fmt.Errorf("! expects a boolean, got %v", v)
+		}
+		return !b, nil
+	}
+	f, ok := asNumber(v)
+	if !ok {
+		return nil, fmt.Errorf("- expects a number, got %v", v)
+	}
+	return -f, nil
+}
+
+func (n binaryNode) eval(get func(string) interface{}) (interface{}, error) {
+	left, err := n.left.eval(get)
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit the boolean operators
+	if n.op == "&&" || n.op == "||" {
+		l, err := cast.ToBoolE(left)
+		if err != nil {
+			return nil, fmt.Errorf("%s expects booleans, got %v", n.op, left)
+		}
+		if l == (n.op == "||") {
+			return l, nil
+		}
+		right, err := n.right.eval(get)
+		if err != nil {
+			return nil, err
+		}
+		r, err := cast.ToBoolE(right)
+		if err != nil {
+			return nil, fmt.Errorf("%s expects booleans, got %v", n.op, right)
+		}
+		return r, nil
+	}
+
+	right, err := n.right.eval(get)
+	if err != nil {
+		return nil, err
+	}
+	ls, lstr := left.(string)
+	rs, rstr := right.(string)
+	l, lok := asNumber(left)
+	r, rok := asNumber(right)
+	// values read from the environment are strings, compare them as numbers
+	// when the other side is one
+	numeric := lok && rok && !(lstr && rstr)
+
+	switch n.op {
+	case "==", "!=":
+		equal := reflect.DeepEqual(left, right)
+		if numeric {
+			equal = l == r
+		}
+		return equal == (n.op == "=="), nil
+	}
+
+	if lstr && rstr {
+		switch n.op {
+		case "+":
+			return ls + rs, nil
+		case "<":
+			return ls < rs, nil
+		case "<=":
+			return ls <= rs, nil
+		case ">":
+			return ls > rs, nil
+		case ">=":
+			return ls >= rs, nil
+		}
+		return nil, fmt.Errorf("%s is not defined on strings", n.op)
+	}
+	if !numeric {
+		return nil, fmt.Errorf("%s expects numbers, got %v and %v", n.op, left, right)
+	}
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/", "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if n.op == "/" {
+			return l / r, nil
+		}
+		return float64(int64(l) % int64(r)), nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+// asNumber converts the operands of arithmetic operators, accepting the
+// numeric strings found in env values
+func asNumber(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func isNumber(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// binaryPrecedence lists the binary operators, loosest binding first
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+// exprParser is a precedence climbing parser over the tokens of an expression
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseExpr(expr string) (exprNode, error) {
+	tokens, err := tokenizeExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	ep := &exprParser{tokens: tokens}
+	node, err := ep.parse(1)
+	if err != nil {
+		return nil, err
+	}
+	if ep.pos < len(ep.tokens) {
+		return nil, fmt.Errorf("unexpected %q", ep.tokens[ep.pos])
+	}
+	return node, nil
+}
+
+func (ep *exprParser) next() string {
+	if ep.pos >= len(ep.tokens) {
+		return ""
+	}
+	tok := ep.tokens[ep.pos]
+	ep.pos++
+	return tok
+}
+
+func (ep *exprParser) peek() string {
+	if ep.pos >= len(ep.tokens) {
+		return ""
+	}
+	return ep.tokens[ep.pos]
+}
+
+// parse reads an expression made of operators binding at least as tightly
+// as minPrec
+func (ep *exprParser) parse(minPrec int) (exprNode, error) {
+	left, err := ep.operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ep.peek()
+		prec, ok := binaryPrecedence[op]
+		if !ok || prec < minPrec {
+			return left, nil
+		}
+		ep.next()
+		right, err := ep.parse(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (ep *exprParser) operand() (exprNode, error) {
+	tok := ep.next()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tok == "(":
+		node, err := ep.parse(1)
+		if err != nil {
+			return nil, err
+		}
+		if ep.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return node, nil
+	case tok == "!" || tok == "-":
+		operand, err := ep.operand()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op: tok, operand: operand}, nil
+	case tok[0] == '"' || tok[0] == '\'':
+		return literalNode{value: tok[1 : len(tok)-1]}, nil
+	case tok[0] >= '0' && tok[0] <= '9':
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok)
+		}
+		return literalNode{value: f}, nil
+	case tok == "true" || tok == "false":
+		return literalNode{value: tok == "true"}, nil
+	case tok == "nil":
+		return literalNode{}, nil
+	case isIdentStart(rune(tok[0])):
+		return keyNode{key: tok}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", tok)
+}
+
+func tokenizeExpr(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], expr[i])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, expr[i:i+end+2])
+			i += end + 2
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(expr) && (isIdentStart(rune(expr[j])) || expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			if i+1 < len(expr) {
+				if two := expr[i : i+2]; two == "&&" || two == "||" || two == "==" || two == "!=" || two == "<=" || two == ">=" {
+					tokens = append(tokens, two)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/%<>!()", c) {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
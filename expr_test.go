@@ -0,0 +1,120 @@
+package viper
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	values := map[string]interface{}{
+		"limits.max_conns": 100,
+		"workers":          4,
+		"ratio":            0.5,
+		"env.workers":      "3",
+		"tls.enabled":      true,
+		"server.port":      8443,
+		"name":             "api",
+	}
+	get := func(key string) interface{} { return values[key] }
+
+	tests := []struct {
+		expr    string
+		want    interface{}
+		wantErr bool
+	}{
+		{expr: "limits.max_conns * workers", want: 400.0},
+		{expr: "limits.max_conns * workers * ratio + 1", want: 201.0},
+		{expr: "(workers + 1) * 2", want: 10.0},
+		{expr: "-workers + 10 % 4", want: -2.0},
+		{expr: "env.workers * 2", want: 6.0},
+		{expr: "env.workers == 3", want: true},
+		{expr: "tls.enabled && server.port != 443", want: true},
+		{expr: "!tls.enabled || missing == nil", want: true},
+		{expr: "name + '-v2'", want: "api-v2"},
+		{expr: `name == "api" && workers >= 4`, want: true},
+		{expr: "missing > 1", wantErr: true},
+		{expr: "workers / 0", wantErr: true},
+		{expr: "name * 2", wantErr: true},
+		{expr: "(workers + 1", wantErr: true},
+		{expr: "workers +", wantErr: true},
+		{expr: "workers $ 2", wantErr: true},
+		{expr: "'open", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := parseExpr(tt.expr)
+			var got interface{}
+			if err == nil {
+				got, err = node.eval(get)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("result = %v (%T), want %v", got, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_WatchExpr(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"v1.json": `{"limits": {"max_conns": 100}, "workers": 4}`,
+		"v2.json": `{"limits": {"max_conns": 200}, "workers": 2}`,
+		"v3.json": `{"limits": {"max_conns": 200}, "workers": 3}`,
+	})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "v1.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	type change struct{ old, new interface{} }
+	var changes []change
+	if _, err := p.WatchExpr("limits.max_conns * workers", func(old, new interface{}) {
+		changes = append(changes, change{old, new})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Override("unrelated", true)
+	// different inputs with the same result
+	if _, err := p.Parse(filepath.Join(dir, "v2.json")); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("callback called for an unchanged result: %v", changes)
+	}
+
+	if _, err := p.Parse(filepath.Join(dir, "v3.json")); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].old != 400.0 || changes[0].new != 600.0 {
+		t.Errorf("changes = %v, want 400 -> 600", changes)
+	}
+
+	if _, err := p.WatchExpr("workers *", nil); err == nil {
+		t.Error("WatchExpr() of an invalid expression should fail")
+	}
+}
+
+func TestParser_WatchExprCancel(t *testing.T) {
+	p := New()
+	if _, err := p.ParseBytes([]byte(`{"workers": 4}`), "json"); err != nil {
+		t.Fatal(err)
+	}
+	var changes int
+	cancel, err := p.WatchExpr("workers * 2", func(old, new interface{}) { changes++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Override("workers", 5)
+	cancel()
+	p.Override("workers", 6)
+	if changes != 1 {
+		t.Errorf("callback called %d times, want 1 before the cancel", changes)
+	}
+	if len(p.listeners) != 0 {
+		t.Errorf("%d listeners left after the cancel, want none", len(p.listeners))
+	}
+}
@@ -0,0 +1,143 @@
+package viper
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// extendsKey is the reserved key a config file uses to declare the file or
+// list of files it is based on
+const extendsKey = "extends"
+
+// readChain reads a config file together with the files it extends. Parents
+// are merged in the order they are listed and the extending file is merged
+// last, so the most specific file always wins. Relative parent paths are
+// resolved from the directory of the file declaring them.
+func (p *Parser) readChain(configFile, typ string, chain []string, depth int) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(configFile)
+	if err != nil {
+		return nil, err
+	}
+	chain = append(chain, abs)
+
+	settings, err := p.readFile(configFile, typ)
+	if err != nil {
+		return nil, err
+	}
+	if settings, err = p.migrate(configFile, settings); err != nil {
+		return nil, fmt.Errorf("%s: %w", configFile, err)
+	}
+	schema, err := popSchema(configFile, settings)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configFile, err)
+	}
+	if schema != "" {
+		p.pending.addSchema(schema)
+	}
+	if settings, err = p.normalizeKeys(settings); err != nil {
+		return nil, fmt.Errorf("%s: %w", configFile, err)
+	}
+	p.resolvePaths(configFile, settings)
+
+	parents, err := popFiles(settings, extendsKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configFile, err)
+	}
+	var includes []string
+	if p.includeDepth > 0 {
+		if includes, err = popFiles(settings, includeKey); err != nil {
+			return nil, fmt.Errorf("%s: %w", configFile, err)
+		}
+	}
+
+	merged := make(map[string]interface{})
+	for _, parent := range parents {
+		parent = p.relativeTo(configFile, parent)
+		parentType := p.typeOf(parent)
+		if filepath.Ext(parent) == "" {
+			parentType = typ
+		}
+		if err := checkCycle(extendsKey, parent, chain); err != nil {
+			return nil, err
+		}
+		ps, err := p.readChain(parent, parentType, chain, depth)
+		if err != nil {
+			return nil, fmt.Errorf("extending %q: %w", parent, err)
+		}
+		merged = p.merge(merged, ps)
+	}
+	if err := p.pending.setOrigin(configFile, settings); err != nil {
+		return nil, err
+	}
+	merged = p.merge(merged, settings)
+	if len(includes) == 0 {
+		return merged, nil
+	}
+	included, err := p.readIncludes(configFile, typ, includes, chain, depth)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configFile, err)
+	}
+	return p.merge(merged, included), nil
+}
+
+// checkCycle fails when the file named by the directive of the last file
+// of chain is already part of it
+func checkCycle(directive, file string, chain []string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	for _, seen := range chain {
+		if seen == abs {
+			return fmt.Errorf("%s cycle: %s", directive, strings.Join(append(chain, abs), " -> "))
+		}
+	}
+	return nil
+}
+
+// relativeTo resolves a path named by configFile from its directory
+func (p *Parser) relativeTo(configFile, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	if p.inFS(configFile) {
+		dir := path.Dir(filepath.ToSlash(strings.TrimPrefix(configFile, fsPrefix)))
+		return fsPrefix + path.Join(dir, filepath.ToSlash(name))
+	}
+	return filepath.Join(filepath.Dir(configFile), name)
+}
+
+// popFiles removes the directive key from the settings and returns the
+// files it lists
+func popFiles(settings map[string]interface{}, directive string) ([]string, error) {
+	var raw interface{}
+	found := false
+	for k, v := range settings {
+		if strings.EqualFold(k, directive) {
+			raw = v
+			found = true
+			delete(settings, k)
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	switch v := raw.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		files := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%q entries must be file paths, got %T", directive, item)
+			}
+			files = append(files, s)
+		}
+		return files, nil
+	}
+	return nil, fmt.Errorf("%q must be a file path or a list of file paths, got %T", directive, raw)
+}
@@ -0,0 +1,108 @@
+package viper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tmpDir
+}
+
+// replaceFile atomically replaces the content of a watched file, so the
+// watcher never reads it half written
+func replaceFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParser_Extends(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base/common.yaml": "name: common\ndb:\n  host: localhost\n  pool: 4\nlog: info\n",
+		"base/tls.json":    `{"tls": {"enabled": true}, "log": "warn"}`,
+		"service.yaml":     "extends: [base/common.yaml, base/tls.json]\nname: service\ndb:\n  pool: 16\n",
+		"prod.yaml":        "extends: service.yaml\nlog: error\n",
+	})
+
+	p := New()
+	cfg, err := p.Parse(filepath.Join(dir, "prod.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"name", "service"},
+		{"db.host", "localhost"},
+		{"db.pool", 16},
+		{"tls.enabled", true},
+		{"log", "error"},
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.path); !jsonEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if _, ok := cfg.Raw[extendsKey]; ok {
+		t.Error("extends key leaked into the effective config")
+	}
+}
+
+func TestParser_ExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"config.yaml": "extends: a.yaml\n",
+				"a.yaml":      "extends: b.yaml\n",
+				"b.yaml":      "extends: a.yaml\n",
+			},
+			want: "extends cycle",
+		},
+		{
+			name:  "missing parent",
+			files: map[string]string{"config.yaml": "extends: nope.yaml\n"},
+			want:  "nope.yaml",
+		},
+		{
+			name:  "invalid value",
+			files: map[string]string{"config.yaml": "extends: {a: b}\n"},
+			want:  "must be a file path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, err := New().Parse(filepath.Join(dir, "config.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package viper
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// FallbackUse reports the reads of a key served by one of its fallbacks
+type FallbackUse struct {
+	Key      string
+	Fallback string
+	Reads    uint64
+}
+
+// fallbackKeys holds the fallback chains and counts their use
+type fallbackKeys struct {
+	chains map[string][]string
+	// used maps key + "\x00" + fallback to an *atomic.Uint64
+	used sync.Map
+}
+
+// Fallback declares the keys read, in order, when key is not set by any
+// source, so a renamed or consolidated setting keeps resolving through its
+// older names during a migration: Fallback("cache.addr", "redis.addr").
+// Fallbacks are not chained: the fallbacks of a fallback are ignored. The
+// first read of a key through each fallback is logged and every read is
+// counted in FallbackUsage, so the remaining users of the old names can be
+// found.
+func (p *Parser) Fallback(key string, fallbacks ...string) {
+	key = strings.ToLower(p.normalizePath(key))
+	chain := make([]string, len(fallbacks))
+	for i, fb := range fallbacks {
+		chain[i] = strings.ToLower(p.normalizePath(fb))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fallbacks.chains == nil {
+		p.fallbacks.chains = make(map[string][]string)
+	}
+	p.fallbacks.chains[key] = chain
+	p.version++
+}
+
+// FallbackUsage returns the number of reads served by each fallback since
+// it was declared, most used first
+func (p *Parser) FallbackUsage() []FallbackUse {
+	var uses []FallbackUse
+	p.fallbacks.used.Range(func(k, c interface{}) bool {
+		key, fb, _ := strings.Cut(k.(string), "\x00")
+		uses = append(uses, FallbackUse{Key: key, Fallback: fb, Reads: c.(*atomic.Uint64).Load()})
+		return true
+	})
+	sort.Slice(uses, func(i, j int) bool {
+		if uses[i].Reads != uses[j].Reads {
+			return uses[i].Reads > uses[j].Reads
+		}
+		if uses[i].Key != uses[j].Key {
+			return uses[i].Key < uses[j].Key
+		}
+		return uses[i].Fallback < uses[j].Fallback
+	})
+	return uses
+}
+
+// fallbackValue returns the value of the first fallback of path that is
+// set, recording its use. Callers hold the read lock.
+func (p *Parser) fallbackValue(path string, derived func(string) (interface{}, bool)) interface{} {
+	key := strings.ToLower(path)
+	for _, fb := range p.fallbacks.chains[key] {
+		v := p.lookupKey(fb, derived)
+		if v == nil {
+			continue
+		}
+		p.countFallback(key, fb)
+		return v
+	}
+	return nil
+}
+
+func (p *Parser) countFallback(key, fb string) {
+	c, loaded := p.fallbacks.used.Load(key + "\x00" + fb)
+	if !loaded {
+		c, loaded = p.fallbacks.used.LoadOrStore(key+"\x00"+fb, new(atomic.Uint64))
+		if !loaded {
+			p.logger.Warn("config key read through its fallback", "key", key, "fallback", fb)
+		}
+	}
+	c.(*atomic.Uint64).Add(1)
+}
+
+// fallbackOrigin describes the fallback serving key, if any. Callers hold
+// the read lock.
+func (p *Parser) fallbackOrigin(key string) (string, bool) {
+	for _, fb := range p.fallbacks.chains[key] {
+		if p.lookupKey(fb, p.derivedValue) != nil {
+			return "fallback " + fb + ": " + p.origin(fb), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,66 @@
+package viper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParser_Fallback(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+redis:
+  addr: redis:6379
+legacy:
+  timeout: 5s
+queue:
+  url: amqp://new
+  old_url: amqp://old
+`})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	p.Fallback("cache.addr", "cache.host", "redis.addr")
+	p.Fallback("cache.timeout", "legacy.timeout")
+	p.Fallback("queue.url", "queue.old_url")
+
+	for key, want := range map[string]string{
+		"cache.addr": "redis:6379",
+		// the key itself wins over its fallbacks
+		"queue.url": "amqp://new",
+		"missing":   "",
+	} {
+		if got := p.GetString(key); got != want {
+			t.Errorf("GetString(%q) = %q, want %q", key, got, want)
+		}
+	}
+	p.GetString("cache.addr")
+
+	var cfg struct {
+		Cache struct {
+			Addr    string
+			Timeout string
+		}
+	}
+	if err := p.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Cache.Addr != "redis:6379" || cfg.Cache.Timeout != "5s" {
+		t.Errorf("Unmarshal() = %+v, want the fallbacks applied", cfg)
+	}
+
+	p.mu.RLock()
+	origin := p.origin("cache.addr")
+	p.mu.RUnlock()
+	if want := "fallback redis.addr: " + filepath.Join(dir, "config.yaml"); origin != want {
+		t.Errorf("origin of cache.addr = %q, want %q", origin, want)
+	}
+
+	want := []FallbackUse{
+		{Key: "cache.addr", Fallback: "redis.addr", Reads: 3},
+		{Key: "cache.timeout", Fallback: "legacy.timeout", Reads: 1},
+	}
+	if got := p.FallbackUsage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FallbackUsage() = %+v, want %+v", got, want)
+	}
+}
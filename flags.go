@@ -0,0 +1,124 @@
+package viper
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// BindFlags binds every flag of fs to the key named after it, as in
+// --db.host for db.host, normalized like the other keys with
+// WithKeyNormalization. A flag set on the command line overrides the config
+// files and the environment variables, while the default of a flag left
+// unset only applies to keys nothing else sets. Overrides still win
+// over flags. Flags may be bound before or after fs is parsed.
+func (p *Parser) BindFlags(fs *pflag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err == nil {
+			err = p.BindFlag(f.Name, f)
+		}
+	})
+	return err
+}
+
+// BindFlag binds a single flag to key, like BindFlags does for flags named
+// after their key
+func (p *Parser) BindFlag(key string, f *pflag.Flag) error {
+	if f == nil {
+		return fmt.Errorf("cannot bind key %q to a nil flag", key)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key = p.normalizePath(key)
+	if err := p.v.BindPFlag(key, f); err != nil {
+		return fmt.Errorf("cannot bind flag %q: %w", f.Name, err)
+	}
+	p.bindFlag(key, func() bool { return f.Changed })
+	p.version++
+	return nil
+}
+
+// BindGoFlags binds every flag of a flag.FlagSet of the standard library,
+// like flag.CommandLine, the way BindFlags does for pflag flag sets
+func (p *Parser) BindGoFlags(fs *flag.FlagSet) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		key, value := p.normalizePath(f.Name), goFlag{fs: fs, f: f}
+		if err = p.v.BindFlagValue(key, value); err == nil {
+			p.bindFlag(key, value.HasChanged)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("cannot bind flags: %w", err)
+	}
+	p.version++
+	return nil
+}
+
+// bindFlag records the flag bound to key, which takes precedence over the
+// config layer once set. Callers hold p.mu.
+func (p *Parser) bindFlag(key string, changed func() bool) {
+	if p.flags == nil {
+		p.flags = make(map[string]func() bool)
+	}
+	p.flags[strings.ToLower(key)] = changed
+}
+
+// setFlags sets in settings, the settings at prefix, the values of the
+// flags under prefix set on the command line. Callers hold the read lock.
+func (p *Parser) setFlags(settings map[string]interface{}, prefix string) {
+	for key, changed := range p.flags {
+		rel, ok := cutPath(key, prefix)
+		if ok && rel != "" && changed() {
+			setPath(settings, rel, p.v.Get(key))
+		}
+	}
+}
+
+// goFlag adapts a flag of the standard library to viper.FlagValue
+type goFlag struct {
+	fs *flag.FlagSet
+	f  *flag.Flag
+}
+
+// HasChanged reports whether the flag was set on the command line. It is
+// checked on every read, so it holds once fs is parsed.
+func (g goFlag) HasChanged() bool {
+	set := false
+	g.fs.Visit(func(f *flag.Flag) {
+		if f == g.f {
+			set = true
+		}
+	})
+	return set
+}
+
+func (g goFlag) Name() string { return g.f.Name }
+
+func (g goFlag) ValueString() string { return g.f.Value.String() }
+
+// ValueType returns the pflag name of the type of the flag, viper casting
+// the value of bool and int flags and reading the others as strings
+func (g goFlag) ValueType() string {
+	getter, ok := g.f.Value.(flag.Getter)
+	if !ok {
+		return "string"
+	}
+	switch getter.Get().(type) {
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case int64:
+		return "int64"
+	}
+	return "string"
+}
@@ -0,0 +1,103 @@
+package viper
+
+import (
+	"flag"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestParser_BindFlags(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  host: file\n  port: 5432\n"})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NEXEN_DB_USER", "env")
+
+	fs := pflag.NewFlagSet("svc", pflag.ContinueOnError)
+	fs.String("db.host", "flag-default", "")
+	fs.Int("db.port", 1, "")
+	fs.String("db.user", "flag-default", "")
+	fs.Duration("timeout", 5*time.Second, "")
+	pool := fs.Int("pool", 0, "")
+	if err := p.BindFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.BindFlag("db.pool", fs.Lookup("pool")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--db.port=6432", "--pool=8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.GetInt("db.port"); got != 6432 {
+		t.Errorf("db.port = %d, want the flag to override the file", got)
+	}
+	if got := p.Origin("db.port"); got != "flag" {
+		t.Errorf("Origin(db.port) = %q, want flag", got)
+	}
+	if db := p.GetStringMap("db"); db["port"] != 6432 || db["host"] != "file" {
+		t.Errorf("db = %v, want the flag over the file in the section", db)
+	}
+	if got := p.GetString("db.host"); got != "file" {
+		t.Errorf("db.host = %q, want the file to win over a flag default", got)
+	}
+	if got := p.GetString("db.user"); got != "env" {
+		t.Errorf("db.user = %q, want the environment to win over a flag default", got)
+	}
+	if got := p.GetDuration("timeout"); got != 5*time.Second {
+		t.Errorf("timeout = %v, want the flag default for an unset key", got)
+	}
+	if got := p.GetInt("db.pool"); got != *pool {
+		t.Errorf("db.pool = %d, want the flag bound to it", got)
+	}
+
+	p.Set("db.port", 7000)
+	if got := p.GetInt("db.port"); got != 7000 {
+		t.Errorf("db.port = %d, want the override to win over the flag", got)
+	}
+	if err := p.BindFlag("db.port", nil); err == nil {
+		t.Error("BindFlag() of a nil flag succeeded")
+	}
+}
+
+func TestParser_BindGoFlags(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "debug: false\nworkers: 2\nname: file\n"})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("svc", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Bool("debug", false, "")
+	fs.Int("workers", 1, "")
+	fs.String("name", "flag-default", "")
+	fs.Duration("grace", time.Second, "")
+	if err := p.BindGoFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetInt("workers"); got != 2 {
+		t.Errorf("workers = %d before parsing the flags, want the file value", got)
+	}
+	if err := fs.Parse([]string{"-debug", "-workers", "8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.GetBool("debug") {
+		t.Error("debug = false, want the flag to override the file")
+	}
+	if got := p.GetInt("workers"); got != 8 {
+		t.Errorf("workers = %d, want 8", got)
+	}
+	if got := p.GetString("name"); got != "file" {
+		t.Errorf("name = %q, want the file to win over a flag default", got)
+	}
+	if got := p.GetDuration("grace"); got != time.Second {
+		t.Errorf("grace = %v, want the flag default", got)
+	}
+}
@@ -0,0 +1,300 @@
+package viper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/printer"
+	hcljson "github.com/hashicorp/hcl/json/parser"
+	"github.com/magiconair/properties"
+	"gopkg.in/ini.v1"
+)
+
+// keyTree builds the nested settings of the formats defining values by
+// dot-separated key paths, like INI sections and Java properties. Keys are
+// matched ignoring case, as viper does not tell them apart either.
+type keyTree struct {
+	settings map[string]interface{}
+	seen     keySet
+	dups     *duplicates
+}
+
+func newKeyTree(dups *duplicates) *keyTree {
+	return &keyTree{settings: map[string]interface{}{}, seen: keySet{}, dups: dups}
+}
+
+// set stores value at path, defined at loc
+func (t *keyTree) set(path string, value interface{}, loc Location) error {
+	if first, dup := t.seen.add(path, loc); dup {
+		t.dups.report(first.key, first.loc, loc)
+	}
+	parts := strings.Split(path, ".")
+	m, err := t.section(parts[:len(parts)-1])
+	if err != nil {
+		return err
+	}
+	last := t.key(m, parts[len(parts)-1])
+	if _, isMap := m[last].(map[string]interface{}); isMap {
+		return fmt.Errorf("key %q is both a value and a section", path)
+	}
+	m[last] = value
+	return nil
+}
+
+// section returns the map at path, creating it as needed
+func (t *keyTree) section(path []string) (map[string]interface{}, error) {
+	m := t.settings
+	for i, part := range path {
+		k := t.key(m, part)
+		switch next := m[k].(type) {
+		case nil:
+			nested := make(map[string]interface{})
+			m[k] = nested
+			m = nested
+		case map[string]interface{}:
+			m = next
+		default:
+			return nil, fmt.Errorf("key %q is both a value and a section", strings.Join(path[:i+1], "."))
+		}
+	}
+	return m, nil
+}
+
+// key returns the key of m matching k ignoring case, or k
+func (t *keyTree) key(m map[string]interface{}, k string) string {
+	if _, ok := m[k]; ok {
+		return k
+	}
+	for existing := range m {
+		if strings.EqualFold(existing, k) {
+			return existing
+		}
+	}
+	return k
+}
+
+// result copies the settings into v, failing on the duplicates found
+func (t *keyTree) result(v map[string]interface{}) error {
+	for k, val := range t.settings {
+		v[k] = val
+	}
+	return t.dups.err()
+}
+
+// leaves returns the values of settings by dot-notation path in lexical
+// order, for the formats without nesting or lists
+func leaves(format string, settings map[string]interface{}) ([]string, map[string]string, error) {
+	flat := flatten(settings)
+	keys := make([]string, 0, len(flat))
+	values := make(map[string]string, len(flat))
+	for k, v := range flat {
+		switch v := v.(type) {
+		case []interface{}:
+			return nil, nil, fmt.Errorf("%s: %s: lists cannot be written as %s", format, k, format)
+		case nil:
+			values[k] = ""
+		default:
+			values[k] = fmt.Sprint(v)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, values, nil
+}
+
+// iniCodec decodes INI files: the keys of the default section are top-level
+// keys, the ones of a section like [server.tls] are nested under it. All
+// values are strings, converted by the getters.
+type iniCodec struct {
+	p *Parser
+}
+
+func (c *iniCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	keys, values, err := leaves("ini", v)
+	if err != nil {
+		return nil, err
+	}
+	cfg := ini.Empty()
+	for _, k := range keys {
+		section, key := ini.DefaultSection, k
+		if i := strings.LastIndex(k, "."); i >= 0 {
+			section, key = k[:i], k[i+1:]
+		}
+		if _, err := cfg.Section(section).NewKey(key, values[k]); err != nil {
+			return nil, fmt.Errorf("ini: %w", err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := cfg.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("ini: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *iniCodec) Decode(b []byte, v map[string]interface{}) error {
+	cfg, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, b)
+	if err != nil {
+		return fmt.Errorf("ini: %w", err)
+	}
+	tree := newKeyTree(&duplicates{})
+	for _, section := range cfg.Sections() {
+		prefix := section.Name()
+		if prefix == ini.DefaultSection {
+			prefix = ""
+		} else if _, err := tree.section(strings.Split(prefix, ".")); err != nil {
+			return fmt.Errorf("ini: [%s]: %w", section.Name(), err)
+		}
+		for _, key := range section.Keys() {
+			for _, value := range key.ValueWithShadows() {
+				if err := tree.set(joinPath(prefix, key.Name()), value, Location{}); err != nil {
+					return fmt.Errorf("ini: %w", err)
+				}
+			}
+		}
+	}
+	return tree.result(v)
+}
+
+// propertiesCodec decodes Java properties files, the dots of the keys
+// nesting them. All values are strings, converted by the getters, and
+// ${key} references are left to WithInterpolation.
+type propertiesCodec struct {
+	p *Parser
+}
+
+func (c *propertiesCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	keys, values, err := leaves("properties", v)
+	if err != nil {
+		return nil, err
+	}
+	props := properties.NewProperties()
+	props.DisableExpansion = true
+	for _, k := range keys {
+		if _, _, err := props.Set(k, values[k]); err != nil {
+			return nil, fmt.Errorf("properties: %w", err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := props.Write(&buf, properties.UTF8); err != nil {
+		return nil, fmt.Errorf("properties: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *propertiesCodec) Decode(b []byte, v map[string]interface{}) error {
+	loader := &properties.Loader{Encoding: properties.UTF8, DisableExpansion: true}
+	props, err := loader.LoadBytes(b)
+	if err != nil {
+		return fmt.Errorf("properties: %w", err)
+	}
+	tree := newKeyTree(&duplicates{})
+	for _, key := range props.Keys() {
+		value, _ := props.Get(key)
+		if err := tree.set(key, value, Location{}); err != nil {
+			return fmt.Errorf("properties: %w", err)
+		}
+	}
+	return tree.result(v)
+}
+
+// hclCodec decodes HCL (version 1) files. Blocks become sections, labelled
+// blocks like service "web" { ... } nest under their labels, and repeated
+// blocks are merged.
+type hclCodec struct {
+	p *Parser
+}
+
+func (c *hclCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("hcl: %w", err)
+	}
+	f, err := hcljson.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("hcl: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, f.Node); err != nil {
+		return nil, fmt.Errorf("hcl: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func (c *hclCodec) Decode(b []byte, v map[string]interface{}) error {
+	f, err := hcl.ParseBytes(b)
+	if err != nil {
+		return fmt.Errorf("hcl: %w", err)
+	}
+	list, ok := f.Node.(*ast.ObjectList)
+	if !ok {
+		return fmt.Errorf("hcl: the document root must be an object")
+	}
+	tree := newKeyTree(&duplicates{})
+	if err := c.object(tree, "", list); err != nil {
+		return fmt.Errorf("hcl: %w", err)
+	}
+	return tree.result(v)
+}
+
+// object stores the items of list under prefix
+func (c *hclCodec) object(tree *keyTree, prefix string, list *ast.ObjectList) error {
+	for _, item := range list.Items {
+		path := prefix
+		for _, k := range item.Keys {
+			path = joinPath(path, fmt.Sprint(k.Token.Value()))
+		}
+		if obj, ok := item.Val.(*ast.ObjectType); ok {
+			if _, err := tree.section(strings.Split(path, ".")); err != nil {
+				return err
+			}
+			if err := c.object(tree, path, obj.List); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := c.value(tree, item.Val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := tree.set(path, value, Location{Line: item.Pos().Line}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value decodes n, the objects of lists reporting their duplicates along
+// with the ones of tree
+func (c *hclCodec) value(tree *keyTree, n ast.Node) (interface{}, error) {
+	switch n := n.(type) {
+	case *ast.LiteralType:
+		v := n.Token.Value()
+		if i, ok := v.(int64); ok && int64(int(i)) == i {
+			return int(i), nil
+		}
+		return v, nil
+	case *ast.ListType:
+		out := make([]interface{}, 0, len(n.List))
+		for _, item := range n.List {
+			v, err := c.value(tree, item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case *ast.ObjectType:
+		item := newKeyTree(tree.dups)
+		if err := c.object(item, "", n.List); err != nil {
+			return nil, err
+		}
+		return item.settings, nil
+	}
+	return nil, fmt.Errorf("line %d: unexpected %T", n.Pos().Line, n)
+}
@@ -0,0 +1,213 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// formatFiles holds the same config written in every supported format
+var formatFiles = map[string]string{
+	"config.toml": `
+name = "api"
+[server]
+port = 8080
+timeout = "5s"
+debug = true
+`,
+	"config.ini": `
+name = api
+
+[server]
+port = 8080
+timeout = 5s
+debug = true
+`,
+	"config.hcl": `
+name = "api"
+server {
+  port    = 8080
+  timeout = "5s"
+  debug   = true
+}
+`,
+	"config.tfvars": `
+name = "api"
+server = {
+  port    = 8080
+  timeout = "5s"
+  debug   = true
+}
+`,
+	"config.properties": `
+# the service
+name = api
+server.port = 8080
+server.timeout = 5s
+server.debug = true
+`,
+	"config.cue": `
+#Server: {
+	port:    int & >0 & <65536
+	timeout: string
+	debug:   bool | *false
+}
+name:   "api"
+server: #Server & {port: 8080, timeout: "5s", debug: true}
+`,
+	"config.xml": `<?xml version="1.0"?>
+<service>
+  <name>api</name>
+  <server port="8080">
+    <timeout>5s</timeout>
+    <debug>true</debug>
+  </server>
+</service>
+`,
+	"config.props": "name=api\nserver.port=8080\nserver.timeout=5s\nserver.debug=true\n",
+}
+
+func TestParser_Formats(t *testing.T) {
+	dir := writeFiles(t, formatFiles)
+	type server struct {
+		Port    int
+		Timeout time.Duration
+		Debug   bool
+	}
+	for name := range formatFiles {
+		t.Run(name, func(t *testing.T) {
+			p := New()
+			if _, err := p.Parse(filepath.Join(dir, name)); err != nil {
+				t.Fatal(err)
+			}
+			if got := p.GetString("name"); got != "api" {
+				t.Errorf("name = %q, want api", got)
+			}
+			var s server
+			if err := p.UnmarshalKey("server", &s); err != nil {
+				t.Fatal(err)
+			}
+			if s != (server{Port: 8080, Timeout: 5 * time.Second, Debug: true}) {
+				t.Errorf("server = %+v, want the decoded section", s)
+			}
+
+			// written back in the same format, the config reads the same
+			out := filepath.Join(t.TempDir(), "saved"+filepath.Ext(name))
+			if err := p.Save(out); err != nil {
+				t.Fatal(err)
+			}
+			saved := New()
+			if _, err := saved.Parse(out); err != nil {
+				t.Fatal(err)
+			}
+			if got := saved.GetInt("server.port"); got != 8080 {
+				t.Errorf("saved server.port = %d, want 8080", got)
+			}
+		})
+	}
+}
+
+func TestParser_HCLBlocks(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.hcl": `
+service "web" {
+  port = 80
+  tags = ["a", "b"]
+}
+service "api" {
+  port = 8080
+}
+service "web" {
+  replicas = 3
+}
+listeners = [{ port = 80 }, { port = 443 }]
+`})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.hcl")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"service.web.port", 80},
+		{"service.web.replicas", 3},
+		{"service.web.tags", []string{"a", "b"}},
+		{"service.api.port", 8080},
+		{"listeners", []map[string]interface{}{{"port": 80}, {"port": 443}}},
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.path); !jsonEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParser_FormatErrors(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+		want    string
+	}{
+		{"bad.ini", "[server\nport = 1\n", "ini: "},
+		{"bad.hcl", "server {\n  port = \n", "hcl: "},
+		{"bad.properties", "a = \\u12\n", "properties: "},
+		{"bad.toml", "a = [\n", "toml: "},
+		{"clash.properties", "a.b = 1\na.b.c = 2\n", `properties: key "a.b" is both a value and a section`},
+		{"clash.ini", "server = 1\n[server]\nport = 2\n", `ini: [server]: key "server" is both a value and a section`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{tt.file: tt.content})
+			_, err := New().Parse(filepath.Join(dir, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("duplicates", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"dup.ini": "[server]\nport = 1\nport = 2\n",
+			"dup.hcl": "port = 1\nPort = 2\n",
+		} {
+			dir := writeFiles(t, map[string]string{name: content})
+			_, err := New().Parse(filepath.Join(dir, name))
+			var dupErr *DuplicateKeyError
+			if !errors.As(err, &dupErr) {
+				t.Errorf("%s: Parse() error = %v, want a *DuplicateKeyError", name, err)
+			}
+		}
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		p := New()
+		if _, err := p.ParseBytes([]byte(`{"hosts": ["a", "b"]}`), "json"); err != nil {
+			t.Fatal(err)
+		}
+		err := p.Save(filepath.Join(t.TempDir(), "out.ini"))
+		if err == nil || !strings.Contains(err.Error(), "lists cannot be written as ini") {
+			t.Errorf("Save() error = %v, want lists rejected", err)
+		}
+	})
+}
+
+func TestParser_DottedMapKeys(t *testing.T) {
+	// maps keyed by host names or addresses load from the formats where
+	// only TOML's quoted keys are ambiguous
+	for typ, content := range map[string]string{
+		"json": `{"hosts": {"api.example.com": "a", "10.0.0.2": "b"}}`,
+		"yaml": "hosts:\n  api.example.com: a\n  10.0.0.2: b\n",
+	} {
+		p := New()
+		if _, err := p.ParseBytes([]byte(content), typ); err != nil {
+			t.Fatalf("ParseBytes() of %s error = %v", typ, err)
+		}
+		want := map[string]interface{}{"api.example.com": "a", "10.0.0.2": "b"}
+		if got := p.GetStringMap("hosts"); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s hosts = %v, want %v", typ, got, want)
+		}
+	}
+}
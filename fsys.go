@@ -0,0 +1,56 @@
+package viper
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// fsPrefix marks the names of the config files read from the fs.FS given to
+// ParseFS
+const fsPrefix = "fs:"
+
+// ParseFS reads the configuration at path in fsys, like defaults embedded
+// with //go:embed, then merges the files read from disk on top, in order,
+// as ParseAll does. The files the embedded config extends are looked up in
+// fsys too, and watching the disk files reloads the embedded config along
+// with them. Relative paths held by the keys of WithRelativePaths are left
+// as they are in embedded files. In errors and provenance, the embedded
+// file is named fs:path.
+func (p *Parser) ParseFS(fsys fs.FS, path string, files ...string) (*Config, error) {
+	p.mu.Lock()
+	prev := p.fsys
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	cfg, err := p.ParseAll(append([]string{fsPrefix + path}, files...)...)
+	if err != nil {
+		p.mu.Lock()
+		p.fsys = prev
+		p.mu.Unlock()
+	}
+	return cfg, err
+}
+
+// inFS reports whether the config file is read from the fs.FS of ParseFS
+func (p *Parser) inFS(configFile string) bool {
+	return p.fsys != nil && strings.HasPrefix(configFile, fsPrefix)
+}
+
+// readSourceFile returns the content of a config file, read from memory,
+// from the fs.FS of ParseFS or from disk
+func (p *Parser) readSourceFile(configFile string) ([]byte, error) {
+	if inline, ok := p.inline[configFile]; ok {
+		return inline.data, nil
+	}
+	if p.inFS(configFile) {
+		name := filepath.ToSlash(strings.TrimPrefix(configFile, fsPrefix))
+		f, err := p.fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return p.readAllLimited(f)
+	}
+	return p.readLimited(configFile)
+}
@@ -0,0 +1,54 @@
+package viper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParser_ParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/base.yaml":     {Data: []byte("server:\n  host: localhost\n  port: 80\nlog: info\n")},
+		"config/defaults.yaml": {Data: []byte("extends: base.yaml\n$schema: schema.json\nserver:\n  port: 8080\n")},
+		"config/schema.json":   {Data: []byte(`{"properties": {"server": {"properties": {"port": {"maximum": 9000}}}}}`)},
+	}
+	dir := writeFiles(t, map[string]string{
+		"override.yaml": "log: debug\n",
+		"invalid.yaml":  "server:\n  port: 9999\n",
+	})
+
+	p := New()
+	if _, err := p.ParseFS(fsys, "config/defaults.yaml", filepath.Join(dir, "override.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"server.host": "localhost", "server.port": "8080", "log": "debug"} {
+		if got := p.GetString(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	p.mu.RLock()
+	origin := p.origin("server.port")
+	p.mu.RUnlock()
+	if origin != "fs:config/defaults.yaml" {
+		t.Errorf("origin of server.port = %q, want the embedded file", origin)
+	}
+
+	errTests := []struct {
+		name    string
+		path    string
+		files   []string
+		wantErr string
+	}{
+		{"missing embedded file", "config/missing.yaml", nil, "fs:config/missing.yaml"},
+		{"schema of the embedded file", "config/defaults.yaml", []string{filepath.Join(dir, "invalid.yaml")}, "greater than the maximum"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New().ParseFS(fsys, tt.path, tt.files...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseFS() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package viper
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// KeyGuard bounds the size of the value of a key, for configs that are
+// valid but operationally dangerous, like thousands of tenants. Zero
+// values disable the matching check.
+type KeyGuard struct {
+	// MaxItems is the maximum length of a slice
+	MaxItems int
+	// MaxEntries is the maximum number of entries of a map
+	MaxEntries int
+	// MaxLength is the maximum length in bytes of a string
+	MaxLength int
+}
+
+// keyGuard is a guard registered for a key pattern
+type keyGuard struct {
+	pattern string
+	guard   KeyGuard
+}
+
+// WithKeyGuards bounds the values of the keys matching the given glob
+// patterns, as in path.Match against the lower-cased dot-notation key:
+// {"tenants": {MaxEntries: 500}}. They are checked on the merged config of
+// every load and reload; a load breaking any of them fails with a
+// *GuardError listing every violation.
+func WithKeyGuards(guards map[string]KeyGuard) Option {
+	return func(p *Parser) {
+		for pattern, g := range guards {
+			p.keyGuards = append(p.keyGuards, keyGuard{pattern: strings.ToLower(pattern), guard: g})
+		}
+	}
+}
+
+// GuardViolation is a value breaking a KeyGuard
+type GuardViolation struct {
+	// Key is the dot-notation key of the value
+	Key string
+	// Guard names the bound: "items", "entries" or "length"
+	Guard string
+	// Max is the bound and Actual the size of the value
+	Max    int
+	Actual int
+	// Origin names the sources of the value
+	Origin string
+}
+
+func (v GuardViolation) String() string {
+	if v.Origin != "" {
+		return fmt.Sprintf("%s: %d %s exceeds the maximum of %d (from %s)", v.Key, v.Actual, v.Guard, v.Max, v.Origin)
+	}
+	return fmt.Sprintf("%s: %d %s exceeds the maximum of %d", v.Key, v.Actual, v.Guard, v.Max)
+}
+
+// GuardError lists the values breaking their KeyGuard
+type GuardError struct {
+	Violations []GuardViolation
+}
+
+func (e *GuardError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return "config exceeds key guards: " + strings.Join(msgs, "; ")
+}
+
+// checkGuards walks settings checking the values of the guarded keys
+func (p *Parser) checkGuards(settings map[string]interface{}) error {
+	if len(p.keyGuards) == 0 {
+		return nil
+	}
+	var violations []GuardViolation
+	check := func(key, guard string, max, actual int) {
+		if max > 0 && actual > max {
+			violations = append(violations, GuardViolation{Key: key, Guard: guard, Max: max, Actual: actual, Origin: p.pending.sourcesOf(key)})
+		}
+	}
+	var walk func(v interface{}, key string)
+	walk = func(v interface{}, key string) {
+		m, isMap := toStringMap(v)
+		for _, g := range p.keyGuards {
+			if ok, _ := path.Match(g.pattern, key); !ok || key == "" {
+				continue
+			}
+			switch t := v.(type) {
+			case []interface{}:
+				check(key, "items", g.guard.MaxItems, len(t))
+			case string:
+				check(key, "length", g.guard.MaxLength, len(t))
+			default:
+				if isMap {
+					check(key, "entries", g.guard.MaxEntries, len(m))
+				}
+			}
+		}
+		for k, child := range m {
+			walk(child, joinPath(key, strings.ToLower(k)))
+		}
+	}
+	walk(settings, "")
+	if len(violations) == 0 {
+		return nil
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Key != violations[j].Key {
+			return violations[i].Key < violations[j].Key
+		}
+		return violations[i].Guard < violations[j].Guard
+	})
+	return &GuardError{Violations: violations}
+}
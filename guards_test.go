@@ -0,0 +1,56 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWithKeyGuards(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ok.yaml": "tenants:\n  a: {}\n  b: {}\nhosts: [x, y]\nbanner: hi\n",
+		"big.yaml": `
+tenants:
+  a: {}
+  b: {}
+  c: {}
+hosts: [x, y, z]
+banner: hello world
+pools:
+  eu:
+    zones: [a, b, c]
+  us:
+    zones: [a]
+`,
+	})
+	p := New(WithKeyGuards(map[string]KeyGuard{
+		"tenants":       {MaxEntries: 2},
+		"hosts":         {MaxItems: 2},
+		"banner":        {MaxLength: 5},
+		"pools.*.zones": {MaxItems: 2},
+	}))
+	if _, err := p.Parse(filepath.Join(dir, "ok.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := p.Parse(filepath.Join(dir, "big.yaml"))
+	var guardErr *GuardError
+	if !errors.As(err, &guardErr) {
+		t.Fatalf("Parse() error = %v, want a *GuardError", err)
+	}
+	big := filepath.Join(dir, "big.yaml")
+	want := []GuardViolation{
+		{Key: "banner", Guard: "length", Max: 5, Actual: 11, Origin: big},
+		{Key: "hosts", Guard: "items", Max: 2, Actual: 3, Origin: big},
+		{Key: "pools.eu.zones", Guard: "items", Max: 2, Actual: 3, Origin: big},
+		// the tenants are empty sections, setting no value
+		{Key: "tenants", Guard: "entries", Max: 2, Actual: 3},
+	}
+	if !reflect.DeepEqual(guardErr.Violations, want) {
+		t.Errorf("violations = %+v, want %+v", guardErr.Violations, want)
+	}
+	if got := len(p.GetStringMap("tenants")); got != 2 {
+		t.Errorf("tenants has %d entries after the failed load, want the previous config kept", got)
+	}
+}
@@ -0,0 +1,122 @@
+package viper
+
+import (
+	"fmt"
+	"time"
+)
+
+// snapshotsKept is the number of loaded configs kept for Rollback
+const snapshotsKept = 10
+
+// rollbackSource names rollbacks in the load history
+const rollbackSource = "<rollback>"
+
+// Snapshot describes a config put in place by a successful load, reload or
+// rollback
+type Snapshot struct {
+	Time time.Time `json:"time"`
+	// Source is the file, files or watched source loaded
+	Source string `json:"source"`
+	// Checksum is the SHA-256 of the settings loaded, as returned by
+	// Checksum while the snapshot was in place
+	Checksum string `json:"checksum"`
+}
+
+// snapshot is a config kept for Rollback
+type snapshot struct {
+	Snapshot
+	state loadState
+}
+
+// History returns the configs put in place by the last successful loads,
+// oldest first, the last one being the config currently served. Up to 10
+// are kept.
+func (p *Parser) History() []Snapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	history := make([]Snapshot, len(p.snapshots))
+	for i, s := range p.snapshots {
+		history[i] = s.Snapshot
+	}
+	return history
+}
+
+// Checksum returns the SHA-256 of the settings put in place by the last
+// successful load, before environment variables, overrides and defaults
+// apply, or an empty string before the first one. Instances loading the
+// same config report the same checksum, whatever the order of its keys.
+func (p *Parser) Checksum() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.snapshots) == 0 {
+		return ""
+	}
+	return p.snapshots[len(p.snapshots)-1].Checksum
+}
+
+// Rollback puts back the config loaded n successful loads ago, 1 being the
+// one before the current config, as after a bad push. The config goes
+// through the registered components and reaches the change listeners like
+// a reload, and is recorded in the history as a new snapshot, so Rollback(1)
+// undoes a rollback. Boot-only keys keep their value and their changes are
+// reported to the OnRestartRequired callbacks, as on a reload. Watched
+// files and sources keep being watched: their next change loads them again.
+func (p *Parser) Rollback(n int) error {
+	p.applyMu.Lock()
+	defer p.applyMu.Unlock()
+
+	p.mu.Lock()
+	if err := p.checkOpen(); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	if n < 1 || n >= len(p.snapshots) {
+		p.mu.Unlock()
+		return fmt.Errorf("cannot roll back %d loads, %d previous configs kept", n, len(p.snapshots)-1)
+	}
+	target := p.snapshots[len(p.snapshots)-1-n]
+	prev, before, state := p.own, p.settings(), p.saveState()
+	restored := target.state
+	var held ChangeSet
+	if len(p.immutable) > 0 && p.own != nil {
+		restored.own, held = p.holdImmutable(p.own, restored.own)
+	}
+	err := p.restoreState(restored)
+	var (
+		changes ChangeSet
+		staged  bool
+	)
+	if err == nil {
+		changes = p.diff(before, p.settings())
+		staged, err = p.stage(state, changes)
+	}
+	p.mu.Unlock()
+
+	if err == nil && staged {
+		err = p.applyStaged(state, restored, changes)
+	}
+	p.mu.Lock()
+	p.recordLoad(rollbackSource, prev, err)
+	p.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error rolling back to %s: %w", target.Checksum, err)
+	}
+	p.logger.Info("config rolled back", "to", target.Checksum, "loaded", target.Time)
+	p.restartRequired(held)
+	p.changed()
+	return nil
+}
+
+// addSnapshot records the config in place after a successful load, of
+// the given checksum. Callers hold p.mu.
+func (p *Parser) addSnapshot(t time.Time, source, checksum string) {
+	s := snapshot{
+		Snapshot: Snapshot{Time: t, Source: source, Checksum: checksum},
+		state:    p.saveState(),
+	}
+	if len(p.snapshots) == snapshotsKept {
+		copy(p.snapshots, p.snapshots[1:])
+		p.snapshots = p.snapshots[:snapshotsKept-1]
+	}
+	p.snapshots = append(p.snapshots, s)
+}
@@ -0,0 +1,153 @@
+package viper
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestParser_Rollback(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 8080\nhost: a\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	p := New()
+	if got := p.Checksum(); got != "" {
+		t.Errorf("Checksum() = %q before any load, want empty", got)
+	}
+	if err := p.Rollback(1); err == nil {
+		t.Error("Rollback() without a previous config succeeded")
+	}
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	good := p.Checksum()
+	if len(good) != 64 {
+		t.Errorf("Checksum() = %q, want a SHA-256", good)
+	}
+
+	replaceFile(t, configFile, "host: a\nport: 8080\n")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Checksum(); got != good {
+		t.Errorf("Checksum() = %q for the same settings reordered, want %q", got, good)
+	}
+
+	replaceFile(t, configFile, "port: 1\nhost: a\n")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	bad := p.Checksum()
+	if bad == good {
+		t.Fatal("Checksum() unchanged by a different config")
+	}
+
+	changed := 0
+	p.OnChange(func() { changed++ })
+	if err := p.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetInt("port"); got != 8080 {
+		t.Errorf("port = %d after Rollback(1), want 8080", got)
+	}
+	if got := p.Checksum(); got != good {
+		t.Errorf("Checksum() = %q after Rollback(1), want %q", got, good)
+	}
+	if changed != 1 {
+		t.Errorf("change listeners called %d times, want 1", changed)
+	}
+
+	history := p.History()
+	if len(history) != 4 {
+		t.Fatalf("History() = %v, want 4 snapshots", history)
+	}
+	if last := history[3]; last.Source != rollbackSource || last.Checksum != good {
+		t.Errorf("last snapshot = %+v, want the rollback to %s", last, good)
+	}
+	if history[0].Source != configFile || history[2].Checksum != bad {
+		t.Errorf("History() = %+v, want the loads in order", history)
+	}
+
+	// undoing the rollback
+	if err := p.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetInt("port"); got != 1 {
+		t.Errorf("port = %d after undoing the rollback, want 1", got)
+	}
+	if err := p.Rollback(5); err == nil {
+		t.Error("Rollback() past the history succeeded")
+	}
+}
+
+func TestParser_History_Bounded(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 0\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New()
+	for i := 0; i < snapshotsKept+5; i++ {
+		if _, err := p.Parse(configFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := p.Parse(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Fatal("Parse() of a missing file succeeded")
+	}
+	if got := len(p.History()); got != snapshotsKept {
+		t.Errorf("History() holds %d snapshots, want %d", got, snapshotsKept)
+	}
+}
+
+func TestParser_Rollback_ComponentFailure(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 8080\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	replaceFile(t, configFile, "port: 9090\n")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	var log []string
+	failing := recordingComponent{name: "server", fail: true, log: &log, mu: &sync.Mutex{}, p: p}
+	if err := p.Register("server", failing); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Rollback(1); err == nil {
+		t.Fatal("Rollback() succeeded with a failing component")
+	}
+	if got := p.GetInt("port"); got != 9090 {
+		t.Errorf("port = %d after a failed rollback, want the current config kept", got)
+	}
+	if got := len(p.History()); got != 2 {
+		t.Errorf("History() holds %d snapshots, want the failed rollback left out", got)
+	}
+}
+
+func TestParser_Rollback_ImmutableKeys(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 8080\nhost: a\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New(WithImmutableKeys("port"))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	replaceFile(t, configFile, "port: 9090\nhost: b\n")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	var held []ChangeSet
+	p.OnRestartRequired(func(c ChangeSet) { held = append(held, c) })
+
+	if err := p.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("host"); got != "a" {
+		t.Errorf("host = %q, want the rolled back a", got)
+	}
+	if got := p.GetInt("port"); got != 9090 {
+		t.Errorf("port = %d, want the boot-only key kept", got)
+	}
+	if len(held) != 1 || len(held[0].Modified) != 1 || held[0].Modified["port"].Old != 9090 {
+		t.Errorf("held changes = %+v, want the rollback of port held back", held)
+	}
+}
@@ -0,0 +1,79 @@
+package viper
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// WithDecodeHooks adds decode hooks to the ones Unmarshal, UnmarshalExact
+// and UnmarshalKey run, to decode strings into the types of the
+// application. They run in order before the built-in hooks, which only
+// convert the values they leave as strings.
+func WithDecodeHooks(hooks ...mapstructure.DecodeHookFunc) Option {
+	return func(p *Parser) {
+		p.decodeHooks = append(p.decodeHooks, hooks...)
+	}
+}
+
+var (
+	durationType  = reflect.TypeOf(time.Duration(0))
+	ipType        = reflect.TypeOf(net.IP{})
+	ipNetType     = reflect.TypeOf(net.IPNet{})
+	ipNetPtrType  = reflect.TypeOf(&net.IPNet{})
+	urlType       = reflect.TypeOf(url.URL{})
+	urlPtrType    = reflect.TypeOf(&url.URL{})
+	regexpPtrType = reflect.TypeOf(&regexp.Regexp{})
+)
+
+// CommonTypesHookFunc returns a decode hook converting strings into
+// time.Duration ("30s"), net.IP ("10.0.0.1"), net.IPNet ("10.0.0.0/24"),
+// url.URL ("https://api.nexen.io") and *regexp.Regexp values, the network
+// and URL ones also behind a pointer.
+//
+// Parser.Unmarshal uses it. It can also be passed to Config.Viper.Unmarshal
+// with viper.DecodeHook.
+func CommonTypesHookFunc() mapstructure.DecodeHookFunc {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String {
+			return data, nil
+		}
+		s := reflect.ValueOf(data).String()
+		switch to {
+		case durationType:
+			return time.ParseDuration(s)
+		case ipType:
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", s)
+			}
+			return ip, nil
+		case ipNetType, ipNetPtrType:
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, err
+			}
+			if to == ipNetType {
+				return *n, nil
+			}
+			return n, nil
+		case urlType, urlPtrType:
+			u, err := url.Parse(s)
+			if err != nil {
+				return nil, err
+			}
+			if to == urlType {
+				return *u, nil
+			}
+			return u, nil
+		case regexpPtrType:
+			return regexp.Compile(s)
+		}
+		return data, nil
+	}
+}
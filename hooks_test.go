@@ -0,0 +1,110 @@
+package viper
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_Unmarshal_commonTypes(t *testing.T) {
+	p := New()
+	if _, err := p.ParseBytes([]byte(`
+timeout: 30s
+ip: 10.0.0.1
+subnet: 10.0.0.0/24
+allowed: 192.168.0.0/16
+api: https://api.nexen.io/v1?debug=true
+upstream: http://localhost:8080
+pattern: ^user-[0-9]+$
+`), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Timeout  time.Duration
+		IP       net.IP
+		Subnet   net.IPNet
+		Allowed  *net.IPNet
+		API      url.URL
+		Upstream *url.URL
+		Pattern  *regexp.Regexp
+	}
+	if err := p.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Timeout != 30*time.Second {
+		t.Errorf("Timeout = %v, want 30s", cfg.Timeout)
+	}
+	if !cfg.IP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("IP = %v, want 10.0.0.1", cfg.IP)
+	}
+	if cfg.Subnet.String() != "10.0.0.0/24" {
+		t.Errorf("Subnet = %v, want 10.0.0.0/24", cfg.Subnet.String())
+	}
+	if cfg.Allowed == nil || !cfg.Allowed.Contains(net.ParseIP("192.168.1.1")) {
+		t.Errorf("Allowed = %v, want 192.168.0.0/16", cfg.Allowed)
+	}
+	if cfg.API.Host != "api.nexen.io" || cfg.API.Query().Get("debug") != "true" {
+		t.Errorf("API = %v, want the parsed URL", cfg.API.String())
+	}
+	if cfg.Upstream == nil || cfg.Upstream.Port() != "8080" {
+		t.Errorf("Upstream = %v, want the parsed URL", cfg.Upstream)
+	}
+	if cfg.Pattern == nil || !cfg.Pattern.MatchString("user-42") {
+		t.Errorf("Pattern = %v, want the compiled expression", cfg.Pattern)
+	}
+
+	for key, value := range map[string]string{"IP": "10.0.0", "Allowed": "10.0.0.0", "Pattern": "(", "Timeout": "soon"} {
+		t.Run(key, func(t *testing.T) {
+			p := New()
+			if _, err := p.ParseBytes([]byte(key+": "+value), "yaml"); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Unmarshal(&cfg); err == nil {
+				t.Errorf("Unmarshal() of %q did not fail", value)
+			}
+		})
+	}
+}
+
+type logLevel int
+
+func TestWithDecodeHooks(t *testing.T) {
+	levels := map[string]logLevel{"debug": 1, "info": 2}
+	levelHook := func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to != reflect.TypeOf(logLevel(0)) {
+			return data, nil
+		}
+		level, ok := levels[strings.ToLower(data.(string))]
+		if !ok {
+			return nil, fmt.Errorf("unknown log level %q", data)
+		}
+		return level, nil
+	}
+	// runs before the built-in hooks
+	foreverHook := func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if to == reflect.TypeOf(time.Duration(0)) && data == "forever" {
+			return time.Duration(1<<63 - 1), nil
+		}
+		return data, nil
+	}
+
+	p := New(WithDecodeHooks(levelHook, foreverHook))
+	if _, err := p.ParseBytes([]byte("level: DEBUG\nttl: forever\n"), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Level logLevel
+		TTL   time.Duration
+	}
+	if err := p.Child().Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Level != 1 || cfg.TTL != time.Duration(1<<63-1) {
+		t.Errorf("Unmarshal() = %+v, want the custom hooks applied", cfg)
+	}
+}
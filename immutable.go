@@ -0,0 +1,200 @@
+package viper
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// WithImmutableKeys marks keys as boot-only. Patterns are globs, as in
+// path.Match, matched against the lower-cased dot-notation key and each of
+// its parents, so "tls" and "tls.*" both cover "tls.cert". Watch keeps
+// boot-only keys at the value they were loaded with and reports their
+// changes to the OnRestartRequired callbacks instead of applying them.
+func WithImmutableKeys(patterns ...string) Option {
+	return func(p *Parser) {
+		for _, pattern := range patterns {
+			p.immutable = append(p.immutable, strings.ToLower(pattern))
+		}
+	}
+}
+
+// OnRestartRequired registers a callback invoked when a watched file changes
+// boot-only keys. The change set lists the changes that were held back.
+func (p *Parser) OnRestartRequired(callback func(ChangeSet)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.restartListeners = append(p.restartListeners, callback)
+}
+
+// isImmutable reports whether the key or one of its parents matches a
+// boot-only pattern
+func (p *Parser) isImmutable(key string) bool {
+	key = strings.ToLower(key)
+	for {
+		for _, pattern := range p.immutable {
+			if ok, _ := path.Match(pattern, key); ok {
+				return true
+			}
+		}
+		i := strings.LastIndexByte(key, '.')
+		if i < 0 {
+			return false
+		}
+		key = key[:i]
+	}
+}
+
+// pendingReload is a reload read but not installed yet
+type pendingReload struct {
+	files    []string
+	settings map[string]interface{}
+	typ      string
+	// held lists the changes to boot-only keys held back
+	held ChangeSet
+	// req is the change to approve, nil when none needs approval
+	req *ChangeRequest
+}
+
+// reload re-reads the config files after a change of source, a watched
+// file or source. Changes to boot-only keys are not applied but held back,
+// and the remaining changes need to be approved before installReload
+// installs them. Callers hold p.mu.
+func (p *Parser) reload(ctx context.Context, source string, files []string) (*pendingReload, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config file loaded")
+	}
+	settings, typ, err := p.read(ctx, files...)
+	if err != nil {
+		return nil, err
+	}
+	r := &pendingReload{files: files, settings: settings, typ: typ}
+	if len(p.immutable) > 0 && p.own != nil {
+		r.settings, r.held = p.holdImmutable(p.own, settings)
+	}
+	r.req = p.approvalRequest(source, r.settings)
+	return r, nil
+}
+
+// installReload installs a reload read by reload. Callers hold p.mu.
+func (p *Parser) installReload(r *pendingReload) error {
+	if err := p.install(r.files[len(r.files)-1], r.settings, r.typ); err != nil {
+		return err
+	}
+	p.files = r.files
+	return nil
+}
+
+// watchedSet returns the files to read again when configFile changes: the
+// files loaded last, with configFile layered on top when it is not one of
+// them
+func (p *Parser) watchedSet(configFile string) []string {
+	files := append([]string(nil), p.files...)
+	for _, f := range files {
+		if filepath.Clean(f) == filepath.Clean(configFile) {
+			return files
+		}
+	}
+	return append(files, configFile)
+}
+
+// holdImmutable returns a copy of next where boot-only keys keep their value
+// from prev, along with the changes held back
+func (p *Parser) holdImmutable(prev, next map[string]interface{}) (map[string]interface{}, ChangeSet) {
+	next = lowerKeys(next)
+	before := flatten(lowerKeys(prev))
+	after := flatten(next)
+
+	held := ChangeSet{
+		Added:    map[string]Change{},
+		Removed:  map[string]Change{},
+		Modified: map[string]Change{},
+	}
+	for k, nv := range after {
+		if _, ok := before[k]; !ok && p.isImmutable(k) {
+			held.Added[k] = p.change(k, nil, nv)
+			deletePath(next, k)
+		}
+	}
+	for k, ov := range before {
+		if !p.isImmutable(k) {
+			continue
+		}
+		nv, ok := after[k]
+		switch {
+		case !ok:
+			held.Removed[k] = p.change(k, ov, nil)
+		case !reflect.DeepEqual(ov, nv):
+			held.Modified[k] = p.change(k, ov, nv)
+		default:
+			continue
+		}
+		setPath(next, k, ov)
+	}
+	return next, held
+}
+
+// restartRequired reports held back changes to the restart callbacks.
+// Callers must not hold p.mu.
+func (p *Parser) restartRequired(held ChangeSet) {
+	if held.Empty() {
+		return
+	}
+	p.mu.RLock()
+	listeners := append([]func(ChangeSet){}, p.restartListeners...)
+	p.mu.RUnlock()
+
+	p.logger.Warn("config change requires a restart", "changes", held.String())
+	for _, callback := range listeners {
+		callback(held)
+	}
+}
+
+// lowerKeys returns a deep copy of m with every map key lower-cased, the way
+// viper stores them
+func lowerKeys(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if nested, ok := toStringMap(v); ok {
+			v = lowerKeys(nested)
+		}
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
+
+// setPath sets the value at a dot-notation path, creating or replacing the
+// intermediate maps as needed
+func setPath(m map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		nested, ok := m[part].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			m[part] = nested
+		}
+		m = nested
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// deletePath removes the value at a dot-notation path, along with the maps
+// left empty
+func deletePath(m map[string]interface{}, key string) {
+	head, rest, nested := strings.Cut(key, ".")
+	if !nested {
+		delete(m, head)
+		return
+	}
+	child, ok := m[head].(map[string]interface{})
+	if !ok {
+		return
+	}
+	deletePath(child, rest)
+	if len(child) == 0 {
+		delete(m, head)
+	}
+}
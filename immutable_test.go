@@ -0,0 +1,98 @@
+package viper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithImmutableKeys(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+server:
+  port: 8080
+  host: a.local
+tls:
+  cert: a.pem
+  private_key: old
+log:
+  level: info
+`})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	p := New(WithImmutableKeys("server.port", "tls.*"))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	restarts := make(chan ChangeSet, 1)
+	p.OnRestartRequired(func(cs ChangeSet) {
+		select {
+		case restarts <- cs:
+		default:
+		}
+	})
+	reloaded := make(chan struct{}, 1)
+	if err := p.Watch(configFile, func() {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(configFile, []byte(`
+server:
+  port: 9090
+  host: b.local
+tls:
+  private_key: new
+  ca: ca.pem
+log:
+  level: debug
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var held ChangeSet
+	select {
+	case held = <-restarts:
+	case <-time.After(time.Second):
+		t.Fatal("no restart required event")
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("watch callback not called")
+	}
+
+	if got, want := held.Keys(), []string{"server.port", "tls.ca", "tls.cert", "tls.private_key"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("held keys = %v, want %v", got, want)
+	}
+	if ch := held.Modified["server.port"]; ch.Old != 8080 || ch.New != 9090 {
+		t.Errorf("server.port change = %+v, want 8080 -> 9090", ch)
+	}
+	if ch := held.Modified["tls.private_key"]; !ch.Sensitive || ch.Old != nil {
+		t.Errorf("tls.private_key change = %+v, want a redacted change", ch)
+	}
+
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{"server.port", 8080},
+		{"tls.cert", "a.pem"},
+		{"tls.private_key", "old"},
+		{"tls.ca", nil},
+		// hot-reloadable keys are applied
+		{"server.host", "b.local"},
+		{"log.level", "debug"},
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.key); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
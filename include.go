@@ -0,0 +1,81 @@
+package viper
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// includeKey is the reserved key a config file uses to pull in other files
+// when includes are enabled
+const includeKey = "include"
+
+// DefaultIncludeDepth is the number of nested includes allowed unless set
+// with WithIncludes
+const DefaultIncludeDepth = 8
+
+// WithIncludes enables the include directive, listing files merged over
+// the file declaring it:
+//
+//	include: [tls.yaml, conf.d/*.yaml]
+//
+// Relative paths are resolved from the directory of the including file and
+// glob patterns expand to the matching files in lexical order, a pattern
+// matching none being skipped. Included files may extend or include
+// others; cycles fail, as do includes nested deeper than maxDepth, which
+// defaults to DefaultIncludeDepth when zero or less.
+func WithIncludes(maxDepth int) Option {
+	return func(p *Parser) {
+		if maxDepth <= 0 {
+			maxDepth = DefaultIncludeDepth
+		}
+		p.includeDepth = maxDepth
+	}
+}
+
+// readIncludes reads the files included by configFile, merged in order.
+// depth is the number of includes leading to configFile.
+func (p *Parser) readIncludes(configFile, typ string, patterns []string, chain []string, depth int) (map[string]interface{}, error) {
+	if depth >= p.includeDepth {
+		return nil, fmt.Errorf("includes nested deeper than %d files", p.includeDepth)
+	}
+	merged := make(map[string]interface{})
+	for _, pattern := range patterns {
+		files, err := p.glob(p.relativeTo(configFile, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("including %q: %w", pattern, err)
+		}
+		for _, file := range files {
+			fileType := p.typeOf(file)
+			if filepath.Ext(file) == "" {
+				fileType = typ
+			}
+			if err := checkCycle(includeKey, file, chain); err != nil {
+				return nil, err
+			}
+			included, err := p.readChain(file, fileType, chain, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("including %q: %w", file, err)
+			}
+			merged = p.merge(merged, included)
+		}
+	}
+	return merged, nil
+}
+
+// glob returns the files matching pattern, or pattern itself when it holds
+// no wildcard so that a missing file fails when read
+func (p *Parser) glob(pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
+	}
+	if !p.inFS(pattern) {
+		return filepath.Glob(pattern)
+	}
+	matches, err := fs.Glob(p.fsys, filepath.ToSlash(strings.TrimPrefix(pattern, fsPrefix)))
+	for i, m := range matches {
+		matches[i] = fsPrefix + m
+	}
+	return matches, err
+}
@@ -0,0 +1,124 @@
+package viper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParser_Includes(t *testing.T) {
+	files := map[string]string{
+		"config.yaml":        "include: [tls.yaml, conf.d/*.yaml, empty.d/*.yaml]\nname: service\nlog: info\ndb:\n  pool: 4\n",
+		"tls.yaml":           "tls:\n  enabled: true\n",
+		"conf.d/10-db.yaml":  "db:\n  pool: 16\n  host: db\n",
+		"conf.d/20-log.yaml": "include: ../extra/debug.yaml\nlog: warn\n",
+		"extra/debug.yaml":   "debug: true\n",
+	}
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"name", "service"},
+		{"tls.enabled", true},
+		{"db.pool", 16},
+		{"db.host", "db"},
+		{"log", "warn"},
+		{"debug", true},
+	}
+
+	dir := writeFiles(t, files)
+	p := New(WithIncludes(0))
+	cfg, err := p.Parse(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.path); !jsonEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if _, ok := cfg.Raw[includeKey]; ok {
+		t.Error("include key leaked into the effective config")
+	}
+	p.mu.RLock()
+	origin := p.origin("db.pool")
+	p.mu.RUnlock()
+	if !strings.HasSuffix(origin, "10-db.yaml") {
+		t.Errorf("origin of db.pool = %q, want the included file", origin)
+	}
+
+	t.Run("fs", func(t *testing.T) {
+		fsys := fstest.MapFS{}
+		for name, content := range files {
+			fsys[name] = &fstest.MapFile{Data: []byte(content)}
+		}
+		p := New(WithIncludes(0))
+		if _, err := p.ParseFS(fsys, "config.yaml"); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.GetString("log"); got != "warn" {
+			t.Errorf("log = %q, want the value of the included file", got)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		p := New()
+		if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+			t.Fatal(err)
+		}
+		if p.Get("tls.enabled") != nil || p.Get(includeKey) == nil {
+			t.Error("files included without WithIncludes")
+		}
+	})
+}
+
+func TestParser_IncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"config.yaml": "include: a.yaml\n",
+				"a.yaml":      "include: [b.yaml]\n",
+				"b.yaml":      "extends: a.yaml\n",
+			},
+			want: "extends cycle",
+		},
+		{
+			name: "depth",
+			files: map[string]string{
+				"config.yaml": "include: a.yaml\n",
+				"a.yaml":      "include: b.yaml\n",
+				"b.yaml":      "include: c.yaml\n",
+				"c.yaml":      "a: 1\n",
+			},
+			depth: 2,
+			want:  "includes nested deeper than 2 files",
+		},
+		{
+			name:  "missing file",
+			files: map[string]string{"config.yaml": "include: nope.yaml\n"},
+			want:  "nope.yaml",
+		},
+		{
+			name:  "invalid value",
+			files: map[string]string{"config.yaml": "include: 3\n"},
+			want:  "must be a file path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, err := New(WithIncludes(tt.depth)).Parse(filepath.Join(dir, "config.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package viper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// placeholderPattern matches the placeholders of WithInterpolation, like
+// ${server.port}, and their escaped form $${server.port}
+var placeholderPattern = regexp.MustCompile(`\$?\$\{([a-zA-Z0-9_.-]+)\}`)
+
+// WithInterpolation lets string values refer to other keys of the config,
+// as in
+//
+//	base_url: "http://${server.host}:${server.port}"
+//
+// Placeholders are replaced once the files and sources are merged and the
+// overlays applied, so they see the merged values, but not the environment,
+// the overrides or the derived keys. A value made of a single placeholder
+// takes the value of the key with its type, sections included; other
+// placeholders are written into the string. A placeholder naming a key
+// that is not set, or a chain of placeholders referring back to itself,
+// fails the load. $${key} is kept as the literal ${key}. Values built from
+// secrets or sensitive keys are redacted like them, and Save writes the
+// placeholders back instead of their values.
+func WithInterpolation() Option {
+	return func(p *Parser) {
+		p.interpolation = true
+	}
+}
+
+// interpolator replaces the placeholders of a settings map, resolving each
+// key once
+type interpolator struct {
+	p *Parser
+	// view holds the settings with lower-cased keys, to look keys up
+	view map[string]interface{}
+	// done holds the values of the keys resolved so far, and stack the keys
+	// being resolved, to detect cycles
+	done  map[string]interface{}
+	stack []string
+	// secret holds the keys whose value was built from a secret
+	secret map[string]bool
+}
+
+// interpolate replaces the placeholders of settings. Callers hold p.mu.
+func (p *Parser) interpolate(settings map[string]interface{}) (map[string]interface{}, error) {
+	in := &interpolator{
+		p:      p,
+		view:   lowerKeys(settings),
+		done:   map[string]interface{}{},
+		secret: map[string]bool{},
+	}
+	out := make(map[string]interface{}, len(settings))
+	for k := range settings {
+		v, err := in.value(strings.ToLower(k))
+		if err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// value returns the value of key with its placeholders replaced
+func (in *interpolator) value(key string) (interface{}, error) {
+	if v, ok := in.done[key]; ok {
+		return v, nil
+	}
+	for i, k := range in.stack {
+		if k == key {
+			return nil, fmt.Errorf("interpolation cycle: %s", strings.Join(append(in.stack[i:], key), " -> "))
+		}
+	}
+	raw, _ := lookupPath(in.view, key)
+	in.stack = append(in.stack, key)
+	v, err := in.resolve(raw, key, true)
+	in.stack = in.stack[:len(in.stack)-1]
+	if err != nil {
+		return nil, err
+	}
+
+	if _, isMap := toStringMap(raw); !isMap && containsPlaceholder(raw) {
+		if _, expanded := in.p.pending.templates[key]; !expanded {
+			// keep the environment placeholders replaced before
+			in.p.pending.templates[key] = raw
+		}
+		if in.secret[key] {
+			in.p.pending.refs[key] = raw
+		}
+	}
+	in.done[key] = v
+	return v, nil
+}
+
+// resolve replaces the placeholders of v, found at path. The entries of
+// maps are resolved as keys unless they sit in a list.
+func (in *interpolator) resolve(v interface{}, path string, keyed bool) (interface{}, error) {
+	if m, ok := toStringMap(v); ok {
+		out := make(map[string]interface{}, len(m))
+		for k, child := range m {
+			var r interface{}
+			var err error
+			if keyed {
+				r, err = in.value(joinPath(path, strings.ToLower(k)))
+			} else {
+				r, err = in.resolve(child, joinPath(path, k), false)
+			}
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	}
+
+	switch t := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, child := range t {
+			r, err := in.resolve(child, fmt.Sprintf("%s[%d]", path, i), false)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	case string:
+		return in.expand(t, path)
+	}
+	return v, nil
+}
+
+// expand replaces the placeholders of s, found at path
+func (in *interpolator) expand(s, path string) (interface{}, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	matches := placeholderPattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) && s[1] == '{' {
+		return in.lookup(s[matches[0][2]:matches[0][3]], path)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		last = m[1]
+		if s[m[0]+1] == '$' {
+			b.WriteString(s[m[0]+1 : m[1]])
+			continue
+		}
+		v, err := in.lookup(s[m[2]:m[3]], path)
+		if err != nil {
+			return nil, err
+		}
+		switch v.(type) {
+		case nil:
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("interpolating %s: ${%s} is not a scalar and cannot be written into a string", path, s[m[2]:m[3]])
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// lookup returns the value of the key named by a placeholder of the value
+// at path
+func (in *interpolator) lookup(name, path string) (interface{}, error) {
+	key := strings.ToLower(in.p.normalizePath(name))
+	if _, ok := lookupPath(in.view, key); !ok {
+		return nil, fmt.Errorf("interpolating %s: key %q is not set", path, key)
+	}
+	v, err := in.value(key)
+	if err != nil {
+		return nil, err
+	}
+	if in.secret[key] || in.p.isSensitive(key) || in.holdsRef(key) {
+		owner := in.keyOf(path)
+		in.secret[owner] = true
+		if _, isMap := v.(map[string]interface{}); isMap && owner == path {
+			// the keys of a section copied whole keep their references
+			for k, ref := range in.p.pending.refs {
+				if rest, ok := strings.CutPrefix(k, key+"."); ok {
+					in.p.pending.refs[joinPath(owner, rest)] = ref
+				}
+			}
+		}
+	}
+	return normalizeJSON(v), nil
+}
+
+// holdsRef reports whether key, or a key of its section, holds a reference
+func (in *interpolator) holdsRef(key string) bool {
+	for k := range in.p.pending.refs {
+		if k == key || strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// keyOf returns the key the value at path belongs to, the list holding it
+// when it sits in one
+func (in *interpolator) keyOf(path string) string {
+	key, _, _ := strings.Cut(path, "[")
+	return key
+}
+
+// containsPlaceholder reports whether v holds a placeholder, escaped or not
+func containsPlaceholder(v interface{}) bool {
+	switch t := v.(type) {
+	case string:
+		return placeholderPattern.MatchString(t)
+	case []interface{}:
+		for _, item := range t {
+			if containsPlaceholder(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
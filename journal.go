@@ -0,0 +1,126 @@
+package viper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// JournalEntry records a config change applied by a load or a reload
+type JournalEntry struct {
+	Time time.Time `json:"time"`
+	// Source names the files or the source the change was read from
+	Source string `json:"source"`
+	// Fingerprint is the SHA-256 of the loaded settings after the change
+	Fingerprint string `json:"fingerprint"`
+	// Changes is the diff applied, values of sensitive and referenced keys
+	// left out
+	Changes ChangeSet `json:"changes"`
+}
+
+// JournalSink persists journal entries, as an audit trail of the changes
+// applied on a node
+type JournalSink interface {
+	Append(entry JournalEntry) error
+}
+
+// JournalFunc adapts a function to a JournalSink
+type JournalFunc func(JournalEntry) error
+
+// Append calls f(entry)
+func (f JournalFunc) Append(entry JournalEntry) error {
+	return f(entry)
+}
+
+// WithJournal appends every change applied by a load or a reload to sink.
+// Loads changing nothing and failed ones are not journaled. The sink is
+// called with the parser locked and must not call back into it; a failing
+// sink is logged and does not fail the load.
+func WithJournal(sink JournalSink) Option {
+	return func(p *Parser) {
+		p.journal = sink
+	}
+}
+
+// WithJournalFile appends the journal to the file at path, one JSON entry
+// per line, as done by FileJournal
+func WithJournalFile(path string) Option {
+	return WithJournal(NewFileJournal(path))
+}
+
+// FileJournal is a JournalSink writing JSON lines to a local file. The file
+// is created with mode 0600 when missing and opened for each entry, so it
+// can be rotated by moving it away.
+type FileJournal struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileJournal returns a FileJournal appending to the file at path
+func NewFileJournal(path string) *FileJournal {
+	return &FileJournal{path: path}
+}
+
+// Append writes entry as a line of JSON at the end of the file
+func (j *FileJournal) Append(entry JournalEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding journal entry: %w", err)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// journalChange appends the changes of a load, whose settings have the
+// given fingerprint, to the journal, if any. Callers hold p.mu.
+func (p *Parser) journalChange(at time.Time, source, sum string, changes ChangeSet) {
+	if p.journal == nil || changes.Empty() {
+		return
+	}
+	entry := JournalEntry{
+		Time:        at,
+		Source:      source,
+		Fingerprint: sum,
+		Changes:     p.redactChanges(changes),
+	}
+	if err := p.journal.Append(entry); err != nil {
+		p.logger.Warn("cannot journal config change", "source", source, "error", err)
+	}
+}
+
+// redactChanges hides the values of the keys resolved from references,
+// which the diff does not know about. Callers hold the read lock.
+func (p *Parser) redactChanges(changes ChangeSet) ChangeSet {
+	for _, m := range []map[string]Change{changes.Added, changes.Removed, changes.Modified} {
+		for k := range m {
+			if p.referenced(k) {
+				m[k] = Change{Sensitive: true}
+			}
+		}
+	}
+	return changes
+}
+
+// fingerprint returns the SHA-256 of the JSON encoding of settings, which
+// sorts the keys of maps. The keys of settings are lower-cased.
+func fingerprint(settings map[string]interface{}) string {
+	b, err := json.Marshal(settings)
+	if err != nil {
+		b = []byte(fmt.Sprintf("%v", settings))
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
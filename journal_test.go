@@ -0,0 +1,69 @@
+package viper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_Journal(t *testing.T) {
+	t.Setenv("JOURNALTEST_PASSWORD", "hunter2")
+	dir := writeFiles(t, map[string]string{
+		"v1.yaml": "log: info\ndb:\n  password: s3cret\n  dsn: $ref{env:JOURNALTEST_PASSWORD}\n",
+		"v2.yaml": "log: debug\ndb:\n  password: other\n  dsn: $ref{env:JOURNALTEST_PASSWORD}\n",
+	})
+	journal := filepath.Join(dir, "journal.jsonl")
+
+	p := New(WithJournalFile(journal))
+	for _, name := range []string{"v1.yaml", "v2.yaml", "v2.yaml", "missing.yaml"} {
+		p.Parse(filepath.Join(dir, name))
+	}
+
+	f, err := os.Open(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []JournalEntry
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var e JournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("journal has %d entries, want one per applied change", len(entries))
+	}
+
+	first, second := entries[0], entries[1]
+	if first.Source != filepath.Join(dir, "v1.yaml") || first.Fingerprint == "" || first.Fingerprint == second.Fingerprint {
+		t.Errorf("entries = %+v, want their source and distinct fingerprints", entries)
+	}
+	if ch := first.Changes.Added["db.dsn"]; !ch.Sensitive || ch.New != nil {
+		t.Errorf("referenced key journaled as %+v, want it redacted", ch)
+	}
+	if ch := second.Changes.Modified["db.password"]; !ch.Sensitive || ch.Old != nil {
+		t.Errorf("sensitive key journaled as %+v, want it redacted", ch)
+	}
+	if ch := second.Changes.Modified["log"]; ch.Old != "info" || ch.New != "debug" {
+		t.Errorf("log journaled as %+v, want info -> debug", ch)
+	}
+
+	t.Run("failing sink", func(t *testing.T) {
+		calls := 0
+		p := New(WithJournal(JournalFunc(func(JournalEntry) error {
+			calls++
+			return errors.New("disk full")
+		})))
+		if _, err := p.Parse(filepath.Join(dir, "v1.yaml")); err != nil {
+			t.Errorf("Parse() error = %v, want the journal failure ignored", err)
+		}
+		if calls != 1 {
+			t.Errorf("sink called %d times, want 1", calls)
+		}
+	})
+}
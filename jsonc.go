@@ -0,0 +1,108 @@
+package viper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// jsoncCodec decodes JSON with comments: // and /* */ comments and the
+// trailing commas of objects and lists are blanked out before the JSON
+// codec runs. Their bytes become spaces, newlines are kept, so the syntax
+// errors and the duplicate keys report the lines of the file. Writes plain
+// JSON.
+type jsoncCodec struct {
+	json *jsonCodec
+}
+
+func (c *jsoncCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	return c.json.Encode(v)
+}
+
+func (c *jsoncCodec) Decode(b []byte, v map[string]interface{}) error {
+	plain, err := stripJSONC(b)
+	if err != nil {
+		return err
+	}
+	err = c.json.Decode(plain, v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("jsonc: line %d: %w", lineAt(plain, syntaxErr.Offset), err)
+	}
+	return err
+}
+
+// stripJSONC returns a copy of b with the comments and trailing commas
+// replaced by spaces
+func stripJSONC(b []byte) ([]byte, error) {
+	out := append([]byte(nil), b...)
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' && out[i] != '\r' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	// comments first, so the commas are followed by plain whitespace
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			i = stringEnd(out, i)
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := i
+			for end < len(out) && out[end] != '\n' {
+				end++
+			}
+			blank(i, end)
+			i = end
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := -1
+			for j := i + 2; j+1 < len(out); j++ {
+				if out[j] == '*' && out[j+1] == '/' {
+					end = j + 2
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("jsonc: line %d: unterminated comment", lineAt(out, int64(i)))
+			}
+			blank(i, end)
+			i = end - 1
+		}
+	}
+
+	for i := 0; i < len(out); i++ {
+		switch out[i] {
+		case '"':
+			i = stringEnd(out, i)
+		case ',':
+			next := i + 1
+			for next < len(out) && isJSONSpace(out[next]) {
+				next++
+			}
+			if next < len(out) && (out[next] == '}' || out[next] == ']') {
+				out[i] = ' '
+			}
+		}
+	}
+	return out, nil
+}
+
+// stringEnd returns the index of the quote closing the string starting at
+// start, or the last index of b when it is not closed
+func stringEnd(b []byte, start int) int {
+	for i := start + 1; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return len(b) - 1
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
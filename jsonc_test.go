@@ -0,0 +1,68 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_JSONC(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.jsonc": `{
+  // the service
+  "name": "api", /* inline */
+  "url": "http://example.com/*not a comment*/",
+  "server": {
+    "port": 8080,
+    /* a
+       block */
+    "hosts": ["a", "b",],
+  },
+}
+`})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.jsonc")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"name", "api"},
+		{"url", "http://example.com/*not a comment*/"},
+		{"server.port", 8080},
+		{"server.hosts", []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.path); !jsonEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	errTests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"syntax", "{\n  // ok\n  \"a\": 1\n  \"b\": 2\n}\n", "jsonc: line 4: "},
+		{"comment", "{\n  \"a\": 1 /* open\n}\n", "jsonc: line 2: unterminated comment"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"bad.jsonc": tt.content})
+			_, err := New().Parse(filepath.Join(dir, "bad.jsonc"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("duplicates", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{"dup.jsonc": "{\n  /* first */\n  \"a\": 1,\n  // second\n  \"a\": 2,\n}\n"})
+		_, err := New().Parse(filepath.Join(dir, "dup.jsonc"))
+		var dupErr *DuplicateKeyError
+		if !errors.As(err, &dupErr) || !strings.Contains(err.Error(), "dup.jsonc:5") {
+			t.Errorf("Parse() error = %v, want a *DuplicateKeyError on line 5", err)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package viper
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// KeyCase is a naming convention for config keys
+type KeyCase int
+
+const (
+	// KeepCase leaves keys as they are written
+	KeepCase KeyCase = iota
+	// SnakeCase writes keys like max_conns
+	SnakeCase
+	// KebabCase writes keys like max-conns
+	KebabCase
+	// CamelCase writes keys like maxConns
+	CamelCase
+)
+
+// WithKeyNormalization rewrites every key of the loaded files, and every
+// path given to the getters, in the given case, so maxConns, max_conns and
+// max-conns written by different tools resolve to the same key. The names
+// of region and locale overlays are kept as they are. Two keys of the same
+// map normalizing to the same key fail the load.
+func WithKeyNormalization(c KeyCase) Option {
+	return func(p *Parser) {
+		p.keyCase = c
+	}
+}
+
+// normalizeKeys returns the settings of a file with their keys normalized
+func (p *Parser) normalizeKeys(settings map[string]interface{}) (map[string]interface{}, error) {
+	if p.keyCase == KeepCase {
+		return settings, nil
+	}
+	return p.normalizeMap(settings, "")
+}
+
+func (p *Parser) normalizeMap(m map[string]interface{}, prefix string) (map[string]interface{}, error) {
+	// the names of overlays are values compared with WithRegion and
+	// WithLocale, not keys
+	overlays := p.isOverlayKey(prefix)
+
+	out := make(map[string]interface{}, len(m))
+	renamed := make(map[string]string, len(m))
+	for k, v := range m {
+		key := k
+		if !overlays {
+			key = normalizeKey(k, p.keyCase)
+		}
+		if other, ok := renamed[key]; ok {
+			return nil, fmt.Errorf("keys %q and %q both normalize to %q", joinPath(prefix, other), joinPath(prefix, k), joinPath(prefix, key))
+		}
+		renamed[key] = k
+
+		var err error
+		if nested, ok := toStringMap(v); ok {
+			if v, err = p.normalizeMap(nested, joinPath(prefix, key)); err != nil {
+				return nil, err
+			}
+		} else if list, ok := v.([]interface{}); ok {
+			items := make([]interface{}, len(list))
+			for i, item := range list {
+				items[i] = item
+				if nested, ok := toStringMap(item); ok {
+					if items[i], err = p.normalizeMap(nested, fmt.Sprintf("%s[%d]", joinPath(prefix, key), i)); err != nil {
+						return nil, err
+					}
+				}
+			}
+			v = items
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// normalizePath rewrites a dot-notation path given to a getter
+func (p *Parser) normalizePath(path string) string {
+	if p.keyCase == KeepCase {
+		return path
+	}
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		if i == 1 && p.isOverlayKey(parts[0]) {
+			continue
+		}
+		parts[i] = normalizeKey(part, p.keyCase)
+	}
+	return strings.Join(parts, ".")
+}
+
+// normalizeKey writes a single key in the given case
+func normalizeKey(key string, c KeyCase) string {
+	words := splitWords(key)
+	if len(words) == 0 {
+		return key
+	}
+	switch c {
+	case SnakeCase:
+		return strings.Join(words, "_")
+	case KebabCase:
+		return strings.Join(words, "-")
+	case CamelCase:
+		for i := 1; i < len(words); i++ {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+		return strings.Join(words, "")
+	}
+	return key
+}
+
+// splitWords splits a key into lower-cased words on separators and case
+// changes, keeping acronyms together: HTTPServer gives http and server
+func splitWords(key string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(key)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			flush()
+			continue
+		case unicode.IsUpper(r) && len(word) > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
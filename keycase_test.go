@@ -0,0 +1,78 @@
+package viper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		key                 string
+		snake, kebab, camel string
+	}{
+		{"maxConns", "max_conns", "max-conns", "maxConns"},
+		{"max_conns", "max_conns", "max-conns", "maxConns"},
+		{"max-conns", "max_conns", "max-conns", "maxConns"},
+		{"MaxConns", "max_conns", "max-conns", "maxConns"},
+		{"HTTPServer", "http_server", "http-server", "httpServer"},
+		{"ipv4Addr", "ipv4_addr", "ipv4-addr", "ipv4Addr"},
+		{"tls", "tls", "tls", "tls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			for c, want := range map[KeyCase]string{SnakeCase: tt.snake, KebabCase: tt.kebab, CamelCase: tt.camel} {
+				if got := normalizeKey(tt.key, c); got != want {
+					t.Errorf("normalizeKey(%q, %d) = %q, want %q", tt.key, c, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithKeyNormalization(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.json": `{"maxConns": 10, "httpServer": {"readTimeout": "5s"}, "upstreams": [{"baseURL": "a"}]}`,
+		"prod.yaml": "extends: base.json\nmax-conns: 20\nhttp_server:\n  idle-timeout: 1m\nregions:\n  eu-west:\n    maxConns: 30\n",
+		"dup.yaml":  "maxConns: 1\nmax_conns: 2\n",
+	})
+
+	p := New(WithKeyNormalization(SnakeCase), WithRegion("eu-west"))
+	cfg, err := p.Parse(filepath.Join(dir, "prod.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"max_conns", "30"},
+		{"maxConns", "30"},
+		{"http_server.read_timeout", "5s"},
+		{"httpServer.idleTimeout", "1m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := p.GetString(tt.path); got != tt.want {
+				t.Errorf("GetString(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+	upstreams, _ := cfg.Raw["upstreams"].([]interface{})
+	if len(upstreams) != 1 || upstreams[0].(map[string]interface{})["base_url"] != "a" {
+		t.Errorf("upstreams = %v, want the keys of list items normalized", cfg.Raw["upstreams"])
+	}
+
+	if err := p.Override("httpServer.readTimeout", "10s"); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("http_server.read_timeout"); got != "10s" {
+		t.Errorf("read_timeout = %q, want the override on the normalized key", got)
+	}
+
+	_, err = New(WithKeyNormalization(SnakeCase)).Parse(filepath.Join(dir, "dup.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "both normalize to") {
+		t.Errorf("Parse() error = %v, want a normalization conflict", err)
+	}
+}
@@ -0,0 +1,140 @@
+package viper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Limits bounds the configuration a parser accepts, protecting it against
+// corrupted or hostile sources. Zero values disable the matching check.
+type Limits struct {
+	// MaxSize is the maximum size in bytes of a single raw source
+	MaxSize int64
+	// MaxDepth is the maximum nesting depth of maps and slices
+	MaxDepth int
+	// MaxKeys is the maximum number of keys across all the nested maps
+	MaxKeys int
+	// MaxStringLength is the maximum length in bytes of a string value
+	MaxStringLength int
+}
+
+// WithLimits sets the limits enforced while decoding config sources
+func WithLimits(limits Limits) Option {
+	return func(p *Parser) {
+		p.limits = limits
+	}
+}
+
+// LimitError is returned when a source exceeds one of the configured limits
+type LimitError struct {
+	// Limit names the breached limit: "size", "depth", "keys" or "string length"
+	Limit string
+	// Max is the configured value of the limit
+	Max int64
+	// Path is the key where the limit was hit, if any
+	Path string
+}
+
+func (e *LimitError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("config exceeds the %s limit of %d", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("config exceeds the %s limit of %d at %q", e.Limit, e.Max, e.Path)
+}
+
+// readLimited reads the whole file, failing as soon as it grows beyond the
+// size limit instead of loading it entirely in memory
+func (p *Parser) readLimited(configFile string) ([]byte, error) {
+	if p.limits.MaxSize <= 0 {
+		return os.ReadFile(configFile)
+	}
+	f, err := os.Open(configFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return p.readAllLimited(f)
+}
+
+func (p *Parser) readAllLimited(r io.Reader) ([]byte, error) {
+	return readAllMax(r, p.limits.MaxSize)
+}
+
+// readAllMax reads r to the end, failing with a *LimitError once it grows
+// beyond max bytes. A max of zero or less reads it whole.
+func readAllMax(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > max {
+		return nil, &LimitError{Limit: "size", Max: max}
+	}
+	return b, nil
+}
+
+// sizeLimitKey is the context key of the size limit of the remote sources
+type sizeLimitKey struct{}
+
+// withSizeLimit returns ctx carrying the size limit of p, so the sources
+// and resolvers fetching remote documents enforce it through readBody
+func (p *Parser) withSizeLimit(ctx context.Context) context.Context {
+	if p.limits.MaxSize <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, sizeLimitKey{}, p.limits.MaxSize)
+}
+
+// readBody reads the body of a remote response, failing with a
+// *LimitError once it grows beyond the size limit ctx carries
+func readBody(ctx context.Context, r io.Reader) ([]byte, error) {
+	max, _ := ctx.Value(sizeLimitKey{}).(int64)
+	return readAllMax(r, max)
+}
+
+// checkLimits walks the decoded settings enforcing the structural limits
+func (p *Parser) checkLimits(settings map[string]interface{}) error {
+	l := p.limits
+	if l.MaxDepth <= 0 && l.MaxKeys <= 0 && l.MaxStringLength <= 0 {
+		return nil
+	}
+
+	keys := 0
+	var walk func(v interface{}, path string, depth int) error
+	walk = func(v interface{}, path string, depth int) error {
+		if l.MaxDepth > 0 && depth > l.MaxDepth {
+			return &LimitError{Limit: "depth", Max: int64(l.MaxDepth), Path: path}
+		}
+		if m, ok := toStringMap(v); ok {
+			keys += len(m)
+			if l.MaxKeys > 0 && keys > l.MaxKeys {
+				return &LimitError{Limit: "keys", Max: int64(l.MaxKeys), Path: path}
+			}
+			for k, child := range m {
+				if err := walk(child, joinPath(path, k), depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		switch t := v.(type) {
+		case []interface{}:
+			for i, child := range t {
+				if err := walk(child, fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+					return err
+				}
+			}
+		case string:
+			if l.MaxStringLength > 0 && len(t) > l.MaxStringLength {
+				return &LimitError{Limit: "string length", Max: int64(l.MaxStringLength), Path: path}
+			}
+		}
+		return nil
+	}
+	return walk(settings, "", 0)
+}
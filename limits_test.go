@@ -0,0 +1,109 @@
+package viper
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  Limits
+		content string
+		want    *LimitError
+	}{
+		{
+			name:    "within limits",
+			limits:  Limits{MaxSize: 1024, MaxDepth: 3, MaxKeys: 10, MaxStringLength: 16},
+			content: `{"a": {"b": {"c": "short"}}}`,
+		},
+		{
+			name:    "size",
+			limits:  Limits{MaxSize: 8},
+			content: `{"key": "value"}`,
+			want:    &LimitError{Limit: "size", Max: 8},
+		},
+		{
+			name:    "depth",
+			limits:  Limits{MaxDepth: 2},
+			content: `{"a": {"b": {"c": 1}}}`,
+			want:    &LimitError{Limit: "depth", Max: 2, Path: "a.b.c"},
+		},
+		{
+			name:    "depth through slices",
+			limits:  Limits{MaxDepth: 2},
+			content: `{"a": [[1]]}`,
+			want:    &LimitError{Limit: "depth", Max: 2, Path: "a[0][0]"},
+		},
+		{
+			name:    "keys",
+			limits:  Limits{MaxKeys: 2},
+			content: `{"a": 1, "b": 2, "c": 3}`,
+			want:    &LimitError{Limit: "keys", Max: 2},
+		},
+		{
+			name:    "string length",
+			limits:  Limits{MaxStringLength: 4},
+			content: `{"a": {"b": "` + strings.Repeat("x", 5) + `"}}`,
+			want:    &LimitError{Limit: "string length", Max: 4, Path: "a.b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configFile := filepath.Join(tmpDir, "config.json")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := New(WithLimits(tt.limits)).Parse(configFile)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Parse() error = %v", err)
+				}
+				return
+			}
+
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("Parse() error = %v, want a *LimitError", err)
+			}
+			if *limitErr != *tt.want {
+				t.Errorf("Parse() error = %+v, want %+v", limitErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithLimits_RemoteBodies(t *testing.T) {
+	big := `{"blob": "` + strings.Repeat("x", 4096) + `"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(big))
+	}))
+	defer srv.Close()
+
+	sources := map[string]Source{
+		"remote": &RemoteConfig{URL: srv.URL},
+		"consul": ConsulKV(srv.URL, "app/"),
+	}
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(WithLimits(Limits{MaxSize: 1024}), WithSource(name, src)).ParseBytes([]byte("{}"), "json")
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Limit != "size" {
+				t.Fatalf("ParseBytes() error = %v, want the size limit enforced", err)
+			}
+		})
+	}
+
+	// no limit, no check
+	if _, err := New(WithSource("remote", &RemoteConfig{URL: srv.URL})).ParseBytes([]byte("{}"), "json"); err != nil {
+		t.Errorf("ParseBytes() without limits error = %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package viper
+
+import (
+	"encoding"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// LevelFunc adapts a function to the level targets of BindLogLevel, for
+// loggers without a level variable, like zerolog.SetGlobalLevel
+type LevelFunc func(level string) error
+
+// UnmarshalText calls f with the level name
+func (f LevelFunc) UnmarshalText(text []byte) error {
+	return f(string(text))
+}
+
+// BindLogLevel keeps a log level in sync with the value of key. The level is
+// set right away, then again after each reload that changes the value,
+// leaving it alone when the reload only touched other keys. Any level
+// variable implementing encoding.TextUnmarshaler works, such as
+// *slog.LevelVar or zap.AtomicLevel. Values are trimmed and lower-cased and
+// "warning" is accepted for "warn". An invalid value is logged and keeps the
+// current level; when it is the initial value, the error is returned too and
+// the level is still kept in sync. The returned function cancels the
+// binding.
+func (p *Parser) BindLogLevel(key string, level encoding.TextUnmarshaler) (cancel func(), err error) {
+	var mu sync.Mutex
+	cancelled := false
+	seen := ""
+	apply := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if cancelled {
+			return nil
+		}
+		value := normalizeLevel(p.GetString(key))
+		if value == "" || value == seen {
+			return nil
+		}
+		seen = value
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			err = fmt.Errorf("invalid log level %q at %q: %w", value, key, err)
+			p.logger.Warn("keeping the current log level", "error", err)
+			return err
+		}
+		return nil
+	}
+
+	remove := p.onChange(func() { _ = apply() })
+	return func() {
+		remove()
+		mu.Lock()
+		defer mu.Unlock()
+		cancelled = true
+	}, apply()
+}
+
+func normalizeLevel(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "warning" {
+		return "warn"
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package viper
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_BindLogLevel(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "log:\n  level: \" WARNING \"\n"})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	var level slog.LevelVar
+	if _, err := p.BindLogLevel("log.level", &level); err != nil {
+		t.Fatal(err)
+	}
+	if got := level.Level(); got != slog.LevelWarn {
+		t.Fatalf("initial level = %v, want WARN", got)
+	}
+
+	var names []string
+	record := LevelFunc(func(name string) error {
+		names = append(names, name)
+		return nil
+	})
+	if _, err := p.BindLogLevel("log.level", record); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Override("log.level", "DEBUG")
+	if got := level.Level(); got != slog.LevelDebug {
+		t.Errorf("level = %v after the change, want DEBUG", got)
+	}
+
+	// a runtime change made elsewhere survives reloads of unrelated keys
+	level.Set(slog.LevelError)
+	p.Override("other", 1)
+	if got := level.Level(); got != slog.LevelError {
+		t.Errorf("level = %v after an unrelated change, want ERROR", got)
+	}
+
+	// invalid values keep the current level
+	p.Override("log.level", "loud")
+	if got := level.Level(); got != slog.LevelError {
+		t.Errorf("level = %v after an invalid value, want ERROR", got)
+	}
+	if _, err := New().BindLogLevel("log.level", &level); err != nil {
+		t.Errorf("BindLogLevel() of an unset key error = %v", err)
+	}
+	bad := New()
+	bad.Override("log.level", "loud")
+	if _, err := bad.BindLogLevel("log.level", &level); err == nil {
+		t.Error("BindLogLevel() of an invalid initial value should fail")
+	}
+
+	if want := []string{"warn", "debug", "loud"}; len(names) != 3 || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Errorf("LevelFunc got %v, want %v", names, want)
+	}
+}
+
+func TestParser_BindLogLevelCancel(t *testing.T) {
+	p := New()
+	p.Override("log.level", "warn")
+	var level slog.LevelVar
+	cancel, err := p.BindLogLevel("log.level", &level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	p.Override("log.level", "debug")
+	if got := level.Level(); got != slog.LevelWarn {
+		t.Errorf("level = %v after the cancel, want WARN", got)
+	}
+	if len(p.listeners) != 0 {
+		t.Errorf("%d listeners left after the cancel, want none", len(p.listeners))
+	}
+}
@@ -0,0 +1,193 @@
+package viper
+
+import (
+	"path"
+	"reflect"
+	"strings"
+)
+
+// deepMerge merges src into dst and returns dst. Nested maps are merged
+// recursively; any other value in src replaces the one in dst. Keys are
+// matched ignoring case, as viper does, keeping the case of dst.
+func deepMerge(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	keys := foldedKeys{m: dst}
+	for k, sv := range src {
+		k = keys.find(k)
+		sm, srcIsMap := toStringMap(sv)
+		dm, dstIsMap := toStringMap(dst[k])
+		if srcIsMap && dstIsMap {
+			dst[k] = deepMerge(dm, sm)
+			continue
+		}
+		dst[k] = sv
+	}
+	return dst
+}
+
+// SliceMerge selects how a list is merged with the list it overrides
+type SliceMerge int
+
+const (
+	// SliceReplace replaces the list entirely
+	SliceReplace SliceMerge = iota
+	// SliceAppend appends the items of the overriding list
+	SliceAppend
+	// SliceUnion appends the items of the overriding list not already
+	// present
+	SliceUnion
+)
+
+// MergeStrategy describes how the settings of a config file are merged on
+// top of the files it overrides: the files it extends, the files listed
+// before it in ParseAll and the base settings of overlays
+type MergeStrategy struct {
+	// Slices selects how lists are merged, SliceReplace by default
+	Slices SliceMerge
+	// ReplaceMaps replaces maps entirely instead of merging them key by key
+	ReplaceMaps bool
+	// NullDeletes removes the keys set to null instead of setting them to
+	// nil
+	NullDeletes bool
+}
+
+// keyStrategy is a merge strategy restricted to the keys matching a pattern
+type keyStrategy struct {
+	pattern  string
+	strategy MergeStrategy
+}
+
+// WithMergeStrategy sets the merge strategy applied to every key without a
+// strategy of its own
+func WithMergeStrategy(s MergeStrategy) Option {
+	return func(p *Parser) {
+		p.mergeStrategy = s
+	}
+}
+
+// WithKeyMergeStrategy sets the merge strategy of the keys matching pattern,
+// a glob as in path.Match matched against the lower-cased dot-notation key
+// and each of its parents. The most specific match wins; among patterns
+// matching the same key, the first one registered wins.
+func WithKeyMergeStrategy(pattern string, s MergeStrategy) Option {
+	return func(p *Parser) {
+		p.keyStrategies = append(p.keyStrategies, keyStrategy{strings.ToLower(pattern), s})
+	}
+}
+
+// strategyFor returns the merge strategy of key
+func (p *Parser) strategyFor(key string) MergeStrategy {
+	key = strings.ToLower(key)
+	for {
+		for _, ks := range p.keyStrategies {
+			if ok, _ := path.Match(ks.pattern, key); ok {
+				return ks.strategy
+			}
+		}
+		i := strings.LastIndexByte(key, '.')
+		if i < 0 {
+			return p.mergeStrategy
+		}
+		key = key[:i]
+	}
+}
+
+// merge merges the settings of a config file into dst following the merge
+// strategies and returns dst
+func (p *Parser) merge(dst, src map[string]interface{}) map[string]interface{} {
+	return p.mergeAt(dst, src, "")
+}
+
+func (p *Parser) mergeAt(dst, src map[string]interface{}, prefix string) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	keys := foldedKeys{m: dst}
+	for k, sv := range src {
+		k = keys.find(k)
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		s := p.strategyFor(key)
+
+		if sv == nil && s.NullDeletes {
+			delete(dst, k)
+			continue
+		}
+		sm, srcIsMap := toStringMap(sv)
+		dm, dstIsMap := toStringMap(dst[k])
+		if old, ok := dst[k]; ok && old != nil && sv != nil && srcIsMap != dstIsMap {
+			p.pending.addConflict(key, old, sv)
+		}
+		if srcIsMap && dstIsMap && !s.ReplaceMaps {
+			dst[k] = p.mergeAt(dm, sm, key)
+			continue
+		}
+		sl, srcIsList := sv.([]interface{})
+		dl, dstIsList := dst[k].([]interface{})
+		if srcIsList && dstIsList {
+			dst[k] = mergeSlices(dl, sl, s.Slices)
+			continue
+		}
+		if srcIsMap && s.NullDeletes {
+			// nulls nested in a new map would otherwise survive
+			sv = p.mergeAt(nil, sm, key)
+		}
+		dst[k] = sv
+	}
+	return dst
+}
+
+// foldedKeys finds the keys of a map ignoring case
+type foldedKeys struct {
+	m map[string]interface{}
+	// index maps the lower-cased keys of m to its keys, built on the first
+	// key not found as is
+	index map[string]string
+}
+
+// find returns the key of m matching k ignoring case, or k when none does,
+// k being then added to the keys of m
+func (f *foldedKeys) find(k string) string {
+	if _, ok := f.m[k]; ok {
+		return k
+	}
+	if f.index == nil {
+		f.index = make(map[string]string, len(f.m))
+		for mk := range f.m {
+			f.index[strings.ToLower(mk)] = mk
+		}
+	}
+	lower := strings.ToLower(k)
+	if mk, ok := f.index[lower]; ok {
+		return mk
+	}
+	f.index[lower] = k
+	return k
+}
+
+func mergeSlices(dst, src []interface{}, mode SliceMerge) []interface{} {
+	switch mode {
+	case SliceAppend:
+		return append(append([]interface{}(nil), dst...), src...)
+	case SliceUnion:
+		out := append([]interface{}(nil), dst...)
+		for _, item := range src {
+			found := false
+			for _, existing := range out {
+				if reflect.DeepEqual(existing, item) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+	return src
+}
@@ -0,0 +1,146 @@
+package viper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeStrategies(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml": `
+hosts: [a, b]
+plugins: [auth, log]
+tags: [x, y]
+labels:
+  team: core
+  tier: web
+db:
+  host: localhost
+  pool: 4
+debug: true
+`,
+		"override.yaml": `
+extends: base.yaml
+hosts: [c]
+plugins: [metrics]
+tags: [y, z]
+labels:
+  tier: api
+db:
+  pool: 8
+debug: null
+`,
+	})
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]interface{}
+	}{
+		{
+			name: "defaults",
+			want: map[string]interface{}{
+				"hosts":   []interface{}{"c"},
+				"plugins": []interface{}{"metrics"},
+				"tags":    []interface{}{"y", "z"},
+				"labels":  map[string]interface{}{"team": "core", "tier": "api"},
+				"db":      map[string]interface{}{"host": "localhost", "pool": 8},
+			},
+		},
+		{
+			name: "global append and null deletes",
+			opts: []Option{WithMergeStrategy(MergeStrategy{Slices: SliceAppend, NullDeletes: true})},
+			want: map[string]interface{}{
+				"hosts":   []interface{}{"a", "b", "c"},
+				"plugins": []interface{}{"auth", "log", "metrics"},
+				"tags":    []interface{}{"x", "y", "y", "z"},
+				"labels":  map[string]interface{}{"team": "core", "tier": "api"},
+				"db":      map[string]interface{}{"host": "localhost", "pool": 8},
+			},
+		},
+		{
+			name: "per key",
+			opts: []Option{
+				WithMergeStrategy(MergeStrategy{Slices: SliceAppend}),
+				WithKeyMergeStrategy("hosts", MergeStrategy{Slices: SliceReplace}),
+				WithKeyMergeStrategy("tags", MergeStrategy{Slices: SliceUnion}),
+				WithKeyMergeStrategy("labels", MergeStrategy{ReplaceMaps: true}),
+				WithKeyMergeStrategy("debug", MergeStrategy{NullDeletes: true}),
+			},
+			want: map[string]interface{}{
+				"hosts":   []interface{}{"c"},
+				"plugins": []interface{}{"auth", "log", "metrics"},
+				"tags":    []interface{}{"x", "y", "z"},
+				"labels":  map[string]interface{}{"tier": "api"},
+				"db":      map[string]interface{}{"host": "localhost", "pool": 8},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := New(tt.opts...).Parse(filepath.Join(dir, "override.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.Raw, tt.want) {
+				t.Errorf("Raw = %v, want %v", cfg.Raw, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_merge(t *testing.T) {
+	src := map[string]interface{}{"debug": nil, "db": map[string]interface{}{"pool": nil, "host": "db"}}
+	tests := []struct {
+		name     string
+		strategy MergeStrategy
+		want     map[string]interface{}
+	}{
+		{
+			name: "null sets nil",
+			want: map[string]interface{}{"debug": nil, "db": map[string]interface{}{"pool": nil, "host": "db", "user": "app"}},
+		},
+		{
+			name:     "null deletes",
+			strategy: MergeStrategy{NullDeletes: true},
+			want:     map[string]interface{}{"db": map[string]interface{}{"host": "db", "user": "app"}},
+		},
+		{
+			name:     "null deletes in replaced maps",
+			strategy: MergeStrategy{NullDeletes: true, ReplaceMaps: true},
+			want:     map[string]interface{}{"db": map[string]interface{}{"host": "db"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := map[string]interface{}{"debug": true, "db": map[string]interface{}{"pool": 4, "user": "app"}}
+			got := New(WithMergeStrategy(tt.strategy)).merge(dst, src)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("merge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_ParseAllMergesKeysIgnoringCase(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml":     "Server:\n  host: a\n  port: 1\n",
+		"override.yaml": "server:\n  Port: 2\n",
+	})
+	p := New()
+	if _, err := p.ParseAll(filepath.Join(dir, "base.yaml"), filepath.Join(dir, "override.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetInt("server.port"); got != 2 {
+		t.Errorf("server.port = %d, want the later file to override", got)
+	}
+	if got := p.GetString("server.host"); got != "a" {
+		t.Errorf("server.host = %q, want a", got)
+	}
+
+	merged := deepMerge(map[string]interface{}{"Server": map[string]interface{}{"port": 1}}, map[string]interface{}{"server": map[string]interface{}{"PORT": 2}})
+	if len(merged) != 1 || merged["Server"].(map[string]interface{})["port"] != 2 {
+		t.Errorf("deepMerge() = %v, want the keys matched ignoring case", merged)
+	}
+}
@@ -0,0 +1,139 @@
+package viper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// MigrationKey is the key holding the version of the schema a config file
+// is written for
+const MigrationKey = "config_version"
+
+// Migration upgrades the settings of a config file to the next version of
+// the schema, returning them. It receives the settings of the file alone,
+// with the keys as written in the file, before the files it extends or
+// includes are merged in.
+type Migration func(settings map[string]interface{}) (map[string]interface{}, error)
+
+// WithMigration registers the migration upgrading the config files at
+// version from of the schema to version from+1. Files setting config_version
+// to a version older than the latest one, the version following the last
+// registered migration, are upgraded on load by the migrations in turn and
+// their config_version set to the latest version. Files without
+// config_version are left as they are, while a version without a migration
+// to upgrade it or newer than the latest one fails the load.
+func WithMigration(from int, m Migration) Option {
+	return func(p *Parser) {
+		if p.migrations == nil {
+			p.migrations = make(map[int]Migration)
+		}
+		p.migrations[from] = m
+	}
+}
+
+// WithMigrationWriteBack writes the config files upgraded by migrations back
+// once they load, so the migrations run once. YAML files keep their layout
+// as they do with Save. JSON files, having no comments to lose, are encoded
+// again. Files of the other formats, which hold comments or constraints an
+// encoder would drop, as .jsonc, .cue or .toml files do, are only migrated
+// in memory and a warning is logged, like files not read from the disk and
+// the ones whose extension is handled by a preprocessor.
+func WithMigrationWriteBack() Option {
+	return func(p *Parser) {
+		p.migrationWriteBack = true
+	}
+}
+
+// latestVersion returns the version of the schema the migrations lead to
+func (p *Parser) latestVersion() int {
+	latest := 0
+	for from := range p.migrations {
+		if from+1 > latest {
+			latest = from + 1
+		}
+	}
+	return latest
+}
+
+// migrate upgrades the settings read from configFile to the latest version
+// of the schema, recording the file to write back if asked
+func (p *Parser) migrate(configFile string, settings map[string]interface{}) (map[string]interface{}, error) {
+	if len(p.migrations) == 0 {
+		return settings, nil
+	}
+	key := MigrationKey
+	for k := range settings {
+		if strings.EqualFold(k, MigrationKey) {
+			key = k
+			break
+		}
+	}
+	raw, ok := settings[key]
+	if !ok {
+		return settings, nil
+	}
+	version, err := cast.ToIntE(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %v: %w", MigrationKey, raw, err)
+	}
+	latest := p.latestVersion()
+	if version > latest {
+		return nil, fmt.Errorf("%s %d is newer than the latest version %d", MigrationKey, version, latest)
+	}
+	if version == latest {
+		return settings, nil
+	}
+
+	from := version
+	for ; version < latest; version++ {
+		m, ok := p.migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from %s %d", MigrationKey, version)
+		}
+		if settings, err = m(settings); err != nil {
+			return nil, fmt.Errorf("migrating from %s %d: %w", MigrationKey, version, err)
+		}
+		if settings == nil {
+			settings = make(map[string]interface{})
+		}
+	}
+	delete(settings, key)
+	settings[MigrationKey] = latest
+	p.logger.Info("config file migrated", "file", configFile, "from", from, "to", latest)
+
+	if !p.migrationWriteBack || !p.onDisk(configFile) {
+		return settings, nil
+	}
+	typ := strings.ToLower(p.typeOf(configFile))
+	var b []byte
+	if doc, ok := p.pending.documents[configFile]; ok {
+		b, err = p.encodeYAML(doc, p.codecs[typ].(*yamlCodec), settings)
+	} else if codec, ok := p.codecs[typ]; ok && typ == "json" {
+		b, err = codec.Encode(normalizeJSON(settings).(map[string]interface{}))
+	} else {
+		p.logger.Warn("migrated config file not written back, its format cannot be written without loss", "file", configFile, "type", typ)
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encoding the migrated config: %w", err)
+	}
+	p.pending.migrated[configFile] = b
+	return settings, nil
+}
+
+// onDisk reports whether configFile is a file of the disk Save can write
+func (p *Parser) onDisk(configFile string) bool {
+	_, inline := p.inline[configFile]
+	return !inline && !strings.HasPrefix(configFile, fsPrefix) && p.unwrappedName(configFile) == configFile
+}
+
+// writeMigrated writes the migrated config files back
+func (p *Parser) writeMigrated() {
+	for file, b := range p.info.migrated {
+		if err := writeFileAtomic(file, b); err != nil {
+			p.logger.Error("cannot write the migrated config file", "file", file, "error", err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package viper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_Migrations(t *testing.T) {
+	v1 := "# the api service\nconfig_version: 1\nhost: api.local # public name\nport: 8080\n"
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":   v1,
+		"readonly.yaml": v1,
+		"plain.yaml":    "host: api.local\n",
+		"config.json":   `{"config_version": 2, "server": {"host": "api.local"}}`,
+	})
+	calls := 0
+	opts := []Option{
+		// 1 -> 2: host and port move under server
+		WithMigration(1, func(settings map[string]interface{}) (map[string]interface{}, error) {
+			calls++
+			settings["server"] = map[string]interface{}{"host": settings["host"], "port": settings["port"]}
+			delete(settings, "host")
+			delete(settings, "port")
+			return settings, nil
+		}),
+		// 2 -> 3: tls is required
+		WithMigration(2, func(settings map[string]interface{}) (map[string]interface{}, error) {
+			calls++
+			settings["tls"] = false
+			return settings, nil
+		}),
+	}
+
+	p := New(append(opts, WithMigrationWriteBack())...)
+	configFile := filepath.Join(dir, "config.yaml")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("%d migrations ran, want 2", calls)
+	}
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"server.host", "api.local"},
+		{"server.port", 8080},
+		{"tls", false},
+		{"config_version", 3},
+		{"host", nil},
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.path); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	b, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# the api service", "config_version: 3", "host: api.local", "tls: false"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("written file = %s, want it to contain %q", b, want)
+		}
+	}
+
+	// written back, the file is not migrated again
+	calls = 0
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 || p.GetString("server.host") != "api.local" {
+		t.Errorf("%d migrations ran on the migrated file, want 0", calls)
+	}
+
+	t.Run("in memory", func(t *testing.T) {
+		calls = 0
+		p := New(opts...)
+		for _, name := range []string{"readonly.yaml", "plain.yaml", "config.json"} {
+			if _, err := p.Parse(filepath.Join(dir, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if calls != 3 {
+			t.Errorf("%d migrations ran, want 3: two for readonly.yaml, one for config.json", calls)
+		}
+		if b, _ := os.ReadFile(filepath.Join(dir, "readonly.yaml")); string(b) != v1 {
+			t.Errorf("readonly.yaml = %s, want it untouched without WithMigrationWriteBack", b)
+		}
+		if got := p.GetInt("config_version"); got != 3 {
+			t.Errorf("config_version = %d, want 3", got)
+		}
+	})
+
+	errTests := []struct {
+		content string
+		want    string
+	}{
+		{"config_version: 4\n", "config_version 4 is newer than the latest version 3"},
+		{"config_version: one\n", "invalid config_version one"},
+		{"config_version: 0\n", "no migration from config_version 0"},
+	}
+	for _, tt := range errTests {
+		dir := writeFiles(t, map[string]string{"bad.yaml": tt.content})
+		_, err := New(opts...).Parse(filepath.Join(dir, "bad.yaml"))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse() error = %v, want it to mention %q", err, tt.want)
+		}
+	}
+
+	errFailed := errors.New("failed")
+	p = New(WithMigration(1, func(map[string]interface{}) (map[string]interface{}, error) { return nil, errFailed }))
+	if _, err := p.ParseBytes([]byte("config_version: 1\n"), "yaml"); !errors.Is(err, errFailed) {
+		t.Errorf("Parse() error = %v, want the migration error", err)
+	}
+}
+
+func TestParser_MigrationWriteBack_LossyFormats(t *testing.T) {
+	files := map[string]string{
+		"config.jsonc": "{\n  // the api service\n  \"config_version\": 1,\n  \"host\": \"api.local\"\n}\n",
+		"config.toml":  "# the api service\nconfig_version = 1\nhost = \"api.local\"\n",
+	}
+	dir := writeFiles(t, files)
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			p := New(WithMigrationWriteBack(), WithMigration(1, func(settings map[string]interface{}) (map[string]interface{}, error) {
+				settings["server"] = map[string]interface{}{"host": settings["host"]}
+				delete(settings, "host")
+				return settings, nil
+			}))
+			configFile := filepath.Join(dir, name)
+			if _, err := p.Parse(configFile); err != nil {
+				t.Fatal(err)
+			}
+			if got := p.GetString("server.host"); got != "api.local" {
+				t.Errorf("server.host = %q, want the config migrated in memory", got)
+			}
+			b, err := os.ReadFile(configFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != content {
+				t.Errorf("%s = %s, want it left as written", name, b)
+			}
+		})
+	}
+}
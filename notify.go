@@ -0,0 +1,313 @@
+package viper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WatchEvent describes a change picked up by a watched config file
+type WatchEvent struct {
+	File string    `json:"file"`
+	Time time.Time `json:"time"`
+}
+
+// OverflowPolicy decides what happens to an event when the consumer's buffer
+// is full
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the incoming event and keeps the buffered ones
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest buffered event to make room
+	DropOldest
+	// CoalesceLatest replaces every buffered event with the incoming one, so
+	// the consumer always ends up with the latest state
+	CoalesceLatest
+	// BlockWithTimeout waits up to Timeout for room, then drops the event
+	BlockWithTimeout
+)
+
+func (o OverflowPolicy) String() string {
+	switch o {
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	case CoalesceLatest:
+		return "coalesce-latest"
+	case BlockWithTimeout:
+		return "block-with-timeout"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(o))
+}
+
+const (
+	// DefaultDeliveryBuffer is the buffer size used when none is given
+	DefaultDeliveryBuffer = 16
+	// DefaultDeliveryTimeout bounds BlockWithTimeout when no timeout is given
+	DefaultDeliveryTimeout = time.Second
+	// DefaultWebhookTimeout bounds each post of a WebhookNotifier created
+	// without a client
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+// DeliveryOptions configures how events are buffered for a slow consumer.
+// Zero values select the defaults.
+type DeliveryOptions struct {
+	// Buffer is the number of events held for the consumer
+	Buffer int
+	// Policy applies once the buffer is full
+	Policy OverflowPolicy
+	// Timeout bounds the wait under BlockWithTimeout
+	Timeout time.Duration
+}
+
+// DeliveryStats counts what happened to the events handed to a notifier
+type DeliveryStats struct {
+	// Delivered counts events handed to the consumer
+	Delivered uint64
+	// Dropped counts events discarded by DropNewest, DropOldest or an
+	// expired BlockWithTimeout wait
+	Dropped uint64
+	// Coalesced counts buffered events replaced under CoalesceLatest
+	Coalesced uint64
+	// Failed counts events the consumer could not process
+	Failed uint64
+}
+
+// queue buffers events for a single consumer according to an overflow policy
+type queue struct {
+	ch   chan WatchEvent
+	opts DeliveryOptions
+	// mu serializes producers so making room and sending happen together
+	mu sync.Mutex
+
+	queued    atomic.Uint64
+	dropped   atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+func newQueue(opts DeliveryOptions) *queue {
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultDeliveryBuffer
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultDeliveryTimeout
+	}
+	return &queue{ch: make(chan WatchEvent, opts.Buffer), opts: opts}
+}
+
+// push enqueues ev and reports whether it was kept
+func (q *queue) push(ev WatchEvent) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	select {
+	case q.ch <- ev:
+		q.queued.Add(1)
+		return true
+	default:
+	}
+
+	switch q.opts.Policy {
+	case DropOldest, CoalesceLatest:
+		for {
+			select {
+			case q.ch <- ev:
+				q.queued.Add(1)
+				return true
+			default:
+			}
+			// the consumer may drain the buffer concurrently, so only
+			// count what was actually removed
+			select {
+			case <-q.ch:
+				if q.opts.Policy == DropOldest {
+					q.dropped.Add(1)
+					continue
+				}
+				q.coalesced.Add(1)
+				for drained := true; drained; {
+					select {
+					case <-q.ch:
+						q.coalesced.Add(1)
+					default:
+						drained = false
+					}
+				}
+			default:
+			}
+		}
+	case BlockWithTimeout:
+		timer := time.NewTimer(q.opts.Timeout)
+		defer timer.Stop()
+		select {
+		case q.ch <- ev:
+			q.queued.Add(1)
+			return true
+		case <-timer.C:
+		}
+	}
+	q.dropped.Add(1)
+	return false
+}
+
+// ChanNotifier delivers watch events on a buffered channel
+type ChanNotifier struct {
+	// C receives the events
+	C <-chan WatchEvent
+	q *queue
+}
+
+// NewChanNotifier returns a notifier whose channel buffers events according
+// to opts
+func NewChanNotifier(opts DeliveryOptions) *ChanNotifier {
+	q := newQueue(opts)
+	return &ChanNotifier{C: q.ch, q: q}
+}
+
+// Notify hands ev to the channel, applying the overflow policy when it is
+// full. It never blocks longer than the BlockWithTimeout timeout.
+func (n *ChanNotifier) Notify(ev WatchEvent) {
+	n.q.push(ev)
+}
+
+// Callback returns a Watch callback notifying about changes to file
+func (n *ChanNotifier) Callback(file string) func() {
+	return func() {
+		n.Notify(WatchEvent{File: file, Time: time.Now()})
+	}
+}
+
+// Stats returns the delivery counters. Delivered counts events accepted by
+// the channel, including ones later replaced by the overflow policy.
+func (n *ChanNotifier) Stats() DeliveryStats {
+	return DeliveryStats{
+		Delivered: n.q.queued.Load(),
+		Dropped:   n.q.dropped.Load(),
+		Coalesced: n.q.coalesced.Load(),
+	}
+}
+
+// WebhookNotifier POSTs watch events as JSON to a URL from a background
+// worker, so a slow endpoint never blocks the reload path
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+	q      *queue
+
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+
+	// ctx is cancelled by Close, aborting the post in flight
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWebhookNotifier starts a notifier posting to url. A nil client selects
+// a client giving up after DefaultWebhookTimeout. Close stops the worker.
+func NewWebhookNotifier(url string, client *http.Client, opts DeliveryOptions) *WebhookNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &WebhookNotifier{
+		url:    url,
+		client: client,
+		q:      newQueue(opts),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// Notify queues ev for posting, applying the overflow policy when the queue
+// is full. Events are dropped once the notifier is closed.
+func (n *WebhookNotifier) Notify(ev WatchEvent) {
+	select {
+	case <-n.ctx.Done():
+		n.q.dropped.Add(1)
+		return
+	default:
+	}
+	n.q.push(ev)
+}
+
+// Callback returns a Watch callback notifying about changes to file
+func (n *WebhookNotifier) Callback(file string) func() {
+	return func() {
+		n.Notify(WatchEvent{File: file, Time: time.Now()})
+	}
+}
+
+// Stats returns the delivery counters. Delivered counts events the endpoint
+// answered with a 2xx status, Failed the ones it rejected or never got.
+func (n *WebhookNotifier) Stats() DeliveryStats {
+	return DeliveryStats{
+		Delivered: n.delivered.Load(),
+		Dropped:   n.q.dropped.Load(),
+		Coalesced: n.q.coalesced.Load(),
+		Failed:    n.failed.Load(),
+	}
+}
+
+// Close stops the worker, cancelling the post in flight, if any, which is
+// counted as failed. Events still queued are dropped.
+func (n *WebhookNotifier) Close() {
+	n.cancel()
+	<-n.done
+	for {
+		select {
+		case <-n.q.ch:
+			n.q.dropped.Add(1)
+		default:
+			return
+		}
+	}
+}
+
+func (n *WebhookNotifier) run() {
+	defer close(n.done)
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case ev := <-n.q.ch:
+			if err := n.post(ev); err != nil {
+				n.failed.Add(1)
+			} else {
+				n.delivered.Add(1)
+			}
+		}
+	}
+}
+
+func (n *WebhookNotifier) post(ev WatchEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %q: unexpected status %s", n.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package viper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChanNotifier_Overflow(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    OverflowPolicy
+		want      []string
+		wantStats DeliveryStats
+	}{
+		{
+			name:      "drop newest",
+			policy:    DropNewest,
+			want:      []string{"a", "b"},
+			wantStats: DeliveryStats{Delivered: 2, Dropped: 3},
+		},
+		{
+			name:      "drop oldest",
+			policy:    DropOldest,
+			want:      []string{"d", "e"},
+			wantStats: DeliveryStats{Delivered: 5, Dropped: 3},
+		},
+		{
+			name:      "coalesce latest",
+			policy:    CoalesceLatest,
+			want:      []string{"e"},
+			wantStats: DeliveryStats{Delivered: 5, Coalesced: 4},
+		},
+		{
+			name:      "block with timeout",
+			policy:    BlockWithTimeout,
+			want:      []string{"a", "b"},
+			wantStats: DeliveryStats{Delivered: 2, Dropped: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewChanNotifier(DeliveryOptions{Buffer: 2, Policy: tt.policy, Timeout: 10 * time.Millisecond})
+			for _, f := range []string{"a", "b", "c", "d", "e"} {
+				n.Notify(WatchEvent{File: f})
+			}
+
+			var got []string
+			for len(n.C) > 0 {
+				got = append(got, (<-n.C).File)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("received %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("received %v, want %v", got, tt.want)
+				}
+			}
+			if stats := n.Stats(); stats != tt.wantStats {
+				t.Errorf("Stats() = %+v, want %+v", stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestChanNotifier_BlockWithTimeout(t *testing.T) {
+	n := NewChanNotifier(DeliveryOptions{Buffer: 1, Policy: BlockWithTimeout, Timeout: time.Second})
+	n.Notify(WatchEvent{File: "a"})
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-n.C
+	}()
+	start := time.Now()
+	n.Notify(WatchEvent{File: "b"})
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Notify() waited %v, want it to resume once the consumer reads", elapsed)
+	}
+	if got := (<-n.C).File; got != "b" {
+		t.Errorf("received %q, want b", got)
+	}
+	if stats := n.Stats(); stats.Dropped != 0 {
+		t.Errorf("Dropped = %d, want 0", stats.Dropped)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var files []string
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var ev WatchEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		mu.Lock()
+		files = append(files, ev.File)
+		mu.Unlock()
+		if ev.File == "bad" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL, srv.Client(), DeliveryOptions{Buffer: 1, Policy: CoalesceLatest})
+	n.Notify(WatchEvent{File: "first"})
+	// wait for the worker to pick up the first event so the rest queue up
+	deadline := time.Now().Add(time.Second)
+	for len(n.q.ch) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the endpoint is stalled: Notify must not block, and only the latest
+	// event survives
+	start := time.Now()
+	for _, f := range []string{"a", "b", "bad"} {
+		n.Notify(WatchEvent{File: f})
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Notify() blocked for %v on a slow endpoint", elapsed)
+	}
+	close(release)
+
+	deadline = time.Now().Add(time.Second)
+	for {
+		stats := n.Stats()
+		if stats.Delivered+stats.Failed == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	n.Close()
+
+	want := DeliveryStats{Delivered: 1, Coalesced: 2, Failed: 1}
+	if stats := n.Stats(); stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(files) != 2 || files[0] != "first" || files[1] != "bad" {
+		t.Errorf("endpoint received %v, want [first bad]", files)
+	}
+
+	n.Notify(WatchEvent{File: "late"})
+	if stats := n.Stats(); stats.Dropped != 1 {
+		t.Errorf("Dropped = %d after Close, want 1", stats.Dropped)
+	}
+}
+
+func TestWebhookNotifier_CloseCancelsPost(t *testing.T) {
+	received := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	n := NewWebhookNotifier(srv.URL, srv.Client(), DeliveryOptions{})
+	n.Notify(WatchEvent{File: "stalled"})
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("endpoint never received the event")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		n.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close() waited for a stalled endpoint")
+	}
+	if stats := n.Stats(); stats.Failed != 1 {
+		t.Errorf("Failed = %d after Close, want the cancelled post", stats.Failed)
+	}
+
+	d := NewWebhookNotifier(srv.URL, nil, DeliveryOptions{})
+	defer d.Close()
+	if d.client.Timeout != DefaultWebhookTimeout {
+		t.Errorf("default client timeout = %v, want %v", d.client.Timeout, DefaultWebhookTimeout)
+	}
+}
@@ -0,0 +1,144 @@
+package viper
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// WithUseNumber makes JSON files keep the exact value of integers, like
+// json.Decoder.UseNumber does: integers are decoded as int64, or uint64 when
+// they only fit an unsigned integer, instead of float64, which cannot
+// represent integers above 2^53 like snowflake IDs or large byte counts.
+// Integers too large for both are kept as json.Number. Other numbers are
+// decoded as float64.
+func WithUseNumber() Option {
+	return func(p *Parser) {
+		p.useNumber = true
+	}
+}
+
+// GetInt64 retrieves a 64-bit integer value from the configuration. Values
+// out of range or with a fractional part are logged and read as 0.
+func (p *Parser) GetInt64(path string) int64 {
+	n, err := toInt64(p.value(path))
+	if err != nil {
+		p.logger.Warn("cannot read int64", "key", path, "error", err)
+		return 0
+	}
+	return n
+}
+
+// GetUint64 retrieves an unsigned 64-bit integer value from the
+// configuration. Negative values, values out of range or with a fractional
+// part are logged and read as 0.
+func (p *Parser) GetUint64(path string) uint64 {
+	n, err := toUint64(p.value(path))
+	if err != nil {
+		p.logger.Warn("cannot read uint64", "key", path, "error", err)
+		return 0
+	}
+	return n
+}
+
+// toInt64 converts v without losing precision
+func toInt64(v interface{}) (int64, error) {
+	switch t := v.(type) {
+	case nil:
+		return 0, nil
+	case bool:
+		if t {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(t), 0, 64)
+	case json.Number:
+		return strconv.ParseInt(t.String(), 10, 64)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows int64", rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		// float64(math.MaxInt64) rounds up to 2^63, which is out of range
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not an int64", f)
+		}
+		return int64(f), nil
+	}
+	return 0, fmt.Errorf("%v (%T) is not an integer", v, v)
+}
+
+// toUint64 converts v without losing precision
+func toUint64(v interface{}) (uint64, error) {
+	switch t := v.(type) {
+	case nil:
+		return 0, nil
+	case bool:
+		if t {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseUint(strings.TrimSpace(t), 0, 64)
+	case json.Number:
+		return strconv.ParseUint(t.String(), 10, 64)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return 0, fmt.Errorf("%d is negative", rv.Int())
+		}
+		return uint64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+			return 0, fmt.Errorf("%v is not a uint64", f)
+		}
+		return uint64(f), nil
+	}
+	return 0, fmt.Errorf("%v (%T) is not an unsigned integer", v, v)
+}
+
+// exactNumbers replaces the json.Number values decoded with UseNumber by
+// int64, uint64 or float64 values
+func exactNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			t[k] = exactNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range t {
+			t[i] = exactNumbers(item)
+		}
+	case json.Number:
+		s := t.String()
+		if strings.ContainsAny(s, ".eE") {
+			if f, err := t.Float64(); err == nil {
+				return f
+			}
+			return t
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
@@ -0,0 +1,111 @@
+package viper
+
+import (
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithUseNumber(t *testing.T) {
+	t.Setenv("NUMBERSTEST_FROM_ENV", "18446744073709551615")
+	dir := writeFiles(t, map[string]string{
+		"config.json": `{
+			"id": 1234567890123456789,
+			"above_2_53": 9007199254740993,
+			"max_int64": 9223372036854775807,
+			"min_int64": -9223372036854775808,
+			"max_uint64": 18446744073709551615,
+			"huge": 123456789012345678901234567890,
+			"ratio": 0.25,
+			"exp": 1e3,
+			"list": [9007199254740993]
+		}`,
+		"config.yaml": "max_int64: 9223372036854775807\nmax_uint64: 18446744073709551615\nratio: 1.5\nneg: -1\n",
+	})
+
+	p := New(WithEnvPrefix("numberstest"), WithUseNumber())
+	if _, err := p.Parse(filepath.Join(dir, "config.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	int64Tests := []struct {
+		path string
+		want int64
+	}{
+		{"id", 1234567890123456789},
+		{"above_2_53", 9007199254740993},
+		{"max_int64", math.MaxInt64},
+		{"min_int64", math.MinInt64},
+		{"max_uint64", 0}, // overflows
+		{"ratio", 0},      // fractional
+		{"exp", 1000},
+	}
+	for _, tt := range int64Tests {
+		t.Run("int64 "+tt.path, func(t *testing.T) {
+			if got := p.GetInt64(tt.path); got != tt.want {
+				t.Errorf("GetInt64(%q) = %d, want %d", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	uint64Tests := []struct {
+		path string
+		want uint64
+	}{
+		{"max_uint64", math.MaxUint64},
+		{"max_int64", math.MaxInt64},
+		{"min_int64", 0}, // negative
+		{"from_env", math.MaxUint64},
+	}
+	for _, tt := range uint64Tests {
+		t.Run("uint64 "+tt.path, func(t *testing.T) {
+			if got := p.GetUint64(tt.path); got != tt.want {
+				t.Errorf("GetUint64(%q) = %d, want %d", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if got, ok := p.Get("huge").(json.Number); !ok || got.String() != "123456789012345678901234567890" {
+		t.Errorf("huge = %v (%T), want the json.Number kept", p.Get("huge"), p.Get("huge"))
+	}
+	if got := p.Get("ratio"); got != 0.25 {
+		t.Errorf("ratio = %v (%T), want float64 0.25", got, got)
+	}
+	if got := p.Get("list").([]interface{})[0]; got != int64(9007199254740993) {
+		t.Errorf("list[0] = %v (%T), want the exact int64", got, got)
+	}
+
+	t.Run("without UseNumber", func(t *testing.T) {
+		p := New()
+		if _, err := p.Parse(filepath.Join(dir, "config.json")); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Get("above_2_53"); got != float64(9007199254740992) {
+			t.Errorf("above_2_53 = %v (%T), want the float64 approximation", got, got)
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		p := New()
+		if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.GetInt64("max_int64"); got != math.MaxInt64 {
+			t.Errorf("GetInt64(max_int64) = %d", got)
+		}
+		if got := p.GetUint64("max_uint64"); got != math.MaxUint64 {
+			t.Errorf("GetUint64(max_uint64) = %d", got)
+		}
+		if got := p.GetUint64("neg"); got != 0 {
+			t.Errorf("GetUint64(neg) = %d, want 0", got)
+		}
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{"bad.json": `{"a": 1} {"b": 2}`})
+		if _, err := New(WithUseNumber()).Parse(filepath.Join(dir, "bad.json")); err == nil {
+			t.Error("Parse() of a file with trailing data succeeded")
+		}
+	})
+}
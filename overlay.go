@@ -0,0 +1,97 @@
+package viper
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	regionsKey = "regions"
+	localesKey = "locales"
+)
+
+// WithRegion selects the overlay declared under `regions.<region>` in the
+// config file. It is merged on top of the base settings. Without it, a
+// `regions` key is plain config data.
+func WithRegion(region string) Option {
+	return func(p *Parser) {
+		p.region = region
+	}
+}
+
+// WithLocale selects the overlay declared under `locales.<locale>` in the
+// config file. It is merged on top of the base settings and the region
+// overlay, so locale-specific values win. Without it, a `locales` key is
+// plain config data.
+func WithLocale(locale string) Option {
+	return func(p *Parser) {
+		p.locale = locale
+	}
+}
+
+// applyOverlays merges the selected region and locale sections on top of the
+// base settings, in that order. The `regions` section is removed from the
+// effective config when WithRegion is set, and the `locales` one when
+// WithLocale is, whether or not they hold the selected overlay.
+func (p *Parser) applyOverlays(settings map[string]interface{}) (map[string]interface{}, error) {
+	axes := []struct {
+		key, name string
+	}{
+		{regionsKey, p.region},
+		{localesKey, p.locale},
+	}
+
+	overlays := make([]map[string]interface{}, 0, len(axes))
+	for _, axis := range axes {
+		if axis.name == "" {
+			continue
+		}
+		section, ok := settings[axis.key]
+		if !ok {
+			continue
+		}
+		delete(settings, axis.key)
+
+		all, ok := toStringMap(section)
+		if !ok {
+			return nil, fmt.Errorf("%q section is not a map", axis.key)
+		}
+		overlay, ok := lookupFold(all, axis.name)
+		if !ok {
+			continue
+		}
+		m, ok := toStringMap(overlay)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s overlay is not a map", axis.key, axis.name)
+		}
+		overlays = append(overlays, m)
+		if err := p.pending.moveOrigins(axis.key + "." + axis.name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, overlay := range overlays {
+		settings = p.merge(settings, overlay)
+	}
+	return settings, nil
+}
+
+// isOverlayKey reports whether the top-level key name holds the overlays of
+// WithRegion or WithLocale
+func (p *Parser) isOverlayKey(name string) bool {
+	name = strings.ToLower(name)
+	return (name == regionsKey && p.region != "") || (name == localesKey && p.locale != "")
+}
+
+// lookupFold returns the value stored under key, ignoring case as viper does
+func lookupFold(m map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
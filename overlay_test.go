@@ -0,0 +1,121 @@
+package viper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_Overlays(t *testing.T) {
+	content := []byte(`
+db:
+  host: db.internal
+  pool: 4
+greeting: hello
+regions:
+  eu-west-1:
+    db:
+      host: db.eu.internal
+    greeting: hi
+locales:
+  de-DE:
+    greeting: hallo
+`)
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configFile, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		host     string
+		greeting string
+	}{
+		{
+			name:     "no overlay",
+			host:     "db.internal",
+			greeting: "hello",
+		},
+		{
+			name:     "region",
+			opts:     []Option{WithRegion("eu-west-1")},
+			host:     "db.eu.internal",
+			greeting: "hi",
+		},
+		{
+			name:     "locale wins over region",
+			opts:     []Option{WithRegion("eu-west-1"), WithLocale("de-DE")},
+			host:     "db.eu.internal",
+			greeting: "hallo",
+		},
+		{
+			name:     "unknown region",
+			opts:     []Option{WithRegion("ap-south-1")},
+			host:     "db.internal",
+			greeting: "hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.opts...)
+			cfg, err := p.Parse(configFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.GetString("db.host"); got != tt.host {
+				t.Errorf("db.host = %v, want %v", got, tt.host)
+			}
+			if got := p.GetString("greeting"); got != tt.greeting {
+				t.Errorf("greeting = %v, want %v", got, tt.greeting)
+			}
+			if got := p.GetInt("db.pool"); got != 4 {
+				t.Errorf("db.pool = %v, want 4", got)
+			}
+			if _, ok := cfg.Raw[regionsKey]; ok != (p.region == "") {
+				t.Errorf("regions section in the effective config = %v, want it kept only without WithRegion", ok)
+			}
+			if _, ok := cfg.Raw[localesKey]; ok != (p.locale == "") {
+				t.Errorf("locales section in the effective config = %v, want it kept only without WithLocale", ok)
+			}
+		})
+	}
+}
+
+func TestParser_OverlaysInvalid(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"regions": {"eu": "nope"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(WithRegion("eu")).Parse(configFile); err == nil {
+		t.Error("Parse() expected an error for a non-map overlay")
+	}
+}
+
+func TestParser_OverlaysPlainData(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("regions: [us-east-1, eu-west-1]\nlocales:\n  default: en-US\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetStringSlice("regions"); len(got) != 2 || got[1] != "eu-west-1" {
+		t.Errorf("regions = %v, want the list kept as config data", got)
+	}
+	if got := p.GetString("locales.default"); got != "en-US" {
+		t.Errorf("locales.default = %q, want en-US", got)
+	}
+
+	if _, err := New(WithLocale("de-DE")).Parse(configFile); err != nil {
+		t.Errorf("Parse() with WithLocale only = %v, want the regions list kept", err)
+	}
+	if _, err := New(WithRegion("eu-west-1")).Parse(configFile); err == nil {
+		t.Error("Parse() of a regions list with WithRegion succeeded")
+	}
+}
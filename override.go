@@ -0,0 +1,312 @@
+package viper
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// override is a value set at runtime on top of every source. Overrides set
+// on the same key stack up, so an expiring one reveals the one it replaced.
+type override struct {
+	value interface{}
+	prev  *override
+	timer *time.Timer
+}
+
+// Override sets the value of a key with the highest precedence. Overrides are
+// kept across reloads and are not inherited by the parent of a child parser.
+// Keys owned by a read-only source cannot be overridden.
+func (p *Parser) Override(path string, value interface{}) error {
+	p.mu.Lock()
+	if err := p.setOverride(path, &override{value: value}); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	p.mu.Unlock()
+	p.changed()
+	return nil
+}
+
+// OverrideFor sets the value of a key with the highest precedence for the
+// given duration. Once it expires, the key reverts to the value it had
+// before and the change listeners are notified, so temporary operational
+// toggles cannot be forgotten.
+func (p *Parser) OverrideFor(path string, value interface{}, ttl time.Duration) error {
+	o := &override{value: value}
+	p.mu.Lock()
+	if err := p.setOverride(path, o); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	o.timer = time.AfterFunc(ttl, func() { p.expire(path, o) })
+	p.mu.Unlock()
+	p.changed()
+	return nil
+}
+
+// Set sets the value of a key with the highest precedence, like Override,
+// but replaces every override of the key, expiring ones included, rather
+// than stacking on top of them.
+//
+// Set does not report failures: a key owned by a read-only source keeps its
+// value, and the only trace of the refusal is a warning in the log. Use
+// TrySet to get the *PolicyError instead.
+func (p *Parser) Set(path string, value interface{}) {
+	if err := p.TrySet(path, value); err != nil {
+		p.logger.Warn("cannot set config key", "key", path, "error", err)
+	}
+}
+
+// TrySet sets the value of a key like Set does, but returns its failure: a
+// *PolicyError when the key is owned by a read-only source, or ErrClosed.
+// The overrides of the key are then left as they were.
+func (p *Parser) TrySet(path string, value interface{}) error {
+	p.mu.Lock()
+	if err := p.checkOverride(path); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	p.clearOverrides(path)
+	if err := p.setOverride(path, &override{value: value}); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	p.mu.Unlock()
+	p.changed()
+	return nil
+}
+
+// ClearOverride removes every override of a key, set by Set, Override or
+// OverrideFor, so it reads its value from the sources again
+func (p *Parser) ClearOverride(path string) {
+	p.mu.Lock()
+	cleared := p.clearOverrides(path)
+	p.mu.Unlock()
+	if cleared {
+		p.changed()
+	}
+}
+
+// clearOverrides drops the overrides of path, stopping their expiry, and
+// reports whether there was any. Callers hold p.mu.
+func (p *Parser) clearOverrides(path string) bool {
+	key := strings.ToLower(p.normalizePath(path))
+	top, ok := p.overrides[key]
+	if !ok {
+		return false
+	}
+	for o := top; o != nil; o = o.prev {
+		if o.timer != nil {
+			o.timer.Stop()
+		}
+	}
+	delete(p.overrides, key)
+	p.version++
+	return true
+}
+
+// setOverride pushes o on top of the overrides of path. Callers hold p.mu.
+func (p *Parser) setOverride(path string, o *override) error {
+	if err := p.checkOverride(path); err != nil {
+		return err
+	}
+	key := strings.ToLower(p.normalizePath(path))
+	if p.overrides == nil {
+		p.overrides = make(map[string]*override)
+	}
+	o.prev = p.overrides[key]
+	p.overrides[key] = o
+	p.version++
+	return nil
+}
+
+// checkOverride reports why path cannot be overridden, if it cannot.
+// Callers hold p.mu.
+func (p *Parser) checkOverride(path string) error {
+	if err := p.checkOpen(); err != nil {
+		return err
+	}
+	key := strings.ToLower(p.normalizePath(path))
+	if owner, locked := p.lockedBy(key); locked {
+		return &PolicyError{Key: key, Source: owner, Attempt: "override"}
+	}
+	return nil
+}
+
+// expire removes o from the overrides of path
+func (p *Parser) expire(path string, o *override) {
+	key := strings.ToLower(p.normalizePath(path))
+	p.mu.Lock()
+	top := p.overrides[key]
+	if top == o {
+		if o.prev == nil {
+			delete(p.overrides, key)
+		} else {
+			p.overrides[key] = o.prev
+		}
+		p.version++
+		p.mu.Unlock()
+		p.logger.Info("config override expired", "key", key)
+		p.changed()
+		return
+	}
+	// o is shadowed by a newer override, unlink it so it is not revealed
+	for cur := top; cur != nil; cur = cur.prev {
+		if cur.prev == o {
+			cur.prev = o.prev
+			break
+		}
+	}
+	p.mu.Unlock()
+}
+
+// overridden returns the value of path taking the overrides into account,
+// including overrides set on a parent or a child of path. Callers hold the
+// read lock.
+func (p *Parser) overridden(path string) (interface{}, bool) {
+	if len(p.overrides) == 0 {
+		return nil, false
+	}
+	key := strings.ToLower(p.normalizePath(path))
+	if o, ok := p.overrides[key]; ok {
+		return o.value, true
+	}
+
+	// an override of a parent map holds the value
+	for parent := key; ; {
+		i := strings.LastIndexByte(parent, '.')
+		if i < 0 {
+			break
+		}
+		parent = parent[:i]
+		if o, ok := p.overrides[parent]; ok {
+			m, isMap := toStringMap(o.value)
+			if !isMap {
+				return nil, false
+			}
+			v, found := lookupPath(lowerKeys(m), key[i+1:])
+			return v, found
+		}
+	}
+
+	// overrides of nested keys are merged into the map they belong to
+	var nested map[string]interface{}
+	for k, o := range p.overrides {
+		rest, ok := strings.CutPrefix(k, key+".")
+		if !ok {
+			continue
+		}
+		if nested == nil {
+			base, _ := toStringMap(p.configured(path))
+			nested = lowerKeys(base)
+		}
+		setPath(nested, rest, o.value)
+	}
+	if nested != nil {
+		return nested, true
+	}
+	return nil, false
+}
+
+// settings returns the effective settings, overrides included. Callers hold
+// the read lock.
+func (p *Parser) settings() map[string]interface{} {
+	settings := p.v.AllSettings()
+	overlayLeaves(settings, p.layer)
+	p.setFlags(settings, "")
+	keys := make([]string, 0, len(p.overrides))
+	for k := range p.overrides {
+		keys = append(keys, k)
+	}
+	// parents first, so overrides of nested keys are applied on top
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := p.overrides[k].value
+		if m, ok := toStringMap(v); ok {
+			v = lowerKeys(m)
+		}
+		setPath(settings, k, v)
+	}
+	return settings
+}
+
+// configured returns the value viper holds at the normalized path, the
+// config layer taking precedence over the environment. Callers hold the
+// read lock.
+func (p *Parser) configured(path string) interface{} {
+	v := p.v.Get(path)
+	cv, ok := lookupPathFold(p.layer, path)
+	if !ok || cv == nil {
+		return v
+	}
+	if changed, ok := p.flags[strings.ToLower(path)]; ok && changed() {
+		return v
+	}
+	cm, isMap := toStringMap(cv)
+	if !isMap {
+		return cv
+	}
+	// a section holds the environment and default keys the layer lacks
+	m, _ := toStringMap(deepCopy(v))
+	if m == nil {
+		m = make(map[string]interface{}, len(cm))
+	}
+	overlayLeaves(m, cm)
+	p.setFlags(m, strings.ToLower(path))
+	return m
+}
+
+// overlayLeaves sets the leaves of src in dst, creating the maps src holds
+// in dst rather than sharing them
+func overlayLeaves(dst, src map[string]interface{}) {
+	keys := foldedKeys{m: dst}
+	for k, sv := range src {
+		k = keys.find(k)
+		sm, ok := toStringMap(sv)
+		if !ok {
+			if sv != nil {
+				dst[k] = sv
+			}
+			continue
+		}
+		dm, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dm, _ = toStringMap(deepCopy(dst[k]))
+			if dm == nil {
+				dm = make(map[string]interface{}, len(sm))
+			}
+			dst[k] = dm
+		}
+		overlayLeaves(dm, sm)
+	}
+}
+
+// lookupPathFold returns the value at a dot-notation path of a nested map,
+// matching the keys ignoring case
+func lookupPathFold(m map[string]interface{}, key string) (interface{}, bool) {
+	head, rest, nested := strings.Cut(key, ".")
+	v, ok := lookupFold(m, head)
+	if !ok || !nested {
+		return v, ok
+	}
+	child, ok := toStringMap(v)
+	if !ok {
+		return nil, false
+	}
+	return lookupPathFold(child, rest)
+}
+
+// lookupPath returns the value at a dot-notation path of a nested map
+func lookupPath(m map[string]interface{}, key string) (interface{}, bool) {
+	head, rest, nested := strings.Cut(key, ".")
+	v, ok := m[head]
+	if !ok || !nested {
+		return v, ok
+	}
+	child, ok := toStringMap(v)
+	if !ok {
+		return nil, false
+	}
+	return lookupPath(child, rest)
+}
@@ -0,0 +1,133 @@
+package viper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParser_Override(t *testing.T) {
+	t.Setenv("OVERRIDETEST_LOG_LEVEL", "warn")
+	dir := writeFiles(t, map[string]string{"config.yaml": "log:\n  level: info\n  format: json\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	p := New(WithEnvPrefix("overridetest"))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	p.Override("log.level", "debug")
+	p.Override("feature", map[string]interface{}{"Enabled": true})
+
+	if got := p.GetString("log.level"); got != "debug" {
+		t.Errorf("log.level = %q, want the override to beat the env", got)
+	}
+	if got, want := p.GetStringMap("log"), map[string]interface{}{"level": "debug", "format": "json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("log = %v, want %v", got, want)
+	}
+	if !p.GetBool("feature.enabled") {
+		t.Error("feature.enabled not read from the overridden map")
+	}
+
+	// overrides survive reloads and show in the parsed settings
+	cfg, err := p.Parse(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Raw["log"].(map[string]interface{})["level"]; got != "debug" {
+		t.Errorf("Raw log.level = %v, want debug", got)
+	}
+}
+
+func TestParser_OverrideFor(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.json": `{"debug": {"verbose": false}}`})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan bool, 10)
+	p.OnChange(func() { changes <- p.GetBool("debug.verbose") })
+
+	p.OverrideFor("debug.verbose", true, 50*time.Millisecond)
+	if !p.GetBool("debug.verbose") {
+		t.Fatal("debug.verbose not overridden")
+	}
+	if got := <-changes; !got {
+		t.Error("change event for the override saw debug.verbose = false")
+	}
+
+	select {
+	case got := <-changes:
+		if got {
+			t.Error("change event for the expiry saw debug.verbose = true")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no change event when the override expired")
+	}
+	if p.GetBool("debug.verbose") {
+		t.Error("debug.verbose still overridden after the ttl")
+	}
+}
+
+func TestParser_OverrideForStacking(t *testing.T) {
+	p := New()
+	p.Override("level", "base")
+	p.OverrideFor("level", "long", 80*time.Millisecond)
+	p.OverrideFor("level", "short", 20*time.Millisecond)
+
+	steps := []struct {
+		at   time.Duration
+		want string
+	}{
+		{0, "short"},
+		{50 * time.Millisecond, "long"},
+		{120 * time.Millisecond, "base"},
+	}
+	start := time.Now()
+	for _, step := range steps {
+		time.Sleep(time.Until(start.Add(step.at)))
+		if got := p.GetString("level"); got != step.want {
+			t.Errorf("level after %v = %q, want %q", step.at, got, step.want)
+		}
+	}
+
+	// an expiring override shadowed by a newer one is not revealed later
+	p.OverrideFor("mode", "a", 20*time.Millisecond)
+	p.OverrideFor("mode", "b", 60*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if got := p.Get("mode"); got != nil {
+		t.Errorf("mode = %v after both overrides expired, want nil", got)
+	}
+}
+
+func TestParser_Set(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "log:\n  level: info\n"})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	var seen []interface{}
+	p.Subscribe("log.level", func(_, new interface{}) { seen = append(seen, new) })
+
+	if err := p.OverrideFor("log.level", "trace", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	p.Set("log.level", "debug")
+	if got := p.GetString("log.level"); got != "debug" {
+		t.Errorf("log.level = %q, want the value set", got)
+	}
+	// the expiring override Set replaced must not revert the key
+	time.Sleep(50 * time.Millisecond)
+	if got := p.GetString("log.level"); got != "debug" {
+		t.Errorf("log.level = %q after the replaced override expired, want debug", got)
+	}
+
+	p.ClearOverride("log.level")
+	if got := p.GetString("log.level"); got != "info" {
+		t.Errorf("log.level = %q after ClearOverride, want the value of the file", got)
+	}
+	if want := []interface{}{"trace", "debug", "info"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("subscription saw %v, want %v", seen, want)
+	}
+}
@@ -2,30 +2,192 @@
 package viper
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/fsnotify/fsnotify"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Parser wraps a viper.Viper instance to isolate parsing logic from
 // application-specific types and behaviours.
 type Parser struct {
+	options
+
 	v       *viper.Viper
+	decoder *settingsDecoder
 	mu      sync.RWMutex
+	watchMu sync.Mutex
+	applyMu sync.Mutex
 	watches map[string]func()
+	codecs  map[string]viper.Codec
+
+	schemas schemaCache
+	refs    SecretCache
+
+	// exported holds the environment variables set from dotenv files
+	exportMu sync.Mutex
+	exported map[string]string
+
+	providersMu sync.RWMutex
+	providers   map[string]*lazyValue
+	fetches     fetchQueue
+	derived     derivedKeys
+	fallbacks   fallbackKeys
+	aliases     keyAliases
+	env         envBindings
+
+	selfConfig bool
+
+	closed bool
+
+	restartListeners []func(ChangeSet)
+	reloadListeners  []func(string, error)
+
+	heldListeners []func(ChangeRequest, error)
+	components    []component
+	sources       []namedSource
+
+	parent    *Parser
+	prefix    string
+	children  []*Parser
+	listeners []listener
+	// listenerID is the id of the last listener registered
+	listenerID uint64
+	own        map[string]interface{}
+	ownType    string
+	// layer holds the settings installed by compose, which take precedence
+	// over the environment
+	layer map[string]interface{}
+	// flags maps the keys bound to flags to whether the flag is set
+	flags     map[string]func() bool
+	files     []string
+	inline    map[string]inlineConfig
+	fsys      fs.FS
+	overrides map[string]*override
+
+	version uint64
+	memo    memoCache
+	reads   *readStats
+
+	pending   loadInfo
+	info      loadInfo
+	history   []ReloadRecord
+	snapshots []snapshot
+	journal   JournalSink
+}
+
+// options holds the settings of a parser set by its options and by
+// Require, which the children of the parser inherit
+type options struct {
+	sensitive   []string
+	readOnly    []string
+	configType  string
+	region      string
+	locale      string
+	timeLayouts []string
+	logger      *slog.Logger
+	tracer      trace.Tracer
+
+	durationUnits map[string]time.Duration
+	sizeUnits     map[string]ByteSize
+	pathKeys      []string
+
+	mergeStrategy  MergeStrategy
+	keyStrategies  []keyStrategy
+	conflictPolicy ConflictPolicy
+
+	limits               Limits
+	keyGuards            []keyGuard
+	duplicateKeys        DuplicateKeyPolicy
+	yaml11TaggedBooleans bool
+	yamlAliasBudget      int
+	useNumber            bool
+	keyCase              KeyCase
+	xmlAttributes        XMLAttributes
+
+	schema    interface{}
+	schemaErr error
+	validator StructValidator
+	required  []string
+
+	decodeHooks []mapstructure.DecodeHookFunc
+
+	preprocessors    []Preprocessor
+	extPreprocessors []extPreprocessor
+
+	resolvers map[string]Resolver
+	lazyRefs  bool
+
+	interpolation    bool
+	envInterpolation bool
+	includeDepth     int
+	profiles         []string
+	searchPaths      []string
+	dotenv           []string
+	dotenvExport     bool
+
+	migrations         map[int]Migration
+	migrationWriteBack bool
+	saveSensitive      bool
+
+	minReloadInterval time.Duration
+	watchDebounce     time.Duration
+
+	immutable []string
+	approver  Approver
+}
+
+// clone returns a copy of o sharing none of its slices and maps, so
+// options applied to the copy leave o as it was
+func (o options) clone() options {
+	o.sensitive = slices.Clone(o.sensitive)
+	o.readOnly = slices.Clone(o.readOnly)
+	o.timeLayouts = slices.Clone(o.timeLayouts)
+	o.durationUnits = maps.Clone(o.durationUnits)
+	o.sizeUnits = maps.Clone(o.sizeUnits)
+	o.pathKeys = slices.Clone(o.pathKeys)
+	o.keyStrategies = slices.Clone(o.keyStrategies)
+	o.keyGuards = slices.Clone(o.keyGuards)
+	o.required = slices.Clone(o.required)
+	o.decodeHooks = slices.Clone(o.decodeHooks)
+	o.preprocessors = slices.Clone(o.preprocessors)
+	o.extPreprocessors = slices.Clone(o.extPreprocessors)
+	o.resolvers = maps.Clone(o.resolvers)
+	o.profiles = slices.Clone(o.profiles)
+	o.searchPaths = slices.Clone(o.searchPaths)
+	o.dotenv = slices.Clone(o.dotenv)
+	o.migrations = maps.Clone(o.migrations)
+	o.immutable = slices.Clone(o.immutable)
+	return o
 }
 
 // Config represents a parsed configuration
 type Config struct {
-	// Raw contains the unmarshaled configuration as a map
+	// Raw contains the unmarshaled configuration as a map. It is a copy
+	// owned by the caller: changing it does not change the parser, and
+	// reloads do not change it.
 	Raw map[string]interface{}
 	// Viper provides direct access to the underlying viper instance
-	// for advanced use cases
+	// for advanced use cases. It is not guarded by the parser lock: use
+	// the getters, IsSet, AllKeys and AllSettings of the parser instead to
+	// read a configuration that may reload.
 	Viper *viper.Viper
+
+	// envMapper names the environment variables ToEnvStruct exports
+	envMapper EnvKeyMapper
 }
 
 // Option defines a function that can modify a Parser
@@ -38,29 +200,57 @@ func WithEnvPrefix(prefix string) Option {
 	}
 }
 
+// WithLogger sets the logger used to report warnings
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Parser) {
+		p.logger = logger
+	}
+}
+
 // WithConfigType explicitly sets the config type
 func WithConfigType(typ string) Option {
 	return func(p *Parser) {
+		p.configType = typ
 		p.v.SetConfigType(typ)
 	}
 }
 
 // New creates a new parser with default settings applied
 func New(opts ...Option) *Parser {
+	decoder := &settingsDecoder{}
 	p := &Parser{
-		v:       viper.New(),
+		options: options{
+			sensitive:   append([]string(nil), DefaultSensitiveKeys...),
+			timeLayouts: DefaultTimeLayouts,
+			logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+			tracer:      noopTracer,
+
+			yamlAliasBudget: DefaultYAMLAliasBudget,
+			resolvers: map[string]Resolver{
+				"env":  EnvResolver(),
+				"file": FileResolver(),
+			},
+		},
+		v:       viper.NewWithOptions(viper.WithDecoderRegistry(decoder)),
+		decoder: decoder,
 		watches: make(map[string]func()),
+		refs:    &refCache{},
 	}
+	p.codecs = p.defaultCodecs()
+	p.setExtPreprocessor(".gz", p.gunzip)
 
 	// Apply default settings
 	p.v.SetEnvPrefix("nexen")
-	p.v.AutomaticEnv()
 	p.v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
 	// Apply custom options
 	for _, opt := range opts {
 		opt(p)
 	}
+	// mapped variable names are bound key by key instead
+	if p.env.mapper == nil {
+		p.v.AutomaticEnv()
+	}
 
 	return p
 }
@@ -68,34 +258,338 @@ func New(opts ...Option) *Parser {
 // Parse reads the configuration from the specified file and unmarshals
 // it into a Config struct. The file type is determined from the extension.
 func (p *Parser) Parse(configFile string) (*Config, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	return p.ParseAll(configFile)
+}
 
-	// Set config file and type
-	p.v.SetConfigFile(configFile)
-	if ext := filepath.Ext(configFile); ext != "" {
-		p.v.SetConfigType(ext[1:]) // Remove the leading dot
+// ParseContext parses configFile like Parse does, passing ctx to the
+// sources, resolvers and schema downloads it involves, so their remote
+// fetches honor its deadline and cancellation. A load failing because ctx
+// is done keeps the previous config.
+func (p *Parser) ParseContext(ctx context.Context, configFile string) (*Config, error) {
+	return p.parseAll(ctx, configFile)
+}
+
+// ParseAll reads several configuration files in order and deep-merges them
+// into a single Config, later files overriding earlier ones, as in a base
+// file followed by environment-specific ones. Each file is read with the
+// files it extends and the overlays and references are applied to the
+// merged result. Watching any of the files reloads them all.
+func (p *Parser) ParseAll(configFiles ...string) (*Config, error) {
+	return p.parseAll(context.Background(), configFiles...)
+}
+
+// parseAll loads the config files, with ctx passed to the remote fetches
+func (p *Parser) parseAll(ctx context.Context, configFiles ...string) (*Config, error) {
+	// a reload or a rollback installing its staged config once the
+	// components applied it would replace the config loaded meanwhile
+	p.applyMu.Lock()
+	defer p.applyMu.Unlock()
+	return p.parse(ctx, configFiles...)
+}
+
+// parse loads the config files as parseAll does. Callers hold p.applyMu.
+func (p *Parser) parse(ctx context.Context, configFiles ...string) (*Config, error) {
+	if len(configFiles) == 0 {
+		return nil, fmt.Errorf("no config file to parse")
 	}
+	name := strings.Join(configFiles, ", ")
+	ctx, span := p.startSpan(ctx, "viper.Parse", filesAttr.StringSlice(configFiles))
 
-	// Read configuration
-	if err := p.v.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("error reading config file %q: %w", configFile, err)
+	p.mu.Lock()
+	if err := p.checkOpen(); err != nil {
+		p.mu.Unlock()
+		endSpan(span, err)
+		return nil, err
+	}
+	prev := p.own
+	err := p.load(ctx, configFiles...)
+	p.recordLoad(name, prev, err)
+	endSpan(span, err)
+	if err != nil {
+		p.mu.Unlock()
+		return nil, err
 	}
 
-	// Get all settings as a map
-	settings := p.v.AllSettings()
+	// Get all settings as a map, sharing nothing with the viper instance
+	settings := deepCopy(p.settings()).(map[string]interface{})
+	p.mu.Unlock()
+	p.changed()
 
 	return &Config{
-		Raw:   settings,
-		Viper: p.v,
+		Raw:       settings,
+		Viper:     p.v,
+		envMapper: p.env.mapper,
 	}, nil
 }
 
+// ParseReader reads the configuration of the given type from r, as from
+// stdin or an HTTP response, and unmarshals it into a Config the way Parse
+// does. Relative paths it holds, like the files it extends, are resolved
+// against the working directory.
+func (p *Parser) ParseReader(r io.Reader, configType string) (*Config, error) {
+	b, err := p.readAllLimited(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+	return p.parseInline(readerSource, b, configType)
+}
+
+// ParseBytes parses the configuration of the given type held by b, as an
+// embedded string, like ParseReader does
+func (p *Parser) ParseBytes(b []byte, configType string) (*Config, error) {
+	return p.parseInline(bytesSource, b, configType)
+}
+
+// Names of the in-memory configs, in errors and provenance
+const (
+	readerSource = "<reader>"
+	bytesSource  = "<bytes>"
+)
+
+// inlineConfig is a config parsed from memory rather than from a file
+type inlineConfig struct {
+	data []byte
+	typ  string
+}
+
+// parseInline parses an in-memory config. It is kept so reloads triggered
+// by watched sources read it again.
+func (p *Parser) parseInline(name string, b []byte, configType string) (*Config, error) {
+	p.applyMu.Lock()
+	defer p.applyMu.Unlock()
+
+	p.mu.Lock()
+	prevInline := p.inline
+	p.inline = map[string]inlineConfig{name: {data: b, typ: configType}}
+	p.mu.Unlock()
+
+	cfg, err := p.parse(context.Background(), name)
+	if err != nil {
+		p.mu.Lock()
+		p.inline = prevInline
+		p.mu.Unlock()
+	}
+	return cfg, err
+}
+
+// load reads the config files and the files they extend, applies the
+// selected overlays and installs the result as the config layer of the
+// underlying viper instance
+func (p *Parser) load(ctx context.Context, configFiles ...string) error {
+	settings, typ, err := p.read(ctx, configFiles...)
+	if err != nil {
+		return err
+	}
+	if err := p.install(configFiles[len(configFiles)-1], settings, typ); err != nil {
+		return err
+	}
+	p.files = append([]string(nil), configFiles...)
+	p.writeMigrated()
+	return nil
+}
+
+// read returns the settings of the config files merged in order, with
+// their parents merged in, overlays applied and references resolved. The
+// type returned is the one of the last file.
+func (p *Parser) read(ctx context.Context, configFiles ...string) (map[string]interface{}, string, error) {
+	ctx = p.withSizeLimit(ctx)
+	p.pending = newLoadInfo(p.readOnly)
+
+	settings, err := p.readDotenv()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Read configuration along with the files it extends
+	var typ string
+	for _, configFile := range configFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		typ = p.typeOf(configFile)
+		_, span := p.startSpan(ctx, "viper.ReadFile", fileAttr.String(configFile))
+		fileSettings, err := p.readChain(configFile, typ, nil, 0)
+		endSpan(span, err)
+		if err != nil {
+			return nil, "", fmt.Errorf("error reading config file %q: %w", configFile, err)
+		}
+		settings = p.merge(settings, fileSettings)
+		if settings, err = p.readProfiles(settings, configFile, typ); err != nil {
+			return nil, "", err
+		}
+	}
+	configFile := strings.Join(configFiles, ", ")
+
+	settings, err = p.readSources(ctx, settings)
+	if err != nil {
+		return nil, "", err
+	}
+	settings, err = p.applyOverlays(settings)
+	if err != nil {
+		return nil, "", fmt.Errorf("error applying overlays to %q: %w", configFile, err)
+	}
+	settings = p.applyAliases(settings)
+	if err := p.checkConflicts(); err != nil {
+		return nil, "", fmt.Errorf("error merging %q: %w", configFile, err)
+	}
+
+	if p.envInterpolation {
+		settings = p.expandEnv(settings)
+	}
+	p.refs.Reset()
+	p.resetProviders()
+	p.pending.markRefs(settings)
+	// without references, resolving them would only copy the settings
+	if !p.lazyRefs && len(p.pending.refs) > 0 {
+		resolved, err := p.resolveRefs(ctx, settings, "")
+		if err != nil {
+			return nil, "", fmt.Errorf("error resolving references in %q: %w", configFile, err)
+		}
+		settings = resolved.(map[string]interface{})
+	}
+	p.pending.secrets = p.secretValues(settings, "", nil)
+	if p.interpolation {
+		if settings, err = p.interpolate(settings); err != nil {
+			return nil, "", fmt.Errorf("error interpolating %q: %w", configFile, err)
+		}
+	}
+	vctx, span := p.startSpan(ctx, "viper.Validate", filesAttr.StringSlice(configFiles))
+	err = p.validate(vctx, settings)
+	endSpan(span, err)
+	if err != nil {
+		return nil, "", fmt.Errorf("error validating %q: %w", configFile, err)
+	}
+	return settings, typ, nil
+}
+
+// validate checks settings against the schema, the required keys and the
+// guards, and reads the settings of the parser they hold
+func (p *Parser) validate(ctx context.Context, settings map[string]interface{}) error {
+	if err := p.validateSchema(ctx, settings); err != nil {
+		return err
+	}
+	if err := p.checkRequired(settings); err != nil {
+		return err
+	}
+	if err := p.checkGuards(settings); err != nil {
+		return err
+	}
+	var err error
+	p.pending.self, err = p.readSelfConfig(settings)
+	return err
+}
+
+// install makes settings the parser's own config layer
+func (p *Parser) install(configFile string, settings map[string]interface{}, typ string) error {
+	if err := p.checkParentLocks(settings); err != nil {
+		return fmt.Errorf("error loading config file %q: %w", configFile, err)
+	}
+	prev := p.saveState()
+	// Keep viper aware of the file so it can be watched
+	p.v.SetConfigFile(configFile)
+	p.own, p.ownType = settings, typ
+	p.pending.lockValues(settings)
+	p.info = p.pending
+	if err := p.compose(); err != nil {
+		// keep serving the previous config rather than a partial one
+		if rerr := p.restoreState(prev); rerr != nil {
+			p.logger.Error("cannot restore the previous config", "error", rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// typeOf returns the config type of the file, ignoring the extensions
+// handled by preprocessors and falling back to the one set with
+// WithConfigType when the file has no extension
+func (p *Parser) typeOf(configFile string) string {
+	if inline, ok := p.inline[configFile]; ok && inline.typ != "" {
+		return inline.typ
+	}
+	if ext := filepath.Ext(p.unwrappedName(configFile)); ext != "" {
+		return ext[1:] // Remove the leading dot
+	}
+	return p.configType
+}
+
+// setConfig replaces the config layer of the underlying viper instance,
+// leaving env bindings, defaults and overrides untouched
+func (p *Parser) setConfig(settings map[string]interface{}, typ string) error {
+	// any type viper supports, the decoder ignoring it
+	p.v.SetConfigType("json")
+	p.decoder.settings = settings
+	err := p.v.ReadConfig(strings.NewReader(""))
+	p.decoder.settings = nil
+	if typ != "" {
+		p.v.SetConfigType(typ)
+	}
+	return err
+}
+
+// settingsDecoder is the decoder of the viper instance of a parser,
+// handing it the settings of setConfig to install in a single pass, where
+// MergeConfigMap compares each key with every key merged before it
+type settingsDecoder struct {
+	settings map[string]interface{}
+}
+
+func (d *settingsDecoder) Decoder(string) (viper.Decoder, error) { return d, nil }
+
+func (d *settingsDecoder) Decode(_ []byte, v map[string]interface{}) error {
+	for k, val := range d.settings {
+		v[k] = val
+	}
+	return nil
+}
+
 // Watch starts watching the config file for changes.
 // The callback will be invoked whenever the file changes.
+//
+// Every watched file has its own watcher, stopped with StopWatch. A change
+// to one of the files loaded by the last Parse or ParseAll reloads all of
+// them, so watching several files keeps the config they make up together.
+// Watching a file that was not loaded layers it on top of them.
 func (p *Parser) Watch(configFile string, callback func()) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	return p.WatchContext(context.Background(), configFile, callback)
+}
+
+// WatchContext watches the config file like Watch does until ctx is done,
+// the watch then stopping as with StopWatch, so it ends with the service
+// owning ctx. It fails with the error of ctx when ctx is already done.
+func (p *Parser) WatchContext(ctx context.Context, configFile string, callback func()) error {
+	return p.watch(ctx, configFile, func(ChangeSet, error) {
+		if callback != nil {
+			callback()
+		}
+	})
+}
+
+// WatchChanges watches the config file for changes like Watch does, calling
+// callback with the keys each successful reload added, removed or modified
+// in the effective config. Reloads failing or changing nothing are not
+// reported. It replaces any watch of the same file.
+func (p *Parser) WatchChanges(configFile string, callback func(ChangeSet)) error {
+	return p.watch(context.Background(), configFile, func(changes ChangeSet, err error) {
+		if err == nil && !changes.Empty() {
+			callback(changes)
+		}
+	})
+}
+
+// watch starts the watcher of configFile, stopped when ctx is done. notify
+// is called after every reload attempt.
+func (p *Parser) watch(ctx context.Context, configFile string, notify func(ChangeSet, error)) error {
+	// watches have their own lock: stopping one waits for a reload in
+	// progress, which needs p.mu
+	p.watchMu.Lock()
+	defer p.watchMu.Unlock()
+	if err := p.openForWatch(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	// Remove existing watch if any
 	if stop, exists := p.watches[configFile]; exists {
@@ -103,26 +597,129 @@ func (p *Parser) Watch(configFile string, callback func()) error {
 		delete(p.watches, configFile)
 	}
 
+	apply := p.reloader(configFile, func() []string { return p.watchedSet(configFile) }, notify)
+
 	// Create new watcher
-	p.v.WatchConfig()
+	limiter := &throttle{interval: p.reloadInterval}
+	settle := &debouncer{wait: p.watchDebounce}
+	stopWatcher, err := watchFile(configFile, p.logger, func() { settle.run(func() { limiter.run(apply) }) })
+	if err != nil {
+		return fmt.Errorf("error watching config file %q: %w", configFile, err)
+	}
 
-	// Store callback
-	p.watches[configFile] = callback
+	// Store the function stopping the watch
+	p.watches[configFile] = p.stopWhenDone(ctx, configFile, func() {
+		stopWatcher()
+		settle.stop()
+		limiter.stop()
+	})
+	return nil
+}
 
-	// Set callback
-	p.v.OnConfigChange(func(e fsnotify.Event) {
-		if callback != nil {
-			callback()
+// stopWhenDone returns stop, the function stopping the watch of name, made
+// to run and remove the watch when ctx is done, unless the watch was
+// stopped or replaced before. Callers hold p.watchMu.
+func (p *Parser) stopWhenDone(ctx context.Context, name string, stop func()) func() {
+	if ctx.Done() == nil {
+		return stop
+	}
+	stopped := make(chan struct{})
+	var once sync.Once
+	stopOnce := func() {
+		once.Do(func() {
+			stop()
+			close(stopped)
+		})
+	}
+	go func() {
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			p.watchMu.Lock()
+			select {
+			case <-stopped:
+				// replaced meanwhile, the entry is another watch
+			default:
+				stopOnce()
+				delete(p.watches, name)
+			}
+			p.watchMu.Unlock()
 		}
-	})
+	}()
+	return stopOnce
+}
 
-	return nil
+// reloader returns the function reloading files when source changes and
+// calling notify with the outcome
+func (p *Parser) reloader(source string, files func() []string, notify func(ChangeSet, error)) func() {
+	return func() {
+		// reloads of different sources and the component pipelines they
+		// run must not interleave
+		p.applyMu.Lock()
+		defer p.applyMu.Unlock()
+
+		// run the files through the full pipeline again
+		p.mu.Lock()
+		if p.closed {
+			// a change seen while the watch was being stopped
+			p.mu.Unlock()
+			return
+		}
+		reloaded := files()
+		ctx, span := p.startSpan(context.Background(), "viper.Reload", sourceAttr.String(source), filesAttr.StringSlice(reloaded))
+		r, err := p.reload(ctx, source, reloaded)
+		p.mu.Unlock()
+
+		// the approver may take its time, ask it without holding the lock
+		if err == nil {
+			err = p.approve(ctx, r.req)
+		}
+
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			endSpan(span, ErrClosed)
+			return
+		}
+		prev, before, state := p.own, p.settings(), p.saveState()
+		var (
+			changes ChangeSet
+			next    loadState
+			staged  bool
+		)
+		if err == nil {
+			err = p.installReload(r)
+		}
+		if err == nil {
+			changes, next = p.diff(before, p.settings()), p.saveState()
+			staged, err = p.stage(state, changes)
+		}
+		p.mu.Unlock()
+
+		if err == nil && staged {
+			err = p.applyStaged(state, next, changes)
+		}
+		p.mu.Lock()
+		p.recordLoad(source, prev, err)
+		p.mu.Unlock()
+		if err != nil {
+			p.logger.Error("cannot reload config", "source", source, "error", err)
+			p.changeHeld(err)
+			p.reloadFailed(source, err)
+		} else {
+			p.restartRequired(r.held)
+			p.changed()
+		}
+		endSpan(span, err)
+
+		notify(changes, err)
+	}
 }
 
-// StopWatch stops watching the specified config file
+// StopWatch stops watching the specified config file or source
 func (p *Parser) StopWatch(configFile string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	p.watchMu.Lock()
+	defer p.watchMu.Unlock()
 
 	if stop, exists := p.watches[configFile]; exists {
 		stop()
@@ -132,44 +729,86 @@ func (p *Parser) StopWatch(configFile string) {
 
 // Get retrieves a value from the configuration using a dot-notation path
 func (p *Parser) Get(path string) interface{} {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.v.Get(path)
+	return p.value(path)
+}
+
+// value returns the value stored at path, see get. It takes the read lock.
+func (p *Parser) value(path string) (v interface{}) {
+	p.reading(func() { v = p.get(path) })
+	return v
+}
+
+// IsSet reports whether path has a value, whichever layer sets it:
+// whenever Get returns something else than nil
+func (p *Parser) IsSet(path string) bool {
+	return p.value(path) != nil
+}
+
+// AllKeys returns the dot-notation paths of every value of the
+// configuration, in lexical order
+func (p *Parser) AllKeys() []string {
+	var settings map[string]interface{}
+	p.reading(func() { settings = flatten(p.effective()) })
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AllSettings returns the configuration as a nested map, every value read
+// the way Get reads it. The map is a copy the caller may modify.
+func (p *Parser) AllSettings() (settings map[string]interface{}) {
+	p.reading(func() { settings = p.effective() })
+	return settings
 }
 
 // GetString retrieves a string value from the configuration
 func (p *Parser) GetString(path string) string {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.v.GetString(path)
+	return cast.ToString(p.value(path))
 }
 
 // GetInt retrieves an integer value from the configuration
 func (p *Parser) GetInt(path string) int {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.v.GetInt(path)
+	return cast.ToInt(p.value(path))
 }
 
 // GetBool retrieves a boolean value from the configuration
 func (p *Parser) GetBool(path string) bool {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.v.GetBool(path)
+	return cast.ToBool(p.value(path))
 }
 
 // GetStringMap retrieves a map of strings from the configuration
 func (p *Parser) GetStringMap(path string) map[string]interface{} {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.v.GetStringMap(path)
+	return cast.ToStringMap(p.value(path))
 }
 
 // GetStringSlice retrieves a slice of strings from the configuration
 func (p *Parser) GetStringSlice(path string) []string {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.v.GetStringSlice(path)
+	return cast.ToStringSlice(p.value(path))
+}
+
+// GetFloat64 retrieves a float value from the configuration
+func (p *Parser) GetFloat64(path string) float64 {
+	return cast.ToFloat64(p.value(path))
+}
+
+// GetIntSlice retrieves a slice of integers from the configuration
+func (p *Parser) GetIntSlice(path string) []int {
+	return cast.ToIntSlice(p.value(path))
+}
+
+// GetStringMapString retrieves a map of strings to strings from the
+// configuration
+func (p *Parser) GetStringMapString(path string) map[string]string {
+	return cast.ToStringMapString(p.value(path))
+}
+
+// GetStringMapStringSlice retrieves a map of strings to slices of strings
+// from the configuration
+func (p *Parser) GetStringMapStringSlice(path string) map[string][]string {
+	return cast.ToStringMapStringSlice(p.value(path))
 }
 
 // GetEnvPrefix returns the current environment variable prefix
@@ -1,9 +1,14 @@
 package viper
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -112,6 +117,229 @@ nested:
 	}
 }
 
+func TestParser_ParseAll(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml":     "name: app\ndb:\n  host: localhost\n  pool: 4\nlog: info\n",
+		"prod.json":     `{"db": {"host": "db.prod"}, "log": "warn"}`,
+		"override.yaml": "log: error\nregions:\n  eu:\n    db:\n      host: db.eu\n",
+	})
+	files := []string{
+		filepath.Join(dir, "base.yaml"),
+		filepath.Join(dir, "prod.json"),
+		filepath.Join(dir, "override.yaml"),
+	}
+
+	p := New(WithRegion("eu"))
+	cfg, err := p.ParseAll(files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"name", "app"},
+		{"db.host", "db.eu"},
+		{"db.pool", 4},
+		{"log", "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := p.Get(tt.path); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Get(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+	if _, ok := cfg.Raw["regions"]; ok {
+		t.Error("Raw still holds the regions section")
+	}
+
+	if _, err := p.ParseAll(); err == nil {
+		t.Error("ParseAll() without files succeeded")
+	}
+	if _, err := p.ParseAll(files[0], filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("ParseAll() with a missing file succeeded")
+	}
+}
+
+func TestParser_ParseReader(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"base.yaml": "server:\n  host: localhost\n  port: 80\n"})
+	base := filepath.Join(dir, "base.yaml")
+
+	tests := []struct {
+		name    string
+		parse   func(p *Parser) (*Config, error)
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "reader",
+			parse: func(p *Parser) (*Config, error) {
+				return p.ParseReader(strings.NewReader(`{"server": {"port": 8080}}`), "json")
+			},
+			want: map[string]interface{}{"server.port": 8080, "server.host": nil},
+		},
+		{
+			name: "bytes extending a file",
+			parse: func(p *Parser) (*Config, error) {
+				return p.ParseBytes([]byte("extends: "+base+"\nserver:\n  port: 8080\n"), "yaml")
+			},
+			want: map[string]interface{}{"server.port": 8080, "server.host": "localhost"},
+		},
+		{
+			name: "no type",
+			parse: func(p *Parser) (*Config, error) {
+				return p.ParseBytes([]byte("port = 1"), "")
+			},
+			wantErr: bytesSource,
+		},
+		{
+			name: "invalid",
+			parse: func(p *Parser) (*Config, error) {
+				return p.ParseBytes([]byte("{"), "json")
+			},
+			wantErr: bytesSource,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			cfg, err := tt.parse(p)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Raw == nil {
+				t.Error("Raw is nil")
+			}
+			for key, want := range tt.want {
+				if got := p.Get(key); fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("%s = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ParseRawCopy(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": "db:\n  host: a\n  replicas: [r1, r2]\n  pools:\n    - name: main\n",
+	})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New()
+	cfg, err := p.Parse(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := cfg.Raw["db"].(map[string]interface{})
+	db["host"] = "changed"
+	db["replicas"].([]interface{})[0] = "changed"
+	db["pools"].([]interface{})[0].(map[string]interface{})["name"] = "changed"
+	if got := p.GetString("db.host"); got != "a" {
+		t.Errorf("db.host = %q after changing Raw, want a", got)
+	}
+	if got := p.GetStringSlice("db.replicas"); got[0] != "r1" {
+		t.Errorf("db.replicas = %v after changing Raw, want [r1 r2]", got)
+	}
+	pools := p.Get("db.pools").([]interface{})
+	if got := pools[0].(map[string]interface{})["name"]; got != "main" {
+		t.Errorf("db.pools[0].name = %v after changing Raw, want main", got)
+	}
+
+	replaceFile(t, configFile, "db:\n  host: b\n")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if got := db["replicas"].([]interface{})[1]; got != "r2" {
+		t.Errorf("Raw db.replicas[1] = %v after a reload, want r2", got)
+	}
+}
+
+func TestParser_ParseContext(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 8080\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	var deadline bool
+	remote := SourceFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		_, deadline = ctx.Deadline()
+		return nil, ctx.Err()
+	})
+	p := New(WithSource("remote", remote))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := p.ParseContext(ctx, configFile); err != nil {
+		t.Fatal(err)
+	}
+	if !deadline {
+		t.Error("the source did not receive the context of ParseContext")
+	}
+
+	replaceFile(t, configFile, "port: 9090\n")
+	cancel()
+	if _, err := p.ParseContext(ctx, configFile); !errors.Is(err, context.Canceled) {
+		t.Errorf("ParseContext() = %v with a cancelled context, want context.Canceled", err)
+	}
+	if got := p.GetInt("port"); got != 8080 {
+		t.Errorf("port = %d, want the previous config kept", got)
+	}
+}
+
+func TestParser_WatchContext(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 8080\n", "other.yaml": "log: info\n"})
+	configFile, other := filepath.Join(dir, "config.yaml"), filepath.Join(dir, "other.yaml")
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	watching := func(name string) bool {
+		p.watchMu.Lock()
+		defer p.watchMu.Unlock()
+		_, ok := p.watches[name]
+		return ok
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := p.WatchContext(ctx, configFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WatchContext(ctx, other, nil); err != nil {
+		t.Fatal(err)
+	}
+	// replaced by a watch the context does not own
+	if err := p.Watch(other, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch(other)
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for watching(configFile) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if watching(configFile) {
+		t.Error("the watch is still running after its context was cancelled")
+	}
+	if !watching(other) {
+		t.Error("the watch replacing one of the context was stopped with it")
+	}
+	replaceFile(t, configFile, "port: 9090\n")
+	time.Sleep(100 * time.Millisecond)
+	if got := p.GetInt("port"); got != 8080 {
+		t.Errorf("port = %d, want the change ignored once the watch stopped", got)
+	}
+
+	if err := p.WatchContext(ctx, configFile, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("WatchContext() = %v with a cancelled context, want context.Canceled", err)
+	}
+}
+
 func TestParser_Watch(t *testing.T) {
 	// Create temporary config file
 	tmpDir := t.TempDir()
@@ -157,6 +385,117 @@ func TestParser_Watch(t *testing.T) {
 	p.StopWatch(configFile)
 }
 
+func TestParser_WatchMultipleFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml":     "name: base\nlog: info\ndb:\n  host: localhost\n",
+		"override.yaml": "log: warn\n",
+	})
+	base := filepath.Join(dir, "base.yaml")
+	override := filepath.Join(dir, "override.yaml")
+
+	p := New()
+	if _, err := p.ParseAll(base, override); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan string, 10)
+	for _, f := range []string{base, override} {
+		f := f
+		if err := p.Watch(f, func() {
+			select {
+			case changes <- f:
+			default:
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer p.StopWatch(override)
+
+	waitFor := func(t *testing.T, path, want string) {
+		t.Helper()
+		deadline := time.After(time.Second)
+		for p.GetString(path) != want {
+			select {
+			case <-changes:
+			case <-deadline:
+				t.Fatalf("%s = %q, want %q", path, p.GetString(path), want)
+			}
+		}
+	}
+
+	if err := os.WriteFile(base, []byte("name: base\nlog: info\ndb:\n  host: db.local\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "db.host", "db.local")
+	if got := p.GetString("log"); got != "warn" {
+		t.Errorf("log = %q after a base change, want the override kept", got)
+	}
+
+	if err := os.WriteFile(override, []byte("log: error\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "log", "error")
+	if got := p.GetString("db.host"); got != "db.local" {
+		t.Errorf("db.host = %q after an override change, want the base kept", got)
+	}
+
+	// a stopped watch no longer reloads, the other one keeps working
+	p.StopWatch(base)
+	if err := os.WriteFile(base, []byte("name: stopped\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := p.GetString("name"); got != "base" {
+		t.Errorf("name = %q, want the stopped watch to ignore changes", got)
+	}
+	if err := os.WriteFile(override, []byte("log: debug\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "log", "debug")
+}
+
+func TestParser_WatchChanges(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "server:\n  port: 8080\n  host: a.local\ndb:\n  password: old\nlegacy: true\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan ChangeSet, 10)
+	if err := p.WatchChanges(configFile, func(cs ChangeSet) { changes <- cs }); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch(configFile)
+
+	replaceFile(t, configFile, "server:\n  port: 9090\n  host: a.local\n  tls: true\ndb:\n  password: new\n")
+	var cs ChangeSet
+	select {
+	case cs = <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("no change set delivered")
+	}
+
+	want := ChangeSet{
+		Added:    map[string]Change{"server.tls": {New: true}},
+		Removed:  map[string]Change{"legacy": {Old: true}},
+		Modified: map[string]Change{"server.port": {Old: 8080, New: 9090}, "db.password": {Sensitive: true}},
+	}
+	if !reflect.DeepEqual(cs, want) {
+		t.Errorf("ChangeSet = %+v, want %+v", cs, want)
+	}
+
+	// reloads that change nothing or fail are not reported
+	replaceFile(t, configFile, "server:\n  port: 9090\n  host: a.local\n  tls: true\ndb:\n  password: new\n")
+	replaceFile(t, configFile, "server: [")
+	select {
+	case cs := <-changes:
+		t.Errorf("unexpected change set %+v", cs)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
 func TestParser_GetMethods(t *testing.T) {
 	content := []byte(`{
 		"string": "value",
@@ -166,6 +505,12 @@ func TestParser_GetMethods(t *testing.T) {
 		"stringMap": {
 			"key1": "value1",
 			"key2": "value2"
+		},
+		"float": 0.25,
+		"intSlice": [1, 2, 3],
+		"stringMapSlice": {
+			"key1": ["a", "b"],
+			"key2": "c"
 		}
 	}`)
 
@@ -211,6 +556,30 @@ func TestParser_GetMethods(t *testing.T) {
 			want:     []string{"a", "b", "c"},
 			typeName: "[]string",
 		},
+		{
+			name:     "GetFloat64",
+			getFunc:  func() interface{} { return p.GetFloat64("float") },
+			want:     0.25,
+			typeName: "float64",
+		},
+		{
+			name:     "GetIntSlice",
+			getFunc:  func() interface{} { return p.GetIntSlice("intSlice") },
+			want:     []int{1, 2, 3},
+			typeName: "[]int",
+		},
+		{
+			name:     "GetStringMapString",
+			getFunc:  func() interface{} { return p.GetStringMapString("stringMap") },
+			want:     map[string]string{"key1": "value1", "key2": "value2"},
+			typeName: "map[string]string",
+		},
+		{
+			name:     "GetStringMapStringSlice",
+			getFunc:  func() interface{} { return p.GetStringMapStringSlice("stringMapSlice") },
+			want:     map[string][]string{"key1": {"a", "b"}, "key2": {"c"}},
+			typeName: "map[string][]string",
+		},
 	}
 
 	for _, tt := range tests {
@@ -235,6 +604,41 @@ func TestParser_GetMethods(t *testing.T) {
 	})
 }
 
+func TestParser_AllSettings(t *testing.T) {
+	t.Setenv("ALLKEYSTEST_DB_PORT", "6543")
+	t.Setenv("ALLKEYSTEST_LOG", "debug")
+	p := New(WithEnvPrefix("allkeystest"), WithDefaults(map[string]interface{}{"log": "info"}))
+	if _, err := p.ParseBytes([]byte("db:\n  host: localhost\n  port: 5432\nname: api\n"), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Override("db.host", "db.local"); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]bool{"db.host": true, "DB.Port": true, "db": true, "log": true, "db.user": false, "missing": false} {
+		if got := p.IsSet(path); got != want {
+			t.Errorf("IsSet(%q) = %v, want %v", path, got, want)
+		}
+	}
+	if got, want := p.AllKeys(), []string{"db.host", "db.port", "log", "name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AllKeys() = %v, want %v", got, want)
+	}
+
+	settings := p.AllSettings()
+	want := map[string]interface{}{
+		"db":   map[string]interface{}{"host": "db.local", "port": 5432},
+		"log":  "debug",
+		"name": "api",
+	}
+	if !jsonEqual(settings, want) {
+		t.Errorf("AllSettings() = %v, want %v", settings, want)
+	}
+	settings["name"] = "changed"
+	if got := p.GetString("name"); got != "api" {
+		t.Errorf("name = %q after modifying AllSettings(), want it untouched", got)
+	}
+}
+
 // jsonEqual compares two values by marshalling them to JSON
 func jsonEqual(a, b interface{}) bool {
 	aJSON, err := json.Marshal(a)
@@ -247,3 +651,207 @@ func jsonEqual(a, b interface{}) bool {
 	}
 	return string(aJSON) == string(bJSON)
 }
+
+// benchSizes are the numbers of services of the benchmark configs, of 8
+// keys each: a large service config, and a generated one
+var benchSizes = []int{50, 1250}
+
+// benchConfig writes a config of services spread over nested sections
+func benchConfig(b *testing.B, configType string, services int) string {
+	b.Helper()
+	sections := make(map[string]interface{})
+	for i := 0; i < services; i++ {
+		sections[fmt.Sprintf("service%d", i)] = map[string]interface{}{
+			"host":    fmt.Sprintf("10.0.%d.%d", i/256, i%256),
+			"port":    8000 + i,
+			"enabled": i%2 == 0,
+			"tags":    []string{"a", "b", "c"},
+			"limits":  map[string]interface{}{"rps": 100 * i, "burst": 10 * i},
+		}
+	}
+	settings := map[string]interface{}{"services": sections, "name": "bench"}
+
+	content, err := New().codecs[configType].Encode(settings)
+	if err != nil {
+		b.Fatal(err)
+	}
+	configFile := filepath.Join(b.TempDir(), "config."+configType)
+	if err := os.WriteFile(configFile, content, 0644); err != nil {
+		b.Fatal(err)
+	}
+	return configFile
+}
+
+// benchEach runs bench for each config size
+func benchEach(b *testing.B, bench func(b *testing.B, services int)) {
+	for _, services := range benchSizes {
+		b.Run(fmt.Sprintf("%dkeys", services*8), func(b *testing.B) {
+			bench(b, services)
+		})
+	}
+}
+
+func BenchmarkParser_Parse(b *testing.B) {
+	for _, configType := range []string{"json", "yaml", "toml"} {
+		b.Run(configType, func(b *testing.B) {
+			benchEach(b, func(b *testing.B, services int) {
+				configFile := benchConfig(b, configType, services)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := New().Parse(configFile); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkParser_Reload measures the path taken by Watch on file changes
+func BenchmarkParser_Reload(b *testing.B) {
+	benchEach(b, func(b *testing.B, services int) {
+		configFile := benchConfig(b, "yaml", services)
+		p := New()
+		if _, err := p.Parse(configFile); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p.mu.Lock()
+			err := p.load(context.Background(), configFile)
+			p.mu.Unlock()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkParser_Get(b *testing.B) {
+	benchEach(b, func(b *testing.B, services int) {
+		configFile := benchConfig(b, "json", services)
+		p := New()
+		if _, err := p.Parse(configFile); err != nil {
+			b.Fatal(err)
+		}
+
+		benchmarks := []struct {
+			name string
+			get  func()
+		}{
+			{"Get", func() { p.Get("services.service42.limits.rps") }},
+			{"GetString", func() { p.GetString("services.service42.host") }},
+			{"GetInt", func() { p.GetInt("services.service42.port") }},
+			{"GetBool", func() { p.GetBool("services.service42.enabled") }},
+			{"GetStringSlice", func() { p.GetStringSlice("services.service42.tags") }},
+			{"GetStringMap", func() { p.GetStringMap("services.service42") }},
+		}
+		for _, bm := range benchmarks {
+			b.Run(bm.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					bm.get()
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkParser_AllSettings(b *testing.B) {
+	benchEach(b, func(b *testing.B, services int) {
+		configFile := benchConfig(b, "json", services)
+		p := New()
+		if _, err := p.Parse(configFile); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p.AllSettings()
+		}
+	})
+}
+
+type benchService struct {
+	Host    string
+	Port    int
+	Enabled bool
+	Tags    []string
+	Limits  map[string]int
+}
+
+func BenchmarkConfig_Unmarshal(b *testing.B) {
+	benchEach(b, func(b *testing.B, services int) {
+		configFile := benchConfig(b, "json", services)
+		cfg, err := New().Parse(configFile)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var target struct {
+				Name     string
+				Services map[string]benchService
+			}
+			if err := cfg.Viper.Unmarshal(&target); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkParser_Unmarshal(b *testing.B) {
+	benchEach(b, func(b *testing.B, services int) {
+		configFile := benchConfig(b, "json", services)
+		p := New()
+		if _, err := p.Parse(configFile); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var target struct {
+				Name     string
+				Services map[string]benchService
+			}
+			if err := p.Unmarshal(&target); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkParser_ParseWide parses configs holding tens of thousands of
+// keys, as generated feature flag or routing tables do
+func BenchmarkParser_ParseWide(b *testing.B) {
+	for _, n := range []int{10000, 40000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			flags := make(map[string]interface{}, n)
+			for i := 0; i < n/2; i++ {
+				flags[fmt.Sprintf("flag%d", i)] = i%2 == 0
+			}
+			settings := map[string]interface{}{"flags": flags}
+			for i := 0; i < n/2; i++ {
+				settings[fmt.Sprintf("route%d", i)] = fmt.Sprintf("/r/%d", i)
+			}
+			content, err := New().codecs["json"].Encode(settings)
+			if err != nil {
+				b.Fatal(err)
+			}
+			configFile := filepath.Join(b.TempDir(), "config.json")
+			if err := os.WriteFile(configFile, content, 0644); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := New().Parse(configFile); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
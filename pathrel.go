@@ -0,0 +1,157 @@
+package viper
+
+import (
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// WithRelativePaths marks keys holding file system paths, such as
+// certificate files or data directories. Relative paths found at those keys
+// are resolved from the directory of the config file defining them rather
+// than from the working directory of the process. Patterns are globs, as in
+// path.Match, matched against the lower-cased dot-notation key and each of
+// its parents. Values coming from the environment, overrides or providers
+// are left untouched.
+func WithRelativePaths(patterns ...string) Option {
+	return func(p *Parser) {
+		for _, pattern := range patterns {
+			p.pathKeys = append(p.pathKeys, strings.ToLower(pattern))
+		}
+	}
+}
+
+// WithRelativePathsFrom marks the keys backing the fields of the struct v
+// tagged with `pathrel:"true"`, as WithRelativePaths does. Keys are named
+// after the mapstructure tag of the fields, falling back to the field name.
+func WithRelativePathsFrom(v interface{}) Option {
+	return func(p *Parser) {
+		t := reflect.TypeOf(v)
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			return
+		}
+		p.pathKeys = append(p.pathKeys, pathRelTags(t, "")...)
+	}
+}
+
+// pathRelTags lists the keys of the fields of t tagged with pathrel,
+// looking into nested and squashed structs
+func pathRelTags(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+		name := field.Name
+		if tag[0] != "" {
+			name = tag[0]
+		}
+		if name == "-" {
+			continue
+		}
+		name = strings.ToLower(name)
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if v, ok := field.Tag.Lookup("pathrel"); ok && v != "false" {
+			keys = append(keys, key)
+			continue
+		}
+		if ft.Kind() != reflect.Struct || ft == timeType {
+			continue
+		}
+		squash := field.Anonymous && tag[0] == ""
+		for _, opt := range tag[1:] {
+			squash = squash || opt == "squash"
+		}
+		if squash {
+			keys = append(keys, pathRelTags(ft, prefix)...)
+		} else {
+			keys = append(keys, pathRelTags(ft, key)...)
+		}
+	}
+	return keys
+}
+
+// isPathKey reports whether the key or one of its parents holds paths.
+// Keys of region and locale overlays are matched without their overlay
+// prefix, since they end up at the root of the config.
+func (p *Parser) isPathKey(key string) bool {
+	key = strings.ToLower(key)
+	if parts := strings.SplitN(key, ".", 3); len(parts) == 3 && p.isOverlayKey(parts[0]) {
+		key = parts[2]
+	}
+	for {
+		for _, pattern := range p.pathKeys {
+			if ok, _ := path.Match(pattern, key); ok {
+				return true
+			}
+		}
+		i := strings.LastIndexByte(key, '.')
+		if i < 0 {
+			return false
+		}
+		key = key[:i]
+	}
+}
+
+// resolvePaths rewrites the relative paths of the settings read from
+// configFile in place
+func (p *Parser) resolvePaths(configFile string, settings map[string]interface{}) {
+	if len(p.pathKeys) == 0 || p.inFS(configFile) {
+		return
+	}
+	abs, err := filepath.Abs(configFile)
+	if err != nil {
+		return
+	}
+	p.resolvePathsIn(filepath.Dir(abs), "", settings)
+}
+
+func (p *Parser) resolvePathsIn(dir, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := toStringMap(v); ok {
+			p.resolvePathsIn(dir, key, nested)
+			m[k] = nested
+			continue
+		}
+		if p.isPathKey(key) {
+			m[k] = relativeTo(dir, v)
+		}
+	}
+}
+
+// relativeTo resolves the relative paths held by v from dir. Empty strings,
+// URLs and references are kept as they are.
+func relativeTo(dir string, v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		if t == "" || filepath.IsAbs(t) || strings.Contains(t, "://") || strings.HasPrefix(t, "~") || containsRef(t) {
+			return t
+		}
+		return filepath.Join(dir, t)
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = relativeTo(dir, item)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,79 @@
+package viper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWithRelativePaths(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base/base.yaml": "tls:\n  cert: certs/server.pem\n  key: /etc/tls/server.key\nname: base\n",
+		"app.yaml": "extends: base/base.yaml\n" +
+			"data_dir: ./data\n" +
+			"includes: [conf.d/a.yaml, https://example.com/b.yaml]\n" +
+			"upstream: http://localhost:8080\n" +
+			"name: app\n" +
+			"regions:\n  eu:\n    tls:\n      ca: eu/ca.pem\n",
+	})
+
+	p := New(WithRelativePaths("tls.*", "data_dir", "includes", "upstream"), WithRegion("eu"))
+	if _, err := p.Parse(filepath.Join(dir, "app.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"tls.cert", filepath.Join(dir, "base", "certs", "server.pem")},
+		{"tls.key", "/etc/tls/server.key"},
+		{"tls.ca", filepath.Join(dir, "eu", "ca.pem")},
+		{"data_dir", filepath.Join(dir, "data")},
+		{"includes", []interface{}{filepath.Join(dir, "conf.d", "a.yaml"), "https://example.com/b.yaml"}},
+		{"upstream", "http://localhost:8080"},
+		{"name", "app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := p.Get(tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("env values", func(t *testing.T) {
+		t.Setenv("PATHRELTEST_CACHE_DIR", "other")
+		p := New(WithEnvPrefix("pathreltest"), WithRelativePaths("cache_dir"))
+		if _, err := p.Parse(filepath.Join(dir, "app.yaml")); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.GetString("cache_dir"); got != "other" {
+			t.Errorf("cache_dir = %q, want the env value untouched", got)
+		}
+	})
+}
+
+func TestWithRelativePathsFrom(t *testing.T) {
+	type tlsConfig struct {
+		Cert string `pathrel:"true"`
+		Key  string `mapstructure:"key_file" pathrel:"true"`
+		Mode string
+	}
+	type common struct {
+		DataDir string `mapstructure:"data_dir" pathrel:"true"`
+	}
+	type config struct {
+		common `mapstructure:",squash"`
+		TLS    *tlsConfig `mapstructure:"tls"`
+		Name   string
+		Skip   string `mapstructure:"-" pathrel:"true"`
+		Off    string `pathrel:"false"`
+	}
+
+	p := New(WithRelativePathsFrom(&config{}))
+	want := []string{"data_dir", "tls.cert", "tls.key_file"}
+	if !reflect.DeepEqual(p.pathKeys, want) {
+		t.Fatalf("path keys = %q, want %q", p.pathKeys, want)
+	}
+}
@@ -0,0 +1,187 @@
+package viper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Component is a part of the application reconfigured when a reload
+// changes the config. Apply is called before the new config is installed:
+// the parser still serves the previous config, and changes carries the new
+// values. When it fails, it must leave the component as it was; the
+// components applied before it are rolled back.
+type Component interface {
+	Apply(ctx context.Context, changes ChangeSet) error
+	Rollback(ctx context.Context, changes ChangeSet) error
+}
+
+// component is a registered component and the components it depends on
+type component struct {
+	name      string
+	c         Component
+	dependsOn []string
+}
+
+// ApplyError is returned when a component failed to apply a reload
+type ApplyError struct {
+	// Component is the name of the component that failed
+	Component string
+	// Err is the error returned by the component
+	Err error
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("component %s cannot apply the config: %v", e.Component, e.Err)
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// Register adds a component to the reload pipeline. After every reload
+// changing the config, Watch applies the change to the components in
+// dependency order, each one after the components it depends on and in
+// registration order otherwise. The first failure stops the pipeline: the
+// components already applied are rolled back in reverse order and the new
+// config is dropped: it is only installed, and visible to the readers of
+// the parser, once every component applied it. Change listeners are only
+// notified once every component applied the change.
+func (p *Parser) Register(name string, c Component, dependsOn ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, existing := range p.components {
+		if existing.name == name {
+			return fmt.Errorf("component %q is already registered", name)
+		}
+	}
+	p.components = append(p.components, component{name: name, c: c, dependsOn: dependsOn})
+	return nil
+}
+
+// applyOrder sorts the components so dependencies come first. Callers hold
+// the read lock.
+func (p *Parser) applyOrder() ([]component, error) {
+	byName := make(map[string]int, len(p.components))
+	for i, c := range p.components {
+		byName[c.name] = i
+	}
+	for _, c := range p.components {
+		for _, dep := range c.dependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("component %q depends on unknown component %q", c.name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(p.components))
+	order := make([]component, 0, len(p.components))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		c := p.components[i]
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("component dependency cycle: %s", strings.Join(append(path, c.name), " -> "))
+		}
+		state[i] = visiting
+		for _, dep := range c.dependsOn {
+			if err := visit(byName[dep], append(path, c.name)); err != nil {
+				return err
+			}
+		}
+		state[i] = done
+		order = append(order, c)
+		return nil
+	}
+	for i := range p.components {
+		if err := visit(i, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// runPipeline applies changes to the registered components, rolling back
+// the applied ones on failure. Callers must not hold p.mu.
+func (p *Parser) runPipeline(changes ChangeSet) error {
+	p.mu.RLock()
+	order, err := p.applyOrder()
+	p.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for i, c := range order {
+		err := c.c.Apply(ctx, changes)
+		if err == nil {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if rerr := order[j].c.Rollback(ctx, changes); rerr != nil {
+				p.logger.Error("cannot roll back component", "component", order[j].name, "error", rerr)
+			}
+		}
+		return &ApplyError{Component: c.name, Err: err}
+	}
+	return nil
+}
+
+// stage reinstalls prev, the load state in place before a reload or a
+// rollback installed the next one, when components must apply changes
+// first, and reports whether they must: the parser keeps serving the
+// previous config until every component applied the new one. Callers hold
+// the lock.
+func (p *Parser) stage(prev loadState, changes ChangeSet) (bool, error) {
+	if changes.Empty() || len(p.components) == 0 {
+		return false, nil
+	}
+	return true, p.restoreState(prev)
+}
+
+// applyStaged applies changes to the registered components and installs
+// next once all of them did. Callers must not hold p.mu.
+func (p *Parser) applyStaged(prev, next loadState, changes ChangeSet) error {
+	if err := p.runPipeline(changes); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.restoreState(next); err != nil {
+		if rerr := p.restoreState(prev); rerr != nil {
+			p.logger.Error("cannot restore the previous config", "error", rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// loadState is the part of the parser state replaced by a load
+type loadState struct {
+	own     map[string]interface{}
+	ownType string
+	files   []string
+	info    loadInfo
+}
+
+// saveState returns the current load state. Callers hold the lock.
+func (p *Parser) saveState() loadState {
+	return loadState{own: p.own, ownType: p.ownType, files: p.files, info: p.info}
+}
+
+// restoreState reinstalls a load state saved before a reload. Callers hold
+// the lock.
+func (p *Parser) restoreState(s loadState) error {
+	p.own, p.ownType, p.files, p.info = s.own, s.ownType, s.files, s.info
+	if len(s.files) > 0 {
+		p.v.SetConfigFile(s.files[len(s.files)-1])
+	}
+	return p.compose()
+}
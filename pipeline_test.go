@@ -0,0 +1,229 @@
+package viper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingComponent struct {
+	name string
+	fail bool
+	log  *[]string
+	mu   *sync.Mutex
+	p    *Parser
+}
+
+func (c recordingComponent) Apply(_ context.Context, changes ChangeSet) error {
+	// the parser serves the previous config until every component applied
+	// the new one
+	served := c.p.GetString("version")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.log = append(*c.log, fmt.Sprintf("apply %s %v over %s", c.name, changes.Modified["version"].New, served))
+	if c.fail {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func (c recordingComponent) Rollback(_ context.Context, _ ChangeSet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.log = append(*c.log, "rollback "+c.name)
+	return nil
+}
+
+func TestParser_Register(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    string
+		deps    map[string][]string
+		wantLog []string
+		wantErr string
+	}{
+		{
+			name:    "dependency order",
+			deps:    map[string][]string{"workers": {"db"}},
+			wantLog: []string{"apply db v2 over v1", "apply workers v2 over v1", "apply cache v2 over v1"},
+		},
+		{
+			name:    "failure rolls back",
+			fail:    "cache",
+			deps:    map[string][]string{"workers": {"db"}},
+			wantLog: []string{"apply db v2 over v1", "apply workers v2 over v1", "apply cache v2 over v1", "rollback workers", "rollback db"},
+			wantErr: "component cache cannot apply the config: boom",
+		},
+		{
+			name:    "short circuit",
+			fail:    "db",
+			deps:    map[string][]string{"workers": {"db"}, "cache": {"workers"}},
+			wantLog: []string{"apply db v2 over v1"},
+			wantErr: "component db cannot apply the config",
+		},
+		{
+			name:    "cycle",
+			deps:    map[string][]string{"workers": {"db"}, "db": {"cache"}, "cache": {"workers"}},
+			wantErr: "component dependency cycle: workers -> db -> cache -> workers",
+		},
+		{
+			name:    "unknown dependency",
+			deps:    map[string][]string{"workers": {"queue"}},
+			wantErr: `component "workers" depends on unknown component "queue"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"config.yaml": "version: v1\n"})
+			configFile := filepath.Join(dir, "config.yaml")
+			p := New()
+			if _, err := p.Parse(configFile); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				log []string
+				mu  sync.Mutex
+			)
+			for _, name := range []string{"workers", "db", "cache"} {
+				c := recordingComponent{name: name, fail: name == tt.fail, log: &log, mu: &mu, p: p}
+				if err := p.Register(name, c, tt.deps[name]...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			notified := make(chan struct{}, 1)
+			p.OnChange(func() {
+				select {
+				case notified <- struct{}{}:
+				default:
+				}
+			})
+			reloaded := make(chan struct{}, 1)
+			if err := p.Watch(configFile, func() {
+				select {
+				case reloaded <- struct{}{}:
+				default:
+				}
+			}); err != nil {
+				t.Fatal(err)
+			}
+			defer p.StopWatch(configFile)
+
+			replaceFile(t, configFile, "version: v2\n")
+			select {
+			case <-reloaded:
+			case <-time.After(time.Second):
+				t.Fatal("no reload")
+			}
+
+			mu.Lock()
+			gotLog := append([]string(nil), log...)
+			mu.Unlock()
+			if !reflect.DeepEqual(gotLog, tt.wantLog) {
+				t.Errorf("log = %q, want %q", gotLog, tt.wantLog)
+			}
+
+			p.mu.RLock()
+			last := p.history[len(p.history)-1]
+			p.mu.RUnlock()
+			if tt.wantErr == "" {
+				if last.Error != "" {
+					t.Errorf("reload error = %q", last.Error)
+				}
+				if got := p.GetString("version"); got != "v2" {
+					t.Errorf("version = %q, want v2", got)
+				}
+				select {
+				case <-notified:
+				default:
+					t.Error("change listeners not notified")
+				}
+				return
+			}
+			if !strings.Contains(last.Error, tt.wantErr) {
+				t.Errorf("reload error = %q, want %q", last.Error, tt.wantErr)
+			}
+			if got := p.GetString("version"); got != "v1" {
+				t.Errorf("version = %q, want the previous config restored", got)
+			}
+			select {
+			case <-notified:
+				t.Error("change listeners notified of a rolled back change")
+			default:
+			}
+		})
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		p := New()
+		c := recordingComponent{}
+		if err := p.Register("db", c); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Register("db", c); err == nil {
+			t.Error("Register() of a duplicate name succeeded")
+		}
+	})
+}
+
+// blockingComponent applies changes once released
+type blockingComponent struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c blockingComponent) Apply(_ context.Context, _ ChangeSet) error {
+	c.entered <- struct{}{}
+	<-c.release
+	return nil
+}
+
+func (c blockingComponent) Rollback(_ context.Context, _ ChangeSet) error { return nil }
+
+func TestParser_ParseWaitsForStagedConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"v1.yaml": "version: v1\n",
+		"v2.yaml": "version: v2\n",
+		"v3.yaml": "version: v3\n",
+	})
+	p := New()
+	for _, name := range []string{"v1.yaml", "v2.yaml"} {
+		if _, err := p.Parse(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := blockingComponent{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	if err := p.Register("blocking", c); err != nil {
+		t.Fatal(err)
+	}
+
+	rolledBack := make(chan error, 1)
+	go func() { rolledBack <- p.Rollback(1) }()
+	<-c.entered
+	parsed := make(chan error, 1)
+	go func() {
+		_, err := p.Parse(filepath.Join(dir, "v3.yaml"))
+		parsed <- err
+	}()
+	select {
+	case <-parsed:
+		t.Fatal("Parse() returned while a rollback was being applied")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(c.release)
+	if err := <-rolledBack; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-parsed; err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("version"); got != "v3" {
+		t.Errorf("version = %q, want the config parsed last", got)
+	}
+}
@@ -0,0 +1,134 @@
+package viper
+
+import (
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is how often config files are checked for changes on
+// platforms where they are polled
+var pollInterval = 2 * time.Second
+
+// configMapData is the link Kubernetes swaps to update the files of a
+// mounted ConfigMap at once
+const configMapData = "..data"
+
+// fileState identifies a version of a file, as seen by a polling watcher
+type fileState struct {
+	real    string
+	size    int64
+	modTime int64
+	missing bool
+}
+
+func statFile(configFile string) fileState {
+	real, _ := filepath.EvalSymlinks(configFile)
+	st, err := os.Stat(configFile)
+	if err != nil {
+		return fileState{real: real, missing: true}
+	}
+	return fileState{real: real, size: st.Size(), modTime: st.ModTime().UnixNano()}
+}
+
+// pollFile calls onChange whenever the size, the modification time or the
+// target of configFile changes between two checks made every interval,
+// until the returned stop function is called. A file reappearing after it
+// was removed counts as a change.
+func pollFile(configFile string, interval time.Duration, logger *slog.Logger, onChange func()) (stop func(), err error) {
+	configFile = filepath.Clean(configFile)
+	last := statFile(configFile)
+	if last.missing {
+		if _, err := os.Stat(filepath.Dir(configFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				current := statFile(configFile)
+				if current == last {
+					continue
+				}
+				last = current
+				if current.missing {
+					logger.Warn("watched config file is missing", "file", configFile)
+					continue
+				}
+				onChange()
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-done
+	}, nil
+}
+
+// dirState returns the state of the files of dir accepted by match, and of
+// its ConfigMap data link
+func dirState(dir string, match func(name string) bool) (map[string]fileState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	state := make(map[string]fileState)
+	for _, e := range entries {
+		if name := e.Name(); match(name) || name == configMapData {
+			state[name] = statFile(filepath.Join(dir, name))
+		}
+	}
+	return state, nil
+}
+
+// pollDir calls onChange whenever a file of dir accepted by match is added,
+// removed or changed between two checks made every interval, until the
+// returned stop function is called
+func pollDir(dir string, interval time.Duration, logger *slog.Logger, match func(name string) bool, onChange func()) (stop func(), err error) {
+	last, err := dirState(dir, match)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				current, err := dirState(dir, match)
+				if err != nil {
+					logger.Warn("cannot list watched config directory", "dir", dir, "error", err)
+					continue
+				}
+				if maps.Equal(current, last) {
+					continue
+				}
+				last = current
+				onChange()
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-done
+	}, nil
+}
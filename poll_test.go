@@ -0,0 +1,94 @@
+package viper
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPollFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "log: info\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	changes := make(chan struct{}, 10)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	stop, err := pollFile(configFile, 5*time.Millisecond, logger, func() { changes <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	expect := func(what string, want bool) {
+		t.Helper()
+		select {
+		case <-changes:
+			if !want {
+				t.Errorf("change reported after %s", what)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if want {
+				t.Errorf("no change reported after %s", what)
+			}
+		}
+	}
+	expect("no write", false)
+	if err := os.WriteFile(configFile, []byte("log: debug\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("a write", true)
+	if err := os.Remove(configFile); err != nil {
+		t.Fatal(err)
+	}
+	expect("a removal", false)
+	if err := os.WriteFile(configFile, []byte("log: warn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("the file came back", true)
+
+	if _, err := pollFile(filepath.Join(dir, "missing", "config.yaml"), time.Second, logger, func() {}); err == nil {
+		t.Error("pollFile() in a missing directory succeeded")
+	}
+}
+
+func TestPollDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"10-base.yaml": "log: info\n", "notes.txt": "-"})
+	match := func(name string) bool { return filepath.Ext(name) == ".yaml" }
+
+	changes := make(chan struct{}, 10)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	stop, err := pollDir(dir, 5*time.Millisecond, logger, match, func() { changes <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	expect := func(what string, want bool) {
+		t.Helper()
+		select {
+		case <-changes:
+			if !want {
+				t.Errorf("change reported after %s", what)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if want {
+				t.Errorf("no change reported after %s", what)
+			}
+		}
+	}
+	expect("no write", false)
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("a write to another file", false)
+	if err := os.WriteFile(filepath.Join(dir, "20-log.yaml"), []byte("log: warn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("an added fragment", true)
+	if err := os.Remove(filepath.Join(dir, "10-base.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	expect("a removed fragment", true)
+}
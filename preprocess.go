@@ -0,0 +1,163 @@
+package viper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Preprocessor transforms the raw bytes of a config file before they are
+// decoded, to unwrap compressed or encrypted artifacts or clean up their
+// text
+type Preprocessor func(b []byte) ([]byte, error)
+
+// extPreprocessor is a preprocessor registered for a file extension
+type extPreprocessor struct {
+	ext string
+	fn  Preprocessor
+}
+
+// WithPreprocessor appends fn to the preprocessors applied to every config
+// file, in registration order, once the preprocessors of its extensions ran
+func WithPreprocessor(fn Preprocessor) Option {
+	return func(p *Parser) {
+		p.preprocessors = append(p.preprocessors, fn)
+	}
+}
+
+// WithExtensionPreprocessor registers fn for the config files whose name
+// ends with ext, like ".enc", replacing the one registered before if any.
+// The extension is stripped before the config type is looked up, so
+// config.yaml.enc decodes as YAML once fn ran. Stacked extensions are
+// unwrapped from the last one: config.json.gz.enc runs the ".enc"
+// preprocessor, then the ".gz" one. The ".gz" extension is registered by
+// default with a decompressor honoring the size limit.
+func WithExtensionPreprocessor(ext string, fn Preprocessor) Option {
+	return func(p *Parser) {
+		p.setExtPreprocessor(ext, fn)
+	}
+}
+
+func (p *Parser) setExtPreprocessor(ext string, fn Preprocessor) {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	for i, pp := range p.extPreprocessors {
+		if pp.ext == ext {
+			p.extPreprocessors[i].fn = fn
+			return
+		}
+	}
+	p.extPreprocessors = append(p.extPreprocessors, extPreprocessor{ext: ext, fn: fn})
+}
+
+// extPreprocessor returns the preprocessor of the extension of name
+func (p *Parser) extPreprocessor(name string) (string, Preprocessor, bool) {
+	ext := filepath.Ext(name)
+	for _, pp := range p.extPreprocessors {
+		if strings.EqualFold(pp.ext, ext) {
+			return ext, pp.fn, true
+		}
+	}
+	return "", nil, false
+}
+
+// unwrappedName strips the extensions handled by preprocessors from name
+func (p *Parser) unwrappedName(name string) string {
+	for {
+		ext, _, ok := p.extPreprocessor(name)
+		if !ok {
+			return name
+		}
+		name = strings.TrimSuffix(name, ext)
+	}
+}
+
+// preprocess runs the preprocessors of the extensions of source, then the
+// global ones, over b
+func (p *Parser) preprocess(b []byte, source string) ([]byte, error) {
+	name := source
+	for {
+		ext, fn, ok := p.extPreprocessor(name)
+		if !ok {
+			break
+		}
+		var err error
+		if b, err = fn(b); err != nil {
+			return nil, fmt.Errorf("preprocessing %s: %w", ext, err)
+		}
+		name = strings.TrimSuffix(name, ext)
+	}
+	for _, fn := range p.preprocessors {
+		var err error
+		if b, err = fn(b); err != nil {
+			return nil, fmt.Errorf("preprocessing: %w", err)
+		}
+	}
+	return b, nil
+}
+
+// gunzip decompresses gzip data, failing once the output exceeds the size
+// limit rather than inflating it entirely
+func (p *Parser) gunzip(b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return p.readAllLimited(zr)
+}
+
+// StripBOM is a Preprocessor removing the UTF-8 byte order mark some
+// editors write at the start of files
+func StripBOM(b []byte) ([]byte, error) {
+	return bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")), nil
+}
+
+// ExpandTabs returns a Preprocessor replacing the tabs indenting lines with
+// width spaces, as YAML rejects tab indentation
+func ExpandTabs(width int) Preprocessor {
+	spaces := strings.Repeat(" ", width)
+	return func(b []byte) ([]byte, error) {
+		lines := bytes.Split(b, []byte("\n"))
+		for i, line := range lines {
+			n := 0
+			for n < len(line) && (line[n] == '\t' || line[n] == ' ') {
+				n++
+			}
+			indent := strings.ReplaceAll(string(line[:n]), "\t", spaces)
+			lines[i] = append([]byte(indent), line[n:]...)
+		}
+		return bytes.Join(lines, []byte("\n")), nil
+	}
+}
+
+// RenderTemplate returns a Preprocessor running config files through
+// text/template with data as the dot and funcs added to the template
+// functions, so files can hold conditional sections and loops:
+//
+//	{{ if eq .Env "prod" }}
+//	replicas: 3
+//	{{ end }}
+//
+// Referring to a missing map entry of data fails. Registered for an
+// extension, as with WithExtensionPreprocessor(".tmpl", RenderTemplate(data,
+// nil)), it renders config.yaml.tmpl only, which Save then refuses to
+// overwrite with the rendered settings.
+func RenderTemplate(data interface{}, funcs template.FuncMap) Preprocessor {
+	return func(b []byte) ([]byte, error) {
+		tmpl, err := template.New("config").Funcs(funcs).Option("missingkey=error").Parse(string(b))
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
@@ -0,0 +1,95 @@
+package viper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParser_Preprocessors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"config.yaml.gz":     gzipped(t, "server:\n  port: 8080\n"),
+		"config.json.gz.b64": []byte(base64.StdEncoding.EncodeToString(gzipped(t, `{"server": {"port": 9090}}`))),
+		"tabs.yaml":          []byte("\xef\xbb\xbfserver:\n\tport: 7070\n"),
+		"bomb.yaml.gz":       gzipped(t, "pad: "+strings.Repeat("x", 4096)+"\n"),
+		"config.yaml.tmpl":   []byte("server:\n{{- if eq .Env \"prod\" }}\n  port: {{ base 6000 }}\n{{- else }}\n  port: 1\n{{- end }}\n"),
+	}
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decode64 := func(b []byte) ([]byte, error) {
+		return base64.StdEncoding.DecodeString(string(b))
+	}
+	render := RenderTemplate(map[string]string{"Env": "prod"}, template.FuncMap{
+		"base": func(n int) int { return n + 60 },
+	})
+
+	tests := []struct {
+		file string
+		opts []Option
+		want int
+	}{
+		{"config.yaml.gz", nil, 8080},
+		{"config.json.gz.b64", []Option{WithExtensionPreprocessor("b64", decode64)}, 9090},
+		{"tabs.yaml", []Option{WithPreprocessor(StripBOM), WithPreprocessor(ExpandTabs(2))}, 7070},
+		{"config.yaml.tmpl", []Option{WithExtensionPreprocessor(".tmpl", render)}, 6060},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			p := New(tt.opts...)
+			if _, err := p.Parse(filepath.Join(dir, tt.file)); err != nil {
+				t.Fatal(err)
+			}
+			if got := p.GetInt("server.port"); got != tt.want {
+				t.Errorf("server.port = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("decompressed size limit", func(t *testing.T) {
+		_, err := New(WithLimits(Limits{MaxSize: 1024})).Parse(filepath.Join(dir, "bomb.yaml.gz"))
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("Parse() error = %v, want a *LimitError", err)
+		}
+	})
+
+	t.Run("template missing data", func(t *testing.T) {
+		render := WithExtensionPreprocessor(".tmpl", RenderTemplate(map[string]string{}, template.FuncMap{"base": strconv.Itoa}))
+		_, err := New(render).Parse(filepath.Join(dir, "config.yaml.tmpl"))
+		if err == nil || !strings.Contains(err.Error(), "Env") {
+			t.Errorf("Parse() error = %v, want the missing Env reported", err)
+		}
+	})
+
+	t.Run("failing preprocessor", func(t *testing.T) {
+		fail := WithPreprocessor(func([]byte) ([]byte, error) { return nil, errors.New("bad key") })
+		_, err := New(fail).Parse(filepath.Join(dir, "config.yaml.gz"))
+		if err == nil || !strings.Contains(err.Error(), "preprocessing: bad key") {
+			t.Errorf("Parse() error = %v, want the preprocessor failure", err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package viper
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithProfile merges the profile variants of every config file parsed on
+// top of it, in the order the profiles are given: with WithProfile("prod",
+// "local"), parsing config.yaml reads config.prod.yaml then
+// config.local.yaml over it. Missing variants are skipped. The variants are
+// read again on every reload; watching one of them reloads the whole set.
+func WithProfile(profiles ...string) Option {
+	return func(p *Parser) {
+		p.profiles = append(p.profiles, profiles...)
+	}
+}
+
+// profileFile returns the name of the profile variant of configFile, the
+// profile going before the extensions handled by preprocessors and the
+// config type: config.yaml.gz becomes config.prod.yaml.gz
+func (p *Parser) profileFile(configFile, profile string) string {
+	unwrapped := p.unwrappedName(configFile)
+	ext := filepath.Ext(unwrapped)
+	return strings.TrimSuffix(unwrapped, ext) + "." + profile + ext + configFile[len(unwrapped):]
+}
+
+// readProfiles merges the profile variants of configFile that exist over
+// settings
+func (p *Parser) readProfiles(settings map[string]interface{}, configFile, typ string) (map[string]interface{}, error) {
+	if _, inline := p.inline[configFile]; inline {
+		return settings, nil
+	}
+	for _, profile := range p.profiles {
+		variant := p.profileFile(configFile, profile)
+		exists, err := p.fileExists(variant)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file %q: %w", variant, err)
+		}
+		if !exists {
+			continue
+		}
+		variantSettings, err := p.readChain(variant, typ, nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file %q: %w", variant, err)
+		}
+		settings = p.merge(settings, variantSettings)
+	}
+	return settings, nil
+}
+
+// fileExists reports whether the config file exists, on disk or in the
+// fs.FS of ParseFS
+func (p *Parser) fileExists(configFile string) (bool, error) {
+	var err error
+	if p.inFS(configFile) {
+		_, err = fs.Stat(p.fsys, filepath.ToSlash(strings.TrimPrefix(configFile, fsPrefix)))
+	} else {
+		_, err = os.Stat(configFile)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,78 @@
+package viper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParser_Profiles(t *testing.T) {
+	files := map[string]string{
+		"config.yaml":       "log: info\ndb:\n  host: localhost\n  pool: 4\n",
+		"config.prod.yaml":  "log: warn\ndb:\n  host: db.prod\n",
+		"config.local.yaml": "db:\n  pool: 1\n",
+	}
+	dir := writeFiles(t, files)
+
+	tests := []struct {
+		name     string
+		profiles []string
+		want     map[string]interface{}
+	}{
+		{"none", nil, map[string]interface{}{"log": "info", "db.host": "localhost", "db.pool": 4}},
+		{"prod", []string{"prod"}, map[string]interface{}{"log": "warn", "db.host": "db.prod", "db.pool": 4}},
+		{"prod and local", []string{"prod", "local"}, map[string]interface{}{"log": "warn", "db.host": "db.prod", "db.pool": 1}},
+		{"missing profile", []string{"staging"}, map[string]interface{}{"log": "info", "db.host": "localhost", "db.pool": 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(WithProfile(tt.profiles...))
+			if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+				t.Fatal(err)
+			}
+			for k, want := range tt.want {
+				if got := p.Get(k); !jsonEqual(got, want) {
+					t.Errorf("%s = %v, want %v", k, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("fs", func(t *testing.T) {
+		fsys := fstest.MapFS{}
+		for name, content := range files {
+			fsys["conf/"+name] = &fstest.MapFile{Data: []byte(content)}
+		}
+		p := New(WithProfile("prod"))
+		if _, err := p.ParseFS(fsys, "conf/config.yaml"); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.GetString("db.host"); got != "db.prod" {
+			t.Errorf("db.host = %q, want the value of the profile", got)
+		}
+	})
+
+	t.Run("invalid profile file", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{"config.yaml": "a: 1\n", "config.prod.yaml": "a: [\n"})
+		_, err := New(WithProfile("prod")).Parse(filepath.Join(dir, "config.yaml"))
+		if err == nil || !strings.Contains(err.Error(), "config.prod.yaml") {
+			t.Errorf("Parse() error = %v, want the profile file reported", err)
+		}
+	})
+}
+
+func TestParser_profileFile(t *testing.T) {
+	p := New()
+	tests := map[string]string{
+		"config.yaml":        "config.prod.yaml",
+		"conf.d/app.json.gz": "conf.d/app.prod.json.gz",
+		"/etc/app/config":    "/etc/app/config.prod",
+		"fs:config/app.toml": "fs:config/app.prod.toml",
+	}
+	for file, want := range tests {
+		if got := p.profileFile(file, "prod"); got != want {
+			t.Errorf("profileFile(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package viper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceInfo identifies the version of a source read by the last load
+type SourceInfo struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+	ModTime time.Time `json:"mod_time,omitempty"`
+}
+
+// loadInfo records where the settings of a load came from
+type loadInfo struct {
+	sources []SourceInfo
+	// origins maps lower-cased dot keys to the source that last set them
+	origins map[string]string
+	// refs maps the keys whose value was resolved from a reference to the
+	// value holding the reference
+	refs map[string]interface{}
+	// templates maps the keys whose value was interpolated from other keys
+	// to the value holding the placeholders
+	templates map[string]interface{}
+	// conflicts lists the keys merged with incompatible types
+	conflicts []MergeConflict
+	// schemas lists the schemas named by the $schema keys of the sources
+	schemas []string
+	// self holds the parser section of the settings, if read
+	self *selfSettings
+	// documents maps the YAML files read to their document node, keeping
+	// their comments and key order for Save
+	documents map[string]*yaml.Node
+	// migrated maps the files upgraded by migrations to their new content,
+	// to write back
+	migrated map[string][]byte
+	// secrets holds the values of the sensitive and referenced keys read,
+	// scrubbed from the load errors
+	secrets []string
+
+	// readOnly lists the patterns of the read-only sources
+	readOnly []string
+	// locked maps the keys defined by read-only sources to their source
+	locked map[string]string
+	// values holds the effective values of the locked keys
+	values map[string]interface{}
+}
+
+func newLoadInfo(readOnly []string) loadInfo {
+	return loadInfo{
+		origins:   map[string]string{},
+		refs:      map[string]interface{}{},
+		templates: map[string]interface{}{},
+		documents: map[string]*yaml.Node{},
+		migrated:  map[string][]byte{},
+		readOnly:  readOnly,
+		locked:    map[string]string{},
+	}
+}
+
+// addSource records the version of a source read while loading
+func (l *loadInfo) addSource(path string, b []byte) {
+	sum := sha256.Sum256(b)
+	info := SourceInfo{Path: path, Size: int64(len(b)), SHA256: hex.EncodeToString(sum[:])}
+	if st, err := os.Stat(path); err == nil {
+		info.ModTime = st.ModTime()
+	}
+	l.sources = append(l.sources, info)
+}
+
+// addSchema records a schema named by a $schema key, once
+func (l *loadInfo) addSchema(location string) {
+	for _, s := range l.schemas {
+		if s == location {
+			return
+		}
+	}
+	l.schemas = append(l.schemas, location)
+}
+
+// setOrigin attributes every leaf of settings to source, replacing the
+// origins recorded by the sources merged before it. It fails when source
+// would shadow a key owned by a read-only source.
+func (l *loadInfo) setOrigin(source string, settings map[string]interface{}) error {
+	readOnly := l.isReadOnly(source)
+	return walkLeaves(settings, "", func(k string, _ interface{}) error {
+		key := strings.ToLower(k)
+		if owner, ok := l.lockedBy(key); ok && !readOnly {
+			return &PolicyError{Key: key, Source: owner, Attempt: source}
+		}
+		l.origins[key] = source
+		if readOnly {
+			l.locked[key] = source
+		}
+		return nil
+	})
+}
+
+// sourcesOf returns the source that set key, or the sources of the keys of
+// the section at key, in the settings of the load. The indices of list
+// items are ignored.
+func (l *loadInfo) sourcesOf(key string) string {
+	key = strings.ToLower(key)
+	if i := strings.IndexByte(key, '['); i >= 0 {
+		key = key[:i]
+	}
+	if source, ok := l.origins[key]; ok {
+		return source
+	}
+	var sources []string
+	for k, source := range l.origins {
+		if strings.HasPrefix(k, key+".") && !slices.Contains(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	return strings.Join(sources, ", ")
+}
+
+// moveOrigins attributes the keys found under prefix to the matching keys
+// at the root, for sections merged on top of the base settings
+func (l *loadInfo) moveOrigins(prefix string) error {
+	prefix = strings.ToLower(prefix) + "."
+	section := strings.TrimSuffix(prefix, ".")
+	for k, source := range l.origins {
+		rest, ok := strings.CutPrefix(k, prefix)
+		if !ok {
+			continue
+		}
+		_, fromReadOnly := l.locked[k]
+		if owner, ok := l.lockedBy(rest); ok && !fromReadOnly {
+			return &PolicyError{Key: rest, Source: owner, Attempt: source + " (" + section + ")"}
+		}
+		l.origins[rest] = source + " (" + section + ")"
+		if fromReadOnly {
+			l.locked[rest] = source
+		}
+	}
+	return nil
+}
+
+// markRefs records the keys holding references, before they are resolved
+func (l *loadInfo) markRefs(settings map[string]interface{}) {
+	walkLeaves(settings, "", func(k string, v interface{}) error {
+		if containsRef(v) {
+			l.refs[strings.ToLower(k)] = v
+		}
+		return nil
+	})
+}
+
+func containsRef(v interface{}) bool {
+	switch t := v.(type) {
+	case string:
+		return strings.Contains(t, "$ref{")
+	case []interface{}:
+		for _, item := range t {
+			if containsRef(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Origin describes where the effective value of path comes from, following
+// the precedence of the getters:
+//
+//   - the name of a read-only source, for the keys it owns
+//   - "provider", for the keys registered with Provide
+//   - "override", for the keys set with Set, Override or OverrideFor
+//   - "derived", for the keys declared with Derive
+//   - "flag", for the keys bound to a flag set on the command line
+//   - the path of the config file, or the name of the source, that last set
+//     it, with the overlay section applied as in "config.yaml (regions.eu)"
+//   - "env NEXEN_SERVER_PORT", for the keys read from the environment
+//   - "fallback old.key: " and the origin of the fallback serving it
+//   - "parent: " and the origin in the parent, for child parsers
+//   - "default", for the keys only set by their default
+//
+// It is empty when path is not set.
+func (p *Parser) Origin(path string) (origin string) {
+	p.reading(func() { origin = p.origin(p.aliased(p.normalizePath(path))) })
+	return origin
+}
+
+// origin describes the source the effective value of key comes from,
+// following the precedence of the lookups. Callers hold the read lock.
+func (p *Parser) origin(key string) string {
+	key = strings.ToLower(key)
+	if _, ok := p.info.values[key]; ok {
+		return p.info.locked[key]
+	}
+	p.providersMu.RLock()
+	_, provided := p.providers[key]
+	p.providersMu.RUnlock()
+
+	if provided {
+		return "provider"
+	}
+	if _, ok := p.overridden(key); ok {
+		return "override"
+	}
+	if _, ok := p.derived.fns[key]; ok {
+		return "derived"
+	}
+	if changed, ok := p.flags[key]; ok && changed() {
+		return "flag"
+	}
+	if source, ok := p.info.origins[key]; ok {
+		return source
+	}
+	for _, name := range p.envNames(key) {
+		if os.Getenv(name) != "" {
+			return "env " + name
+		}
+	}
+	if source, ok := p.fallbackOrigin(key); ok {
+		return source
+	}
+	if p.parent != nil {
+		p.parent.mu.RLock()
+		defer p.parent.mu.RUnlock()
+		return "parent: " + p.parent.origin(p.parentKey(key))
+	}
+	if p.v.Get(key) == nil {
+		return ""
+	}
+	return "default"
+}
@@ -0,0 +1,62 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_Origin(t *testing.T) {
+	t.Setenv("ORIGINTEST_LOG_LEVEL", "debug")
+	dir := writeFiles(t, map[string]string{
+		"base.yaml":   "name: api\nserver:\n  host: localhost\n",
+		"config.yaml": "extends: base.yaml\nserver:\n  port: 0\nregions:\n  eu:\n    server:\n      host: eu.local\n",
+	})
+	base, configFile := filepath.Join(dir, "base.yaml"), filepath.Join(dir, "config.yaml")
+	p := New(
+		WithEnvPrefix("origintest"),
+		WithRegion("eu"),
+		WithDefaults(map[string]interface{}{"timeout": "5s", "log": map[string]interface{}{"level": "info"}}),
+	)
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	p.Set("name", "web")
+	p.RegisterAlias("service_name", "name")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"server.port", configFile},
+		{"Server.Host", configFile + " (regions.eu)"},
+		{"log.level", "env ORIGINTEST_LOG_LEVEL"},
+		{"name", "override"},
+		{"service_name", "override"},
+		{"timeout", "default"},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		if got := p.Origin(tt.path); got != tt.want {
+			t.Errorf("Origin(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	p.ClearOverride("name")
+	if got := p.Origin("name"); got != base {
+		t.Errorf("Origin(%q) = %q, want %q", "name", got, base)
+	}
+
+	// validation errors name the origin of the failing values
+	var cfg struct {
+		Server struct {
+			Port int `validate:"min=1"`
+		}
+	}
+	var validationErr *ValidationError
+	if err := p.Unmarshal(&cfg); !errors.As(err, &validationErr) {
+		t.Fatalf("Unmarshal() error = %v, want a *ValidationError", err)
+	}
+	if got := validationErr.Fields[0].Origin; got != configFile {
+		t.Errorf("Origin of the failing field = %q, want %q", got, configFile)
+	}
+}
@@ -0,0 +1,189 @@
+package viper
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ValueProvider supplies the value of a key on first access, for values too
+// expensive to compute while parsing
+type ValueProvider interface {
+	Value(ctx context.Context, key string) (interface{}, error)
+}
+
+// ValueProviderFunc adapts a function to the ValueProvider interface
+type ValueProviderFunc func(ctx context.Context, key string) (interface{}, error)
+
+// Value calls f(ctx, key)
+func (f ValueProviderFunc) Value(ctx context.Context, key string) (interface{}, error) {
+	return f(ctx, key)
+}
+
+// lazyValue caches the value returned by a provider
+type lazyValue struct {
+	provider ValueProvider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	loaded  bool
+	value   interface{}
+	expires time.Time
+}
+
+// Provide registers a provider for the given key. The provider is called on
+// the first read of the key and its value, which takes precedence over every
+// other source, is cached for ttl. A ttl of zero or less caches the value
+// until the next load. When a refresh fails, the last good value is kept.
+// The provider is called without holding the parser lock, so it may read
+// the config or override it.
+func (p *Parser) Provide(key string, provider ValueProvider, ttl time.Duration) {
+	p.providersMu.Lock()
+	defer p.providersMu.Unlock()
+	if p.providers == nil {
+		p.providers = make(map[string]*lazyValue)
+	}
+	p.providers[strings.ToLower(key)] = &lazyValue{provider: provider, ttl: ttl}
+}
+
+// provided returns the value of the provider registered for path, if any.
+// Callers hold the read lock, so the provider is not called: a value not
+// loaded yet or expired is fetched by reading once the lock is released.
+func (p *Parser) provided(path string) (interface{}, bool) {
+	p.providersMu.RLock()
+	lv, ok := p.providers[strings.ToLower(path)]
+	p.providersMu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	lv.mu.Lock()
+	defer lv.mu.Unlock()
+	if !lv.fresh() {
+		p.fetches.add("provider:"+strings.ToLower(path), func() { p.refresh(lv, path) })
+	}
+	return lv.value, true
+}
+
+// fresh reports whether the cached value can be served. Callers hold lv.mu.
+func (lv *lazyValue) fresh() bool {
+	return lv.loaded && (lv.ttl <= 0 || time.Now().Before(lv.expires))
+}
+
+// refresh calls the provider of lv unless its value is fresh. When the
+// provider fails, the last good value is kept.
+func (p *Parser) refresh(lv *lazyValue, path string) {
+	lv.mu.Lock()
+	fresh := lv.fresh()
+	lv.mu.Unlock()
+	if fresh {
+		return
+	}
+
+	v, err := lv.provider.Value(context.Background(), path)
+	if err != nil {
+		p.logger.Error("config value provider failed", "key", path, "error", err)
+		return
+	}
+	lv.mu.Lock()
+	defer lv.mu.Unlock()
+	lv.loaded = true
+	lv.value = v
+	lv.expires = time.Now().Add(lv.ttl)
+}
+
+// fetchQueue holds the provider calls and the lazy resolutions of
+// references the reads met under p.mu, run by reading once it is released
+type fetchQueue struct {
+	mu      sync.Mutex
+	pending map[string]*fetch
+	// requests numbers the fetches requested, so a read runs the ones it
+	// requested alone
+	requests atomic.Uint64
+}
+
+// fetch is a provider call or a resolution shared by the reads requesting
+// it while it is pending. first and last number the requests it serves.
+type fetch struct {
+	once        sync.Once
+	run         func()
+	first, last uint64
+}
+
+// add requests the fetch of key, done by run unless one is pending
+func (q *fetchQueue) add(key string, run func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := q.requests.Add(1)
+	f, ok := q.pending[key]
+	if !ok {
+		if q.pending == nil {
+			q.pending = make(map[string]*fetch)
+		}
+		f = &fetch{run: run, first: n}
+		q.pending[key] = f
+	}
+	f.last = n
+}
+
+// run runs the pending fetches requested after request from up to request
+// to, waiting for the ones other reads run
+func (q *fetchQueue) run(from, to uint64) {
+	q.mu.Lock()
+	requested := make(map[string]*fetch)
+	for key, f := range q.pending {
+		if f.first <= to && f.last > from {
+			requested[key] = f
+		}
+	}
+	q.mu.Unlock()
+	for key, f := range requested {
+		f.once.Do(f.run)
+		q.mu.Lock()
+		if q.pending[key] == f {
+			delete(q.pending, key)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// reading calls f under the read lock. The providers and the references
+// resolved lazily that f needs are called once the lock is released, as
+// they may read or change the config themselves, then f is called again
+// with their values.
+func (p *Parser) reading(f func()) {
+	from := p.fetches.requests.Load()
+	p.readLocked(f)
+	to := p.fetches.requests.Load()
+	if to == from {
+		return
+	}
+	p.fetches.run(from, to)
+	// derived keys computed without the fetched values are computed again
+	p.derived.mu.Lock()
+	p.derived.values = nil
+	p.derived.mu.Unlock()
+	p.readLocked(f)
+}
+
+func (p *Parser) readLocked(f func()) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	f()
+}
+
+// resetProviders drops the values cached until the next load
+func (p *Parser) resetProviders() {
+	p.providersMu.RLock()
+	defer p.providersMu.RUnlock()
+	for _, lv := range p.providers {
+		lv.mu.Lock()
+		if lv.ttl <= 0 {
+			lv.loaded = false
+			lv.value = nil
+		}
+		lv.mu.Unlock()
+	}
+}
@@ -0,0 +1,111 @@
+package viper
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParser_Provide(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.json": `{"signing": {"key": "from-file"}, "other": 1}`})
+	configFile := filepath.Join(dir, "config.json")
+
+	var calls int32
+	fail := false
+	provider := ValueProviderFunc(func(_ context.Context, key string) (interface{}, error) {
+		if fail {
+			return nil, errors.New("backend down")
+		}
+		return int(atomic.AddInt32(&calls, 1)), nil
+	})
+
+	p := New()
+	p.Provide("signing.key", provider, 0)
+	p.Provide("rotating", provider, 20*time.Millisecond)
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Fatalf("provider called %d times while parsing", calls)
+	}
+
+	if got := p.GetInt("signing.key"); got != 1 {
+		t.Errorf("signing.key = %v, want 1", got)
+	}
+	if got := p.GetInt("signing.key"); got != 1 {
+		t.Errorf("signing.key = %v, want the cached value 1", got)
+	}
+	if got := p.GetInt("other"); got != 1 {
+		t.Errorf("other = %v, want 1", got)
+	}
+
+	if got := p.GetInt("rotating"); got != 2 {
+		t.Errorf("rotating = %v, want 2", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := p.GetInt("rotating"); got != 3 {
+		t.Errorf("rotating = %v, want a refreshed 3", got)
+	}
+
+	fail = true
+	time.Sleep(30 * time.Millisecond)
+	if got := p.GetInt("rotating"); got != 3 {
+		t.Errorf("rotating = %v, want the last good value 3", got)
+	}
+	fail = false
+
+	// a reload drops the values cached until then
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetInt("signing.key"); got != 4 {
+		t.Errorf("signing.key = %v after reload, want 4", got)
+	}
+}
+
+func TestParser_ProvideOutsideLock(t *testing.T) {
+	p := New()
+	if _, err := p.ParseBytes([]byte(`{"seed": "s", "workers": 2, "token": "from-file"}`), "json"); err != nil {
+		t.Fatal(err)
+	}
+	// the provider reads and changes the config, which deadlocks when it
+	// runs under the parser lock
+	p.Provide("token", ValueProviderFunc(func(context.Context, string) (interface{}, error) {
+		if err := p.Override("fetched", true); err != nil {
+			return nil, err
+		}
+		return p.GetString("seed") + "-token", nil
+	}), 0)
+	p.Provide("pool", ValueProviderFunc(func(context.Context, string) (interface{}, error) {
+		return 8, nil
+	}), 0)
+	p.Derive("capacity", func(c View) (interface{}, error) {
+		return c.GetInt("pool") * c.GetInt("workers"), nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if got := p.GetString("token"); got != "s-token" {
+			t.Errorf("token = %q, want s-token", got)
+		}
+		if !p.GetBool("fetched") {
+			t.Error("the override of the provider was lost")
+		}
+		// derived from a provided key not fetched yet
+		if got := p.GetInt("capacity"); got != 16 {
+			t.Errorf("capacity = %d, want 16", got)
+		}
+		if got := p.AllSettings()["token"]; got != "s-token" {
+			t.Errorf("AllSettings()[token] = %v, want s-token", got)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reads deadlocked on a provider using the parser")
+	}
+}
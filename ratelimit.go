@@ -0,0 +1,73 @@
+package viper
+
+import (
+	"sync"
+	"time"
+)
+
+// WithMinReloadInterval applies watched changes at most once per interval.
+// A change arriving sooner is postponed until the interval has elapsed, and
+// every change arriving meanwhile is collapsed into that single reload, which
+// reads the file as it is by then.
+func WithMinReloadInterval(d time.Duration) Option {
+	return func(p *Parser) {
+		p.minReloadInterval = d
+	}
+}
+
+// throttle runs a function at most once per interval
+type throttle struct {
+	every time.Duration
+	// interval, when set, returns the interval in place of every, read on
+	// every run so it can change while the throttle is in use
+	interval func() time.Duration
+
+	mu    sync.Mutex
+	last  time.Time
+	timer *time.Timer
+}
+
+// run calls fn right away when the interval has elapsed since the previous
+// call, and otherwise schedules a single call for when it has
+func (t *throttle) run(fn func()) {
+	every := t.every
+	if t.interval != nil {
+		every = t.interval()
+	}
+	if every <= 0 {
+		fn()
+		return
+	}
+
+	t.mu.Lock()
+	if t.timer != nil {
+		// a call is already scheduled and will pick up this change
+		t.mu.Unlock()
+		return
+	}
+	wait := every - time.Since(t.last)
+	if wait <= 0 {
+		t.last = time.Now()
+		t.mu.Unlock()
+		fn()
+		return
+	}
+	t.timer = time.AfterFunc(wait, func() {
+		t.mu.Lock()
+		t.timer = nil
+		t.last = time.Now()
+		t.mu.Unlock()
+		fn()
+	})
+	t.mu.Unlock()
+}
+
+// stop cancels the scheduled call, if any
+func (t *throttle) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
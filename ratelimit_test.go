@@ -0,0 +1,70 @@
+package viper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMinReloadInterval(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.json": `{"rev": 0}`})
+	configFile := filepath.Join(dir, "config.json")
+
+	interval := 300 * time.Millisecond
+	p := New(WithMinReloadInterval(interval))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	var reloads int32
+	if err := p.Watch(configFile, func() { atomic.AddInt32(&reloads, 1) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// a sync tool rewriting the file in a loop
+	for i := 1; i <= 10; i++ {
+		if err := os.WriteFile(configFile, []byte(fmt.Sprintf(`{"rev": %d}`, i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	deadline := time.Now().Add(2 * interval)
+	for p.GetInt("rev") != 10 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := p.GetInt("rev"); got != 10 {
+		t.Fatalf("rev = %d, want the final content 10", got)
+	}
+	// one reload for the first change, one for everything that followed
+	if got := atomic.LoadInt32(&reloads); got > 2 {
+		t.Errorf("applied %d reloads, want at most 2", got)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	var calls int32
+	th := &throttle{every: 50 * time.Millisecond}
+	for i := 0; i < 5; i++ {
+		th.run(func() { atomic.AddInt32(&calls, 1) })
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("calls = %d right away, want 1", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("calls = %d after the interval, want 2", got)
+	}
+
+	th = &throttle{every: 50 * time.Millisecond}
+	th.run(func() { atomic.AddInt32(&calls, 1) })
+	th.run(func() { atomic.AddInt32(&calls, 1) })
+	th.stop()
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("calls = %d after stop, want the scheduled call cancelled", got)
+	}
+}
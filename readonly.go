@@ -0,0 +1,120 @@
+package viper
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// WithReadOnlySources marks the sources matching the given glob patterns,
+// as in filepath.Match against the source path or its base name, as
+// authoritative. The keys they define cannot be shadowed: files merged on
+// top of them, overlays, overrides and child parsers redefining them fail
+// with a *PolicyError, and environment variables and providers are ignored
+// for them.
+func WithReadOnlySources(patterns ...string) Option {
+	return func(p *Parser) {
+		p.readOnly = append(p.readOnly, patterns...)
+	}
+}
+
+// PolicyError is returned when a change would shadow a key owned by a
+// read-only source
+type PolicyError struct {
+	// Key is the key the change would shadow
+	Key string
+	// Source is the read-only source owning the key
+	Source string
+	// Attempt describes the change: a source path, "override", ...
+	Attempt string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s cannot redefine %q: the key is owned by read-only source %s", e.Attempt, e.Key, e.Source)
+}
+
+// isReadOnly reports whether source matches one of the read-only patterns
+func (l *loadInfo) isReadOnly(source string) bool {
+	for _, pattern := range l.readOnly {
+		if ok, _ := filepath.Match(pattern, source); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(source)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lockedBy returns the read-only source owning key, a parent of key or one
+// of its children
+func (l *loadInfo) lockedBy(key string) (string, bool) {
+	if source, ok := l.locked[key]; ok {
+		return source, true
+	}
+	for k, source := range l.locked {
+		if strings.HasPrefix(k, key+".") || strings.HasPrefix(key, k+".") {
+			return source, true
+		}
+	}
+	return "", false
+}
+
+// lockValues records the effective values of the locked keys, so they can be
+// served without looking at the layers shadowing them
+func (l *loadInfo) lockValues(settings map[string]interface{}) {
+	if len(l.locked) == 0 {
+		return
+	}
+	flat := flatten(lowerKeys(settings))
+	l.values = make(map[string]interface{}, len(l.locked))
+	for k := range l.locked {
+		l.values[k] = flat[k]
+	}
+}
+
+// lockedBy returns the read-only source owning key in p or its parents.
+// Callers hold the read lock.
+func (p *Parser) lockedBy(key string) (string, bool) {
+	key = strings.ToLower(key)
+	if source, ok := p.info.lockedBy(key); ok {
+		return source, true
+	}
+	if p.parent == nil {
+		return "", false
+	}
+	p.parent.mu.RLock()
+	defer p.parent.mu.RUnlock()
+	return p.parent.lockedBy(p.parentKey(key))
+}
+
+// lockedValue returns the value of key when it is owned by a read-only
+// source of p or its parents. Callers hold the read lock.
+func (p *Parser) lockedValue(key string) (interface{}, bool) {
+	key = strings.ToLower(key)
+	if v, ok := p.info.values[key]; ok {
+		return v, true
+	}
+	if p.parent == nil {
+		return nil, false
+	}
+	p.parent.mu.RLock()
+	defer p.parent.mu.RUnlock()
+	return p.parent.lockedValue(p.parentKey(key))
+}
+
+// checkParentLocks fails when the settings of a child parser redefine keys
+// owned by a read-only source of its parents
+func (p *Parser) checkParentLocks(settings map[string]interface{}) error {
+	if p.parent == nil {
+		return nil
+	}
+	p.parent.mu.RLock()
+	defer p.parent.mu.RUnlock()
+	for k := range flatten(settings) {
+		if owner, ok := p.parent.lockedBy(p.parentKey(k)); ok {
+			return &PolicyError{Key: strings.ToLower(k), Source: owner, Attempt: "child parser"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithReadOnlySources(t *testing.T) {
+	t.Setenv("READONLYTEST_AUDIT_RETENTION_DAYS", "1")
+	t.Setenv("READONLYTEST_SERVER_PORT", "9000")
+	dir := writeFiles(t, map[string]string{
+		"compliance.yaml": "audit:\n  retention_days: 365\n  enabled: true\n",
+		"app.yaml":        "extends: compliance.yaml\nserver:\n  host: app.local\n",
+		"shadow.yaml":     "extends: compliance.yaml\naudit:\n  retention_days: 7\n",
+		"overlay.yaml":    "extends: compliance.yaml\nregions:\n  eu:\n    audit:\n      enabled: false\n",
+		"plugin.yaml":     "audit:\n  enabled: false\n",
+	})
+	newParser := func(opts ...Option) *Parser {
+		return New(append([]Option{WithEnvPrefix("readonlytest"), WithReadOnlySources("compliance.yaml")}, opts...)...)
+	}
+
+	p := newParser(WithDefaults(map[string]interface{}{"server": map[string]interface{}{"port": 8080}}))
+	if _, err := p.Parse(filepath.Join(dir, "app.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetInt("audit.retention_days"); got != 365 {
+		t.Errorf("audit.retention_days = %d, want the read-only 365 despite the env", got)
+	}
+	if got := p.GetInt("server.port"); got != 9000 {
+		t.Errorf("server.port = %d, want the env to apply to other keys", got)
+	}
+
+	assertPolicyError := func(t *testing.T, err error, key string) {
+		t.Helper()
+		var policyErr *PolicyError
+		if !errors.As(err, &policyErr) {
+			t.Fatalf("error = %v, want a *PolicyError", err)
+		}
+		if policyErr.Key != key || filepath.Base(policyErr.Source) != "compliance.yaml" {
+			t.Errorf("PolicyError = %+v, want key %s owned by compliance.yaml", policyErr, key)
+		}
+	}
+
+	t.Run("override", func(t *testing.T) {
+		assertPolicyError(t, p.Override("audit.retention_days", 1), "audit.retention_days")
+		assertPolicyError(t, p.Override("audit", map[string]interface{}{}), "audit")
+		if err := p.Override("server.port", 1); err != nil {
+			t.Errorf("Override() of a regular key error = %v", err)
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		assertPolicyError(t, p.TrySet("audit.retention_days", 1), "audit.retention_days")
+		p.Set("audit.retention_days", 1)
+		if got := p.GetInt("audit.retention_days"); got != 365 {
+			t.Errorf("audit.retention_days = %d after Set, want the read-only 365", got)
+		}
+		if err := p.TrySet("server.port", 2); err != nil {
+			t.Errorf("TrySet() of a regular key error = %v", err)
+		}
+		if got := p.GetInt("server.port"); got != 2 {
+			t.Errorf("server.port = %d, want the value set", got)
+		}
+	})
+
+	t.Run("extending file", func(t *testing.T) {
+		_, err := newParser().Parse(filepath.Join(dir, "shadow.yaml"))
+		assertPolicyError(t, err, "audit.retention_days")
+	})
+
+	t.Run("overlay", func(t *testing.T) {
+		_, err := newParser(WithRegion("eu")).Parse(filepath.Join(dir, "overlay.yaml"))
+		assertPolicyError(t, err, "audit.enabled")
+		if _, err := newParser().Parse(filepath.Join(dir, "overlay.yaml")); err != nil {
+			t.Errorf("Parse() without the overlay error = %v", err)
+		}
+	})
+
+	t.Run("child parser", func(t *testing.T) {
+		child := p.Child()
+		_, err := child.Parse(filepath.Join(dir, "plugin.yaml"))
+		assertPolicyError(t, err, "audit.enabled")
+		assertPolicyError(t, child.Override("audit.enabled", false), "audit.enabled")
+		if !child.GetBool("audit.enabled") {
+			t.Error("child audit.enabled changed by a rejected source")
+		}
+	})
+}
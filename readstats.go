@@ -0,0 +1,85 @@
+package viper
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// KeyStats reports how often a key is read
+type KeyStats struct {
+	Key string
+	// Reads is the estimated number of reads, a multiple of the sampling
+	// rate
+	Reads uint64
+}
+
+// readStats counts the sampled reads of every key
+type readStats struct {
+	every uint64
+	seen  atomic.Uint64
+	keys  sync.Map // string -> *atomic.Uint64
+}
+
+// WithReadStats counts the reads of every key going through the getters,
+// sampling one read out of every, so hot keys and keys nobody reads can be
+// found with ReadStats. Values of every below 1 count every read.
+func WithReadStats(every int) Option {
+	return func(p *Parser) {
+		if every < 1 {
+			every = 1
+		}
+		p.reads = &readStats{every: uint64(every)}
+	}
+}
+
+func (s *readStats) record(key string) {
+	if s.seen.Add(1)%s.every != 0 {
+		return
+	}
+	c, ok := s.keys.Load(key)
+	if !ok {
+		c, _ = s.keys.LoadOrStore(key, new(atomic.Uint64))
+	}
+	c.(*atomic.Uint64).Add(s.every)
+}
+
+// ReadStats returns the read counts collected since WithReadStats enabled
+// them, most read keys first. Every known key is listed, keys never read
+// with zero reads, along with the keys read but missing from the config.
+// It returns nil when read stats are disabled.
+func (p *Parser) ReadStats() []KeyStats {
+	if p.reads == nil {
+		return nil
+	}
+	counts := make(map[string]uint64)
+	p.mu.RLock()
+	for k := range flatten(p.settings()) {
+		counts[k] = 0
+	}
+	p.mu.RUnlock()
+	p.reads.keys.Range(func(k, c interface{}) bool {
+		counts[k.(string)] += c.(*atomic.Uint64).Load()
+		return true
+	})
+
+	stats := make([]KeyStats, 0, len(counts))
+	for k, n := range counts {
+		stats = append(stats, KeyStats{Key: k, Reads: n})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Reads != stats[j].Reads {
+			return stats[i].Reads > stats[j].Reads
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
+
+// countRead records a read of path when read stats are enabled
+func (p *Parser) countRead(path string) {
+	if p.reads != nil {
+		p.reads.record(strings.ToLower(path))
+	}
+}
@@ -0,0 +1,61 @@
+package viper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWithReadStats(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "server:\n  port: 8080\n  host: a.local\nlegacy: true\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	tests := []struct {
+		name  string
+		every int
+		want  []KeyStats
+	}{
+		{
+			name:  "every read",
+			every: 1,
+			want: []KeyStats{
+				{Key: "server.port", Reads: 4},
+				{Key: "server.host", Reads: 2},
+				{Key: "missing", Reads: 1},
+				{Key: "legacy", Reads: 0},
+			},
+		},
+		{
+			name:  "sampled",
+			every: 7,
+			want: []KeyStats{
+				{Key: "missing", Reads: 7},
+				{Key: "legacy", Reads: 0},
+				{Key: "server.host", Reads: 0},
+				{Key: "server.port", Reads: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(WithReadStats(tt.every))
+			if _, err := p.Parse(configFile); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 4; i++ {
+				p.GetInt("server.port")
+			}
+			p.GetString("Server.Host")
+			p.Get("server.host")
+			p.GetBool("missing")
+
+			if got := p.ReadStats(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadStats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := New().ReadStats(); got != nil {
+		t.Errorf("ReadStats() without WithReadStats = %v, want nil", got)
+	}
+}
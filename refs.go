@@ -0,0 +1,287 @@
+package viper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// refPattern matches references such as $ref{env:DB_PASSWORD} or
+// $ref{vault:kv/app#db_password}
+var refPattern = regexp.MustCompile(`\$ref\{([a-zA-Z][a-zA-Z0-9+.-]*):([^}]*)\}`)
+
+// Resolver resolves the references of a single scheme, like the path
+// part of $ref{file:/run/secrets/db}
+type Resolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface
+type ResolverFunc func(ctx context.Context, ref string) (string, error)
+
+// Resolve calls f(ctx, ref)
+func (f ResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// EnvResolver resolves $ref{env:NAME} from the process environment
+func EnvResolver() Resolver {
+	return ResolverFunc(func(_ context.Context, name string) (string, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", name)
+		}
+		return v, nil
+	})
+}
+
+// FileResolver resolves $ref{file:/path} with the content of the file,
+// without its trailing newline
+func FileResolver() Resolver {
+	return ResolverFunc(func(_ context.Context, path string) (string, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	})
+}
+
+// WithResolver registers the resolver for the references of the given
+// scheme. The env and file schemes are registered by default.
+func WithResolver(scheme string, r Resolver) Option {
+	return func(p *Parser) {
+		p.resolvers[scheme] = r
+	}
+}
+
+// WithLazyResolution defers the resolution of references until the first
+// read of a key holding them, instead of resolving them all while parsing.
+// Resolved values are cached until the next load, and resolvers are called
+// without holding the parser lock. Config.Raw keeps the unresolved
+// references in that mode.
+func WithLazyResolution() Option {
+	return func(p *Parser) {
+		p.lazyRefs = true
+	}
+}
+
+// WatchableResolver is a resolver able to report that the values it
+// resolved changed, like rotated secrets
+type WatchableResolver interface {
+	Resolver
+	// Watch blocks until ctx is done, calling onChange whenever a value
+	// it resolved may have changed and onError for the failures it
+	// recovers from
+	Watch(ctx context.Context, onChange func(), onError func(error))
+}
+
+// WatchResolver watches the resolver registered for scheme, which must be
+// a WatchableResolver. Every change it reports reloads the config, resolving
+// the references again, and calls callback the way Watch does for files.
+// The watch is stopped with StopWatch(scheme).
+func (p *Parser) WatchResolver(scheme string, callback func()) error {
+	if err := p.openForWatch(); err != nil {
+		return err
+	}
+	r, ok := p.resolvers[scheme]
+	if !ok {
+		return fmt.Errorf("no resolver registered for %q references", scheme)
+	}
+	wr, ok := r.(WatchableResolver)
+	if !ok {
+		return fmt.Errorf("the resolver of %q references cannot be watched", scheme)
+	}
+	p.watchBackground(scheme, wr, callback)
+	return nil
+}
+
+// refCache memoizes resolved references between two loads, in plain text
+type refCache struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (c *refCache) Get(ref string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[ref]
+	return v, ok
+}
+
+func (c *refCache) Set(ref, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]string)
+	}
+	c.values[ref] = value
+}
+
+func (c *refCache) Reset() {
+	c.mu.Lock()
+	c.values = nil
+	c.mu.Unlock()
+}
+
+// resolveRefs replaces every reference found in the strings of v
+func (p *Parser) resolveRefs(ctx context.Context, v interface{}, path string) (interface{}, error) {
+	return p.replaceRefs(v, path, func(scheme, ref string) (string, error) {
+		return p.resolveRef(ctx, scheme, ref)
+	})
+}
+
+// replaceRefs replaces every reference found in the strings of v with its
+// value returned by resolve
+func (p *Parser) replaceRefs(v interface{}, path string, resolve func(scheme, ref string) (string, error)) (interface{}, error) {
+	if m, ok := toStringMap(v); ok {
+		out := make(map[string]interface{}, len(m))
+		for k, child := range m {
+			r, err := p.replaceRefs(child, joinPath(path, k), resolve)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	}
+
+	switch t := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, child := range t {
+			r, err := p.replaceRefs(child, fmt.Sprintf("%s[%d]", path, i), resolve)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	case string:
+		r, err := replaceRefString(t, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %w", path, err)
+		}
+		return r, nil
+	}
+	return v, nil
+}
+
+func replaceRefString(s string, resolve func(scheme, ref string) (string, error)) (string, error) {
+	if !strings.Contains(s, "$ref{") {
+		return s, nil
+	}
+	var firstErr error
+	out := refPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if firstErr != nil {
+			return match
+		}
+		parts := refPattern.FindStringSubmatch(match)
+		v, err := resolve(parts[1], parts[2])
+		if err != nil {
+			firstErr = err
+			return match
+		}
+		return v
+	})
+	return out, firstErr
+}
+
+// errRefPending reports a reference resolved lazily whose value is not
+// cached yet
+var errRefPending = errors.New("reference not resolved yet")
+
+// cachedRef returns the cached value of a reference resolved lazily.
+// Callers hold the read lock, so the resolver is not called: a value not
+// cached yet is resolved by reading once the lock is released.
+func (p *Parser) cachedRef(path, scheme, ref string) (string, error) {
+	key := scheme + ":" + ref
+	if v, ok := p.refs.Get(key); ok {
+		return v, nil
+	}
+	if _, ok := p.resolvers[scheme]; !ok {
+		return "", fmt.Errorf("no resolver registered for %q references", scheme)
+	}
+	p.fetches.add("ref:"+key, func() {
+		if _, err := p.resolveRef(p.withSizeLimit(context.Background()), scheme, ref); err != nil {
+			p.logger.Error("cannot resolve config reference", "key", path, "error", err)
+		}
+	})
+	return "", errRefPending
+}
+
+func (p *Parser) resolveRef(ctx context.Context, scheme, ref string) (string, error) {
+	key := scheme + ":" + ref
+	if v, ok := p.refs.Get(key); ok {
+		return v, nil
+	}
+
+	r, ok := p.resolvers[scheme]
+	if !ok {
+		return "", fmt.Errorf("no resolver registered for %q references", scheme)
+	}
+	ctx, span := p.startSpan(ctx, "viper.ResolveRef", refSchemeAttr.String(scheme))
+	v, err := r.Resolve(ctx, ref)
+	endSpan(span, err)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+
+	p.refs.Set(key, v)
+	return v, nil
+}
+
+// get returns the value stored at path. Keys owned by read-only sources are
+// served as loaded; other keys go through their provider if any, the
+// overrides, their derivation, and the resolution of their references when
+// they are resolved lazily. Deprecated aliases read the keys replacing
+// them. Callers must hold the read lock.
+func (p *Parser) get(path string) interface{} {
+	path = p.aliased(p.normalizePath(path))
+	p.countRead(path)
+	return p.lookup(path, p.derivedValue)
+}
+
+// lookup returns the value at the normalized path, derived keys being
+// computed by derived. Unset keys are read through their fallbacks.
+func (p *Parser) lookup(path string, derived func(string) (interface{}, bool)) interface{} {
+	if v := p.lookupKey(path, derived); v != nil {
+		return v
+	}
+	return p.fallbackValue(path, derived)
+}
+
+// lookupKey returns the value at the normalized path, ignoring fallbacks
+func (p *Parser) lookupKey(path string, derived func(string) (interface{}, bool)) interface{} {
+	if v, ok := p.lockedValue(path); ok {
+		return v
+	}
+	if v, ok := p.provided(path); ok {
+		return v
+	}
+	if v, ok := p.overridden(path); ok {
+		return v
+	}
+	if v, ok := derived(path); ok {
+		return v
+	}
+	v := p.configured(path)
+	if !p.lazyRefs || v == nil {
+		return v
+	}
+	resolved, err := p.replaceRefs(v, path, func(scheme, ref string) (string, error) {
+		return p.cachedRef(path, scheme, ref)
+	})
+	if errors.Is(err, errRefPending) {
+		return nil
+	}
+	if err != nil {
+		p.logger.Error("cannot resolve config reference", "key", path, "error", err)
+		return nil
+	}
+	return resolved
+}
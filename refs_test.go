@@ -0,0 +1,149 @@
+package viper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_References(t *testing.T) {
+	t.Setenv("TEST_DB_PASSWORD", "hunter2")
+	dir := writeFiles(t, map[string]string{
+		"secret": "from-file\n",
+	})
+	content := `{
+		"db": {
+			"password": "$ref{env:TEST_DB_PASSWORD}",
+			"dsn": "postgres://app:$ref{env:TEST_DB_PASSWORD}@db/app",
+			"api_key": "$ref{file:` + filepath.Join(dir, "secret") + `}"
+		},
+		"signing": ["$ref{vault:kv/app#signing_key}"]
+	}`
+	configFile := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vault := ResolverFunc(func(_ context.Context, ref string) (string, error) {
+		return "vault(" + ref + ")", nil
+	})
+	p := New(WithResolver("vault", vault))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"db.password", "hunter2"},
+		{"db.dsn", "postgres://app:hunter2@db/app"},
+		{"db.api_key", "from-file"},
+		{"signing", []string{"vault(kv/app#signing_key)"}},
+	}
+	for _, tt := range tests {
+		if got := p.Get(tt.path); !jsonEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParser_ReferenceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "unknown scheme", content: `{"a": "$ref{nope:x}"}`, want: `no resolver registered for "nope"`},
+		{name: "unset env", content: `{"a": {"b": "$ref{env:TEST_SURELY_UNSET}"}}`, want: "resolving a.b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"config.json": tt.content})
+			_, err := New().Parse(filepath.Join(dir, "config.json"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithLazyResolution(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.json": `{"lazy": "$ref{count:x}", "broken": "$ref{nope:x}"}`,
+	})
+
+	calls := 0
+	counter := ResolverFunc(func(_ context.Context, ref string) (string, error) {
+		calls++
+		return "resolved", nil
+	})
+	p := New(WithLazyResolution(), WithResolver("count", counter))
+	cfg, err := p.Parse(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("Parse() must not resolve lazily resolved references: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("resolver called %d times while parsing", calls)
+	}
+	if got := cfg.Raw["lazy"]; got != "$ref{count:x}" {
+		t.Errorf("Raw[lazy] = %v, want the unresolved reference", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		if got := p.GetString("lazy"); got != "resolved" {
+			t.Errorf("GetString(lazy) = %v, want 'resolved'", got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("resolver called %d times, want 1", calls)
+	}
+	if got := p.Get("broken"); got != nil {
+		t.Errorf("Get(broken) = %v, want nil", got)
+	}
+}
+
+func TestParser_WatchResolver(t *testing.T) {
+	p := New()
+	if err := p.WatchResolver("env", nil); err == nil {
+		t.Error("WatchResolver() of a resolver that cannot be watched succeeded")
+	}
+	if err := p.WatchResolver("unknown", nil); err == nil {
+		t.Error("WatchResolver() of an unknown scheme succeeded")
+	}
+}
+
+func TestWithLazyResolutionOutsideLock(t *testing.T) {
+	// the resolver changes the config, which deadlocks when it runs under
+	// the parser lock
+	var p *Parser
+	resolver := ResolverFunc(func(_ context.Context, ref string) (string, error) {
+		if err := p.Override("resolved", ref); err != nil {
+			return "", err
+		}
+		return "secret-" + ref, nil
+	})
+	p = New(WithLazyResolution(), WithResolver("cfg", resolver))
+	if _, err := p.ParseBytes([]byte(`{"db": {"password": "$ref{cfg:db}"}}`), "json"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if got := p.GetString("db.password"); got != "secret-db" {
+			t.Errorf("db.password = %q, want secret-db", got)
+		}
+		if got := p.GetString("resolved"); got != "db" {
+			t.Errorf("resolved = %q, want the override of the resolver", got)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reads deadlocked on a resolver using the parser")
+	}
+}
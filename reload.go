@@ -0,0 +1,34 @@
+package viper
+
+import "errors"
+
+// OnReloadError registers a callback invoked when the reload of a watched
+// file, directory or source fails, with the name of what changed and the
+// error. Reloads are staged: the new content is read, merged and validated
+// against the schema, the required keys and the guards before it replaces
+// the config, and the components it is applied to roll it back when one of
+// them fails. A failed reload, as of a half-written or invalid file, thus
+// leaves the previous config in place, served until the next successful
+// reload. Changes held back by an approver are reported to the OnChangeHeld
+// callbacks instead.
+func (p *Parser) OnReloadError(callback func(source string, err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reloadListeners = append(p.reloadListeners, callback)
+}
+
+// reloadFailed reports a failed reload to the OnReloadError callbacks.
+// Callers must not hold p.mu.
+func (p *Parser) reloadFailed(source string, err error) {
+	var held *heldChange
+	if errors.As(err, &held) {
+		return
+	}
+	p.mu.RLock()
+	listeners := append([]func(string, error){}, p.reloadListeners...)
+	p.mu.RUnlock()
+
+	for _, callback := range listeners {
+		callback(source, err)
+	}
+}
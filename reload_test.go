@@ -0,0 +1,101 @@
+package viper
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParser_OnReloadError(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "host: a\nport: 8080\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	p := New()
+	p.Require("host")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	type failure struct {
+		source string
+		err    error
+	}
+	failures := make(chan failure, 10)
+	p.OnReloadError(func(source string, err error) { failures <- failure{source, err} })
+	reloads := make(chan struct{}, 10)
+	if err := p.Watch(configFile, func() { reloads <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch(configFile)
+
+	expectFailure := func(what string) error {
+		t.Helper()
+		select {
+		case f := <-failures:
+			if f.source != configFile {
+				t.Errorf("source = %q after %s, want %q", f.source, what, configFile)
+			}
+			if got := p.GetInt("port"); got != 8080 {
+				t.Errorf("port = %d after %s, want the previous config kept", got, what)
+			}
+			return f.err
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no reload error reported after %s", what)
+			return nil
+		}
+	}
+
+	replaceFile(t, configFile, "host: a\nport: [80")
+	expectFailure("a half-written file")
+
+	replaceFile(t, configFile, "port: 9090\n")
+	var missing *MissingKeysError
+	if err := expectFailure("a file missing a required key"); !errors.As(err, &missing) {
+		t.Errorf("error = %v, want a *MissingKeysError", err)
+	}
+
+	for len(reloads) > 0 {
+		<-reloads
+	}
+	replaceFile(t, configFile, "host: b\nport: 9090\n")
+	deadline := time.After(2 * time.Second)
+	for p.GetInt("port") != 9090 {
+		select {
+		case <-reloads:
+		case f := <-failures:
+			t.Fatalf("reload error %v for a valid file", f.err)
+		case <-deadline:
+			t.Fatal("the valid file was not applied")
+		}
+	}
+	if got := p.GetString("host"); got != "b" {
+		t.Errorf("host = %q, want b", got)
+	}
+}
+
+func TestParser_OnReloadError_ApprovalHeld(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 8080\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New(WithApprover(ApproverFunc(func(ctx context.Context, req ChangeRequest) error {
+		return errors.New("change freeze")
+	})))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	failed := false
+	p.OnReloadError(func(string, error) { failed = true })
+	held := make(chan struct{}, 1)
+	p.OnChangeHeld(func(ChangeRequest, error) { held <- struct{}{} })
+
+	replaceFile(t, configFile, "port: 9090\n")
+	p.reloader(configFile, func() []string { return p.files }, func(ChangeSet, error) {})()
+	select {
+	case <-held:
+	default:
+		t.Fatal("the held change was not reported to OnChangeHeld")
+	}
+	if failed {
+		t.Error("a held change was reported to OnReloadError")
+	}
+}
@@ -0,0 +1,220 @@
+package viper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FirebaseRemoteConfigURL is the REST endpoint of the Firebase Remote Config
+// template of a project
+const FirebaseRemoteConfigURL = "https://firebaseremoteconfig.googleapis.com/v1/projects/%s/remoteConfig"
+
+// Targeting describes the client a remote config template is evaluated for
+type Targeting struct {
+	// UserID identifies the client in percent conditions
+	UserID string
+	// Attributes holds the values conditions compare, by dot-notation key,
+	// as in "device.os" or "app.version"
+	Attributes map[string]interface{}
+}
+
+// RemoteConfig is a source reading a remote config template over HTTP and
+// evaluating its conditions client-side. Templates have the shape of the
+// Firebase Remote Config REST API: named conditions in priority order and
+// parameters, optionally grouped, holding a default value and values by
+// condition name. Parameter names are dot-notation keys of the local key
+// space.
+//
+// Conditions use the WatchExpr syntax, as in
+// "device.os == 'ios' && app.build >= 120", reading the attributes of the
+// targeting. A condition failing to evaluate, for instance comparing a
+// missing attribute, does not match. The percent key is the position of
+// the user, between 0 and 100, in a bucketing stable for a given user ID.
+type RemoteConfig struct {
+	// URL is the address of the template
+	URL string
+	// Client sends the requests, http.DefaultClient when nil. Its transport
+	// carries the credentials, like a Google OAuth2 token for Firebase.
+	Client *http.Client
+	// Header is added to every request
+	Header http.Header
+	// Firebase selects the Firebase value encoding, parameter values being
+	// strings converted according to their valueType. Otherwise values are
+	// plain JSON.
+	Firebase bool
+	// Targeting is the client conditions are evaluated for
+	Targeting Targeting
+}
+
+// FirebaseRemoteConfig returns a source reading the Remote Config template
+// of a Firebase project with client, which must authenticate the requests
+func FirebaseRemoteConfig(project string, client *http.Client, targeting Targeting) *RemoteConfig {
+	return &RemoteConfig{
+		URL:       fmt.Sprintf(FirebaseRemoteConfigURL, project),
+		Client:    client,
+		Firebase:  true,
+		Targeting: targeting,
+	}
+}
+
+type remoteTemplate struct {
+	Conditions []struct {
+		Name       string `json:"name"`
+		Expression string `json:"expression"`
+	} `json:"conditions"`
+	Parameters      map[string]remoteParameter `json:"parameters"`
+	ParameterGroups map[string]struct {
+		Parameters map[string]remoteParameter `json:"parameters"`
+	} `json:"parameterGroups"`
+}
+
+type remoteParameter struct {
+	DefaultValue      json.RawMessage            `json:"defaultValue"`
+	ConditionalValues map[string]json.RawMessage `json:"conditionalValues"`
+	ValueType         string                     `json:"valueType"`
+}
+
+// Load fetches the template and returns the parameter values selected for
+// the targeting
+func (r *RemoteConfig) Load(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, values := range r.Header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", r.URL, resp.Status)
+	}
+	b, err := readBody(ctx, resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return r.evaluate(b)
+}
+
+// evaluate returns the settings a raw template yields for the targeting
+func (r *RemoteConfig) evaluate(b []byte) (map[string]interface{}, error) {
+	var tmpl remoteTemplate
+	if err := json.Unmarshal(b, &tmpl); err != nil {
+		return nil, fmt.Errorf("invalid remote config template: %w", err)
+	}
+
+	// conditions are listed by priority, the first matching one wins
+	var matched []string
+	for _, c := range tmpl.Conditions {
+		node, err := parseExpr(c.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %w", c.Name, err)
+		}
+		// conditions comparing missing attributes do not match
+		if v, err := node.eval(r.attribute); err == nil && v == true {
+			matched = append(matched, c.Name)
+		}
+	}
+
+	params := make(map[string]remoteParameter, len(tmpl.Parameters))
+	for name, param := range tmpl.Parameters {
+		params[name] = param
+	}
+	for _, group := range tmpl.ParameterGroups {
+		for name, param := range group.Parameters {
+			params[name] = param
+		}
+	}
+
+	settings := make(map[string]interface{})
+	for name, param := range params {
+		raw := param.DefaultValue
+		for _, c := range matched {
+			if v, ok := param.ConditionalValues[c]; ok {
+				raw = v
+				break
+			}
+		}
+		v, ok, err := r.value(raw, param.ValueType)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", name, err)
+		}
+		if ok {
+			setPath(settings, name, v)
+		}
+	}
+	return settings, nil
+}
+
+// value decodes a parameter value. It reports false for the values leaving
+// the key to the other sources.
+func (r *RemoteConfig) value(raw json.RawMessage, valueType string) (interface{}, bool, error) {
+	if len(raw) == 0 {
+		return nil, false, nil
+	}
+	if !r.Firebase {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, false, err
+		}
+		return v, v != nil, nil
+	}
+
+	var fv struct {
+		Value           *string `json:"value"`
+		UseInAppDefault bool    `json:"useInAppDefault"`
+	}
+	if err := json.Unmarshal(raw, &fv); err != nil {
+		return nil, false, err
+	}
+	if fv.UseInAppDefault || fv.Value == nil {
+		return nil, false, nil
+	}
+	s := *fv.Value
+	switch strings.ToUpper(valueType) {
+	case "BOOLEAN":
+		v, err := strconv.ParseBool(s)
+		return v, err == nil, err
+	case "NUMBER":
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v, true, nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		return v, err == nil, err
+	case "JSON":
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, false, err
+		}
+		return v, true, nil
+	}
+	return s, true, nil
+}
+
+// attribute returns the value of a targeting attribute read by a condition
+func (r *RemoteConfig) attribute(key string) interface{} {
+	if key == "percent" {
+		h := fnv.New32a()
+		h.Write([]byte(r.Targeting.UserID))
+		return float64(h.Sum32()%10000) / 100
+	}
+	if v, ok := r.Targeting.Attributes[key]; ok {
+		return v
+	}
+	v, _ := lookupPath(r.Targeting.Attributes, key)
+	return v
+}
@@ -0,0 +1,118 @@
+package viper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const firebaseTemplate = `{
+  "conditions": [
+    {"name": "ios_beta", "expression": "device.os == 'ios' && app.build >= 120"},
+    {"name": "ios", "expression": "device.os == 'ios'"},
+    {"name": "nobody", "expression": "percent < 0"}
+  ],
+  "parameters": {
+    "welcome": {
+      "defaultValue": {"value": "hello"},
+      "conditionalValues": {"ios": {"value": "hello ios"}, "ios_beta": {"value": "hello beta"}}
+    },
+    "limits.max_conns": {"defaultValue": {"value": "10"}, "valueType": "NUMBER"},
+    "server.port": {
+      "defaultValue": {"useInAppDefault": true},
+      "conditionalValues": {"nobody": {"value": "1"}},
+      "valueType": "NUMBER"
+    }
+  },
+  "parameterGroups": {
+    "features": {
+      "parameters": {
+        "features.dark_mode": {
+          "defaultValue": {"value": "false"},
+          "conditionalValues": {"ios": {"value": "true"}},
+          "valueType": "BOOLEAN"
+        },
+        "features.tiers": {"defaultValue": {"value": "[\"free\", \"pro\"]"}, "valueType": "JSON"}
+      }
+    }
+  }
+}`
+
+func TestRemoteConfig(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(firebaseTemplate))
+	}))
+	defer srv.Close()
+
+	dir := writeFiles(t, map[string]string{"config.yaml": "server:\n  port: 8080\nwelcome: local\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		welcome    string
+		darkMode   bool
+	}{
+		{"default", map[string]interface{}{"device.os": "android"}, "hello", false},
+		{"matching condition", map[string]interface{}{"device": map[string]interface{}{"os": "ios"}}, "hello ios", true},
+		{"first condition wins", map[string]interface{}{"device.os": "ios", "app.build": 130}, "hello beta", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &RemoteConfig{
+				URL:       srv.URL,
+				Header:    http.Header{"Authorization": {"Bearer token"}},
+				Firebase:  true,
+				Targeting: Targeting{UserID: "user-1", Attributes: tt.attributes},
+			}
+			p := New(WithSource("firebase", src))
+			if _, err := p.Parse(configFile); err != nil {
+				t.Fatal(err)
+			}
+			if auth != "Bearer token" {
+				t.Errorf("Authorization = %q, want the configured header", auth)
+			}
+			if got := p.GetString("welcome"); got != tt.welcome {
+				t.Errorf("welcome = %q, want %q", got, tt.welcome)
+			}
+			if got := p.GetBool("features.dark_mode"); got != tt.darkMode {
+				t.Errorf("features.dark_mode = %v, want %v", got, tt.darkMode)
+			}
+			if got := p.GetInt("limits.max_conns"); got != 10 {
+				t.Errorf("limits.max_conns = %v, want 10", got)
+			}
+			if got := p.GetInt("server.port"); got != 8080 {
+				t.Errorf("server.port = %v, want the in-app default 8080", got)
+			}
+			if got := p.GetStringSlice("features.tiers"); !reflect.DeepEqual(got, []string{"free", "pro"}) {
+				t.Errorf("features.tiers = %q, want the decoded JSON value", got)
+			}
+		})
+	}
+}
+
+func TestRemoteConfig_evaluate(t *testing.T) {
+	generic := &RemoteConfig{Targeting: Targeting{UserID: "user-1"}}
+	got, err := generic.evaluate([]byte(`{
+  "conditions": [{"name": "everyone", "expression": "percent >= 0 && percent < 100"}],
+  "parameters": {
+    "limits": {"defaultValue": {"max": 1}, "conditionalValues": {"everyone": {"max": 2}}},
+    "unset": {"defaultValue": null}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"limits": map[string]interface{}{"max": float64(2)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("evaluate() = %v, want %v", got, want)
+	}
+
+	if _, err := generic.evaluate([]byte(`{"conditions": [{"name": "bad", "expression": "a ="}]}`)); err == nil {
+		t.Error("evaluate() with an invalid condition succeeded")
+	}
+}
@@ -0,0 +1,87 @@
+package viper
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// MissingKeysError lists the required keys a load left unset
+type MissingKeysError struct {
+	Keys []string
+}
+
+func (e *MissingKeysError) Error() string {
+	return "missing required config keys: " + strings.Join(e.Keys, ", ")
+}
+
+// Require declares keys every load and reload must set to a non-empty
+// value, through a config file, a source, an environment variable, an
+// override, a provider, a derivation or a fallback. A load leaving some of
+// them unset fails with a *MissingKeysError listing all of them and the
+// previous config is kept.
+func (p *Parser) Require(keys ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, k := range keys {
+		p.required = append(p.required, strings.ToLower(p.normalizePath(k)))
+	}
+}
+
+// checkRequired fails when settings, about to be installed, leave required
+// keys unset. Callers hold p.mu.
+func (p *Parser) checkRequired(settings map[string]interface{}) error {
+	if len(p.required) == 0 {
+		return nil
+	}
+	settings = lowerKeys(settings)
+	var missing []string
+	for _, key := range p.required {
+		if p.isSet(settings, key) {
+			continue
+		}
+		set := false
+		for _, fb := range p.fallbacks.chains[key] {
+			if set = p.isSet(settings, fb); set {
+				break
+			}
+		}
+		if !set {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return &MissingKeysError{Keys: missing}
+}
+
+// isSet reports whether key has a non-empty value once settings are
+// installed
+func (p *Parser) isSet(settings map[string]interface{}, key string) bool {
+	if v, ok := lookupPath(settings, key); ok && v != nil && v != "" {
+		return true
+	}
+	for _, name := range p.envNames(key) {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	if v, ok := p.overridden(key); ok && v != nil {
+		return true
+	}
+	p.providersMu.RLock()
+	_, provided := p.providers[key]
+	p.providersMu.RUnlock()
+	if _, derived := p.derived.fns[key]; provided || derived {
+		return true
+	}
+	if p.parent == nil {
+		return false
+	}
+	p.parent.mu.RLock()
+	defer p.parent.mu.RUnlock()
+	v := p.parent.get(p.parentKey(key))
+	return v != nil && v != ""
+}
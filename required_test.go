@@ -0,0 +1,45 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParser_Require(t *testing.T) {
+	t.Setenv("REQUIRETEST_DATABASE_URL", "postgres://db")
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":  "server:\n  port: 8080\n  host: \"\"\nlegacy:\n  region: eu\n",
+		"partial.yaml": "log: info\n",
+	})
+
+	p := New(WithEnvPrefix("requiretest"))
+	p.Require("database.url", "server.port", "cache.region")
+	p.Fallback("cache.region", "legacy.region")
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Require("server.host", "api.token")
+	_, err := p.Parse(filepath.Join(dir, "partial.yaml"))
+	var missing *MissingKeysError
+	if !errors.As(err, &missing) {
+		t.Fatalf("Parse() error = %v, want a *MissingKeysError", err)
+	}
+	want := []string{"api.token", "cache.region", "server.host", "server.port"}
+	if !reflect.DeepEqual(missing.Keys, want) {
+		t.Errorf("missing keys = %v, want %v", missing.Keys, want)
+	}
+	if got := p.GetInt("server.port"); got != 8080 {
+		t.Errorf("server.port = %d after the failed load, want the previous config kept", got)
+	}
+
+	if err := p.Override("api.token", "t0ken"); err != nil {
+		t.Fatal(err)
+	}
+	p.Derive("server.host", func(View) (interface{}, error) { return "localhost", nil })
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Errorf("Parse() error = %v, want the keys set by an override and a derivation accepted", err)
+	}
+}
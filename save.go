@@ -0,0 +1,176 @@
+package viper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Save writes the effective configuration to path, for tooling changing
+// the config with Set and persisting it. It is encoded as the type named by
+// the extension of path, with the codec registered for it: yaml, yml, json
+// and toml out of the box. Values resolved from references are written as
+// the references they came from, so secrets never reach the file. Sensitive
+// keys, as set with WithSensitiveKeys, are written with the values the
+// config files hold, and left out when the files do not set them, unless
+// the parser was created with WithSavedSensitiveValues. Other overrides and
+// values read from the environment are written as they are. The file is
+// replaced atomically and keeps its mode, 0600 when it is created.
+func (p *Parser) Save(path string) error {
+	return p.SaveKey("", path)
+}
+
+// WithSavedSensitiveValues lets Save, SaveKey and WriteConfig write the
+// values of sensitive keys set by overrides, flags, defaults or the
+// environment, which they otherwise leave out of the file
+func WithSavedSensitiveValues() Option {
+	return func(p *Parser) {
+		p.saveSensitive = true
+	}
+}
+
+// SaveKey writes the sub-tree at key of the effective configuration to
+// path, as Save does for the whole of it.
+//
+// When path is a YAML file the config was loaded from, the file is written
+// in its own layout: comments, key order, quoting and anchors are kept for
+// the values left unchanged, keys no longer set are dropped and new keys
+// are appended in lexical order.
+func (p *Parser) SaveKey(key, path string) error {
+	if p.unwrappedName(path) != path {
+		return fmt.Errorf("cannot write %q: its extension is handled by a preprocessor", path)
+	}
+	typ := p.typeOf(path)
+
+	var b []byte
+	var err error
+	p.reading(func() { b, err = p.encodeKey(key, path, typ) })
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// encodeKey encodes the sub-tree at key of the effective configuration as
+// SaveKey writes it to path. Callers hold the read lock.
+func (p *Parser) encodeKey(key, path, typ string) ([]byte, error) {
+	codec, ok := p.codecs[strings.ToLower(typ)]
+	if !ok {
+		return nil, fmt.Errorf("cannot write %q: no codec for config type %q", path, typ)
+	}
+	settings := p.persisted()
+	if key != "" {
+		sub, found := lookupPath(settings, strings.ToLower(p.normalizePath(key)))
+		m, isMap := toStringMap(sub)
+		if !found || !isMap {
+			return nil, fmt.Errorf("cannot write %q: %q is not a section of the config", path, key)
+		}
+		settings = m
+	}
+
+	var b []byte
+	var err error
+	if template, yc := p.yamlTemplate(path, key); template != nil {
+		b, err = p.encodeYAML(template, yc, settings)
+	} else {
+		b, err = codec.Encode(normalizeJSON(settings).(map[string]interface{}))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error encoding %q: %w", path, err)
+	}
+	return b, nil
+}
+
+// WriteConfig writes the effective configuration back to the file it was
+// loaded from, the last one given to ParseAll, as Save does. Settings the
+// file inherited through extends or from the files merged before it are
+// written into it too.
+func (p *Parser) WriteConfig() error {
+	p.mu.RLock()
+	var file string
+	if n := len(p.files); n > 0 {
+		file = p.files[n-1]
+	}
+	_, inline := p.inline[file]
+	p.mu.RUnlock()
+	switch {
+	case file == "":
+		return fmt.Errorf("no config file loaded")
+	case inline || strings.HasPrefix(file, fsPrefix):
+		return fmt.Errorf("config %q was not loaded from a file on disk", file)
+	}
+	return p.Save(file)
+}
+
+// persisted returns the effective settings with the values resolved from
+// references replaced by the references, the interpolated values not
+// overridden since by their placeholders and, unless saveSensitive is set,
+// the sensitive keys by the values of the config files. Callers hold the
+// read lock.
+func (p *Parser) persisted() map[string]interface{} {
+	settings := p.effective()
+	if !p.saveSensitive {
+		p.keepFileSecrets(settings, "")
+	}
+	for k, ref := range p.info.refs {
+		if _, ok := lookupPath(settings, k); ok {
+			setPath(settings, k, ref)
+		}
+	}
+	for k, template := range p.info.templates {
+		if _, overridden := p.overridden(k); overridden {
+			continue
+		}
+		if _, ok := lookupPath(settings, k); ok {
+			setPath(settings, k, template)
+		}
+	}
+	return settings
+}
+
+// keepFileSecrets replaces the values of the sensitive keys of settings,
+// the settings at prefix, with the ones the config files hold, and removes
+// the keys they do not set. Callers hold the read lock.
+func (p *Parser) keepFileSecrets(settings map[string]interface{}, prefix string) {
+	for k, v := range settings {
+		key := joinPath(prefix, k)
+		if !p.isSensitive(key) {
+			if m, ok := v.(map[string]interface{}); ok {
+				p.keepFileSecrets(m, key)
+			}
+			continue
+		}
+		if fv, ok := lookupPathFold(p.layer, key); ok && fv != nil {
+			settings[k] = deepCopy(fv)
+		} else {
+			delete(settings, k)
+		}
+	}
+}
+
+// writeFileAtomic replaces the file at path with b through a temporary file
+// renamed over it
+func writeFileAtomic(path string, b []byte) error {
+	mode := os.FileMode(0o600)
+	if st, err := os.Stat(path); err == nil {
+		mode = st.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,204 @@
+package viper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_Save(t *testing.T) {
+	t.Setenv("SAVETEST_DB_PASSWORD", "hunter2")
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+server:
+  host: localhost
+  port: 8080
+db:
+  password: $ref{env:SAVETEST_DB_PASSWORD}
+`})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	p.Set("server.port", 9090)
+
+	for _, name := range []string{"out.json", "out.toml", "out.yml"} {
+		t.Run(name, func(t *testing.T) {
+			out := filepath.Join(dir, name)
+			if err := p.Save(out); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(b), "hunter2") || !strings.Contains(string(b), "$ref{env:SAVETEST_DB_PASSWORD}") {
+				t.Errorf("saved config = %s, want the reference instead of the secret", b)
+			}
+			saved := New()
+			if _, err := saved.Parse(out); err != nil {
+				t.Fatal(err)
+			}
+			if got := saved.GetInt("server.port"); got != 9090 {
+				t.Errorf("saved server.port = %d, want the value set", got)
+			}
+			if got := saved.GetString("db.password"); got != "hunter2" {
+				t.Errorf("saved db.password resolves to %q, want the secret", got)
+			}
+		})
+	}
+
+	t.Run("sub-tree", func(t *testing.T) {
+		out := filepath.Join(dir, "server.json")
+		if err := p.SaveKey("server", out); err != nil {
+			t.Fatal(err)
+		}
+		saved := New()
+		if _, err := saved.Parse(out); err != nil {
+			t.Fatal(err)
+		}
+		if got := saved.GetString("host"); got != "localhost" {
+			t.Errorf("host = %q, want the sub-tree at the root", got)
+		}
+		if err := p.SaveKey("server.port", out); err == nil {
+			t.Error("SaveKey() of a scalar succeeded")
+		}
+	})
+
+	t.Run("write back", func(t *testing.T) {
+		if err := os.Chmod(configFile, 0o640); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.WriteConfig(); err != nil {
+			t.Fatal(err)
+		}
+		st, err := os.Stat(configFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Mode().Perm() != 0o640 {
+			t.Errorf("mode = %v, want the mode of the file kept", st.Mode().Perm())
+		}
+		if _, err := p.Parse(configFile); err != nil {
+			t.Fatal(err)
+		}
+		p.ClearOverride("server.port")
+		if got := p.GetInt("server.port"); got != 9090 {
+			t.Errorf("server.port = %d after writing back, want 9090", got)
+		}
+	})
+
+	errTests := []struct {
+		name string
+		save func() error
+	}{
+		{"unknown type", func() error { return p.Save(filepath.Join(dir, "out.ini2")) }},
+		{"preprocessed extension", func() error { return p.Save(filepath.Join(dir, "out.yaml.gz")) }},
+		{"in-memory config", func() error {
+			p := New()
+			if _, err := p.ParseBytes([]byte("a: 1"), "yaml"); err != nil {
+				t.Fatal(err)
+			}
+			return p.WriteConfig()
+		}},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.save(); err == nil {
+				t.Error("save succeeded")
+			}
+		})
+	}
+}
+
+func TestParser_WriteConfig_keepsYAMLLayout(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": `# service settings
+server:
+  # where to listen
+  port: 8080 # default port
+  host: "localhost"
+defaults: &defaults
+  retries: 3
+primary:
+  <<: *defaults
+  name: primary
+legacy: true
+`})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	p.Set("server.port", 9090)
+	p.Set("server.debug", true)
+	if err := p.WriteConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# service settings
+server:
+  # where to listen
+  port: 9090 # default port
+  host: "localhost"
+  debug: true
+defaults: &defaults
+  retries: 3
+primary:
+  <<: *defaults
+  name: primary
+legacy: true
+`
+	if string(b) != want {
+		t.Errorf("written config =\n%s\nwant\n%s", b, want)
+	}
+}
+
+func TestParser_Save_SensitiveKeys(t *testing.T) {
+	t.Setenv("SAVESECRETTEST_API_TOKEN", "env-token")
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  host: localhost\n  password: file-password\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+	parse := func(opts ...Option) *Parser {
+		opts = append([]Option{WithEnvPrefix("savesecrettest"), WithDefaults(map[string]interface{}{"api": map[string]interface{}{"token": ""}})}, opts...)
+		p := New(opts...)
+		if _, err := p.Parse(configFile); err != nil {
+			t.Fatal(err)
+		}
+		p.Set("db.password", "set-password")
+		p.Set("db.host", "db.local")
+		return p
+	}
+
+	out := filepath.Join(dir, "out.yaml")
+	if err := parse().Save(out); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"env-token", "set-password"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("saved config = %s, want %s left out", b, secret)
+		}
+	}
+	if !strings.Contains(string(b), "file-password") || !strings.Contains(string(b), "db.local") {
+		t.Errorf("saved config = %s, want the password of the file and the host set", b)
+	}
+
+	if err := parse(WithSavedSensitiveValues()).Save(out); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = os.ReadFile(out); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"env-token", "set-password"} {
+		if !strings.Contains(string(b), secret) {
+			t.Errorf("saved config = %s, want %s with WithSavedSensitiveValues", b, secret)
+		}
+	}
+}
@@ -0,0 +1,554 @@
+package viper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WithSchema validates the settings read from the config files against a
+// JSON Schema document before they are installed, so Parse fails and Watch
+// keeps the previous config when they violate it. The supported keywords
+// are type, enum, const, properties, required, additionalProperties,
+// minProperties, maxProperties, items, minItems, maxItems, uniqueItems,
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf,
+// minLength, maxLength, pattern, allOf, anyOf, oneOf, not and $ref to
+// "#/definitions/..." or "#/$defs/...". Property names are matched ignoring
+// case, as keys are. Environment variables and overrides are not validated.
+//
+// A config file can also name its own schema, a local path relative to the
+// file or an http(s) URL, with a top-level "$schema" key. The settings are
+// then validated against it as well, so validation travels with the file.
+func WithSchema(schema []byte) Option {
+	return func(p *Parser) {
+		var doc interface{}
+		if err := json.Unmarshal(schema, &doc); err != nil {
+			p.schemaErr = fmt.Errorf("invalid schema: %w", err)
+			return
+		}
+		if err := checkSchema(doc, "#"); err != nil {
+			p.schemaErr = fmt.Errorf("invalid schema: %w", err)
+			return
+		}
+		p.schema = doc
+	}
+}
+
+// SchemaViolation is a single violation of the schema
+type SchemaViolation struct {
+	// Path is the dot-notation path of the offending value, empty for the
+	// root
+	Path string
+	// Message describes the violation
+	Message string
+	// Origin names the sources of the offending value, empty when it is
+	// not set
+	Origin string
+}
+
+func (v SchemaViolation) String() string {
+	msg := v.Message
+	if v.Origin != "" {
+		msg += " (from " + v.Origin + ")"
+	}
+	if v.Path == "" {
+		return msg
+	}
+	return v.Path + ": " + msg
+}
+
+// SchemaError lists every violation of the schema found in a config
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("config violates the schema: %s", strings.Join(msgs, "; "))
+}
+
+// validateSchema checks settings against the schema set with WithSchema and
+// the schemas named by the $schema keys of the files read
+func (p *Parser) validateSchema(ctx context.Context, settings map[string]interface{}) error {
+	if p.schemaErr != nil {
+		return p.schemaErr
+	}
+	schemas := make([]interface{}, 0, len(p.pending.schemas)+1)
+	if p.schema != nil {
+		schemas = append(schemas, p.schema)
+	}
+	for _, location := range p.pending.schemas {
+		doc, err := p.fileSchema(ctx, location)
+		if err != nil {
+			return err
+		}
+		schemas = append(schemas, doc)
+	}
+
+	violations := p.inheritedViolations(settings)
+	for _, schema := range schemas {
+		sv := &schemaValidator{root: schema, hidden: p.concealed}
+		sv.validate(schema, settings, "")
+		violations = append(violations, sv.violations...)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	for i := range violations {
+		if violations[i].Path != "" {
+			violations[i].Origin = p.pending.sourcesOf(violations[i].Path)
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return &SchemaError{Violations: violations}
+}
+
+// inheritedViolations checks the config a child parser would serve with
+// settings, its own settings merged on top of the inherited config, against
+// the schemas of its ancestors. Each schema describes the tree of its
+// parser, so the config is checked within that tree and only the
+// violations at or under the sub-tree of the child are reported, relative
+// to it. Callers hold p.mu.
+func (p *Parser) inheritedViolations(settings map[string]interface{}) []SchemaViolation {
+	var violations []SchemaViolation
+	tree, at := settings, ""
+	for c := p; c.parent != nil; c = c.parent {
+		c.parent.mu.RLock()
+		full := c.parent.settings()
+		c.parent.mu.RUnlock()
+		if c.prefix == "" {
+			tree = deepMerge(full, tree)
+		} else {
+			sub, _ := lookupPath(full, c.prefix)
+			inherited, _ := toStringMap(sub)
+			setPath(full, c.prefix, deepMerge(inherited, tree))
+			tree = full
+		}
+		if at == "" {
+			at = c.prefix
+		} else if c.prefix != "" {
+			at = c.prefix + "." + at
+		}
+
+		// the schema is set at construction, so it is read without the lock
+		if c.parent.schema == nil {
+			continue
+		}
+		parent, scope := c.parent, at
+		sv := &schemaValidator{root: parent.schema, hidden: func(path string) bool {
+			rel, ok := cutPath(path, scope)
+			return (ok && p.concealed(rel)) || parent.isSensitive(path)
+		}}
+		sv.validate(parent.schema, tree, "")
+		for _, v := range sv.violations {
+			if path, ok := cutPath(v.Path, at); ok {
+				v.Path = path
+				violations = append(violations, v)
+			}
+		}
+	}
+	return violations
+}
+
+// concealed reports whether the value of key, a path of the config
+// installed or being loaded, must not appear in errors: the values of
+// sensitive keys and of keys resolved from references. Callers hold p.mu.
+func (p *Parser) concealed(key string) bool {
+	key = strings.ToLower(key)
+	// references are recorded for the lists holding them
+	base, _, _ := strings.Cut(key, "[")
+	if _, ok := p.pending.refs[base]; ok {
+		return true
+	}
+	return p.isSensitive(key) || p.referenced(base)
+}
+
+// checkSchema rejects schemas using invalid patterns
+func checkSchema(schema interface{}, at string) error {
+	switch s := schema.(type) {
+	case map[string]interface{}:
+		if pattern, ok := s["pattern"].(string); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%s: %w", at, err)
+			}
+		}
+		for k, v := range s {
+			if err := checkSchema(v, at+"/"+k); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, v := range s {
+			if err := checkSchema(v, fmt.Sprintf("%s/%d", at, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	root       interface{}
+	violations []SchemaViolation
+	depth      int
+	// hidden reports the paths whose values must not appear in violations
+	hidden func(path string) bool
+}
+
+func (sv *schemaValidator) fail(path, format string, args ...interface{}) {
+	sv.violations = append(sv.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// shown returns v as violations print it, redacted for hidden paths
+func (sv *schemaValidator) shown(path string, v interface{}) interface{} {
+	if sv.hidden != nil && sv.hidden(path) {
+		return redacted
+	}
+	return v
+}
+
+// valid reports whether v matches schema without recording violations
+func (sv *schemaValidator) valid(schema, v interface{}, path string) bool {
+	sub := &schemaValidator{root: sv.root, depth: sv.depth, hidden: sv.hidden}
+	sub.validate(schema, v, path)
+	return len(sub.violations) == 0
+}
+
+func (sv *schemaValidator) validate(schema, v interface{}, path string) {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			sv.fail(path, "no value is allowed")
+		}
+		return
+	case map[string]interface{}:
+		sv.validateObject(s, v, path)
+	}
+}
+
+func (sv *schemaValidator) validateObject(s map[string]interface{}, v interface{}, path string) {
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := sv.resolve(ref)
+		if err != nil {
+			sv.fail(path, "%v", err)
+			return
+		}
+		// guard against schemas referencing themselves without consuming input
+		if sv.depth > 64 {
+			sv.fail(path, "$ref %s nests too deeply", ref)
+			return
+		}
+		sv.depth++
+		sv.validate(target, v, path)
+		sv.depth--
+	}
+
+	if t, ok := s["type"]; ok && !matchesType(t, v) {
+		sv.fail(path, "expected %s, got %s", describeType(t), jsonType(v))
+		return
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if schemaEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sv.fail(path, "%v is not one of %v", sv.shown(path, v), enum)
+		}
+	}
+	if c, ok := s["const"]; ok && !schemaEqual(c, v) {
+		sv.fail(path, "%v is not %v", sv.shown(path, v), c)
+	}
+
+	for _, sub := range schemaList(s["allOf"]) {
+		sv.validate(sub, v, path)
+	}
+	if anyOf := schemaList(s["anyOf"]); len(anyOf) > 0 {
+		matched := false
+		for _, sub := range anyOf {
+			if sv.valid(sub, v, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			sv.fail(path, "does not match any of the anyOf schemas")
+		}
+	}
+	if oneOf := schemaList(s["oneOf"]); len(oneOf) > 0 {
+		matched := 0
+		for _, sub := range oneOf {
+			if sv.valid(sub, v, path) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			sv.fail(path, "matches %d of the oneOf schemas, want exactly one", matched)
+		}
+	}
+	if not, ok := s["not"]; ok && sv.valid(not, v, path) {
+		sv.fail(path, "matches the schema it must not match")
+	}
+
+	if m, ok := toStringMap(v); ok {
+		sv.validateMap(s, m, path)
+	}
+	if list, ok := v.([]interface{}); ok {
+		sv.validateList(s, list, path)
+	}
+	if str, ok := v.(string); ok {
+		sv.validateString(s, str, path)
+	}
+	if f, ok := schemaNumber(v); ok {
+		sv.validateNumber(s, f, path)
+	}
+}
+
+func (sv *schemaValidator) validateMap(s map[string]interface{}, m map[string]interface{}, path string) {
+	props, _ := s["properties"].(map[string]interface{})
+	for _, name := range stringList(s["required"]) {
+		if _, ok := lookupFold(m, name); !ok {
+			sv.fail(joinPath(path, name), "is required")
+		}
+	}
+	if n, ok := schemaNumber(s["minProperties"]); ok && float64(len(m)) < n {
+		sv.fail(path, "has %d keys, want at least %v", len(m), n)
+	}
+	if n, ok := schemaNumber(s["maxProperties"]); ok && float64(len(m)) > n {
+		sv.fail(path, "has %d keys, want at most %v", len(m), n)
+	}
+
+	additional, hasAdditional := s["additionalProperties"]
+	for k, child := range m {
+		childPath := joinPath(path, k)
+		if prop, ok := lookupFold(props, k); ok {
+			sv.validate(prop, child, childPath)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok {
+			if !allowed {
+				sv.fail(childPath, "is not allowed")
+			}
+			continue
+		}
+		sv.validate(additional, child, childPath)
+	}
+}
+
+func (sv *schemaValidator) validateList(s map[string]interface{}, list []interface{}, path string) {
+	if items, ok := s["items"]; ok {
+		for i, item := range list {
+			sv.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	if n, ok := schemaNumber(s["minItems"]); ok && float64(len(list)) < n {
+		sv.fail(path, "has %d items, want at least %v", len(list), n)
+	}
+	if n, ok := schemaNumber(s["maxItems"]); ok && float64(len(list)) > n {
+		sv.fail(path, "has %d items, want at most %v", len(list), n)
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+		for i := range list {
+			for j := i + 1; j < len(list); j++ {
+				if schemaEqual(list[i], list[j]) {
+					sv.fail(path, "items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+}
+
+func (sv *schemaValidator) validateString(s map[string]interface{}, str, path string) {
+	length := float64(len([]rune(str)))
+	if n, ok := schemaNumber(s["minLength"]); ok && length < n {
+		sv.fail(path, "is %v characters long, want at least %v", length, n)
+	}
+	if n, ok := schemaNumber(s["maxLength"]); ok && length > n {
+		sv.fail(path, "is %v characters long, want at most %v", length, n)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		// patterns are checked by WithSchema
+		if re := regexp.MustCompile(pattern); !re.MatchString(str) {
+			sv.fail(path, "%q does not match %q", sv.shown(path, str), pattern)
+		}
+	}
+}
+
+func (sv *schemaValidator) validateNumber(s map[string]interface{}, f float64, path string) {
+	if n, ok := schemaNumber(s["minimum"]); ok && f < n {
+		sv.fail(path, "%v is less than the minimum %v", sv.shown(path, f), n)
+	}
+	if n, ok := schemaNumber(s["maximum"]); ok && f > n {
+		sv.fail(path, "%v is greater than the maximum %v", sv.shown(path, f), n)
+	}
+	if n, ok := schemaNumber(s["exclusiveMinimum"]); ok && f <= n {
+		sv.fail(path, "%v is not greater than %v", sv.shown(path, f), n)
+	}
+	if n, ok := schemaNumber(s["exclusiveMaximum"]); ok && f >= n {
+		sv.fail(path, "%v is not less than %v", sv.shown(path, f), n)
+	}
+	if n, ok := schemaNumber(s["multipleOf"]); ok && n > 0 {
+		if q := f / n; q != math.Trunc(q) {
+			sv.fail(path, "%v is not a multiple of %v", sv.shown(path, f), n)
+		}
+	}
+}
+
+// resolve returns the schema a local $ref points to
+func (sv *schemaValidator) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return sv.root, nil
+	}
+	rest, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %s, only local references are", ref)
+	}
+	node := sv.root
+	for _, part := range strings.Split(rest, "/") {
+		part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+		if node, ok = m[part]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+	}
+	return node, nil
+}
+
+func matchesType(t interface{}, v interface{}) bool {
+	for _, name := range stringList(t) {
+		actual := jsonType(v)
+		if name == actual || name == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func describeType(t interface{}) string {
+	return strings.Join(stringList(t), " or ")
+}
+
+// jsonType names the JSON Schema type of a decoded value
+func jsonType(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	if _, ok := toStringMap(v); ok {
+		return "object"
+	}
+	switch t := v.(type) {
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case time.Time, localTime:
+		// dates decoded by YAML and TOML are strings in JSON
+		return "string"
+	}
+	if f, ok := schemaNumber(v); ok {
+		if f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// schemaNumber converts the numbers found in configs and schemas
+func schemaNumber(v interface{}) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	if !isNumber(v) {
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return float64(rv.Uint()), true
+}
+
+// schemaEqual compares values the JSON way, where 1 and 1.0 are equal
+func schemaEqual(a, b interface{}) bool {
+	if fa, ok := schemaNumber(a); ok {
+		fb, ok := schemaNumber(b)
+		return ok && fa == fb
+	}
+	if ma, ok := toStringMap(a); ok {
+		mb, ok := toStringMap(b)
+		if !ok || len(ma) != len(mb) {
+			return false
+		}
+		for k, va := range ma {
+			if vb, ok := mb[k]; !ok || !schemaEqual(va, vb) {
+				return false
+			}
+		}
+		return true
+	}
+	if la, ok := a.([]interface{}); ok {
+		lb, ok := b.([]interface{})
+		if !ok || len(la) != len(lb) {
+			return false
+		}
+		for i := range la {
+			if !schemaEqual(la[i], lb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func schemaList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+// stringList reads keywords holding a string or a list of strings
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
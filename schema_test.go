@@ -0,0 +1,130 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["server", "name"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 2, "pattern": "^[a-z-]+$"},
+		"server": {
+			"type": "object",
+			"required": ["port"],
+			"properties": {
+				"port": {"type": "integer", "minimum": 1, "maximum": 65535},
+				"mode": {"enum": ["http", "grpc"]},
+				"ratio": {"type": "number", "exclusiveMaximum": 1}
+			}
+		},
+		"hosts": {"type": "array", "items": {"$ref": "#/$defs/host"}, "uniqueItems": true, "minItems": 1},
+		"started": {"type": "string"},
+		"extends": true
+	},
+	"$defs": {
+		"host": {"type": "string", "anyOf": [{"pattern": "^[a-z.]+$"}, {"const": "localhost:8080"}]}
+	}
+}`
+
+func TestWithSchema(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"valid.yaml": "name: api\nserver:\n  Port: 8080\n  mode: grpc\n  ratio: 0.5\nhosts: [a.local, localhost:8080]\nstarted: 2024-05-01\n",
+		"invalid.yaml": "name: API\nserver:\n  port: 70000\n  mode: soap\n  ratio: 1\n" +
+			"hosts: [a.local, a.local, \"B:1\"]\ntypo: true\n",
+		"missing.json": `{"server": {"port": "8080"}}`,
+		"base.yaml":    "name: base\nserver:\n  port: 1\n",
+		"child.yaml":   "extends: base.yaml\nserver:\n  port: 0\n",
+	})
+
+	p := New(WithSchema([]byte(testSchema)))
+	if _, err := p.Parse(filepath.Join(dir, "valid.yaml")); err != nil {
+		t.Fatalf("Parse() of a valid config error = %v", err)
+	}
+
+	invalid, missing, child := filepath.Join(dir, "invalid.yaml"), filepath.Join(dir, "missing.json"), filepath.Join(dir, "child.yaml")
+	tests := []struct {
+		file string
+		want []SchemaViolation
+	}{
+		{
+			file: "invalid.yaml",
+			want: []SchemaViolation{
+				{Path: "hosts", Message: "items 0 and 1 are equal", Origin: invalid},
+				{Path: "hosts[2]", Message: "does not match any of the anyOf schemas", Origin: invalid},
+				{Path: "name", Message: `"API" does not match "^[a-z-]+$"`, Origin: invalid},
+				{Path: "server.mode", Message: "soap is not one of [http grpc]", Origin: invalid},
+				{Path: "server.port", Message: "70000 is greater than the maximum 65535", Origin: invalid},
+				{Path: "server.ratio", Message: "1 is not less than 1", Origin: invalid},
+				{Path: "typo", Message: "is not allowed", Origin: invalid},
+			},
+		},
+		{
+			file: "missing.json",
+			want: []SchemaViolation{
+				{Path: "name", Message: "is required"},
+				{Path: "server.port", Message: "expected integer, got string", Origin: missing},
+			},
+		},
+		{
+			// the merged settings are validated
+			file: "child.yaml",
+			want: []SchemaViolation{
+				{Path: "server.port", Message: "0 is less than the minimum 1", Origin: child},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := New(WithSchema([]byte(testSchema))).Parse(filepath.Join(dir, tt.file))
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("Parse() error = %v, want a *SchemaError", err)
+			}
+			if !reflect.DeepEqual(schemaErr.Violations, tt.want) {
+				t.Errorf("violations =\n%v\nwant\n%v", schemaErr.Violations, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid schema", func(t *testing.T) {
+		for _, schema := range []string{`{`, `{"properties": {"a": {"pattern": "("}}}`} {
+			_, err := New(WithSchema([]byte(schema))).Parse(filepath.Join(dir, "valid.yaml"))
+			if err == nil || !strings.Contains(err.Error(), "invalid schema") {
+				t.Errorf("Parse() with schema %s error = %v, want an invalid schema error", schema, err)
+			}
+		}
+	})
+}
+
+func TestWithSchema_RedactsSecrets(t *testing.T) {
+	t.Setenv("SCHEMATEST_TIER", "t13r-v4lue")
+	schema := []byte(`{
+		"properties": {
+			"db": {"properties": {"password": {"pattern": "^[a-z]{16,}$"}, "port": {"maximum": 100}}},
+			"tier": {"enum": ["a", "b"]}
+		}
+	}`)
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": "db:\n  password: s3cr3t\n  port: 5432\ntier: $ref{env:SCHEMATEST_TIER}\n",
+	})
+	_, err := New(WithSchema(schema)).Parse(filepath.Join(dir, "config.yaml"))
+	if err == nil {
+		t.Fatal("Parse() succeeded, want schema violations")
+	}
+	msg := err.Error()
+	for _, secret := range []string{"s3cr3t", "t13r-v4lue"} {
+		if strings.Contains(msg, secret) {
+			t.Errorf("Parse() error = %q, leaks %q", msg, secret)
+		}
+	}
+	// values of other keys are still shown
+	if !strings.Contains(msg, "5432 is greater than the maximum 100") || strings.Count(msg, redacted) != 2 {
+		t.Errorf("Parse() error = %q, want the port shown and the secrets redacted", msg)
+	}
+}
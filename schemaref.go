@@ -0,0 +1,111 @@
+package viper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// schemaKey is the reserved key a config file uses to name the JSON Schema
+// it must validate against
+const schemaKey = "$schema"
+
+// schemaFetchTimeout bounds the download of a schema named by a URL
+const schemaFetchTimeout = 10 * time.Second
+
+// schemaCache holds the schemas downloaded for the $schema keys, by URL
+type schemaCache struct {
+	mu   sync.Mutex
+	docs map[string]interface{}
+}
+
+// popSchema removes the $schema key from the settings of configFile and
+// returns the location of the schema it names, made absolute for local
+// files
+func popSchema(configFile string, settings map[string]interface{}) (string, error) {
+	var location string
+	for k, v := range settings {
+		if !strings.EqualFold(k, schemaKey) {
+			continue
+		}
+		delete(settings, k)
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return "", fmt.Errorf("%q must be a file path or a URL, got %v", schemaKey, v)
+		}
+		location = s
+	}
+	if location == "" || strings.Contains(location, "://") {
+		return location, nil
+	}
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(filepath.Dir(configFile), location)
+	}
+	return location, nil
+}
+
+// fileSchema returns the schema document at location. Local files are read
+// on every load so edits apply on the next reload, downloaded schemas are
+// cached for the life of the parser.
+func (p *Parser) fileSchema(ctx context.Context, location string) (interface{}, error) {
+	remote := strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+	if remote {
+		p.schemas.mu.Lock()
+		doc, ok := p.schemas.docs[location]
+		p.schemas.mu.Unlock()
+		if ok {
+			return doc, nil
+		}
+	}
+
+	var b []byte
+	var err error
+	if remote {
+		b, err = fetchSchema(p.withSizeLimit(ctx), location)
+	} else {
+		b, err = p.readSourceFile(strings.TrimPrefix(location, "file://"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema %q: %w", location, err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("invalid schema %q: %w", location, err)
+	}
+	if err := checkSchema(doc, "#"); err != nil {
+		return nil, fmt.Errorf("invalid schema %q: %w", location, err)
+	}
+
+	if remote {
+		p.schemas.mu.Lock()
+		if p.schemas.docs == nil {
+			p.schemas.docs = make(map[string]interface{})
+		}
+		p.schemas.docs[location] = doc
+		p.schemas.mu.Unlock()
+	}
+	return doc, nil
+}
+
+func fetchSchema(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, schemaFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return readBody(ctx, resp.Body)
+}
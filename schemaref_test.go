@@ -0,0 +1,79 @@
+package viper
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const portSchema = `{
+  "type": "object",
+  "properties": {"port": {"type": "integer", "maximum": 65535}},
+  "required": ["port"]
+}`
+
+func TestParser_SchemaKey(t *testing.T) {
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write([]byte(portSchema))
+	}))
+	defer srv.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"schemas/port.json": portSchema,
+		"local.yaml":        "$schema: schemas/port.json\nport: 8080\n",
+		"invalid.yaml":      "$schema: schemas/port.json\nport: 70000\n",
+		"remote.yaml":       "$schema: " + srv.URL + "/port.json\nport: 70000\n",
+		"child.yaml":        "extends: invalid.yaml\nport: 443\n",
+		"missing.yaml":      "$schema: schemas/missing.json\nport: 1\n",
+		"bad.yaml":          "$schema: [a]\nport: 1\n",
+	})
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"valid", "local.yaml", ""},
+		{"violation", "invalid.yaml", "port: 70000 is greater than the maximum 65535"},
+		{"remote", "remote.yaml", "port: 70000 is greater than the maximum 65535"},
+		{"inherited", "child.yaml", ""},
+		{"missing schema", "missing.yaml", "error reading schema"},
+		{"invalid key", "bad.yaml", `"$schema" must be a file path or a URL`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			cfg, err := p.Parse(filepath.Join(dir, tt.file))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				if _, ok := cfg.Raw[schemaKey]; ok {
+					t.Errorf("settings = %v, want the %s key removed", cfg.Raw, schemaKey)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	fetches = 0
+	p := New()
+	for i := 0; i < 2; i++ {
+		_, err := p.Parse(filepath.Join(dir, "remote.yaml"))
+		var serr *SchemaError
+		if !errors.As(err, &serr) {
+			t.Fatalf("Parse() error = %v, want a *SchemaError", err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("schema fetched %d times, want once per parser", fetches)
+	}
+}
@@ -0,0 +1,110 @@
+package viper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// WithSearchPaths sets the directories Find and ParseName look for config
+// files in, in order of precedence. Environment variables and a leading ~
+// are expanded. The working directory is searched when none is given.
+func WithSearchPaths(paths ...string) Option {
+	return func(p *Parser) {
+		p.searchPaths = append(p.searchPaths, paths...)
+	}
+}
+
+// DefaultSearchPaths returns the conventional directories holding the
+// config of app, in order of precedence: the working directory, the user
+// config directory ($XDG_CONFIG_HOME/app or its platform equivalent) and
+// /etc/app
+func DefaultSearchPaths(app string) []string {
+	paths := []string{"."}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, app))
+	}
+	return append(paths, filepath.Join("/etc", app))
+}
+
+// ConfigNotFoundError reports that no config file of the given name was
+// found in the search paths
+type ConfigNotFoundError struct {
+	Name  string
+	Paths []string
+}
+
+func (e *ConfigNotFoundError) Error() string {
+	return fmt.Sprintf("config %q not found in %s", e.Name, strings.Join(e.Paths, ", "))
+}
+
+// Find returns the config file called name in the search paths. Names
+// without an extension match any supported config type. The first search
+// path holding a match wins; within a directory, the types are tried in
+// the order of viper.SupportedExts, then the types of the codecs registered
+// with WithCodec in lexical order. It fails with a *ConfigNotFoundError
+// when no path holds one.
+func (p *Parser) Find(name string) (string, error) {
+	paths := p.searchPaths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	candidates := []string{name}
+	if filepath.Ext(name) == "" {
+		candidates = nil
+		for _, ext := range p.configExts() {
+			candidates = append(candidates, name+"."+ext)
+		}
+	}
+
+	searched := make([]string, 0, len(paths))
+	for _, dir := range paths {
+		dir = expandPath(dir)
+		searched = append(searched, dir)
+		for _, c := range candidates {
+			file := filepath.Join(dir, c)
+			if st, err := os.Stat(file); err == nil && !st.IsDir() {
+				return file, nil
+			}
+		}
+	}
+	return "", &ConfigNotFoundError{Name: name, Paths: searched}
+}
+
+// ParseName parses the config file Find locates for name
+func (p *Parser) ParseName(name string) (*Config, error) {
+	file, err := p.Find(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.Parse(file)
+}
+
+// configExts returns the config types Find tries, in order of precedence
+func (p *Parser) configExts() []string {
+	exts := append([]string(nil), viper.SupportedExts...)
+	var extra []string
+	for typ := range p.codecs {
+		if !slices.Contains(exts, typ) {
+			extra = append(extra, typ)
+		}
+	}
+	sort.Strings(extra)
+	return append(exts, extra...)
+}
+
+// expandPath expands the environment variables of path and its leading ~
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,52 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_Find(t *testing.T) {
+	local := writeFiles(t, map[string]string{"app.toml": "log = 'local'\n"})
+	home := writeFiles(t, map[string]string{
+		"nexen/config.yaml": "log: home\n",
+		"nexen/config.json": `{"log": "home-json"}`,
+		"nexen/app.toml":    "log = 'home'\n",
+	})
+	system := writeFiles(t, map[string]string{"config.yaml": "log: system\n", "other.ini": "[a]\nb=1\n"})
+	t.Setenv("FINDTEST_HOME", home)
+	p := New(WithSearchPaths(local, "$FINDTEST_HOME/nexen", system))
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"config", filepath.Join(home, "nexen", "config.json")},
+		{"config.yaml", filepath.Join(home, "nexen", "config.yaml")},
+		{"app", filepath.Join(local, "app.toml")},
+		{"other", filepath.Join(system, "other.ini")},
+	}
+	for _, tt := range tests {
+		got, err := p.Find(tt.name)
+		if err != nil {
+			t.Errorf("Find(%q) error = %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Find(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	_, err := p.Find("missing")
+	var notFound *ConfigNotFoundError
+	if !errors.As(err, &notFound) || len(notFound.Paths) != 3 || notFound.Paths[1] != filepath.Join(home, "nexen") {
+		t.Errorf("Find() error = %#v, want a *ConfigNotFoundError listing the expanded paths", err)
+	}
+
+	if _, err := p.ParseName("app"); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("log"); got != "local" {
+		t.Errorf("log = %q, want the config of the first search path", got)
+	}
+}
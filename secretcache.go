@@ -0,0 +1,125 @@
+package viper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"sync"
+)
+
+// SecretCache stores the values of the resolved references between two
+// loads, so each reference is resolved once per load
+type SecretCache interface {
+	Get(ref string) (string, bool)
+	Set(ref, value string)
+	// Reset drops every value, at the start of each load
+	Reset()
+}
+
+// WithSecretCache replaces the plain in-memory cache of the resolved
+// references, as with an EncryptedSecretCache
+func WithSecretCache(c SecretCache) Option {
+	return func(p *Parser) {
+		p.refs = c
+	}
+}
+
+// EncryptedSecretCache is a SecretCache keeping the resolved values
+// encrypted with AES-GCM under a random key generated for the process, so
+// they do not appear in clear in heap dumps. Values are only decrypted while
+// a reference is being resolved. Combine it with WithLazyResolution so the
+// parsed settings do not hold the resolved values either.
+type EncryptedSecretCache struct {
+	mu     sync.Mutex
+	key    []byte
+	aead   cipher.AEAD
+	values map[string][]byte
+}
+
+// NewEncryptedSecretCache returns an empty cache with a fresh key
+func NewEncryptedSecretCache() (*EncryptedSecretCache, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedSecretCache{key: key, aead: aead, values: make(map[string][]byte)}, nil
+}
+
+// Get decrypts the value cached for ref
+func (c *EncryptedSecretCache) Get(ref string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sealed, ok := c.values[ref]
+	if !ok || c.aead == nil {
+		return "", false
+	}
+	n := c.aead.NonceSize()
+	plain, err := c.aead.Open(nil, sealed[:n], sealed[n:], []byte(ref))
+	if err != nil {
+		return "", false
+	}
+	v := string(plain)
+	zero(plain)
+	return v, true
+}
+
+// Set encrypts and caches the value of ref. It does nothing once the cache
+// is closed.
+func (c *EncryptedSecretCache) Set(ref, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aead == nil {
+		return
+	}
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(value)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return
+	}
+	if old, ok := c.values[ref]; ok {
+		zero(old)
+	}
+	c.values[ref] = c.aead.Seal(nonce, nonce, []byte(value), []byte(ref))
+}
+
+// Reset zeroizes and drops every cached value
+func (c *EncryptedSecretCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+}
+
+func (c *EncryptedSecretCache) reset() {
+	for ref, sealed := range c.values {
+		zero(sealed)
+		delete(c.values, ref)
+	}
+}
+
+// Close zeroizes the cached values and the key. The cache stays usable but
+// no longer caches anything.
+func (c *EncryptedSecretCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aead == nil {
+		return errors.New("secret cache already closed")
+	}
+	c.reset()
+	zero(c.key)
+	c.aead = nil
+	return nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,64 @@
+package viper
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedSecretCache(t *testing.T) {
+	cache, err := NewEncryptedSecretCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	resolver := ResolverFunc(func(_ context.Context, ref string) (string, error) {
+		calls++
+		return "s3cret-" + ref, nil
+	})
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  password: $ref{secret:db}\n  replica: $ref{secret:db}\n"})
+	p := New(WithResolver("secret", resolver), WithSecretCache(cache), WithLazyResolution())
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"db.password", "db.replica", "db.password"} {
+		if got := p.GetString(key); got != "s3cret-db" {
+			t.Errorf("%s = %q, want s3cret-db", key, got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("resolver called %d times, want the cached value reused", calls)
+	}
+
+	sealed := cache.values["secret:db"]
+	if len(sealed) == 0 || bytes.Contains(sealed, []byte("s3cret")) {
+		t.Errorf("cached value %q, want it encrypted", sealed)
+	}
+
+	// a reload resolves the references again
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	p.GetString("db.password")
+	if calls != 2 {
+		t.Errorf("resolver called %d times after a reload, want 2", calls)
+	}
+
+	sealed = cache.values["secret:db"]
+	key := cache.key
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.values) != 0 || !bytes.Equal(sealed, make([]byte, len(sealed))) || !bytes.Equal(key, make([]byte, len(key))) {
+		t.Error("Close() did not zeroize the cache")
+	}
+	cache.Set("secret:db", "again")
+	if _, ok := cache.Get("secret:db"); ok {
+		t.Error("Get() after Close() found a value")
+	}
+	if err := cache.Close(); err == nil {
+		t.Error("second Close() succeeded")
+	}
+}
@@ -0,0 +1,166 @@
+package viper
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// parserSection is the key of the section reconfiguring the parser
+const parserSection = "parser"
+
+// WithSelfConfig lets the loaded config reconfigure the parser through its
+// reserved parser section:
+//
+//	parser:
+//	  min_reload_interval: 5s
+//	  duplicate_keys: warn # error, warn or allow
+//	  conflicts: error     # warn, error or allow
+//	  yaml11_tagged_booleans: true
+//	  sensitive_keys: ["*dsn*"]
+//
+// The settings of the section replace the ones given as options, sensitive
+// keys being added to them, and revert once the section is gone. The
+// section is validated on every load: unknown keys or invalid values fail
+// it. Its keys have provenance like any other. The sensitive keys and the
+// reload interval apply as soon as the config is installed; the decoding
+// settings apply from the next load, the section being only known once
+// decoded.
+func WithSelfConfig() Option {
+	return func(p *Parser) {
+		p.selfConfig = true
+	}
+}
+
+// selfSettings holds the settings of the parser section. Nil fields keep
+// the settings given as options.
+type selfSettings struct {
+	minReloadInterval    *time.Duration
+	duplicateKeys        *DuplicateKeyPolicy
+	conflicts            *ConflictPolicy
+	yaml11TaggedBooleans *bool
+	sensitive            []string
+}
+
+// selfSection is the parser section as written in the config
+type selfSection struct {
+	MinReloadInterval    *time.Duration `mapstructure:"min_reload_interval"`
+	DuplicateKeys        *string        `mapstructure:"duplicate_keys"`
+	Conflicts            *string        `mapstructure:"conflicts"`
+	YAML11TaggedBooleans *bool          `mapstructure:"yaml11_tagged_booleans"`
+	SensitiveKeys        []string       `mapstructure:"sensitive_keys"`
+}
+
+var duplicateKeyPolicies = map[string]DuplicateKeyPolicy{
+	"error": DuplicateKeysError,
+	"warn":  DuplicateKeysWarn,
+	"allow": DuplicateKeysAllow,
+}
+
+var conflictPolicies = map[string]ConflictPolicy{
+	"warn":  ConflictsWarn,
+	"error": ConflictsError,
+	"allow": ConflictsAllow,
+}
+
+// readSelfConfig decodes and validates the parser section of settings, if
+// any
+func (p *Parser) readSelfConfig(settings map[string]interface{}) (*selfSettings, error) {
+	if !p.selfConfig {
+		return nil, nil
+	}
+	raw, ok := lookupPath(lowerKeys(settings), parserSection)
+	if !ok {
+		return nil, nil
+	}
+	var section selfSection
+	var md mapstructure.Metadata
+	if err := p.decodeWith(raw, &section, &md); err != nil {
+		return nil, fmt.Errorf("%s section: %w", parserSection, err)
+	}
+	if len(md.Unused) > 0 {
+		keys := make([]string, len(md.Unused))
+		for i, k := range md.Unused {
+			keys[i] = joinPath(parserSection, strings.ToLower(k))
+		}
+		sort.Strings(keys)
+		return nil, &UnknownKeysError{Keys: keys}
+	}
+
+	s := &selfSettings{
+		minReloadInterval:    section.MinReloadInterval,
+		yaml11TaggedBooleans: section.YAML11TaggedBooleans,
+	}
+	if s.minReloadInterval != nil && *s.minReloadInterval < 0 {
+		return nil, fmt.Errorf("%s.min_reload_interval: %v is negative", parserSection, *s.minReloadInterval)
+	}
+	if section.DuplicateKeys != nil {
+		policy, ok := duplicateKeyPolicies[strings.ToLower(*section.DuplicateKeys)]
+		if !ok {
+			return nil, fmt.Errorf("%s.duplicate_keys: unknown policy %q", parserSection, *section.DuplicateKeys)
+		}
+		s.duplicateKeys = &policy
+	}
+	if section.Conflicts != nil {
+		policy, ok := conflictPolicies[strings.ToLower(*section.Conflicts)]
+		if !ok {
+			return nil, fmt.Errorf("%s.conflicts: unknown policy %q", parserSection, *section.Conflicts)
+		}
+		s.conflicts = &policy
+	}
+	for _, pattern := range section.SensitiveKeys {
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s.sensitive_keys: %q: %w", parserSection, pattern, err)
+		}
+		s.sensitive = append(s.sensitive, pattern)
+	}
+	return s, nil
+}
+
+// The accessors below return the settings in effect, the parser section
+// of the installed config taking precedence over the options. Callers hold
+// the read lock.
+
+func (p *Parser) duplicateKeyPolicy() DuplicateKeyPolicy {
+	if s := p.info.self; s != nil && s.duplicateKeys != nil {
+		return *s.duplicateKeys
+	}
+	return p.duplicateKeys
+}
+
+func (p *Parser) mergeConflictPolicy() ConflictPolicy {
+	if s := p.info.self; s != nil && s.conflicts != nil {
+		return *s.conflicts
+	}
+	return p.conflictPolicy
+}
+
+func (p *Parser) taggedBooleans() bool {
+	if s := p.info.self; s != nil && s.yaml11TaggedBooleans != nil {
+		return *s.yaml11TaggedBooleans
+	}
+	return p.yaml11TaggedBooleans
+}
+
+func (p *Parser) sensitivePatterns() []string {
+	if s := p.info.self; s != nil && len(s.sensitive) > 0 {
+		return append(append([]string(nil), p.sensitive...), s.sensitive...)
+	}
+	return p.sensitive
+}
+
+// reloadInterval returns the minimum interval between watched reloads. It
+// takes the read lock.
+func (p *Parser) reloadInterval() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if s := p.info.self; s != nil && s.minReloadInterval != nil {
+		return *s.minReloadInterval
+	}
+	return p.minReloadInterval
+}
@@ -0,0 +1,80 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithSelfConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+parser:
+  min_reload_interval: 3s
+  duplicate_keys: allow
+  sensitive_keys: ["*dsn*"]
+db:
+  dsn: postgres://app:pw@db
+`,
+		"dups.yaml":    "log: info\nlog: debug\n",
+		"unknown.yaml": "parser:\n  reload: 1s\n",
+		"invalid.yaml": "parser:\n  duplicate_keys: sometimes\n",
+	})
+	p := New(WithSelfConfig(), WithMinReloadInterval(time.Second))
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.reloadInterval(); got != 3*time.Second {
+		t.Errorf("reload interval = %v, want the one of the parser section", got)
+	}
+	if ch := p.Diff(nil, map[string]interface{}{"db": map[string]interface{}{"dsn": "x"}}).Added["db.dsn"]; !ch.Sensitive {
+		t.Error("db.dsn not redacted, want the sensitive keys of the section applied")
+	}
+	p.mu.RLock()
+	origin := p.origin("parser.duplicate_keys")
+	p.mu.RUnlock()
+	if origin != filepath.Join(dir, "config.yaml") {
+		t.Errorf("origin of parser.duplicate_keys = %q, want the config file", origin)
+	}
+
+	// the duplicates policy of the installed section applies to this load,
+	// which drops the section and reverts to the options
+	if _, err := p.Parse(filepath.Join(dir, "dups.yaml")); err != nil {
+		t.Fatalf("Parse() error = %v, want the duplicates allowed by the section", err)
+	}
+	if got := p.reloadInterval(); got != time.Second {
+		t.Errorf("reload interval = %v once the section is gone, want the option", got)
+	}
+	var dupErr *DuplicateKeyError
+	if _, err := p.Parse(filepath.Join(dir, "dups.yaml")); !errors.As(err, &dupErr) {
+		t.Errorf("Parse() error = %v, want the default duplicates policy back", err)
+	}
+
+	errTests := []struct {
+		file string
+		want string
+	}{
+		{"unknown.yaml", "unknown config keys: parser.reload"},
+		{"invalid.yaml", `parser.duplicate_keys: unknown policy "sometimes"`},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := New(WithSelfConfig()).Parse(filepath.Join(dir, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		p := New()
+		if _, err := p.Parse(filepath.Join(dir, "unknown.yaml")); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.GetString("parser.reload"); got != "1s" {
+			t.Errorf("parser.reload = %q, want the section read as plain config", got)
+		}
+	})
+}
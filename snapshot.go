@@ -0,0 +1,57 @@
+package viper
+
+import "github.com/spf13/viper"
+
+// Snapshot returns a copy of the effective config, every value read the way
+// Get reads it at the same instant, so related keys read from it are
+// consistent with each other even when a reload happens meanwhile, as in
+// a request handler reading a host and its port. The copy is detached from
+// the parser: reloads, overrides and changes to its maps do not reach it,
+// and its Viper instance serves the copied values alone, without
+// environment lookups.
+func (p *Parser) Snapshot() *Config {
+	var settings map[string]interface{}
+	var prefix string
+	var mapper EnvKeyMapper
+	p.reading(func() {
+		settings = p.effective()
+		prefix = p.v.GetEnvPrefix()
+		mapper = p.env.mapper
+	})
+
+	v := viper.New()
+	v.SetEnvPrefix(prefix)
+	// the viper instance holds its own copy, so changes to Raw stay in Raw
+	_ = v.MergeConfigMap(deepCopy(settings).(map[string]interface{}))
+	return &Config{
+		Raw:       deepCopy(settings).(map[string]interface{}),
+		Viper:     v,
+		envMapper: mapper,
+	}
+}
+
+// deepCopy returns a copy of v sharing no map or slice with it
+func deepCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			out[k] = deepCopy(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, child := range t {
+			out[i] = deepCopy(child)
+		}
+		return out
+	case []string:
+		return append([]string(nil), t...)
+	case []byte:
+		return append([]byte(nil), t...)
+	}
+	if m, ok := toStringMap(v); ok {
+		return deepCopy(m)
+	}
+	return v
+}
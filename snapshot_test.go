@@ -0,0 +1,72 @@
+package viper
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestParser_Snapshot(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": "db:\n  host: a\n  port: 1\n  replicas: [r1, r2]\n",
+	})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	p.Set("db.user", "admin")
+
+	snap := p.Snapshot()
+	if got := snap.Viper.GetString("db.user"); got != "admin" {
+		t.Errorf("db.user = %q, want the override", got)
+	}
+	if got := snap.Viper.GetEnvPrefix(); got != "nexen" {
+		t.Errorf("env prefix = %q, want nexen", got)
+	}
+
+	replaceFile(t, configFile, "db:\n  host: b\n  port: 2\n")
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	p.Set("db.user", "root")
+	if got := snap.Viper.GetString("db.host") + ":" + snap.Viper.GetString("db.port"); got != "a:1" {
+		t.Errorf("snapshot db = %s after a reload, want a:1", got)
+	}
+	if got := snap.Viper.GetString("db.user"); got != "admin" {
+		t.Errorf("snapshot db.user = %q after a new override, want admin", got)
+	}
+
+	snap.Raw["db"].(map[string]interface{})["replicas"].([]interface{})[0] = "changed"
+	if got := snap.Viper.GetStringSlice("db.replicas"); got[0] != "r1" {
+		t.Errorf("db.replicas = %v in Viper after changing Raw, want them kept", got)
+	}
+	if got := p.Snapshot().Raw["db"].(map[string]interface{})["host"]; got != "b" {
+		t.Errorf("new snapshot db.host = %v, want b", got)
+	}
+}
+
+func TestParser_Snapshot_Consistent(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "pair:\n  a: 0\n  b: 0\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 50; i++ {
+			p.Set("pair", map[string]interface{}{"a": i, "b": i})
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		snap := p.Snapshot()
+		if a, b := snap.Viper.GetInt("pair.a"), snap.Viper.GetInt("pair.b"); a != b {
+			t.Fatalf("snapshot a = %d, b = %d, want the same update", a, b)
+		}
+	}
+	wg.Wait()
+}
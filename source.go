@@ -0,0 +1,156 @@
+package viper
+
+import (
+	"context"
+	"fmt"
+)
+
+// Source supplies settings from somewhere other than a config file, such as
+// a remote config backend
+type Source interface {
+	Load(ctx context.Context) (map[string]interface{}, error)
+}
+
+// SourceFunc adapts a function to the Source interface
+type SourceFunc func(ctx context.Context) (map[string]interface{}, error)
+
+// Load calls f(ctx)
+func (f SourceFunc) Load(ctx context.Context) (map[string]interface{}, error) {
+	return f(ctx)
+}
+
+// WatchableSource is a source able to report its changes, as by polling or
+// long polling its backend
+type WatchableSource interface {
+	Source
+	// Watch blocks until ctx is done, calling onChange whenever the
+	// settings may have changed and onError for the failures it recovers
+	// from
+	Watch(ctx context.Context, onChange func(), onError func(error))
+}
+
+// namedSource is a source registered with WithSource or
+// WithBootstrapSource
+type namedSource struct {
+	name string
+	src  Source
+	// bootstrap builds src from the settings read before it, if set
+	bootstrap *bootstrap
+}
+
+// WithSource merges the settings loaded from src on top of the config files
+// on every load and reload, sources registered later overriding earlier
+// ones. Keys are case-insensitive. The keys it sets are attributed to name in provenance. A failing
+// source fails the load.
+func WithSource(name string, src Source) Option {
+	return func(p *Parser) {
+		p.sources = append(p.sources, namedSource{name: name, src: src})
+	}
+}
+
+// readSources merges the settings of the registered sources on top of
+// settings
+func (p *Parser) readSources(ctx context.Context, settings map[string]interface{}) (map[string]interface{}, error) {
+	for i := range p.sources {
+		s := &p.sources[i]
+		src := s.src
+		if s.bootstrap != nil {
+			var err error
+			if src, err = p.bootstrapSource(ctx, s, settings); err != nil {
+				return nil, fmt.Errorf("error loading source %q: %w", s.name, err)
+			}
+		}
+		sctx, span := p.startSpan(ctx, "viper.LoadSource", sourceAttr.String(s.name))
+		loaded, err := src.Load(sctx)
+		endSpan(span, err)
+		if err != nil {
+			return nil, fmt.Errorf("error loading source %q: %w", s.name, err)
+		}
+		if err := p.checkLimits(loaded); err != nil {
+			return nil, fmt.Errorf("error loading source %q: %w", s.name, err)
+		}
+		if loaded, err = p.normalizeKeys(loaded); err != nil {
+			return nil, fmt.Errorf("error loading source %q: %w", s.name, err)
+		}
+		loaded = lowerKeys(loaded)
+		if err := p.pending.setOrigin(s.name, loaded); err != nil {
+			return nil, err
+		}
+		settings = p.merge(settings, loaded)
+	}
+	return settings, nil
+}
+
+// WatchSource watches the source registered under name, which must be a
+// WatchableSource. Every change it reports reloads the config files and
+// sources loaded by the last Parse or ParseAll and calls callback, the way
+// Watch does for files, and is subject to the same approval, component
+// pipeline and change notifications. The watch is stopped with StopWatch.
+func (p *Parser) WatchSource(name string, callback func()) error {
+	var src WatchableSource
+	p.mu.RLock()
+	if err := p.checkOpen(); err != nil {
+		p.mu.RUnlock()
+		return err
+	}
+	for _, s := range p.sources {
+		if s.name != name {
+			continue
+		}
+		if s.src == nil {
+			p.mu.RUnlock()
+			return fmt.Errorf("source %q is not bootstrapped yet", name)
+		}
+		ws, ok := s.src.(WatchableSource)
+		if !ok {
+			p.mu.RUnlock()
+			return fmt.Errorf("source %q cannot be watched", name)
+		}
+		src = ws
+	}
+	p.mu.RUnlock()
+	if src == nil {
+		return fmt.Errorf("unknown source %q", name)
+	}
+
+	p.watchBackground(name, src, callback)
+	return nil
+}
+
+// backgroundWatcher is a backend reporting its changes from a goroutine
+type backgroundWatcher interface {
+	Watch(ctx context.Context, onChange func(), onError func(error))
+}
+
+// watchBackground runs w until StopWatch(name), reloading the config and
+// calling callback on every change it reports
+func (p *Parser) watchBackground(name string, w backgroundWatcher, callback func()) {
+	p.watchMu.Lock()
+	defer p.watchMu.Unlock()
+	if stop, exists := p.watches[name]; exists {
+		stop()
+		delete(p.watches, name)
+	}
+
+	files := func() []string { return append([]string(nil), p.files...) }
+	apply := p.reloader(name, files, func(ChangeSet, error) {
+		if callback != nil {
+			callback()
+		}
+	})
+	limiter := &throttle{interval: p.reloadInterval}
+	ctx, cancel := context.WithCancel(p.withSizeLimit(context.Background()))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Watch(ctx, func() { limiter.run(apply) }, func(err error) {
+			p.logger.Warn("config source watcher error", "source", name, "error", err)
+		})
+	}()
+
+	p.watches[name] = func() {
+		cancel()
+		<-done
+		limiter.stop()
+	}
+}
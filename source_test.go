@@ -0,0 +1,61 @@
+package viper
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_WithSource(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "server:\n  host: localhost\n  port: 8080\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	calls := 0
+	remote := SourceFunc(func(context.Context) (map[string]interface{}, error) {
+		calls++
+		return map[string]interface{}{"Server": map[string]interface{}{"Port": calls}}, nil
+	})
+	p := New(WithSource("remote", remote))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetString("server.host"); got != "localhost" {
+		t.Errorf("server.host = %q, want the file value", got)
+	}
+	if got := p.GetInt("server.port"); got != 1 {
+		t.Errorf("server.port = %v, want the source value 1", got)
+	}
+	p.mu.RLock()
+	origin := p.origin("server.port")
+	p.mu.RUnlock()
+	if origin != "remote" {
+		t.Errorf("origin of server.port = %q, want remote", origin)
+	}
+
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.GetInt("server.port"); got != 2 {
+		t.Errorf("server.port = %v after a reload, want 2", got)
+	}
+
+	failing := SourceFunc(func(context.Context) (map[string]interface{}, error) {
+		return nil, errors.New("backend down")
+	})
+	_, err := New(WithSource("remote", failing)).Parse(configFile)
+	if err == nil || !strings.Contains(err.Error(), `source "remote": backend down`) {
+		t.Errorf("Parse() error = %v, want the source error", err)
+	}
+}
+
+func TestParser_WatchSource(t *testing.T) {
+	p := New(WithSource("static", SourceFunc(nil)))
+	if err := p.WatchSource("static", nil); err == nil {
+		t.Error("WatchSource() of a source that cannot be watched succeeded")
+	}
+	if err := p.WatchSource("unknown", nil); err == nil {
+		t.Error("WatchSource() of an unknown source succeeded")
+	}
+}
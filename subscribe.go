@@ -0,0 +1,36 @@
+package viper
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Subscribe calls callback whenever a load, a reload or an override changes
+// the value of key, with its previous and new value. Subscribing to a
+// parent key, like "server", reports the changes of any key below it with
+// the whole subtree. The returned function cancels the subscription.
+func (p *Parser) Subscribe(key string, callback func(old, new interface{})) (cancel func()) {
+	var mu sync.Mutex
+	cancelled := false
+	last := p.Get(key)
+	remove := p.onChange(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if cancelled {
+			return
+		}
+		v := p.Get(key)
+		if reflect.DeepEqual(v, last) {
+			return
+		}
+		old := last
+		last = v
+		callback(old, v)
+	})
+	return func() {
+		remove()
+		mu.Lock()
+		defer mu.Unlock()
+		cancelled = true
+	}
+}
@@ -0,0 +1,82 @@
+package viper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParser_Subscribe(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"v1.yaml": "server:\n  port: 8080\n  host: a.local\nlog: info\n",
+		"v2.yaml": "server:\n  port: 8080\n  host: b.local\nlog: debug\n",
+	})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "v1.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	type event struct{ old, new interface{} }
+	var port, server []event
+	cancelPort := p.Subscribe("server.port", func(old, new interface{}) {
+		port = append(port, event{old, new})
+	})
+	p.Subscribe("server", func(old, new interface{}) {
+		server = append(server, event{old, new})
+	})
+
+	// log and server.host change, server.port does not
+	if _, err := p.Parse(filepath.Join(dir, "v2.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if len(port) != 0 {
+		t.Errorf("server.port events = %v, want none", port)
+	}
+	wantServer := []event{{
+		map[string]interface{}{"port": 8080, "host": "a.local"},
+		map[string]interface{}{"port": 8080, "host": "b.local"},
+	}}
+	if !reflect.DeepEqual(server, wantServer) {
+		t.Errorf("server events = %v, want %v", server, wantServer)
+	}
+
+	if err := p.Override("server.port", 9090); err != nil {
+		t.Fatal(err)
+	}
+	if want := []event{{8080, 9090}}; !reflect.DeepEqual(port, want) {
+		t.Errorf("server.port events = %v, want %v", port, want)
+	}
+
+	cancelPort()
+	if err := p.Override("server.port", 9191); err != nil {
+		t.Fatal(err)
+	}
+	if len(port) != 1 {
+		t.Errorf("server.port events after cancel = %v, want no new event", port)
+	}
+}
+
+func TestParser_SubscribeCancelRemovesListener(t *testing.T) {
+	p := New()
+	cancels := make([]func(), 3)
+	for i := range cancels {
+		cancels[i] = p.Subscribe("port", func(old, new interface{}) {})
+	}
+	var changes int
+	p.OnChange(func() { changes++ })
+
+	cancels[1]()
+	cancels[1]()
+	if len(p.listeners) != 3 {
+		t.Fatalf("%d listeners after a cancel, want 3", len(p.listeners))
+	}
+	cancels[0]()
+	cancels[2]()
+	if len(p.listeners) != 1 {
+		t.Fatalf("%d listeners after cancelling every subscription, want 1", len(p.listeners))
+	}
+	p.Override("port", 80)
+	if changes != 1 {
+		t.Errorf("OnChange callback called %d times, want 1", changes)
+	}
+}
@@ -0,0 +1,234 @@
+package viper
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// historySize is the number of loads kept for support bundles
+const historySize = 50
+
+// redacted replaces sensitive values in exported configs
+const redacted = "[REDACTED]"
+
+// ReloadRecord describes one load of a config file
+type ReloadRecord struct {
+	Time time.Time `json:"time"`
+	File string    `json:"file"`
+	// Error is set when the load failed and the previous config was kept,
+	// with the values of sensitive and referenced keys redacted
+	Error string `json:"error,omitempty"`
+	// Changed lists the keys the load changed
+	Changed []string `json:"changed,omitempty"`
+}
+
+// bundleStatus reports whether the config currently served is the one on disk
+type bundleStatus struct {
+	Valid     bool      `json:"valid"`
+	Error     string    `json:"error,omitempty"`
+	File      string    `json:"file,omitempty"`
+	LastLoad  time.Time `json:"last_load,omitempty"`
+	Generated time.Time `json:"generated"`
+}
+
+// recordLoad appends a load to the history, journals the changes it
+// applied and keeps the config it put in place for Rollback. prev holds the
+// parser's own settings before the load. Callers hold p.mu.
+func (p *Parser) recordLoad(configFile string, prev map[string]interface{}, err error) {
+	rec := ReloadRecord{Time: time.Now(), File: configFile}
+	if err != nil {
+		rec.Error = p.scrub(err.Error())
+	} else {
+		own := lowerKeys(p.own)
+		sum := fingerprint(own)
+		changes := p.diff(lowerKeys(prev), own)
+		rec.Changed = changes.Keys()
+		p.journalChange(rec.Time, configFile, sum, changes)
+		p.addSnapshot(rec.Time, configFile, sum)
+	}
+	if len(p.history) == historySize {
+		copy(p.history, p.history[1:])
+		p.history = p.history[:historySize-1]
+	}
+	p.history = append(p.history, rec)
+}
+
+// scrub redacts the values of the sensitive and referenced keys of the
+// config in place and of the last load read from msg. Callers hold p.mu.
+func (p *Parser) scrub(msg string) string {
+	for _, secret := range slices.Concat(p.pending.secrets, p.info.secrets) {
+		msg = strings.ReplaceAll(msg, secret, redacted)
+	}
+	return msg
+}
+
+// secretValues appends to secrets the values held by the sensitive and
+// referenced keys of v, found at path. Callers hold p.mu.
+func (p *Parser) secretValues(v interface{}, path string, secrets []string) []string {
+	if path != "" && p.concealed(path) {
+		return leafValues(v, secrets)
+	}
+	if m, ok := toStringMap(v); ok {
+		for k, child := range m {
+			secrets = p.secretValues(child, joinPath(path, k), secrets)
+		}
+	}
+	if list, ok := v.([]interface{}); ok {
+		for i, item := range list {
+			secrets = p.secretValues(item, fmt.Sprintf("%s[%d]", path, i), secrets)
+		}
+	}
+	return secrets
+}
+
+// leafValues appends the values held by v to values, as they are printed
+func leafValues(v interface{}, values []string) []string {
+	if m, ok := toStringMap(v); ok {
+		for _, child := range m {
+			values = leafValues(child, values)
+		}
+		return values
+	}
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			values = leafValues(item, values)
+		}
+		return values
+	}
+	if s := fmt.Sprint(v); v != nil && s != "" {
+		values = append(values, s)
+	}
+	return values
+}
+
+// SupportBundle writes a gzipped tar archive describing the parser state,
+// safe to attach to support tickets: the effective config with sensitive
+// and referenced values redacted, the origin of every key, the versions of
+// the sources last read, the load history and whether the last load
+// succeeded. The values of sensitive and referenced keys are redacted from
+// the errors of failed loads as well.
+func (p *Parser) SupportBundle(w io.Writer) error {
+	var config interface{}
+	var provenance map[string]string
+	var sources []SourceInfo
+	var history []ReloadRecord
+	status := bundleStatus{Valid: true, Generated: time.Now()}
+	p.reading(func() {
+		settings := p.settings()
+		provenance = make(map[string]string)
+		for k := range flatten(settings) {
+			provenance[k] = p.origin(k)
+		}
+		sources = append([]SourceInfo{}, p.info.sources...)
+		history = append([]ReloadRecord{}, p.history...)
+		if n := len(history); n > 0 {
+			last := history[n-1]
+			status.Valid = last.Error == ""
+			status.Error = last.Error
+			status.File = last.File
+			status.LastLoad = last.Time
+		}
+		config = p.redact(settings, "")
+	})
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	entries := []struct {
+		name string
+		v    interface{}
+	}{
+		{"config.json", config},
+		{"provenance.json", provenance},
+		{"sources.json", sources},
+		{"history.json", history},
+		{"status.json", status},
+	}
+	for _, e := range entries {
+		b, err := json.MarshalIndent(e.v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", e.name, err)
+		}
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(b)), ModTime: status.Generated}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// DumpRedacted writes the effective configuration to w, encoded as the
+// config type format with its codec, like "yaml" or "json", for logging it
+// at startup. The values of the keys matching the sensitive patterns, see
+// WithSensitiveKeys, and of the keys resolved from references are written
+// as [REDACTED].
+func (p *Parser) DumpRedacted(w io.Writer, format string) error {
+	var codec viper.Codec
+	var ok bool
+	var settings map[string]interface{}
+	p.reading(func() {
+		if codec, ok = p.codecs[strings.ToLower(format)]; ok {
+			settings = p.redact(p.effective(), "").(map[string]interface{})
+		}
+	})
+	if !ok {
+		return fmt.Errorf("cannot dump the config: no codec for config type %q", format)
+	}
+
+	b, err := codec.Encode(normalizeJSON(settings).(map[string]interface{}))
+	if err != nil {
+		return fmt.Errorf("error encoding the config as %s: %w", format, err)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// redact returns a copy of v where the values of sensitive keys and of keys
+// resolved from references are replaced. Callers hold the read lock.
+func (p *Parser) redact(v interface{}, path string) interface{} {
+	if m, ok := toStringMap(v); ok {
+		out := make(map[string]interface{}, len(m))
+		for k, child := range m {
+			out[k] = p.redact(child, joinPath(path, k))
+		}
+		return out
+	}
+	if path != "" && (p.isSensitive(path) || p.referenced(path)) {
+		return redacted
+	}
+	if list, ok := v.([]interface{}); ok {
+		out := make([]interface{}, len(list))
+		for i, item := range list {
+			out[i] = p.redact(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+		return out
+	}
+	return v
+}
+
+// referenced reports whether the value of key was resolved from a reference
+// by the parser or one of its parents. Callers hold the read lock.
+func (p *Parser) referenced(key string) bool {
+	if _, ok := p.info.refs[key]; ok {
+		return true
+	}
+	if p.parent == nil {
+		return false
+	}
+	p.parent.mu.RLock()
+	defer p.parent.mu.RUnlock()
+	return p.parent.referenced(p.parentKey(key))
+}
@@ -0,0 +1,209 @@
+package viper
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readBundle returns the entries of a support bundle by name
+func readBundle(t *testing.T, b []byte) map[string][]byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	entries := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[hdr.Name] = content
+	}
+}
+
+func TestParser_SupportBundle(t *testing.T) {
+	t.Setenv("BUNDLETEST_LOG_LEVEL", "debug")
+	t.Setenv("BUNDLETEST_DSN_SECRET", "postgres://u:p@db/app")
+	dir := writeFiles(t, map[string]string{
+		"base.yaml": "server:\n  host: base.local\n  port: 8080\nlog:\n  level: info\n",
+		"app.yaml": `extends: base.yaml
+server:
+  port: 9090
+db:
+  dsn: $ref{env:BUNDLETEST_DSN_SECRET}
+  password: hunter2
+regions:
+  eu:
+    server:
+      host: eu.local
+`,
+	})
+	configFile := filepath.Join(dir, "app.yaml")
+
+	p := New(WithEnvPrefix("bundletest"), WithRegion("eu"))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	p.Override("feature.enabled", true)
+	if _, err := p.Parse(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Fatal("Parse() of a missing file should fail")
+	}
+
+	var buf bytes.Buffer
+	if err := p.SupportBundle(&buf); err != nil {
+		t.Fatal(err)
+	}
+	entries := readBundle(t, buf.Bytes())
+
+	config := string(entries["config.json"])
+	for _, secret := range []string{"hunter2", "postgres://"} {
+		if strings.Contains(config, secret) {
+			t.Errorf("config.json leaks %q:\n%s", secret, config)
+		}
+	}
+	if !strings.Contains(config, "eu.local") {
+		t.Errorf("config.json misses the effective values:\n%s", config)
+	}
+
+	var provenance map[string]string
+	if err := json.Unmarshal(entries["provenance.json"], &provenance); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"server.port":     filepath.Join(dir, "app.yaml"),
+		"server.host":     filepath.Join(dir, "app.yaml") + " (regions.eu)",
+		"log.level":       filepath.Join(dir, "base.yaml"),
+		"feature.enabled": "override",
+	}
+	for k, v := range want {
+		if provenance[k] != v {
+			t.Errorf("provenance[%s] = %q, want %q", k, provenance[k], v)
+		}
+	}
+
+	var sources []SourceInfo
+	if err := json.Unmarshal(entries["sources.json"], &sources); err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 || sources[0].SHA256 == "" {
+		t.Errorf("sources = %+v, want app.yaml and base.yaml with their hashes", sources)
+	}
+
+	var history []ReloadRecord
+	if err := json.Unmarshal(entries["history.json"], &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Error != "" || history[1].Error == "" {
+		t.Fatalf("history = %+v, want a successful then a failed load", history)
+	}
+	if !strings.Contains(strings.Join(history[0].Changed, ","), "server.port") {
+		t.Errorf("history[0].Changed = %v, want it to list server.port", history[0].Changed)
+	}
+
+	var status bundleStatus
+	if err := json.Unmarshal(entries["status.json"], &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Valid || !strings.Contains(status.Error, "missing.yaml") {
+		t.Errorf("status = %+v, want the failed load reported", status)
+	}
+}
+
+func TestParser_DumpRedacted(t *testing.T) {
+	t.Setenv("DUMPTEST_DB_TOKEN", "from-env")
+	t.Setenv("DUMPTEST_SIGNING", "s3cr3t")
+	p := New(WithEnvPrefix("dumptest"), WithSensitiveKeys("*signing_key*"))
+	if _, err := p.ParseBytes([]byte(`
+db:
+  host: localhost
+  password: hunter2
+  token: from-file
+auth:
+  signing_key: $ref{env:DUMPTEST_SIGNING}
+  issuer: nexen
+`), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.DumpRedacted(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"db":   map[string]interface{}{"host": "localhost", "password": redacted, "token": redacted},
+		"auth": map[string]interface{}{"signing_key": redacted, "issuer": "nexen"},
+	}
+	if !jsonEqual(got, want) {
+		t.Errorf("DumpRedacted() = %s, want %v", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := p.DumpRedacted(&buf, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2", "from-env", "from-file", "s3cr3t"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("DumpRedacted() = %s, want %q redacted", buf.String(), secret)
+		}
+	}
+	if err := p.DumpRedacted(&buf, "bogus"); err == nil {
+		t.Error("DumpRedacted() in an unknown format did not fail")
+	}
+}
+
+func TestParser_SupportBundleScrubsErrors(t *testing.T) {
+	schema := []byte(`{"properties": {"db": {"properties": {"password": {"minLength": 16}}}}}`)
+	p := New(WithSchema(schema))
+	if _, err := p.ParseBytes([]byte(`{"db": {"password": "long-enough-s3cr3t"}}`), "json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ParseBytes([]byte(`{"db": {"password": "sh0rt"}}`), "json"); err == nil {
+		t.Fatal("ParseBytes() of a password failing the schema succeeded")
+	}
+	// errors raised by user code quoting a secret of the config in place
+	p.mu.Lock()
+	p.recordLoad("approver", nil, errors.New(`password "long-enough-s3cr3t" reused`))
+	p.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := p.SupportBundle(&buf); err != nil {
+		t.Fatal(err)
+	}
+	entries := readBundle(t, buf.Bytes())
+	for _, name := range []string{"history.json", "status.json"} {
+		content := string(entries[name])
+		for _, secret := range []string{"sh0rt", "s3cr3t"} {
+			if strings.Contains(content, secret) {
+				t.Errorf("%s leaks %q:\n%s", name, secret, content)
+			}
+		}
+	}
+
+	var history []ReloadRecord
+	if err := json.Unmarshal(entries["history.json"], &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || !strings.Contains(history[1].Error, "db.password") || history[2].Error != `password "[REDACTED]" reused` {
+		t.Errorf("history = %+v, want the failed loads reported with the secrets redacted", history)
+	}
+}
@@ -0,0 +1,152 @@
+package viper
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/cast"
+)
+
+// DefaultTimeLayouts are tried in order when parsing time values, unless
+// replaced with WithTimeLayouts
+var DefaultTimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// WithTimeLayouts replaces the list of layouts tried by GetTime and
+// GetTimeInLocation
+func WithTimeLayouts(layouts ...string) Option {
+	return func(p *Parser) {
+		p.timeLayouts = layouts
+	}
+}
+
+// GetTime retrieves a time value from the configuration. Values without an
+// explicit offset are interpreted in the local time zone.
+func (p *Parser) GetTime(path string) time.Time {
+	return p.GetTimeInLocation(path, time.Local)
+}
+
+// GetTimeInLocation retrieves a time value from the configuration,
+// interpreting values without an explicit offset in the given location.
+func (p *Parser) GetTimeInLocation(path string, loc *time.Location) time.Time {
+	t, _ := toTime(p.value(path), loc, p.timeLayouts)
+	return t
+}
+
+// localTime is implemented by the TOML local date and date-time types
+type localTime interface {
+	AsTime(zone *time.Location) time.Time
+}
+
+// toTime converts a raw config value into a time, trying every layout in order
+func toTime(v interface{}, loc *time.Location, layouts []string) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case localTime:
+		return t.AsTime(loc), nil
+	case string:
+		return parseTime(t, loc, layouts)
+	}
+	return cast.ToTimeInDefaultLocationE(v, loc)
+}
+
+func parseTime(s string, loc *time.Location, layouts []string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse %q as a time with layouts %q", s, layouts)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// TimeHookFunc returns a decode hook converting strings into time.Time using
+// the given layouts (DefaultTimeLayouts when empty) in the given location.
+// Struct fields tagged with `layout:"2006-01-02"` are parsed with that layout
+// instead.
+//
+// Parser.Unmarshal uses it with the parser layouts. It can also be passed to
+// Config.Viper.Unmarshal with viper.DecodeHook.
+func TimeHookFunc(loc *time.Location, layouts ...string) mapstructure.DecodeHookFunc {
+	if len(layouts) == 0 {
+		layouts = DefaultTimeLayouts
+	}
+	return func(from, to reflect.Value) (interface{}, error) {
+		data := from.Interface()
+		switch {
+		case to.Type() == timeType:
+			if from.Kind() != reflect.String {
+				return data, nil
+			}
+			return parseTime(from.String(), loc, layouts)
+		case to.Kind() == reflect.Struct:
+			m, ok := data.(map[string]interface{})
+			if !ok {
+				return data, nil
+			}
+			return applyLayoutTags(m, to.Type(), loc)
+		}
+		return data, nil
+	}
+}
+
+// applyLayoutTags parses the entries of m backing time fields of t tagged
+// with a layout. Keys are matched the same way mapstructure does.
+func applyLayoutTags(m map[string]interface{}, t reflect.Type, loc *time.Location) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		layout, ok := field.Tag.Lookup("layout")
+		if !ok {
+			continue
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft != timeType {
+			continue
+		}
+
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]; tag != "" {
+			name = tag
+		}
+		for k, v := range m {
+			s, isString := v.(string)
+			if !isString || !strings.EqualFold(k, name) {
+				continue
+			}
+			parsed, err := parseTime(s, loc, []string{layout})
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			if out == nil {
+				// copy on first write so the source settings stay untouched
+				out = make(map[string]interface{}, len(m))
+				for k, v := range m {
+					out[k] = v
+				}
+			}
+			out[k] = parsed
+		}
+	}
+	if out == nil {
+		return m, nil
+	}
+	return out, nil
+}
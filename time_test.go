@@ -0,0 +1,113 @@
+package viper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestParser_GetTime(t *testing.T) {
+	content := []byte(`
+released: "2024-03-01"
+stamp: "2024-03-01T10:00:00Z"
+custom: "01/03/2024"
+broken: "tomorrow"
+`)
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configFile, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("tzdata not available:", err)
+	}
+
+	p := New(WithTimeLayouts(append([]string{"02/01/2006"}, DefaultTimeLayouts...)...))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		loc  *time.Location
+		want time.Time
+	}{
+		{
+			name: "local date keeps the location",
+			path: "released",
+			loc:  berlin,
+			want: time.Date(2024, 3, 1, 0, 0, 0, 0, berlin),
+		},
+		{
+			name: "explicit offset wins",
+			path: "stamp",
+			loc:  berlin,
+			want: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "custom layout",
+			path: "custom",
+			loc:  time.UTC,
+			want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "unparseable",
+			path: "broken",
+			loc:  time.UTC,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.GetTimeInLocation(tt.path, tt.loc)
+			if !got.Equal(tt.want) {
+				t.Errorf("GetTimeInLocation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := p.GetTime("released"); got.Location() != time.Local {
+		t.Errorf("GetTime() location = %v, want Local", got.Location())
+	}
+}
+
+func TestTimeHookFunc(t *testing.T) {
+	content := []byte(`{"start": "2024-03-01", "until": "01.04.2024", "nested": {"at": "2024-03-01 12:30:00"}}`)
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.json")
+	if err := os.WriteFile(configFile, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := New().Parse(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var target struct {
+		Start  time.Time
+		Until  *time.Time `mapstructure:"until" layout:"02.01.2006"`
+		Nested struct {
+			At time.Time `layout:"2006-01-02 15:04:05"`
+		}
+	}
+	if err := cfg.Viper.Unmarshal(&target, viper.DecodeHook(TimeHookFunc(time.UTC))); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); !target.Start.Equal(want) {
+		t.Errorf("Start = %v, want %v", target.Start, want)
+	}
+	if want := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC); target.Until == nil || !target.Until.Equal(want) {
+		t.Errorf("Until = %v, want %v", target.Until, want)
+	}
+	if want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC); !target.Nested.At.Equal(want) {
+		t.Errorf("Nested.At = %v, want %v", target.Nested.At, want)
+	}
+}
@@ -0,0 +1,57 @@
+package viper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// tomlCodec decodes TOML documents, keeping arrays of tables as lists of
+// maps and dotted keys as nested tables
+type tomlCodec struct {
+	p *Parser
+}
+
+func (c *tomlCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	return toml.Marshal(v)
+}
+
+func (c *tomlCodec) Decode(b []byte, v map[string]interface{}) error {
+	if err := toml.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if err := delimitedKeys("", v); err != nil {
+		return err
+	}
+	if c.p.duplicateKeyPolicy() == DuplicateKeysAllow {
+		return nil
+	}
+	// TOML rejects exact duplicates itself, but keys differing by case
+	// would collide once viper lowercases them
+	return caseDuplicates(v)
+}
+
+// delimitedKeys rejects quoted keys containing a dot, like "example.com".
+// Viper splits key paths on dots, so such a key would silently become a
+// nested table and its value could no longer be read back.
+func delimitedKeys(prefix string, v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if strings.Contains(k, ".") {
+				return fmt.Errorf("key %q contains a dot, which is reserved as the key path separator", joinPath(prefix, k))
+			}
+			if err := delimitedKeys(joinPath(prefix, k), item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if err := delimitedKeys(fmt.Sprintf("%s[%d]", prefix, i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
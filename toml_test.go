@@ -0,0 +1,134 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const tomlServers = `
+title = "fleet"
+server.http.port = 80
+server.http.timeout = "5s"
+
+[[servers]]
+name = "a"
+ports = [1, 2]
+[servers.tls]
+cert = "a.pem"
+
+[[servers]]
+name = "b"
+[[servers.routes]]
+path = "/"
+[[servers.routes]]
+path = "/api"
+`
+
+type tomlFleet struct {
+	Title  string
+	Server struct {
+		HTTP struct {
+			Port    int
+			Timeout string
+		}
+	}
+	Servers []struct {
+		Name   string
+		Ports  []int
+		TLS    struct{ Cert string }
+		Routes []struct{ Path string }
+	}
+}
+
+func TestTOMLCodec_Fidelity(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"fleet.toml": tomlServers,
+		// the child only touches a dotted key, the parent's tables stay intact
+		"prod.toml": "extends = \"fleet.toml\"\nserver.http.port = 443\n",
+	})
+
+	p := New()
+	cfg, err := p.Parse(filepath.Join(dir, "prod.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fleet tomlFleet
+	if err := cfg.Viper.Unmarshal(&fleet); err != nil {
+		t.Fatal(err)
+	}
+	if fleet.Server.HTTP.Port != 443 || fleet.Server.HTTP.Timeout != "5s" {
+		t.Errorf("server.http = %+v, want port 443 merged over timeout 5s", fleet.Server.HTTP)
+	}
+	if len(fleet.Servers) != 2 {
+		t.Fatalf("servers = %+v, want 2 entries", fleet.Servers)
+	}
+	if s := fleet.Servers[0]; s.Name != "a" || !reflect.DeepEqual(s.Ports, []int{1, 2}) || s.TLS.Cert != "a.pem" {
+		t.Errorf("servers[0] = %+v", s)
+	}
+	if s := fleet.Servers[1]; s.Name != "b" || len(s.Routes) != 2 || s.Routes[1].Path != "/api" {
+		t.Errorf("servers[1] = %+v", s)
+	}
+
+	// encoding the settings and parsing them again must not lose anything
+	out, err := p.codecs["toml"].Encode(cfg.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip := filepath.Join(writeFiles(t, map[string]string{"out.toml": string(out)}), "out.toml")
+	again, err := New().Parse(roundTrip)
+	if err != nil {
+		t.Fatalf("parsing the encoded settings: %v\n%s", err, out)
+	}
+	if !reflect.DeepEqual(again.Raw, cfg.Raw) {
+		t.Errorf("round trip changed the settings\ngot:  %v\nwant: %v", again.Raw, cfg.Raw)
+	}
+}
+
+func TestTOMLCodec_LossyKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "quoted key with a dot",
+			content: "[hosts]\n\"example.com\" = \"10.0.0.1\"\n",
+			wantErr: `key "hosts.example.com" contains a dot`,
+		},
+		{
+			name:    "quoted key inside an array of tables",
+			content: "[[servers]]\n\"a.b\" = 1\n",
+			wantErr: `key "servers[0].a.b" contains a dot`,
+		},
+		{
+			name:    "keys differing by case inside an array of tables",
+			content: "[[servers]]\nname = \"a\"\n[[servers]]\nname = \"b\"\nName = \"c\"\n",
+			wantErr: "servers[1].Name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"config.toml": tt.content})
+			_, err := New().Parse(filepath.Join(dir, "config.toml"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// case clashes follow the duplicate key policy
+	dir := writeFiles(t, map[string]string{"config.toml": "[[servers]]\nname = \"b\"\nName = \"c\"\n"})
+	_, err := New().Parse(filepath.Join(dir, "config.toml"))
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Errorf("Parse() error = %v, want a *DuplicateKeyError", err)
+	}
+	if _, err := New(WithDuplicateKeys(DuplicateKeysAllow)).Parse(filepath.Join(dir, "config.toml")); err != nil {
+		t.Errorf("Parse() with DuplicateKeysAllow error = %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package viper
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation scope of the spans of the parser
+const tracerName = "github.com/nexenio/nexen-viper"
+
+// Attributes of the spans
+const (
+	filesAttr     = attribute.Key("config.files")
+	fileAttr      = attribute.Key("config.file")
+	sourceAttr    = attribute.Key("config.source")
+	refSchemeAttr = attribute.Key("config.ref.scheme")
+)
+
+// WithTracerProvider produces OpenTelemetry spans with the tracers of tp:
+// viper.Parse around Parse and ParseAll, viper.Reload around the reloads of
+// watched files and sources, and within them viper.ReadFile for each config
+// file, viper.LoadSource for each source, viper.ResolveRef for each
+// reference fetched from its backend and viper.Validate around validation.
+// Spans carry the config files, the source names or the reference schemes
+// as attributes, and record the error failing them. Sources and resolvers
+// receive the context of their span, so their own spans nest below it.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(p *Parser) {
+		p.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// noopTracer is the tracer of the parsers without a tracer provider
+var noopTracer = noop.NewTracerProvider().Tracer(tracerName)
+
+// startSpan starts the span name as a child of the span of ctx
+func (p *Parser) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return p.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, recording err as its failure if not nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
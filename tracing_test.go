@@ -0,0 +1,109 @@
+package viper
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParser_WithTracerProvider(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "db:\n  password: $ref{vault:db}\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	var sourceSpan trace.SpanContext
+	remote := SourceFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		sourceSpan = trace.SpanContextFromContext(ctx)
+		return map[string]interface{}{"port": 8080}, nil
+	})
+	vault := ResolverFunc(func(context.Context, string) (string, error) { return "s3cret", nil })
+	p := New(WithTracerProvider(tp), WithSource("remote", remote), WithResolver("vault", vault))
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	root, ok := spans["viper.Parse"]
+	if !ok {
+		t.Fatalf("spans = %v, want viper.Parse", recorder.Ended())
+	}
+	if got := attr(root, filesAttr).AsStringSlice(); len(got) != 1 || got[0] != configFile {
+		t.Errorf("config.files of viper.Parse = %v, want [%s]", got, configFile)
+	}
+	for name, want := range map[string]string{
+		"viper.ReadFile":   configFile,
+		"viper.LoadSource": "remote",
+		"viper.ResolveRef": "vault",
+		"viper.Validate":   "",
+	} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of viper.Parse", name)
+		}
+		for _, kv := range s.Attributes() {
+			if kv.Key != filesAttr && kv.Value.AsString() != want {
+				t.Errorf("%s = %q on %s, want %q", kv.Key, kv.Value.AsString(), name, want)
+			}
+		}
+	}
+	if sourceSpan.SpanID() != spans["viper.LoadSource"].SpanContext().SpanID() {
+		t.Error("the source did not receive the context of its span")
+	}
+
+	recorder = tracetest.NewSpanRecorder()
+	tp.RegisterSpanProcessor(recorder)
+	p.reloader("remote", func() []string { return p.files }, func(ChangeSet, error) {})()
+	reload := recorder.Ended()[len(recorder.Ended())-1]
+	if reload.Name() != "viper.Reload" || attr(reload, sourceAttr).AsString() != "remote" {
+		t.Errorf("last span = %s %v, want viper.Reload of remote", reload.Name(), reload.Attributes())
+	}
+}
+
+func TestParser_WithTracerProvider_Error(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": "port: 8080\n"})
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	failing := SourceFunc(func(context.Context) (map[string]interface{}, error) {
+		return nil, errors.New("backend down")
+	})
+	p := New(WithTracerProvider(tp), WithSource("remote", failing))
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err == nil {
+		t.Fatal("Parse() succeeded with a failing source")
+	}
+	for _, s := range recorder.Ended() {
+		if s.Name() == "viper.ReadFile" {
+			if s.Status().Code != codes.Unset {
+				t.Errorf("status of viper.ReadFile = %v, want unset", s.Status())
+			}
+			continue
+		}
+		if s.Status().Code != codes.Error || len(s.Events()) == 0 {
+			t.Errorf("%s status = %v with %d events, want the recorded error", s.Name(), s.Status(), len(s.Events()))
+		}
+	}
+}
+
+// attr returns the value of the attribute key of s
+func attr(s sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
@@ -0,0 +1,106 @@
+package viper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ErrKeyNotFound is returned by Get for keys no source sets
+var ErrKeyNotFound = errors.New("config key not found")
+
+// TypeError is returned by Get when the value of a key cannot be converted
+// to the requested type
+type TypeError struct {
+	// Key is the dot-notation key read
+	Key string
+	// Value is the value found, redacted for sensitive and referenced keys
+	Value interface{}
+	// Type is the requested type
+	Type string
+	// Err is the conversion failure, reduced to its cause for sensitive
+	// and referenced keys
+	Err error
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("config key %q: cannot read %v (%T) as %s: %v", e.Key, e.Value, e.Value, e.Type, e.Err)
+}
+
+func (e *TypeError) Unwrap() error {
+	return e.Err
+}
+
+// Get retrieves the value at path as a T, reporting missing keys with
+// ErrKeyNotFound and values that do not convert to T with a *TypeError
+// instead of reading them as the zero value. Conversions follow Unmarshal,
+// except that integers must fit T exactly and durations honor the units
+// declared with WithDurationUnits.
+func Get[T any](p *Parser, path string) (out T, err error) {
+	p.reading(func() { out, err = getAs[T](p, path) })
+	return out, err
+}
+
+// getAs reads the value at path as Get does. Callers hold the read lock.
+func getAs[T any](p *Parser, path string) (T, error) {
+	var out T
+	v := p.get(path)
+	if v == nil {
+		return out, fmt.Errorf("%w: %q", ErrKeyNotFound, path)
+	}
+	if err := p.convert(path, v, &out); err != nil {
+		if p.isSensitive(path) || p.referenced(path) {
+			// conversion errors quote the value, keep only their cause
+			var numErr *strconv.NumError
+			if errors.As(err, &numErr) {
+				err = numErr.Err
+			} else {
+				err = strconv.ErrSyntax
+			}
+			v = redacted
+		}
+		return out, &TypeError{Key: path, Value: v, Type: fmt.Sprintf("%T", out), Err: err}
+	}
+	return out, nil
+}
+
+// convert stores the value v read at path in target, a pointer. Callers
+// hold the read lock.
+func (p *Parser) convert(path string, v, target interface{}) error {
+	switch t := target.(type) {
+	case *time.Duration:
+		d, err := p.toDuration(path, v)
+		*t = d
+		return err
+	case *time.Time:
+		tm, err := toTime(v, time.Local, p.timeLayouts)
+		*t = tm
+		return err
+	}
+	rv := reflect.ValueOf(target).Elem()
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt64(v)
+		if err != nil {
+			return err
+		}
+		if rv.OverflowInt(n) {
+			return fmt.Errorf("%d overflows %s", n, rv.Type())
+		}
+		rv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := toUint64(v)
+		if err != nil {
+			return err
+		}
+		if rv.OverflowUint(n) {
+			return fmt.Errorf("%d overflows %s", n, rv.Type())
+		}
+		rv.SetUint(n)
+		return nil
+	}
+	return p.decodeInto(v, target)
+}
@@ -0,0 +1,92 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+name: api
+port: "8080"
+ratio: 0.5
+debug: true
+timeout: 1500ms
+idle: 2
+hosts: [a, b]
+started: 2024-05-01T10:00:00Z
+big: 300
+db:
+  password: hunter2
+`})
+	p := New(WithDurationUnits(map[string]time.Duration{"idle": time.Minute}))
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, got, want interface{}, err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("Get[%T](%q) error = %v", want, name, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("Get[%T](%q) = %v, want %v", want, name, got, want)
+		}
+	}
+	name, err := Get[string](p, "name")
+	check("name", name, "api", err)
+	port, err := Get[int](p, "port")
+	check("port", port, 8080, err)
+	ratio, err := Get[float64](p, "ratio")
+	check("ratio", ratio, 0.5, err)
+	debug, err := Get[bool](p, "debug")
+	check("debug", debug, true, err)
+	timeout, err := Get[time.Duration](p, "timeout")
+	check("timeout", timeout, 1500*time.Millisecond, err)
+	idle, err := Get[time.Duration](p, "idle")
+	check("idle", idle, 2*time.Minute, err)
+	hosts, err := Get[[]string](p, "hosts")
+	check("hosts", hosts, []string{"a", "b"}, err)
+	started, err := Get[time.Time](p, "started")
+	check("started", started.UTC(), time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), err)
+	db, err := Get[struct{ Password string }](p, "db")
+	check("db", db, struct{ Password string }{"hunter2"}, err)
+
+	t.Run("missing key", func(t *testing.T) {
+		if _, err := Get[string](p, "missing"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Get() error = %v, want ErrKeyNotFound", err)
+		}
+	})
+
+	errTests := []struct {
+		name string
+		get  func() error
+		want string
+	}{
+		{"not an int", func() error { _, err := Get[int](p, "name"); return err }, `"name": cannot read api (string) as int`},
+		{"fractional int", func() error { _, err := Get[int](p, "ratio"); return err }, "0.5 is not an int64"},
+		{"overflow", func() error { _, err := Get[int8](p, "big"); return err }, "300 overflows int8"},
+		{"not a bool", func() error { _, err := Get[bool](p, "hosts"); return err }, "as bool"},
+		{"sensitive value", func() error { _, err := Get[int](p, "db.password"); return err }, "cannot read [REDACTED] (string) as int"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.get()
+			var typeErr *TypeError
+			if !errors.As(err, &typeErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Get() error = %v, want a *TypeError containing %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("sensitive cause", func(t *testing.T) {
+		_, err := Get[int](p, "db.password")
+		if strings.Contains(err.Error(), "hunter2") || !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("Get() error = %v, want strconv.ErrSyntax without the value", err)
+		}
+	})
+}
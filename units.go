@@ -0,0 +1,239 @@
+package viper
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/cast"
+)
+
+// ByteSize is a number of bytes, used as the unit of size values
+type ByteSize int64
+
+// Decimal and binary size units
+const (
+	Byte ByteSize = 1
+	KB   ByteSize = 1000
+	MB   ByteSize = 1000 * KB
+	GB   ByteSize = 1000 * MB
+	TB   ByteSize = 1000 * GB
+	KiB  ByteSize = 1 << 10
+	MiB  ByteSize = 1 << 20
+	GiB  ByteSize = 1 << 30
+	TiB  ByteSize = 1 << 40
+)
+
+// sizeSuffixes maps the lower-cased suffixes accepted in size strings to
+// their unit
+var sizeSuffixes = map[string]ByteSize{
+	"b":   Byte,
+	"kb":  KB,
+	"mb":  MB,
+	"gb":  GB,
+	"tb":  TB,
+	"kib": KiB,
+	"mib": MiB,
+	"gib": GiB,
+	"tib": TiB,
+}
+
+// WithDurationUnits declares the canonical unit of duration keys, applied to
+// bare numbers like `timeout: 30`. Bare numbers at keys without a declared
+// unit are rejected rather than guessed.
+func WithDurationUnits(units map[string]time.Duration) Option {
+	return func(p *Parser) {
+		if p.durationUnits == nil {
+			p.durationUnits = make(map[string]time.Duration, len(units))
+		}
+		for k, u := range units {
+			p.durationUnits[strings.ToLower(k)] = u
+		}
+	}
+}
+
+// WithSizeUnits declares the canonical unit of size keys, applied to bare
+// numbers like `max_upload: 10`. Bare numbers at keys without a declared
+// unit are bytes, as SizeHookFunc reads them.
+func WithSizeUnits(units map[string]ByteSize) Option {
+	return func(p *Parser) {
+		if p.sizeUnits == nil {
+			p.sizeUnits = make(map[string]ByteSize, len(units))
+		}
+		for k, u := range units {
+			p.sizeUnits[strings.ToLower(k)] = u
+		}
+	}
+}
+
+// GetDuration retrieves a duration from the configuration, like "1.5s" or a
+// bare number at a key declared with WithDurationUnits. Invalid or
+// ambiguous values are logged and read as 0.
+func (p *Parser) GetDuration(path string) time.Duration {
+	var d time.Duration
+	var err error
+	p.reading(func() { d, err = p.duration(path) })
+	if err != nil {
+		p.logger.Warn("cannot read duration", "key", path, "error", err)
+		return 0
+	}
+	return d
+}
+
+// GetDurationAs retrieves a duration expressed in the given unit, so
+// GetDurationAs("timeout", time.Millisecond) returns 1500 for "1.5s".
+// Invalid or ambiguous values are logged and read as 0.
+func (p *Parser) GetDurationAs(path string, unit time.Duration) float64 {
+	var d time.Duration
+	var err error
+	p.reading(func() { d, err = p.duration(path) })
+	if err != nil {
+		p.logger.Warn("cannot read duration", "key", path, "error", err)
+		return 0
+	}
+	return float64(d) / float64(unit)
+}
+
+// GetSizeAs retrieves a size expressed in the given unit, so
+// GetSizeAs("max_upload", MiB) returns 1.5 for "1536KiB". Invalid values
+// are logged and read as 0.
+func (p *Parser) GetSizeAs(path string, unit ByteSize) float64 {
+	var n float64
+	var err error
+	p.reading(func() { n, err = p.size(path) })
+	if err != nil {
+		p.logger.Warn("cannot read size", "key", path, "error", err)
+		return 0
+	}
+	return n / float64(unit)
+}
+
+// GetSizeBytes retrieves a size in bytes, like 536870912 for "512MiB" or
+// 1500000000 for "1.5GB", rounded to the nearest byte. Bare numbers are
+// bytes unless WithSizeUnits declares another unit. Invalid values are
+// logged and read as 0.
+func (p *Parser) GetSizeBytes(path string) int64 {
+	var n float64
+	var err error
+	p.reading(func() { n, err = p.size(path) })
+	if err != nil {
+		p.logger.Warn("cannot read size", "key", path, "error", err)
+		return 0
+	}
+	return int64(math.Round(n))
+}
+
+var byteSizeType = reflect.TypeOf(ByteSize(0))
+
+// SizeHookFunc returns a decode hook converting strings like "512MiB" or
+// "1.5GB" into ByteSize values, bare numbers being bytes.
+//
+// Parser.Unmarshal uses it. It can also be passed to Config.Viper.Unmarshal
+// with viper.DecodeHook.
+func SizeHookFunc() mapstructure.DecodeHookFunc {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to != byteSizeType {
+			return data, nil
+		}
+		n, _, err := parseSize(reflect.ValueOf(data).String())
+		if err != nil {
+			return nil, err
+		}
+		return ByteSize(math.Round(n)), nil
+	}
+}
+
+// duration converts the value at path. Callers hold the read lock.
+func (p *Parser) duration(path string) (time.Duration, error) {
+	return p.toDuration(path, p.get(path))
+}
+
+// toDuration converts the value v read at path, bare numbers taking the
+// unit declared for path
+func (p *Parser) toDuration(path string, v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case time.Duration:
+		return v, nil
+	case string:
+		s := strings.TrimSpace(v)
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return p.bareDuration(path, f)
+		}
+		return time.ParseDuration(s)
+	default:
+		f, err := cast.ToFloat64E(v)
+		if err != nil {
+			return 0, err
+		}
+		return p.bareDuration(path, f)
+	}
+}
+
+func (p *Parser) bareDuration(path string, f float64) (time.Duration, error) {
+	unit, ok := p.durationUnits[strings.ToLower(path)]
+	if !ok {
+		return 0, fmt.Errorf("%v has no unit and none is declared for %q", f, path)
+	}
+	return time.Duration(f * float64(unit)), nil
+}
+
+// size converts the value at path to bytes. Callers hold the read lock.
+func (p *Parser) size(path string) (float64, error) {
+	v := p.get(path)
+	if v == nil {
+		return 0, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		f, err := cast.ToFloat64E(v)
+		if err != nil {
+			return 0, err
+		}
+		return p.bareSize(path, f), nil
+	}
+
+	n, hasUnit, err := parseSize(s)
+	if err != nil || hasUnit {
+		return n, err
+	}
+	return p.bareSize(path, n), nil
+}
+
+// parseSize converts a size like "1.5GB" to bytes. hasUnit is false for
+// bare numbers, returned as they are.
+func parseSize(s string) (n float64, hasUnit bool, err error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, false, err
+		}
+		return f, false, nil
+	}
+	f, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid size %q", s)
+	}
+	unit, ok := sizeSuffixes[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, false, fmt.Errorf("invalid size unit in %q", s)
+	}
+	return f * float64(unit), true, nil
+}
+
+// bareSize converts the bare number f at path with the unit declared for
+// path, bytes by default
+func (p *Parser) bareSize(path string, f float64) float64 {
+	unit, ok := p.sizeUnits[strings.ToLower(path)]
+	if !ok {
+		unit = Byte
+	}
+	return f * float64(unit)
+}
@@ -0,0 +1,127 @@
+package viper
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParser_UnitGetters(t *testing.T) {
+	t.Setenv("UNITTEST_CACHE_TTL", "90")
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+http:
+  timeout: 1.5s
+  idle: 2
+upload:
+  max: 1536KiB
+  chunk: 4
+  part: 2.5 MB
+  bare: 10
+  bogus: 10 parsecs
+`})
+	p := New(
+		WithEnvPrefix("unittest"),
+		WithDefaults(map[string]interface{}{"cache": map[string]interface{}{"ttl": 0}}),
+		WithDurationUnits(map[string]time.Duration{"http.idle": time.Minute, "cache.ttl": time.Second}),
+		WithSizeUnits(map[string]ByteSize{"upload.chunk": MiB}),
+	)
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	durations := []struct {
+		key  string
+		unit time.Duration
+		want float64
+	}{
+		{"http.timeout", time.Millisecond, 1500},
+		{"http.idle", time.Second, 120},
+		// env values are strings and use the declared unit too
+		{"cache.ttl", time.Minute, 1.5},
+		{"missing", time.Second, 0},
+	}
+	for _, tt := range durations {
+		if got := p.GetDurationAs(tt.key, tt.unit); got != tt.want {
+			t.Errorf("GetDurationAs(%q, %v) = %v, want %v", tt.key, tt.unit, got, tt.want)
+		}
+	}
+	if got := p.GetDuration("http.idle"); got != 2*time.Minute {
+		t.Errorf("GetDuration(%q) = %v, want %v", "http.idle", got, 2*time.Minute)
+	}
+
+	sizes := []struct {
+		key  string
+		unit ByteSize
+		want float64
+	}{
+		{"upload.max", MiB, 1.5},
+		{"upload.chunk", KiB, 4096},
+		{"upload.part", KB, 2500},
+		// no declared unit: bytes, as SizeHookFunc reads them
+		{"upload.bare", Byte, 10},
+		{"upload.bogus", Byte, 0},
+	}
+	for _, tt := range sizes {
+		if got := p.GetSizeAs(tt.key, tt.unit); got != tt.want {
+			t.Errorf("GetSizeAs(%q, %d) = %v, want %v", tt.key, tt.unit, got, tt.want)
+		}
+	}
+	if got := p.GetDurationAs("upload.bare", time.Second); got != 0 {
+		t.Errorf("GetDurationAs() of a bare number without a unit = %v, want 0", got)
+	}
+	if got := p.GetSizeBytes("upload.part"); got != 2500000 {
+		t.Errorf("GetSizeBytes(%q) = %d, want 2500000", "upload.part", got)
+	}
+	if got := p.GetSizeBytes("upload.chunk"); got != 4*int64(MiB) {
+		t.Errorf("GetSizeBytes(%q) = %d, want %d", "upload.chunk", got, 4*MiB)
+	}
+}
+
+func TestParser_GetSizeBytesBare(t *testing.T) {
+	t.Setenv("SIZETEST_LIMIT", "2048")
+	p := New(WithEnvPrefix("sizetest"), WithDefaults(map[string]interface{}{"limit": 1}))
+	if _, err := p.ParseBytes([]byte("max_body: 1048576\n"), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		MaxBody ByteSize `mapstructure:"max_body"`
+		Limit   ByteSize
+	}
+	if err := p.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]ByteSize{"max_body": cfg.MaxBody, "limit": cfg.Limit} {
+		if got := p.GetSizeBytes(key); got != int64(want) {
+			t.Errorf("GetSizeBytes(%q) = %d, want %d as Unmarshal reads it", key, got, want)
+		}
+	}
+	if cfg.MaxBody != MiB || cfg.Limit != 2048 {
+		t.Errorf("Unmarshal() = %+v, want the bare numbers as bytes", cfg)
+	}
+}
+
+func TestParser_Unmarshal_sizes(t *testing.T) {
+	p := New()
+	if _, err := p.ParseBytes([]byte("buffer: 512MiB\ncache: 1.5GB\nmax: 1024\nlimit: \"2048\"\n"), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Buffer ByteSize
+		Cache  ByteSize
+		Max    ByteSize
+		Limit  ByteSize
+	}
+	if err := p.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Buffer != 512*MiB || cfg.Cache != 1500*MB || cfg.Max != 1024 || cfg.Limit != 2048 {
+		t.Errorf("Unmarshal() = %+v, want the sizes in bytes", cfg)
+	}
+
+	if _, err := p.ParseBytes([]byte("buffer: 12 parsecs\n"), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Unmarshal(&cfg); err == nil {
+		t.Error("Unmarshal() of an invalid size did not fail")
+	}
+}
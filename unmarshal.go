@@ -0,0 +1,172 @@
+package viper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// Unmarshal decodes the effective configuration into target, a pointer to a
+// struct or map. Fields are matched against keys the way mapstructure does,
+// honoring `mapstructure` tags. Strings are decoded into time.Time with the
+// parser's time layouts, into the types of CommonTypesHookFunc, into
+// ByteSize values like "512MiB", into slices split on commas and into types
+// implementing encoding.TextUnmarshaler, after the hooks added with
+// WithDecodeHooks. Fields absent from the configuration take the value of
+// their `default` tag, see DefaultTagHookFunc. Structs are then validated
+// against their `validate` tags, every failing field being listed in a
+// *ValidationError.
+func (p *Parser) Unmarshal(target interface{}) (err error) {
+	p.reading(func() {
+		if err = p.decodeInto(p.effective(), target); err == nil {
+			err = p.validateStruct(target, "")
+		}
+	})
+	return err
+}
+
+// UnmarshalExact decodes the effective configuration into target as
+// Unmarshal does, but also fails on the keys no field of target accepts,
+// like misspelled ones. Unknown keys are reported in an *UnknownKeysError,
+// joined with the *ValidationError of the struct if it is invalid too.
+func (p *Parser) UnmarshalExact(target interface{}) (err error) {
+	p.reading(func() { err = p.unmarshalExact(target) })
+	return err
+}
+
+// unmarshalExact decodes the effective configuration as UnmarshalExact
+// does. Callers hold the read lock.
+func (p *Parser) unmarshalExact(target interface{}) error {
+	var md mapstructure.Metadata
+	if err := p.decodeWith(p.effective(), target, &md); err != nil {
+		return err
+	}
+	var unknown error
+	if len(md.Unused) > 0 {
+		keys := make([]string, len(md.Unused))
+		for i, k := range md.Unused {
+			keys[i] = strings.ToLower(k)
+		}
+		sort.Strings(keys)
+		unknown = &UnknownKeysError{Keys: keys}
+	}
+	return errors.Join(unknown, p.validateStruct(target, ""))
+}
+
+// UnknownKeysError lists the keys of the configuration UnmarshalExact found
+// no field for
+type UnknownKeysError struct {
+	Keys []string
+}
+
+func (e *UnknownKeysError) Error() string {
+	return "unknown config keys: " + strings.Join(e.Keys, ", ")
+}
+
+// UnmarshalKey decodes the value at path into target, as Unmarshal does for
+// the whole configuration. A missing key leaves target untouched, though
+// it is still validated.
+func (p *Parser) UnmarshalKey(path string, target interface{}) (err error) {
+	p.reading(func() { err = p.unmarshalKey(path, target) })
+	return err
+}
+
+// unmarshalKey decodes the value at path as UnmarshalKey does. Callers hold
+// the read lock.
+func (p *Parser) unmarshalKey(path string, target interface{}) error {
+	v, ok := lookupPath(p.effective(), path)
+	if !ok {
+		return p.validateStruct(target, strings.ToLower(path))
+	}
+	if err := p.decodeInto(v, target); err != nil {
+		return fmt.Errorf("error decoding %q: %w", path, err)
+	}
+	return p.validateStruct(target, strings.ToLower(path))
+}
+
+// effective returns the settings with every value looked up the way the
+// getters do, so read-only sources, providers, lazy references and
+// fallbacks apply. Callers hold the read lock.
+func (p *Parser) effective() map[string]interface{} {
+	settings := p.settings()
+	out := p.effectiveMap(settings, "", p.plainReads())
+	for k := range p.fallbacks.chains {
+		if v, ok := lookupPath(settings, k); ok {
+			if _, isMap := toStringMap(v); !isMap {
+				continue
+			}
+		}
+		if v := p.get(k); v != nil {
+			setPath(out, k, v)
+		}
+	}
+	return out
+}
+
+// effectiveMap returns the section of the settings at prefix with its
+// values looked up. When plain, the values of the settings are kept
+// rather than looked up again, but for the read-only keys and the unset
+// ones.
+func (p *Parser) effectiveMap(m map[string]interface{}, prefix string, plain bool) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		key := joinPath(prefix, k)
+		if nested, ok := toStringMap(v); ok {
+			if section := p.effectiveMap(nested, key, plain); len(section) > 0 {
+				out[k] = section
+			}
+			continue
+		}
+		if _, locked := p.info.values[key]; !plain || locked || v == nil {
+			v = p.get(key)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// plainReads reports whether get reads the values of settings: no layer
+// but viper and the overrides sets values, and reads are not counted
+func (p *Parser) plainReads() bool {
+	p.providersMu.RLock()
+	provided := len(p.providers) > 0
+	p.providersMu.RUnlock()
+	return !provided && p.parent == nil && !p.lazyRefs && p.reads == nil &&
+		len(p.derived.fns) == 0 && len(p.aliases.targets) == 0
+}
+
+// decodeHook is the decode hook used by Unmarshal
+func (p *Parser) decodeHook() mapstructure.DecodeHookFunc {
+	hooks := append([]mapstructure.DecodeHookFunc(nil), p.decodeHooks...)
+	return mapstructure.ComposeDecodeHookFunc(append(hooks,
+		DefaultTagHookFunc(),
+		TimeHookFunc(time.Local, p.timeLayouts...),
+		CommonTypesHookFunc(),
+		SizeHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.TextUnmarshallerHookFunc(),
+	)...)
+}
+
+func (p *Parser) decodeInto(input, target interface{}) error {
+	return p.decodeWith(input, target, nil)
+}
+
+// decodeWith decodes input into target, recording the keys left unused in
+// md when it is not nil
+func (p *Parser) decodeWith(input, target interface{}, md *mapstructure.Metadata) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       p.decodeHook(),
+		WeaklyTypedInput: true,
+		Metadata:         md,
+		Result:           target,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
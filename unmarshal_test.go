@@ -0,0 +1,131 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParser_Unmarshal(t *testing.T) {
+	t.Setenv("UNMARSHALTEST_SERVER_PORT", "9000")
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+name: api
+server:
+  host: localhost
+  timeout: 1500ms
+  tags: a,b
+release: "01/05/2024"
+started: 2024-05-01T10:00:00Z
+`})
+
+	type server struct {
+		Host    string
+		Port    int
+		Timeout time.Duration
+		Tags    []string
+	}
+	type config struct {
+		Name    string
+		Server  server
+		Release time.Time `layout:"02/01/2006"`
+		Started time.Time
+	}
+
+	p := New(WithEnvPrefix("unmarshaltest"), WithDefaults(map[string]interface{}{"server": map[string]interface{}{"port": 8080}}))
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Override("name", "overridden"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got config
+	if err := p.Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := config{
+		Name: "overridden",
+		Server: server{
+			Host:    "localhost",
+			Port:    9000,
+			Timeout: 1500 * time.Millisecond,
+			Tags:    []string{"a", "b"},
+		},
+		Release: time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+		Started: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	if !got.Release.Equal(want.Release) || !got.Started.Equal(want.Started) {
+		t.Errorf("Unmarshal() times = %v, %v, want %v, %v", got.Release, got.Started, want.Release, want.Started)
+	}
+	got.Release, got.Started, want.Release, want.Started = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+
+	t.Run("key", func(t *testing.T) {
+		var s server
+		if err := p.UnmarshalKey("server", &s); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(s, want.Server) {
+			t.Errorf("UnmarshalKey() = %+v, want %+v", s, want.Server)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		s := server{Host: "kept"}
+		if err := p.UnmarshalKey("missing", &s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Host != "kept" {
+			t.Errorf("UnmarshalKey() of a missing key changed the target: %+v", s)
+		}
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		var port struct{ Host int }
+		if err := p.UnmarshalKey("server", &port); err == nil {
+			t.Error("UnmarshalKey() of a string into an int succeeded")
+		}
+	})
+}
+
+func TestParser_UnmarshalExact(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+name: api
+server:
+  host: localhost
+  hots: typo
+  port: 0
+level: debug
+`})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg struct {
+		Name   string
+		Server struct {
+			Host string
+			Port int `validate:"min=1"`
+		}
+	}
+	err := p.UnmarshalExact(&cfg)
+	var unknown *UnknownKeysError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("UnmarshalExact() error = %v, want an *UnknownKeysError", err)
+	}
+	if want := []string{"level", "server.hots"}; !reflect.DeepEqual(unknown.Keys, want) {
+		t.Errorf("unknown keys = %v, want %v", unknown.Keys, want)
+	}
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || len(invalid.Fields) != 1 || invalid.Fields[0].Key != "server.port" {
+		t.Errorf("UnmarshalExact() error = %v, want the invalid server.port", err)
+	}
+	if cfg.Server.Host != "localhost" {
+		t.Errorf("UnmarshalExact() did not fill the known keys: %+v", cfg)
+	}
+}
@@ -0,0 +1,276 @@
+package viper
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StructValidator validates the structs filled by Unmarshal. It is
+// implemented by *validator.Validate of github.com/go-playground/validator.
+type StructValidator interface {
+	Struct(s interface{}) error
+}
+
+// WithValidator replaces the built-in validation of the structs filled by
+// Unmarshal and UnmarshalKey, for instance with a go-playground/validator
+// instance supporting more tags
+func WithValidator(v StructValidator) Option {
+	return func(p *Parser) {
+		p.validator = v
+	}
+}
+
+// FieldError is a validation failure of a single field
+type FieldError struct {
+	// Key is the dot-notation config key of the field
+	Key string
+	// Field is the name of the struct field
+	Field string
+	// Tag is the failing rule, like required or min
+	Tag string
+	// Param is the parameter of the rule, like 1 in min=1
+	Param string
+	// Value is the value of the field, redacted for sensitive and
+	// referenced keys
+	Value interface{}
+	// Origin is where the value comes from, as reported by Parser.Origin,
+	// or empty when the key is not set
+	Origin string
+}
+
+func (e FieldError) String() string {
+	rule := e.Tag
+	if e.Param != "" {
+		rule += "=" + e.Param
+	}
+	if e.Origin != "" {
+		return fmt.Sprintf("%s: %v fails %s (from %s)", e.Key, e.Value, rule, e.Origin)
+	}
+	return fmt.Sprintf("%s: %v fails %s", e.Key, e.Value, rule)
+}
+
+// ValidationError lists every field of an unmarshaled struct failing its
+// `validate` tag
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.String()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// validateStruct checks the `validate` tags of target once it is filled.
+// The built-in rules are required, omitempty, min, max, len, eq, ne, gt,
+// gte, lt, lte, oneof and dive, with the semantics of go-playground/validator:
+// sizes apply to the length of strings, slices and maps and durations
+// accept parameters like 1s. Nested structs are always checked.
+func (p *Parser) validateStruct(target interface{}, prefix string) error {
+	rv := reflect.ValueOf(target)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	if p.validator != nil {
+		return p.validator.Struct(rv.Addr().Interface())
+	}
+
+	var fields []FieldError
+	if err := validateFields(rv, prefix, &fields); err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		for i := range fields {
+			fields[i].Origin = p.origin(fields[i].Key)
+			if p.concealed(fields[i].Key) {
+				fields[i].Value = redacted
+			}
+		}
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func validateFields(rv reflect.Value, prefix string, out *[]FieldError) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+		name := field.Name
+		if tag[0] != "" {
+			name = tag[0]
+		}
+		if name == "-" {
+			continue
+		}
+		key := prefix
+		squash := field.Anonymous && tag[0] == ""
+		for _, opt := range tag[1:] {
+			squash = squash || opt == "squash"
+		}
+		if !squash {
+			key = joinPath(prefix, strings.ToLower(name))
+		}
+
+		fv := rv.Field(i)
+		if rules := field.Tag.Get("validate"); rules != "" && rules != "-" {
+			if err := validateValue(fv, field.Name, key, strings.Split(rules, ","), out); err != nil {
+				return err
+			}
+		} else if err := validateNested(fv, key, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested checks the fields of the structs held by v
+func validateNested(v reflect.Value, key string, out *[]FieldError) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct && v.Type() != timeType {
+		return validateFields(v, key, out)
+	}
+	return nil
+}
+
+func validateValue(v reflect.Value, field, key string, rules []string, out *[]FieldError) error {
+	for i, rule := range rules {
+		tag, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch tag {
+		case "":
+			continue
+		case "omitempty":
+			if v.IsZero() {
+				return nil
+			}
+			continue
+		case "dive":
+			inner := v
+			for inner.Kind() == reflect.Ptr && !inner.IsNil() {
+				inner = inner.Elem()
+			}
+			switch inner.Kind() {
+			case reflect.Slice, reflect.Array:
+				for j := 0; j < inner.Len(); j++ {
+					if err := validateValue(inner.Index(j), field, fmt.Sprintf("%s[%d]", key, j), rules[i+1:], out); err != nil {
+						return err
+					}
+				}
+			case reflect.Map:
+				iter := inner.MapRange()
+				for iter.Next() {
+					if err := validateValue(iter.Value(), field, joinPath(key, fmt.Sprint(iter.Key().Interface())), rules[i+1:], out); err != nil {
+						return err
+					}
+				}
+			default:
+				return fmt.Errorf("field %s: dive on a %s", field, inner.Kind())
+			}
+			return nil
+		}
+
+		ok, err := checkRule(v, tag, param)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field, err)
+		}
+		if !ok {
+			*out = append(*out, FieldError{Key: key, Field: field, Tag: tag, Param: param, Value: v.Interface()})
+			// the other rules of a missing value would only repeat it
+			if tag == "required" {
+				return nil
+			}
+		}
+	}
+	return validateNested(v, key, out)
+}
+
+// checkRule reports whether v satisfies a single rule
+func checkRule(v reflect.Value, tag, param string) (bool, error) {
+	switch tag {
+	case "required":
+		return !v.IsZero(), nil
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if s == option {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "min", "max", "len", "eq", "ne", "gt", "gte", "lt", "lte":
+	default:
+		return false, fmt.Errorf("unknown validation rule %q", tag)
+	}
+
+	n, limit, err := measure(v, param)
+	if err != nil {
+		return false, fmt.Errorf("rule %s=%s: %w", tag, param, err)
+	}
+	switch tag {
+	case "min", "gte":
+		return n >= limit, nil
+	case "max", "lte":
+		return n <= limit, nil
+	case "len", "eq":
+		return n == limit, nil
+	case "ne":
+		return n != limit, nil
+	case "gt":
+		return n > limit, nil
+	}
+	return n < limit, nil
+}
+
+// measure returns the quantity rules compare for v, its length or its
+// value, along with the rule parameter
+func measure(v reflect.Value, param string) (float64, float64, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, 0, nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(param)
+		if err != nil {
+			return 0, 0, err
+		}
+		return float64(v.Int()), float64(d), nil
+	}
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), limit, nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), limit, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), limit, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), limit, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), limit, nil
+	}
+	return 0, 0, fmt.Errorf("cannot compare a %s", v.Kind())
+}
@@ -0,0 +1,146 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubValidator struct{ calls int }
+
+func (v *stubValidator) Struct(interface{}) error {
+	v.calls++
+	return errors.New("stub")
+}
+
+func TestParser_UnmarshalValidate(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+name: ""
+server:
+  port: 0
+  mode: soap
+  timeout: 100ms
+hosts: [a, ""]
+limits:
+  conns: 200
+  ratio: 0.5
+`})
+
+	type server struct {
+		Port    int           `validate:"required,min=1,max=65535"`
+		Mode    string        `validate:"oneof=http grpc"`
+		Timeout time.Duration `validate:"gte=1s"`
+	}
+	type limits struct {
+		Conns int     `validate:"lte=100"`
+		Ratio float64 `validate:"gt=0,lt=1"`
+		Burst int     `validate:"omitempty,min=10"`
+	}
+	type config struct {
+		Name   string   `validate:"required"`
+		Server server   `mapstructure:"server"`
+		Hosts  []string `validate:"min=1,dive,required"`
+		Limits *limits
+		Tags   []string `validate:"len=0"`
+	}
+
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg config
+	err := p.Unmarshal(&cfg)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Unmarshal() error = %v, want a *ValidationError", err)
+	}
+	got := make([]string, len(verr.Fields))
+	for i, f := range verr.Fields {
+		got[i] = f.Key + " " + f.Tag
+	}
+	want := []string{
+		"name required",
+		"server.port required",
+		"server.mode oneof",
+		"server.timeout gte",
+		"hosts[1] required",
+		"limits.conns lte",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("failing fields = %q, want %q", got, want)
+	}
+	if cfg.Server.Mode != "soap" {
+		t.Errorf("Unmarshal() did not decode before validating: %+v", cfg)
+	}
+
+	t.Run("key", func(t *testing.T) {
+		var s server
+		err := p.UnmarshalKey("server", &s)
+		if err == nil || !strings.Contains(err.Error(), "server.port: 0 fails required") {
+			t.Errorf("UnmarshalKey() error = %v, want server.port reported", err)
+		}
+		var missing struct {
+			Addr string `validate:"required"`
+		}
+		if err := p.UnmarshalKey("missing", &missing); !errors.As(err, &verr) {
+			t.Errorf("UnmarshalKey() of a missing key error = %v, want required fields reported", err)
+		}
+	})
+
+	t.Run("unknown rule", func(t *testing.T) {
+		var bad struct {
+			Name string `validate:"email"`
+		}
+		if err := p.Unmarshal(&bad); err == nil || !strings.Contains(err.Error(), `unknown validation rule "email"`) {
+			t.Errorf("Unmarshal() error = %v, want an unknown rule error", err)
+		}
+	})
+
+	t.Run("custom validator", func(t *testing.T) {
+		v := &stubValidator{}
+		p := New(WithValidator(v))
+		if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+			t.Fatal(err)
+		}
+		var cfg config
+		if err := p.Unmarshal(&cfg); err == nil || err.Error() != "stub" || v.calls != 1 {
+			t.Errorf("Unmarshal() error = %v after %d calls, want the custom validator error", err, v.calls)
+		}
+	})
+}
+
+func TestParser_UnmarshalValidateRedacts(t *testing.T) {
+	t.Setenv("VALIDATETEST_REGION", "r3gi0n")
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": "db:\n  password: s3cr3t\n  port: 5432\nregion: $ref{env:VALIDATETEST_REGION}\n",
+	})
+	p := New()
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		DB struct {
+			Password string `validate:"min=16"`
+			Port     int    `validate:"max=100"`
+		}
+		Region string `validate:"oneof=eu us"`
+	}
+	err := p.Unmarshal(&cfg)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 3 {
+		t.Fatalf("Unmarshal() error = %v, want 3 failing fields", err)
+	}
+	msg := err.Error()
+	for _, secret := range []string{"s3cr3t", "r3gi0n"} {
+		if strings.Contains(msg, secret) {
+			t.Errorf("Unmarshal() error = %q, leaks %q", msg, secret)
+		}
+	}
+	if !strings.Contains(msg, "db.port: 5432 fails max=100") || strings.Count(msg, redacted) != 2 {
+		t.Errorf("Unmarshal() error = %q, want the port shown and the secrets redacted", msg)
+	}
+}
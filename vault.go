@@ -0,0 +1,280 @@
+package viper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+const (
+	// DefaultVaultRefresh is how often a watched Vault resolver checks the
+	// secrets it resolved
+	DefaultVaultRefresh = time.Minute
+	// DefaultVaultTimeout bounds each request of a Vault resolver using the
+	// default client
+	DefaultVaultTimeout = 10 * time.Second
+)
+
+// VaultResolver resolves $ref{vault:path#field} references with the field of
+// the secret read from HashiCorp Vault at path, as in
+// $ref{vault:secret/data/myapp#password}. KV version 2 secrets are
+// unwrapped. Register it with WithResolver("vault", ...) and watch it with
+// WatchResolver, which renews the leases of the secrets read and reloads
+// the config when a secret rotates.
+type VaultResolver struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+	refresh   time.Duration
+
+	mu      sync.Mutex
+	secrets map[string]*vaultSecret
+}
+
+// vaultSecret is a secret read by a resolution, with its lease
+type vaultSecret struct {
+	fields    map[string]interface{}
+	leaseID   string
+	lease     time.Duration
+	renewable bool
+	readAt    time.Time
+}
+
+// VaultOption configures a VaultResolver
+type VaultOption func(*VaultResolver)
+
+// WithVaultToken sets the token authenticating the requests, VAULT_TOKEN by
+// default
+func WithVaultToken(token string) VaultOption {
+	return func(v *VaultResolver) {
+		v.token = token
+	}
+}
+
+// WithVaultNamespace sets the Vault Enterprise namespace of the requests
+func WithVaultNamespace(namespace string) VaultOption {
+	return func(v *VaultResolver) {
+		v.namespace = namespace
+	}
+}
+
+// WithVaultClient sets the HTTP client sending the requests, a client
+// giving up after DefaultVaultTimeout by default
+func WithVaultClient(client *http.Client) VaultOption {
+	return func(v *VaultResolver) {
+		v.client = client
+	}
+}
+
+// WithVaultRefresh sets how often a watch checks the secrets it resolved,
+// DefaultVaultRefresh by default
+func WithVaultRefresh(d time.Duration) VaultOption {
+	return func(v *VaultResolver) {
+		v.refresh = d
+	}
+}
+
+// Vault returns a resolver reading secrets from the Vault server at addr,
+// VAULT_ADDR when empty
+func Vault(addr string, opts ...VaultOption) *VaultResolver {
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	v := &VaultResolver{
+		addr:    strings.TrimSuffix(addr, "/"),
+		token:   os.Getenv("VAULT_TOKEN"),
+		client:  &http.Client{Timeout: DefaultVaultTimeout},
+		refresh: DefaultVaultRefresh,
+		secrets: make(map[string]*vaultSecret),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Resolve returns the field of the secret named by ref, as path#field
+func (v *VaultResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || field == "" {
+		return "", fmt.Errorf("vault reference %q has no #field", ref)
+	}
+	secret, err := v.read(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	v.mu.Lock()
+	v.secrets[path] = secret
+	v.mu.Unlock()
+
+	value, ok := secret.fields[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %q has no field %q", path, field)
+	}
+	return vaultString(value)
+}
+
+// Watch checks the secrets resolved so far every refresh interval. Leases
+// past half their duration are renewed; secrets without a lease, or whose
+// lease cannot be renewed, are read again and onChange is called when they
+// changed.
+func (v *VaultResolver) Watch(ctx context.Context, onChange func(), onError func(error)) {
+	ticker := time.NewTicker(v.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if v.check(ctx, onError) {
+			onChange()
+		}
+	}
+}
+
+// check renews or reads again the secrets resolved so far and reports
+// whether one of them changed
+func (v *VaultResolver) check(ctx context.Context, onError func(error)) bool {
+	v.mu.Lock()
+	secrets := make(map[string]*vaultSecret, len(v.secrets))
+	for path, s := range v.secrets {
+		secrets[path] = s
+	}
+	v.mu.Unlock()
+
+	changed := false
+	for path, s := range secrets {
+		if s.leaseID != "" {
+			if time.Since(s.readAt) < s.lease/2 {
+				continue
+			}
+			if s.renewable {
+				err := v.renew(ctx, s)
+				if err == nil {
+					continue
+				}
+				onError(err)
+			}
+		}
+		fresh, err := v.read(ctx, path)
+		if err != nil {
+			onError(err)
+			continue
+		}
+		v.mu.Lock()
+		v.secrets[path] = fresh
+		v.mu.Unlock()
+		if !vaultEqual(s.fields, fresh.fields) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Errors        []string               `json:"errors"`
+}
+
+// read reads the secret at path
+func (v *VaultResolver) read(ctx context.Context, path string) (*vaultSecret, error) {
+	var resp vaultResponse
+	if err := v.do(ctx, http.MethodGet, strings.TrimPrefix(path, "/"), nil, &resp); err != nil {
+		return nil, fmt.Errorf("vault: reading %q: %w", path, err)
+	}
+	fields := resp.Data
+	// KV version 2 nests the secret under data, next to its metadata
+	if inner, ok := fields["data"].(map[string]interface{}); ok {
+		if _, ok := fields["metadata"]; ok {
+			fields = inner
+		}
+	}
+	return &vaultSecret{
+		fields:    fields,
+		leaseID:   resp.LeaseID,
+		lease:     time.Duration(resp.LeaseDuration) * time.Second,
+		renewable: resp.Renewable,
+		readAt:    time.Now(),
+	}, nil
+}
+
+// renew extends the lease of s
+func (v *VaultResolver) renew(ctx context.Context, s *vaultSecret) error {
+	body := map[string]interface{}{"lease_id": s.leaseID, "increment": int(s.lease / time.Second)}
+	var resp vaultResponse
+	if err := v.do(ctx, http.MethodPut, "sys/leases/renew", body, &resp); err != nil {
+		return fmt.Errorf("vault: renewing lease %q: %w", s.leaseID, err)
+	}
+	v.mu.Lock()
+	s.lease = time.Duration(resp.LeaseDuration) * time.Second
+	s.renewable = resp.Renewable
+	s.readAt = time.Now()
+	v.mu.Unlock()
+	return nil
+}
+
+func (v *VaultResolver) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+"/v1/"+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := readBody(ctx, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		var failure vaultResponse
+		if err == nil && json.Unmarshal(b, &failure) == nil && len(failure.Errors) > 0 {
+			return fmt.Errorf("%s: %s", resp.Status, strings.Join(failure.Errors, "; "))
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// vaultString formats a secret field as the string replacing a reference
+func vaultString(v interface{}) (string, error) {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+	return cast.ToStringE(v)
+}
+
+func vaultEqual(a, b map[string]interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
@@ -0,0 +1,131 @@
+package viper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVault serves a KV version 2 secret and a leased database secret
+type fakeVault struct {
+	mu       sync.Mutex
+	password string
+	renewals int
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "root" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/v1/secret/data/myapp":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"password": f.password, "port": 5432},
+				"metadata": map[string]interface{}{"version": 1},
+			},
+		})
+	case "/v1/database/creds/app":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lease_id":       "database/creds/app/1",
+			"lease_duration": 1,
+			"renewable":      true,
+			"data":           map[string]interface{}{"username": "v-app"},
+		})
+	case "/v1/sys/leases/renew":
+		f.renewals++
+		json.NewEncoder(w).Encode(map[string]interface{}{"lease_id": "database/creds/app/1", "lease_duration": 1, "renewable": true})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func TestVaultResolver(t *testing.T) {
+	vault := &fakeVault{password: "s3cret"}
+	srv := httptest.NewServer(vault)
+	defer srv.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+db:
+  user: $ref{vault:database/creds/app#username}
+  password: $ref{vault:secret/data/myapp#password}
+  port: $ref{vault:secret/data/myapp#port}
+`,
+		"missing.yaml": "db:\n  password: $ref{vault:secret/data/other#password}\n",
+		"nofield.yaml": "db:\n  password: $ref{vault:secret/data/myapp}\n",
+	})
+
+	resolver := Vault(srv.URL, WithVaultToken("root"), WithVaultRefresh(20*time.Millisecond))
+	p := New(WithResolver("vault", resolver))
+	if _, err := p.Parse(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"db.user": "v-app", "db.password": "s3cret", "db.port": "5432"} {
+		if got := p.GetString(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	reloaded := make(chan struct{}, 1)
+	if err := p.WatchResolver("vault", func() {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch("vault")
+
+	vault.mu.Lock()
+	vault.password = "rotated"
+	vault.mu.Unlock()
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reload after the secret rotated")
+	}
+	if got := p.GetString("db.password"); got != "rotated" {
+		t.Errorf("db.password = %q after the rotation, want rotated", got)
+	}
+
+	// the database lease lasts a second and is renewed past its half
+	time.Sleep(700 * time.Millisecond)
+	vault.mu.Lock()
+	renewals := vault.renewals
+	vault.mu.Unlock()
+	if renewals == 0 {
+		t.Error("the database lease was not renewed")
+	}
+
+	errTests := []struct {
+		name    string
+		file    string
+		token   string
+		wantErr string
+	}{
+		{"missing secret", "missing.yaml", "root", "404"},
+		{"no field", "nofield.yaml", "root", "has no #field"},
+		{"denied", "config.yaml", "other", "permission denied"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(WithResolver("vault", Vault(srv.URL, WithVaultToken(tt.token))))
+			_, err := p.Parse(filepath.Join(dir, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// Package vipertest provides helpers to test services against their
+// configuration files
+package vipertest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	viper "github.com/nexenio/nexen-viper"
+)
+
+// AssertSatisfies parses the config file with a parser built from opts and
+// checks it fills target, a pointer to the service's config struct, with
+// UnmarshalExact: every key must be known to target and the `validate` tags
+// of target must hold. The test fails with a report listing every problem,
+// which lets a service keep a contract test against its example config.
+func AssertSatisfies(t testing.TB, file string, target interface{}, opts ...viper.Option) {
+	t.Helper()
+	p := viper.New(opts...)
+	if _, err := p.Parse(file); err != nil {
+		t.Fatalf("config %s cannot be parsed: %v", file, err)
+		return
+	}
+	if err := p.UnmarshalExact(target); err != nil {
+		t.Errorf("config %s does not satisfy %T:\n%s", file, target, report(err))
+	}
+}
+
+// report lists the problems of err one per line
+func report(err error) string {
+	var lines []string
+	var unknown *viper.UnknownKeysError
+	if errors.As(err, &unknown) {
+		for _, k := range unknown.Keys {
+			lines = append(lines, fmt.Sprintf("\tunknown key %s", k))
+		}
+	}
+	var invalid *viper.ValidationError
+	if errors.As(err, &invalid) {
+		for _, f := range invalid.Fields {
+			lines = append(lines, "\t"+f.String())
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "\t"+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
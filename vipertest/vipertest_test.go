@@ -0,0 +1,73 @@
+package vipertest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recorder captures the failures reported by AssertSatisfies
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertSatisfies(t *testing.T) {
+	type server struct {
+		Host string `validate:"required"`
+		Port int    `validate:"min=1,max=65535"`
+	}
+	type appConfig struct {
+		Name   string `validate:"required"`
+		Server server
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"valid", "name: api\nserver:\n  host: localhost\n  port: 8080\n", nil},
+		{"unknown keys", "name: api\nserver:\n  host: localhost\n  port: 8080\n  hots: x\nlevel: debug\n",
+			[]string{"unknown key level", "unknown key server.hots"}},
+		{"invalid fields", "server:\n  host: localhost\n  port: 70000\n",
+			[]string{"name:  fails required", "server.port: 70000 fails max=65535"}},
+		{"unparsable", "name: [api\n", []string{"cannot be parsed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(file, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			r := &recorder{TB: t}
+			AssertSatisfies(r, file, &appConfig{})
+			if len(tt.want) == 0 {
+				if len(r.failures) != 0 {
+					t.Errorf("AssertSatisfies() failed: %v", r.failures)
+				}
+				return
+			}
+			if len(r.failures) != 1 {
+				t.Fatalf("AssertSatisfies() failures = %v, want one", r.failures)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(r.failures[0], want) {
+					t.Errorf("AssertSatisfies() report = %q, want it to contain %q", r.failures[0], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+//go:build !js && !wasip1 && !tinygo
+
+package viper
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchFile calls onChange whenever configFile is written, created or
+// replaced, until the returned stop function is called. The directory is
+// watched rather than the file so atomic saves are picked up too. When the
+// file is a symlink, as in a mounted Kubernetes ConfigMap where it links
+// through the ..data link kubelet swaps on every update, the directories of
+// the links it resolves through and of the real file are watched as well,
+// and the watches follow the chain whenever it changes.
+func watchFile(configFile string, logger *slog.Logger, onChange func()) (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	configFile = filepath.Clean(configFile)
+	if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	chain := linkChain(configFile)
+	dirs := followChain(watcher, configFile, nil, chain, logger)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if _, err := os.Stat(configFile); err != nil {
+					// removed or dangling while being replaced
+					continue
+				}
+				current := linkChain(configFile)
+				swapped := !slices.Equal(current, chain)
+				if swapped {
+					dirs = followChain(watcher, configFile, dirs, current, logger)
+					chain = current
+				}
+				// the chain was swapped, or the file or the real file behind
+				// it was written
+				if swapped || (event.Has(fsnotify.Write|fsnotify.Create) && isChainFile(event.Name, chain)) {
+					onChange()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("config watcher error", "file", configFile, "error", err)
+			}
+		}
+	}()
+
+	return func() {
+		watcher.Close()
+		<-done
+	}, nil
+}
+
+// maxLinkHops bounds the symlink chains followed, against link loops
+const maxLinkHops = 32
+
+// linkChain returns configFile followed by the links it resolves through
+// and the real file, if they differ from it
+func linkChain(configFile string) []string {
+	chain := []string{configFile}
+	path := configFile
+	for i := 0; i < maxLinkHops; i++ {
+		st, err := os.Lstat(path)
+		if err != nil || st.Mode()&os.ModeSymlink == 0 {
+			break
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			break
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = filepath.Clean(target)
+		chain = append(chain, path)
+	}
+	// directory links along the path, like ..data, are resolved here
+	if real, err := filepath.EvalSymlinks(path); err == nil && real != path {
+		chain = append(chain, real)
+	}
+	return chain
+}
+
+// followChain watches the real directories of the files of chain besides
+// the one of configFile, always watched, and stops watching the ones of
+// dirs no longer needed. It returns the directories watched.
+func followChain(watcher *fsnotify.Watcher, configFile string, dirs, chain []string, logger *slog.Logger) []string {
+	base := realDir(configFile)
+	var next []string
+	for _, f := range chain[1:] {
+		dir := realDir(f)
+		if dir == base || slices.Contains(next, dir) {
+			continue
+		}
+		next = append(next, dir)
+		if slices.Contains(dirs, dir) {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			logger.Warn("cannot watch the target of a config file link", "file", configFile, "dir", dir, "error", err)
+		}
+	}
+	for _, dir := range dirs {
+		if !slices.Contains(next, dir) {
+			// removed directories are no longer watched already
+			_ = watcher.Remove(dir)
+		}
+	}
+	return next
+}
+
+// realDir returns the directory of file with its symlinks resolved
+func realDir(file string) string {
+	dir := filepath.Dir(file)
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		return real
+	}
+	return dir
+}
+
+// isChainFile reports whether name, as reported by the watcher, is a file of
+// chain
+func isChainFile(name string, chain []string) bool {
+	name = filepath.Clean(name)
+	if slices.Contains(chain, name) {
+		return true
+	}
+	real := filepath.Join(realDir(name), filepath.Base(name))
+	return real == chain[len(chain)-1]
+}
+
+// watchDir calls onChange whenever a file of dir accepted by match is
+// written, created, removed or renamed, or the ..data link of a mounted
+// ConfigMap is swapped, until the returned stop function is called
+func watchDir(dir string, logger *slog.Logger, match func(name string) bool, onChange func()) (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Base(event.Name)
+				if (match(name) || name == configMapData) && !event.Has(fsnotify.Chmod) {
+					onChange()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("config watcher error", "dir", dir, "error", err)
+			}
+		}
+	}()
+
+	return func() {
+		watcher.Close()
+		<-done
+	}, nil
+}
@@ -0,0 +1,16 @@
+//go:build js || wasip1 || tinygo
+
+package viper
+
+import "log/slog"
+
+// watchFile polls configFile on platforms without file system
+// notifications, like WebAssembly and TinyGo targets
+func watchFile(configFile string, logger *slog.Logger, onChange func()) (stop func(), err error) {
+	return pollFile(configFile, pollInterval, logger, onChange)
+}
+
+// watchDir polls dir on platforms without file system notifications
+func watchDir(dir string, logger *slog.Logger, match func(name string) bool, onChange func()) (stop func(), err error) {
+	return pollDir(dir, pollInterval, logger, match, onChange)
+}
@@ -0,0 +1,129 @@
+//go:build !js && !wasip1 && !tinygo
+
+package viper
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// updateConfigMap swaps the ..data link of a mounted ConfigMap to a new
+// version of its files, the way kubelet does
+func updateConfigMap(t *testing.T, dir, version string, files map[string]string) {
+	t.Helper()
+	if err := os.Mkdir(filepath.Join(dir, version), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, version, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old, _ := os.Readlink(filepath.Join(dir, configMapData))
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(version, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, configMapData)); err != nil {
+		t.Fatal(err)
+	}
+	if old != "" {
+		if err := os.RemoveAll(filepath.Join(dir, old)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParser_WatchConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	updateConfigMap(t, dir, "..2024_01", map[string]string{"config.yaml": "rev: 1\n"})
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.Symlink(filepath.Join(configMapData, "config.yaml"), configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan struct{}, 10)
+	if err := p.Watch(configFile, func() { changes <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch(configFile)
+
+	// the watches must follow the swaps, not only see the first one
+	for rev, version := range []string{"..2024_02", "..2024_03"} {
+		updateConfigMap(t, dir, version, map[string]string{"config.yaml": "rev: " + strconv.Itoa(2+rev) + "\n"})
+		waitRev(t, p, changes, 2+rev)
+	}
+
+	// a file of the current version written in place
+	real := filepath.Join(dir, "..2024_03", "config.yaml")
+	if err := os.WriteFile(real, []byte("rev: 4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitRev(t, p, changes, 4)
+}
+
+func TestParser_WatchSymlinkElsewhere(t *testing.T) {
+	linkDir, targetDir := t.TempDir(), t.TempDir()
+	target := filepath.Join(targetDir, "app.yaml")
+	if err := os.WriteFile(target, []byte("rev: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(linkDir, "config.yaml")
+	if err := os.Symlink(target, configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New()
+	if _, err := p.Parse(configFile); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan struct{}, 10)
+	if err := p.Watch(configFile, func() { changes <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopWatch(configFile)
+
+	if err := os.WriteFile(target, []byte("rev: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitRev(t, p, changes, 2)
+
+	// relinked to a file of a third directory
+	otherDir := t.TempDir()
+	other := filepath.Join(otherDir, "app.yaml")
+	if err := os.WriteFile(other, []byte("rev: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmp := configFile + ".tmp"
+	if err := os.Symlink(other, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, configFile); err != nil {
+		t.Fatal(err)
+	}
+	waitRev(t, p, changes, 3)
+	if err := os.WriteFile(other, []byte("rev: 4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitRev(t, p, changes, 4)
+}
+
+// waitRev waits for the reload setting rev to want
+func waitRev(t *testing.T, p *Parser, changes <-chan struct{}, want int) {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for p.GetInt("rev") != want {
+		select {
+		case <-changes:
+		case <-deadline:
+			t.Fatalf("rev = %d, want %d after the update", p.GetInt("rev"), want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package viper
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// XMLAttributes selects how the attributes of XML elements are decoded
+type XMLAttributes int
+
+const (
+	// XMLAttributesAsKeys decodes attributes as keys of their element, like
+	// its child elements: <server port="80"/> reads as server.port. An
+	// attribute and a child element of the same name are duplicate keys.
+	XMLAttributesAsKeys XMLAttributes = iota
+	// XMLAttributesPrefixed decodes attributes as keys starting with @,
+	// keeping them apart from the child elements: server.@port
+	XMLAttributesPrefixed
+	// XMLAttributesIgnored drops the attributes
+	XMLAttributesIgnored
+)
+
+// WithXMLAttributes sets how the attributes of XML files are decoded,
+// XMLAttributesAsKeys by default
+func WithXMLAttributes(a XMLAttributes) Option {
+	return func(p *Parser) {
+		p.xmlAttributes = a
+	}
+}
+
+// xmlText is the key holding the text of elements that also have child
+// elements or attributes
+const xmlText = "#text"
+
+// xmlCodec decodes XML files. The children of the root element are the
+// top-level keys, whatever the root is called. Elements holding only text
+// are values, the others are sections, with their text under #text, and
+// sibling elements of the same name form a list. Namespaces are dropped and
+// all values are strings, converted by the getters. Writes a <config> root.
+type xmlCodec struct {
+	p *Parser
+}
+
+func (c *xmlCodec) Decode(b []byte, v map[string]interface{}) error {
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	dups := &duplicates{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return errors.New("xml: no root element")
+		}
+		if err != nil {
+			return fmt.Errorf("xml: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			root, err := c.element(dec, start, nil, dups)
+			if err != nil {
+				return fmt.Errorf("xml: %w", err)
+			}
+			if m, ok := root.(map[string]interface{}); ok {
+				for k, val := range m {
+					v[k] = val
+				}
+			} else if root != "" {
+				return fmt.Errorf("xml: the root element <%s> only holds text", start.Name.Local)
+			}
+			return dups.err()
+		}
+	}
+}
+
+// element decodes the element opened by start, at path
+func (c *xmlCodec) element(dec *xml.Decoder, start xml.StartElement, path pathStack, dups *duplicates) (interface{}, error) {
+	m := make(map[string]interface{})
+	seen := keySet{}
+	// lists holds the keys set by child elements, by lower-case key
+	lists := make(map[string]string)
+	line, _ := dec.InputPos()
+
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" || c.p.xmlAttributes == XMLAttributesIgnored {
+			continue
+		}
+		key := attr.Name.Local
+		if c.p.xmlAttributes == XMLAttributesPrefixed {
+			key = "@" + key
+		}
+		if first, dup := seen.add(key, Location{Line: line}); dup {
+			dups.report(path.with(first.key), first.loc, Location{Line: line})
+		}
+		m[key] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.StartElement:
+			name := tok.Name.Local
+			line, _ := dec.InputPos()
+			if key, ok := lists[strings.ToLower(name)]; ok {
+				// a repeated element, appended to the list of its siblings
+				items, isList := m[key].([]interface{})
+				if !isList {
+					items = []interface{}{m[key]}
+				}
+				path.pushKey(key)
+				path.pushIndex(len(items))
+				value, err := c.element(dec, tok, path, dups)
+				path.pop()
+				path.pop()
+				if err != nil {
+					return nil, err
+				}
+				m[key] = append(items, value)
+				continue
+			}
+			if first, dup := seen.add(name, Location{Line: line}); dup {
+				dups.report(path.with(first.key), first.loc, Location{Line: line})
+			}
+			path.pushKey(name)
+			value, err := c.element(dec, tok, path, dups)
+			path.pop()
+			if err != nil {
+				return nil, err
+			}
+			lists[strings.ToLower(name)] = name
+			m[name] = value
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return value, nil
+			}
+			if value != "" {
+				m[xmlText] = value
+			}
+			return m, nil
+		}
+	}
+}
+
+// xmlName matches the keys that can be written as XML element names
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func (c *xmlCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := c.encode(enc, "config", v); err != nil {
+		return nil, fmt.Errorf("xml: %w", err)
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, fmt.Errorf("xml: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// encode writes value as the element called name, a list as repeated
+// elements
+func (c *xmlCodec) encode(enc *xml.Encoder, name string, value interface{}) error {
+	if !xmlName.MatchString(name) {
+		return fmt.Errorf("key %q cannot be written as an element", name)
+	}
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			if _, nested := item.([]interface{}); nested {
+				return fmt.Errorf("%s: nested lists cannot be written as xml", name)
+			}
+			if err := c.encode(enc, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	m, isMap := toStringMap(value)
+	if !isMap {
+		if value == nil {
+			value = ""
+		}
+		return enc.EncodeElement(fmt.Sprint(value), start)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	children := keys[:0:0]
+	for _, k := range keys {
+		attr, isAttr := strings.CutPrefix(k, "@")
+		switch {
+		case isAttr && c.p.xmlAttributes == XMLAttributesPrefixed:
+			if !xmlName.MatchString(attr) {
+				return fmt.Errorf("%s: key %q cannot be written as an attribute", name, k)
+			}
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr}, Value: fmt.Sprint(m[k])})
+		case k != xmlText:
+			children = append(children, k)
+		}
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if text, ok := m[xmlText]; ok {
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(text))); err != nil {
+			return err
+		}
+	}
+	for _, k := range children {
+		if err := c.encode(enc, k, m[k]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
@@ -0,0 +1,105 @@
+package viper
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_XML(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- legacy settings -->
+<config xmlns="urn:nexen" xmlns:x="urn:extra">
+  <name>api</name>
+  <x:region>eu</x:region>
+  <backend host="a" weight="2"/>
+  <backend host="b">primary</backend>
+  <log level="debug">stdout</log>
+  <empty/>
+</config>
+`
+	tests := []struct {
+		attributes XMLAttributes
+		want       map[string]interface{}
+	}{
+		{XMLAttributesAsKeys, map[string]interface{}{
+			"name":   "api",
+			"region": "eu",
+			"backend": []interface{}{
+				map[string]interface{}{"host": "a", "weight": "2"},
+				map[string]interface{}{"host": "b", "#text": "primary"},
+			},
+			"log":   map[string]interface{}{"level": "debug", "#text": "stdout"},
+			"empty": "",
+		}},
+		{XMLAttributesPrefixed, map[string]interface{}{
+			"name":   "api",
+			"region": "eu",
+			"backend": []interface{}{
+				map[string]interface{}{"@host": "a", "@weight": "2"},
+				map[string]interface{}{"@host": "b", "#text": "primary"},
+			},
+			"log":   map[string]interface{}{"@level": "debug", "#text": "stdout"},
+			"empty": "",
+		}},
+		{XMLAttributesIgnored, map[string]interface{}{
+			"name":    "api",
+			"region":  "eu",
+			"backend": []interface{}{"", "primary"},
+			"log":     "stdout",
+			"empty":   "",
+		}},
+	}
+	for _, tt := range tests {
+		p := New(WithXMLAttributes(tt.attributes))
+		got := map[string]interface{}{}
+		if err := p.codecs["xml"].Decode([]byte(content), got); err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(got, tt.want) {
+			t.Errorf("attributes %d: Decode() = %v, want %v", tt.attributes, got, tt.want)
+		}
+
+		// written back, the attributes read the same
+		b, err := p.codecs["xml"].Encode(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roundTrip := map[string]interface{}{}
+		if err := p.codecs["xml"].Decode(b, roundTrip); err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(roundTrip, tt.want) {
+			t.Errorf("attributes %d: Decode(Encode()) = %v, want %v", tt.attributes, roundTrip, tt.want)
+		}
+	}
+
+	errTests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"malformed", "<config><a></config>", "xml: "},
+		{"text root", "<config>text</config>", "xml: the root element <config> only holds text"},
+		{"no root", "<!-- nothing -->", "xml: no root element"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"bad.xml": tt.content})
+			_, err := New().Parse(filepath.Join(dir, "bad.xml"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("duplicates", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{"dup.xml": "<config>\n  <server port=\"1\">\n    <port>2</port>\n  </server>\n</config>\n"})
+		_, err := New().Parse(filepath.Join(dir, "dup.xml"))
+		var dupErr *DuplicateKeyError
+		if !errors.As(err, &dupErr) || dupErr.Duplicates[0].Key != "server.port" {
+			t.Errorf("Parse() error = %v, want server.port reported as a duplicate", err)
+		}
+	})
+}
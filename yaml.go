@@ -0,0 +1,269 @@
+package viper
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WithYAML11TaggedBooleans accepts the YAML 1.1 boolean spellings, such as
+// yes, on, Y or NO, when they are explicitly tagged, as in
+// `enabled: !!bool yes`, which then reads as true. Without it such values
+// fail to decode. Untagged, these spellings are strings either way: like
+// the YAML 1.2 core schema, the parser only resolves true and false (in
+// lower, title or upper case) to booleans.
+func WithYAML11TaggedBooleans() Option {
+	return func(p *Parser) {
+		p.yaml11TaggedBooleans = true
+	}
+}
+
+// DefaultYAMLAliasBudget is the number of nodes YAML aliases may expand to
+// unless changed with WithYAMLAliasBudget
+const DefaultYAMLAliasBudget = 100000
+
+// WithYAMLAliasBudget caps the number of nodes produced by expanding YAML
+// aliases and merge keys, rejecting "billion laughs" documents with a
+// *LimitError before they allocate. A budget of zero or less disables the check.
+func WithYAMLAliasBudget(nodes int) Option {
+	return func(p *Parser) {
+		p.yamlAliasBudget = nodes
+	}
+}
+
+// yaml12Booleans lists the boolean spellings of the YAML 1.2 core schema
+var yaml12Booleans = map[string]bool{
+	"true": true, "True": true, "TRUE": true,
+	"false": false, "False": false, "FALSE": false,
+}
+
+// yaml11Booleans lists the additional boolean spellings of YAML 1.1
+var yaml11Booleans = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"on": true, "On": true, "ON": true,
+	"n": false, "N": false, "no": false, "No": false, "NO": false,
+	"off": false, "Off": false, "OFF": false,
+}
+
+// yamlCodec decodes YAML documents node by node, so the parser decides how
+// scalars are resolved instead of leaving it to the library defaults
+type yamlCodec struct {
+	p *Parser
+}
+
+func (c *yamlCodec) Encode(v map[string]interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (c *yamlCodec) Decode(b []byte, v map[string]interface{}) error {
+	_, err := c.decodeDocument(b, v)
+	return err
+}
+
+// decodeDocument decodes b into v like Decode, also returning the document
+// node so it can serve as the layout of the file when it is written back
+func (c *yamlCodec) decodeDocument(b []byte, v map[string]interface{}) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return nil, nil // empty document
+	}
+
+	root := doc.Content[0]
+	if root.Kind == yaml.AliasNode {
+		root = root.Alias
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("yaml: line %d: the document root must be a mapping", root.Line)
+	}
+
+	d := c.decoder()
+	m, err := d.mapping(root)
+	if err != nil {
+		return nil, err
+	}
+	for k, val := range m {
+		v[k] = val
+	}
+	return &doc, d.dups.err()
+}
+
+func (c *yamlCodec) decoder() *yamlDecoder {
+	return &yamlDecoder{
+		taggedBooleans: c.p.taggedBooleans(),
+		dups:           &duplicates{},
+		budget:         c.p.yamlAliasBudget,
+		spent:          new(int),
+	}
+}
+
+type yamlDecoder struct {
+	taggedBooleans bool
+	dups           *duplicates
+	path           pathStack
+
+	// budget is the number of nodes aliases may expand to, shared through
+	// spent with the decoders of merged mappings
+	budget     int
+	spent      *int
+	aliasDepth int
+}
+
+func (d *yamlDecoder) node(n *yaml.Node) (interface{}, error) {
+	if d.aliasDepth > 0 && d.budget > 0 {
+		if *d.spent++; *d.spent > d.budget {
+			return nil, &LimitError{Limit: "alias nodes", Max: int64(d.budget), Path: d.path.String()}
+		}
+	}
+
+	switch n.Kind {
+	case yaml.AliasNode:
+		d.aliasDepth++
+		defer func() { d.aliasDepth-- }()
+		return d.node(n.Alias)
+	case yaml.MappingNode:
+		return d.mapping(n)
+	case yaml.SequenceNode:
+		out := make([]interface{}, 0, len(n.Content))
+		for i, item := range n.Content {
+			d.path.pushIndex(i)
+			v, err := d.node(item)
+			d.path.pop()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case yaml.ScalarNode:
+		return d.scalar(n)
+	}
+	return nil, fmt.Errorf("yaml: line %d: unexpected node kind %v", n.Line, n.Kind)
+}
+
+func (d *yamlDecoder) scalar(n *yaml.Node) (interface{}, error) {
+	tag := n.ShortTag()
+	switch tag {
+	case "!!str":
+		// most values are plain strings, skip the generic decoder for them
+		return n.Value, nil
+	case "!!null":
+		return nil, nil
+	}
+
+	explicit := n.Tag != "" && n.Style&yaml.TaggedStyle != 0
+	if d.taggedBooleans && tag == "!!bool" {
+		if b, ok := yaml12Booleans[n.Value]; ok {
+			return b, nil
+		}
+		if b, ok := yaml11Booleans[n.Value]; ok && explicit {
+			return b, nil
+		}
+		if !explicit {
+			return n.Value, nil
+		}
+	}
+
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (d *yamlDecoder) mapping(n *yaml.Node) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(n.Content)/2)
+
+	// Merge keys first, so explicit keys always take precedence
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].ShortTag() != "!!merge" {
+			continue
+		}
+		if err := d.merge(out, n.Content[i+1]); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(keySet, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.ShortTag() == "!!merge" {
+			continue
+		}
+		k, err := d.key(key)
+		if err != nil {
+			return nil, err
+		}
+		// keep the last occurrence, duplicates are reported once decoded
+		loc := Location{Line: key.Line}
+		if first, dup := seen.add(k, loc); dup {
+			d.dups.report(d.path.with(first.key), first.loc, loc)
+		}
+
+		d.path.pushKey(k)
+		v, err := d.node(value)
+		d.path.pop()
+		if err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (d *yamlDecoder) key(n *yaml.Node) (string, error) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("yaml: line %d: mapping keys must be scalars", n.Line)
+	}
+	return n.Value, nil
+}
+
+// merge applies a `<<` merge key. With a sequence of mappings, earlier
+// mappings take precedence over later ones.
+func (d *yamlDecoder) merge(out map[string]interface{}, n *yaml.Node) error {
+	aliased := 0
+	if n.Kind == yaml.AliasNode && n.Alias.Kind == yaml.SequenceNode {
+		n = n.Alias
+		aliased = 1
+	}
+	sources := []*yaml.Node{n}
+	if n.Kind == yaml.SequenceNode {
+		sources = n.Content
+	}
+	for _, src := range sources {
+		aliasDepth := d.aliasDepth + aliased
+		if src.Kind == yaml.AliasNode {
+			src = src.Alias
+			aliasDepth++
+		}
+		if src.Kind != yaml.MappingNode {
+			return errors.New("yaml: map merge requires map or sequence of maps as the value")
+		}
+		// merged mappings are decoded on their own, they cannot clash with
+		// the keys of the including mapping
+		sub := &yamlDecoder{
+			taggedBooleans: d.taggedBooleans,
+			dups:           &duplicates{},
+			path:           d.path,
+			budget:         d.budget,
+			spent:          d.spent,
+			aliasDepth:     aliasDepth,
+		}
+		m, err := sub.mapping(src)
+		if err != nil {
+			return err
+		}
+		for k, v := range m {
+			if _, exists := out[k]; !exists {
+				out[k] = v
+			}
+		}
+	}
+	return nil
+}
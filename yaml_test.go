@@ -0,0 +1,153 @@
+package viper
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestYAMLCodec_MatchesLibrary(t *testing.T) {
+	content := []byte(`
+base: &base
+  host: localhost
+  port: 8080
+service:
+  <<: *base
+  port: 9090
+list: [1, 2.5, "three", null, true]
+date: 2024-03-01
+country: NO
+switch: on
+nested:
+  deep:
+    value: ~
+`)
+	var want map[string]interface{}
+	if err := yaml.Unmarshal(content, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]interface{}{}
+	if err := (&yamlCodec{p: New()}).Decode(content, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %#v, want %#v", got, want)
+	}
+}
+
+func TestWithYAML11TaggedBooleans(t *testing.T) {
+	content := []byte(`
+country: NO
+answer: yes
+switch: on
+enabled: true
+shout: FALSE
+forced: !!bool yes
+`)
+	want := map[string]interface{}{
+		"country": "NO",
+		"answer":  "yes",
+		"switch":  "on",
+		"enabled": true,
+		"shout":   false,
+		"forced":  true,
+	}
+
+	got := map[string]interface{}{}
+	if err := (&yamlCodec{p: New(WithYAML11TaggedBooleans())}).Decode(content, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %#v, want %#v", got, want)
+	}
+
+	t.Run("default rejects tagged YAML 1.1 booleans", func(t *testing.T) {
+		if err := (&yamlCodec{p: New()}).Decode(content, map[string]interface{}{}); err == nil {
+			t.Error("Decode() expected an error for !!bool yes")
+		}
+	})
+}
+
+func TestYAMLCodec_YAML11BooleansStayStrings(t *testing.T) {
+	content := []byte("answer: yes\nswitch: on\ncountry: NO\nenabled: True\n")
+	want := map[string]interface{}{"answer": "yes", "switch": "on", "country": "NO", "enabled": true}
+	for _, opts := range [][]Option{nil, {WithYAML11TaggedBooleans()}} {
+		got := map[string]interface{}{}
+		if err := (&yamlCodec{p: New(opts...)}).Decode(content, got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode() with %d options = %#v, want %#v", len(opts), got, want)
+		}
+	}
+}
+
+func TestYAMLCodec_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "root is a sequence", content: "- a\n- b\n"},
+		{name: "duplicate key", content: "a: 1\na: 2\n"},
+		{name: "invalid syntax", content: "a: [1, 2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&yamlCodec{p: New()}).Decode([]byte(tt.content), map[string]interface{}{}); err == nil {
+				t.Error("Decode() expected an error")
+			}
+		})
+	}
+}
+
+func TestWithYAMLAliasBudget(t *testing.T) {
+	bomb := []byte(`
+a: &a ["lol", "lol", "lol", "lol", "lol", "lol", "lol", "lol", "lol", "lol"]
+b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a, *a]
+c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b, *b]
+d: &d [*c, *c, *c, *c, *c, *c, *c, *c, *c, *c]
+e: &e [*d, *d, *d, *d, *d, *d, *d, *d, *d, *d]
+f: &f [*e, *e, *e, *e, *e, *e, *e, *e, *e, *e]
+g: &g [*f, *f, *f, *f, *f, *f, *f, *f, *f, *f]
+h: &h [*g, *g, *g, *g, *g, *g, *g, *g, *g, *g]
+i: &i [*h, *h, *h, *h, *h, *h, *h, *h, *h, *h]
+`)
+	small := []byte(`
+base: &base {host: localhost, port: 80}
+one: *base
+two:
+  <<: *base
+  port: 8080
+`)
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		content []byte
+		wantErr bool
+	}{
+		{name: "default budget rejects bombs", content: bomb, wantErr: true},
+		{name: "default budget accepts regular aliases", content: small},
+		{name: "tight budget", opts: []Option{WithYAMLAliasBudget(3)}, content: small, wantErr: true},
+		{name: "disabled", opts: []Option{WithYAMLAliasBudget(0)}, content: small},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&yamlCodec{p: New(tt.opts...)}).Decode(tt.content, map[string]interface{}{})
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Decode() error = %v", err)
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Limit != "alias nodes" {
+				t.Errorf("Decode() error = %v, want an alias nodes *LimitError", err)
+			}
+		})
+	}
+}
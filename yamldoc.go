@@ -0,0 +1,278 @@
+package viper
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlTemplate returns the node of the YAML file at path as last loaded,
+// narrowed to the section at key, to lay out the settings written back to
+// it. Callers hold the read lock.
+func (p *Parser) yamlTemplate(path, key string) (*yaml.Node, *yamlCodec) {
+	codec, ok := p.codecs[strings.ToLower(p.typeOf(path))].(*yamlCodec)
+	if !ok {
+		return nil, nil
+	}
+	var doc *yaml.Node
+	for source, d := range p.info.documents {
+		if filepath.Clean(source) == filepath.Clean(path) {
+			doc = d
+			break
+		}
+	}
+	if doc == nil || key == "" {
+		return doc, codec
+	}
+
+	n := doc.Content[0]
+	for _, part := range strings.Split(strings.ToLower(p.normalizePath(key)), ".") {
+		if n.Kind == yaml.AliasNode {
+			n = n.Alias
+		}
+		if n.Kind != yaml.MappingNode {
+			return nil, nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if p.yamlKey(n.Content[i]) == part {
+				next = n.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil, nil
+		}
+		n = next
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{n}}, codec
+}
+
+// yamlKey returns the settings key a mapping key of a YAML file is read as
+func (p *Parser) yamlKey(n *yaml.Node) string {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if p.keyCase == KeepCase {
+		return strings.ToLower(n.Value)
+	}
+	return strings.ToLower(normalizeKey(n.Value, p.keyCase))
+}
+
+// encodeYAML encodes settings in the layout of the template document: the
+// comments, key order, quoting and anchors of the values left unchanged are
+// kept, as is the indentation. Keys gone from settings are dropped and new
+// ones are appended in lexical order. Callers hold the read lock.
+func (p *Parser) encodeYAML(template *yaml.Node, codec *yamlCodec, settings map[string]interface{}) ([]byte, error) {
+	m := &yamlMerger{p: p, codec: codec, changed: map[*yaml.Node]bool{}}
+	root, err := m.merge(template.Content[0], settings)
+	if err != nil {
+		return nil, err
+	}
+	doc := *template
+	doc.Content = []*yaml.Node{untagMerges(root)}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(yamlIndent(template.Content[0]))
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlMerger merges settings into the nodes of a YAML document
+type yamlMerger struct {
+	p     *Parser
+	codec *yamlCodec
+	// changed holds the anchored nodes whose value changed, whose aliases
+	// must be written out as the value they had
+	changed map[*yaml.Node]bool
+}
+
+// merge returns the node of v, reusing n, the node of the template at the
+// same place, for as much of v as it already holds
+func (m *yamlMerger) merge(n *yaml.Node, v interface{}) (*yaml.Node, error) {
+	old, err := m.codec.decoder().node(n)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.DeepEqual(m.canonical(old), m.canonical(v)) {
+		if n.Kind == yaml.AliasNode && m.changed[n.Alias] {
+			return m.replace(n, old)
+		}
+		if !m.aliasesChanged(n) {
+			return n, nil
+		}
+	} else if n.Anchor != "" {
+		m.changed[n] = true
+	}
+
+	if settings, ok := toStringMap(v); ok && n.Kind == yaml.MappingNode {
+		return m.mapping(n, settings)
+	}
+	if list, ok := v.([]interface{}); ok && n.Kind == yaml.SequenceNode {
+		out := *n
+		out.Content = make([]*yaml.Node, 0, len(list))
+		for i, item := range list {
+			if i >= len(n.Content) {
+				fresh, err := m.fresh(item)
+				if err != nil {
+					return nil, err
+				}
+				out.Content = append(out.Content, fresh)
+				continue
+			}
+			merged, err := m.merge(n.Content[i], item)
+			if err != nil {
+				return nil, err
+			}
+			out.Content = append(out.Content, merged)
+		}
+		return &out, nil
+	}
+	return m.replace(n, v)
+}
+
+// mapping merges settings into the mapping node n, keeping the keys it
+// already has in their order
+func (m *yamlMerger) mapping(n *yaml.Node, settings map[string]interface{}) (*yaml.Node, error) {
+	keys := make(map[string]string, len(settings))
+	for k := range settings {
+		keys[m.p.yamlKey(&yaml.Node{Value: k})] = k
+	}
+
+	out := *n
+	out.Content = make([]*yaml.Node, 0, len(n.Content))
+	used := make(map[string]bool, len(settings))
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		// merge keys are dropped, the keys they brought being written out
+		if key.ShortTag() == "!!merge" {
+			continue
+		}
+		k, ok := keys[m.p.yamlKey(key)]
+		if !ok || used[k] {
+			continue
+		}
+		used[k] = true
+		merged, err := m.merge(value, settings[k])
+		if err != nil {
+			return nil, err
+		}
+		out.Content = append(out.Content, key, merged)
+	}
+
+	added := make([]string, 0, len(settings)-len(used))
+	for k := range settings {
+		if !used[k] {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(added)
+	for _, k := range added {
+		fresh, err := m.fresh(settings[k])
+		if err != nil {
+			return nil, err
+		}
+		out.Content = append(out.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, fresh)
+	}
+	return &out, nil
+}
+
+// replace returns a new node for v carrying the comments of n
+func (m *yamlMerger) replace(n *yaml.Node, v interface{}) (*yaml.Node, error) {
+	fresh, err := m.fresh(v)
+	if err != nil {
+		return nil, err
+	}
+	fresh.HeadComment, fresh.LineComment, fresh.FootComment = n.HeadComment, n.LineComment, n.FootComment
+	return fresh, nil
+}
+
+func (m *yamlMerger) fresh(v interface{}) (*yaml.Node, error) {
+	var n yaml.Node
+	if err := n.Encode(normalizeJSON(v)); err != nil {
+		return nil, fmt.Errorf("error encoding yaml: %w", err)
+	}
+	return &n, nil
+}
+
+// aliasesChanged reports whether n refers to an anchored node whose value
+// changed
+func (m *yamlMerger) aliasesChanged(n *yaml.Node) bool {
+	if n.Kind == yaml.AliasNode {
+		return m.changed[n.Alias]
+	}
+	for _, item := range n.Content {
+		if m.aliasesChanged(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonical rewrites v with the keys of its maps as settings keys, so the
+// values decoded from the template compare with the effective ones
+func (m *yamlMerger) canonical(v interface{}) interface{} {
+	if settings, ok := toStringMap(v); ok {
+		out := make(map[string]interface{}, len(settings))
+		for k, item := range settings {
+			out[m.p.yamlKey(&yaml.Node{Value: k})] = m.canonical(item)
+		}
+		return out
+	}
+	if list, ok := v.([]interface{}); ok {
+		out := make([]interface{}, len(list))
+		for i, item := range list {
+			out[i] = m.canonical(item)
+		}
+		return out
+	}
+	return v
+}
+
+// untagMerges returns a copy of n whose merge keys carry no explicit tag,
+// yaml.v3 writing them as "!!merge <<" otherwise
+func untagMerges(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.AliasNode || len(n.Content) == 0 {
+		return n
+	}
+	out := *n
+	out.Content = make([]*yaml.Node, len(n.Content))
+	for i, item := range n.Content {
+		if n.Kind == yaml.MappingNode && i%2 == 0 && item.ShortTag() == "!!merge" {
+			key := *item
+			key.Tag = ""
+			out.Content[i] = &key
+			continue
+		}
+		out.Content[i] = untagMerges(item)
+	}
+	return &out
+}
+
+// yamlIndent returns the indentation of the first nested mapping of n, 4
+// spaces as yaml.Marshal writes when n has none
+func yamlIndent(n *yaml.Node) int {
+	if n.Kind != yaml.MappingNode {
+		return 4
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if value.Kind == yaml.MappingNode && value.Style&yaml.FlowStyle == 0 && len(value.Content) > 0 {
+			if indent := value.Content[0].Column - key.Column; indent > 0 {
+				return indent
+			}
+		}
+	}
+	return 4
+}